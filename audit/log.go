@@ -0,0 +1,226 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// OutcomeSucceeded calls were answered with a 2xx response.
+	OutcomeSucceeded = "succeeded"
+	// OutcomeRejected calls were answered with a 4xx response before a command was sent, e.g. invalid parameters.
+	OutcomeRejected = "rejected"
+	// OutcomeFailed calls were answered with any other response, e.g. the Ecoflow cloud refused the command.
+	OutcomeFailed = "failed"
+)
+
+// Outcomes are the outcomes an entry can be filtered by.
+var Outcomes = []string{OutcomeSucceeded, OutcomeRejected, OutcomeFailed}
+
+// Entry is a mutating API call.
+type Entry struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	// Actor is the hashed identity of the caller, see Actor
+	Actor  string `json:"actor"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Route is the route pattern of the call, e.g. /api/power_station/{serial_number}/out/ac
+	Route        string `json:"route"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	// Request is the request body, bodies that are not JSON are kept as a string
+	Request   json.RawMessage `json:"request,omitempty" swaggertype:"object"`
+	Status    int             `json:"status"`
+	Outcome   string          `json:"outcome"`
+	ErrorCode string          `json:"error_code,omitempty"`
+	LatencyMs float64         `json:"latency_ms"`
+	// Upstream are the commands sent to the Ecoflow cloud for the call
+	Upstream []Call `json:"upstream,omitempty"`
+}
+
+// Call is a command sent to the Ecoflow cloud.
+type Call struct {
+	// Response is the CmdSetResponse of the Ecoflow cloud
+	Response  json.RawMessage `json:"response,omitempty" swaggertype:"object"`
+	Error     string          `json:"error,omitempty"`
+	LatencyMs float64         `json:"latency_ms"`
+}
+
+// Filter selects entries, empty fields match all entries.
+type Filter struct {
+	Actor        string
+	SerialNumber string
+	Outcome      string
+	Method       string
+	Route        string
+	From         time.Time
+	To           time.Time
+}
+
+// Match reports whether the entry is selected by the filter. From is inclusive and To is exclusive.
+func (f Filter) Match(entry Entry) bool {
+	switch {
+	case f.Actor != "" && entry.Actor != f.Actor,
+		f.SerialNumber != "" && entry.SerialNumber != f.SerialNumber,
+		f.Outcome != "" && entry.Outcome != f.Outcome,
+		f.Method != "" && entry.Method != f.Method,
+		f.Route != "" && entry.Route != f.Route,
+		!f.From.IsZero() && entry.Time.Before(f.From),
+		!f.To.IsZero() && !entry.Time.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// Actor returns the hashed identity of a caller, e.g. of service.HeaderIdentity. The identities contain account names
+// and key fingerprints, the log only keeps a hash that can be compared with the hash of a known identity.
+func Actor(identity string) string {
+	sum := sha256.Sum256([]byte(identity))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Milliseconds returns the duration in milliseconds, rounded to microseconds.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Log is an append-only log of entries in a JSON Lines file. Entries are never changed or removed by the server.
+type Log struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// Open opens the log in the file, it is created if it doesn't exist.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("can't create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("can't open audit log: %w", err)
+	}
+	// a line cut off by a crash is terminated, otherwise the next entry would be appended to it
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err = file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			_, err = file.Write([]byte{'\n'})
+		}
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("can't open audit log: %w", err)
+		}
+	}
+	return &Log{path: path, file: file}, nil
+}
+
+// Append writes the entry to the log, its ID and time are set if they are empty.
+func (l *Log) Append(entry Entry) (Entry, error) {
+	if entry.ID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return Entry{}, err
+		}
+		entry.ID = hex.EncodeToString(id)
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return Entry{}, errors.New("audit log is closed")
+	}
+	// the entry is written with a single call, so a crash can only cut off the last line
+	if _, err = l.file.Write(append(line, '\n')); err != nil {
+		return Entry{}, fmt.Errorf("can't write audit log: %w", err)
+	}
+	return entry, nil
+}
+
+// Query returns the last limit entries selected by the filter, newest first.
+func (l *Log) Query(filter Filter, limit int) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	err := l.scan(filter, func(entry Entry, _ []byte) error {
+		entries = append(entries, entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Export writes the entries selected by the filter to w as JSON Lines, oldest first.
+func (l *Log) Export(w io.Writer, filter Filter) error {
+	return l.scan(filter, func(_ Entry, line []byte) error {
+		_, err := w.Write(append(line, '\n'))
+		return err
+	})
+}
+
+// scan reads the log and calls fn with the entries selected by the filter and their lines. Lines that can't be
+// decoded, e.g. a line cut off by a crash, are skipped.
+func (l *Log) scan(filter Filter, fn func(entry Entry, line []byte) error) error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("can't read audit log: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			line = bytes.TrimSpace(line)
+			var entry Entry
+			if json.Unmarshal(line, &entry) == nil && filter.Match(entry) {
+				if err := fn(entry, line); err != nil {
+					return err
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("can't read audit log: %w", err)
+		}
+	}
+}
+
+// Close closes the log, entries can't be appended anymore.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func appendEntries(t *testing.T, log *Log) {
+	t.Helper()
+	for i, entry := range []Entry{
+		{Actor: Actor("vault:home"), Method: "PUT", SerialNumber: "R601ZEB4ZEAL0528", Status: 200, Outcome: OutcomeSucceeded},
+		{Actor: Actor("vault:home"), Method: "PUT", SerialNumber: "R331ZEB4ZEAL0528", Status: 400, Outcome: OutcomeRejected},
+		{Actor: Actor("vault:office"), Method: "PUT", SerialNumber: "R601ZEB4ZEAL0528", Status: 409, Outcome: OutcomeFailed},
+		{Actor: Actor("vault:home"), Method: "POST", Status: 200, Outcome: OutcomeSucceeded},
+	} {
+		entry.Time = start.Add(time.Duration(i) * time.Minute)
+		_, err := log.Append(entry)
+		require.NoError(t, err)
+	}
+}
+
+func serialNumbers(entries []Entry) []string {
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry.Time.Format("15:04")+" "+entry.SerialNumber)
+	}
+	return result
+}
+
+func TestLog_Query(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := Open(path)
+	require.NoError(t, err)
+	appendEntries(t, log)
+	require.NoError(t, log.Close())
+
+	// entries are kept across restarts
+	log, err = Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		limit    int
+		expected []string
+	}{
+		{name: "all entries newest first", limit: 10, expected: []string{"12:03 ", "12:02 R601ZEB4ZEAL0528", "12:01 R331ZEB4ZEAL0528", "12:00 R601ZEB4ZEAL0528"}},
+		{name: "limit keeps the newest entries", limit: 2, expected: []string{"12:03 ", "12:02 R601ZEB4ZEAL0528"}},
+		{name: "actor", filter: Filter{Actor: Actor("vault:office")}, limit: 10, expected: []string{"12:02 R601ZEB4ZEAL0528"}},
+		{name: "serial number", filter: Filter{SerialNumber: "R601ZEB4ZEAL0528"}, limit: 10, expected: []string{"12:02 R601ZEB4ZEAL0528", "12:00 R601ZEB4ZEAL0528"}},
+		{name: "outcome and method", filter: Filter{Outcome: OutcomeSucceeded, Method: "PUT"}, limit: 10, expected: []string{"12:00 R601ZEB4ZEAL0528"}},
+		{name: "range", filter: Filter{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)}, limit: 10, expected: []string{"12:02 R601ZEB4ZEAL0528", "12:01 R331ZEB4ZEAL0528"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := log.Query(tt.filter, tt.limit)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, serialNumbers(entries))
+		})
+	}
+}
+
+func TestLog_Export(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+	appendEntries(t, log)
+
+	var exported bytes.Buffer
+	require.NoError(t, log.Export(&exported, Filter{SerialNumber: "R601ZEB4ZEAL0528"}))
+
+	lines := strings.Split(strings.TrimSpace(exported.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"time":"2025-03-01T12:00:00Z"`, "oldest first")
+	assert.Contains(t, lines[1], `"time":"2025-03-01T12:02:00Z"`)
+}
+
+func TestLog_SkipsIncompleteEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":"1","time":"2025-03-01T12:00:00Z","outcome":"succeeded"}`+"\n"+`{"id":"2","ti`), 0o600))
+
+	log, err := Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+	entries, err := log.Query(Filter{}, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the entry cut off by a crash is skipped")
+	assert.Equal(t, "1", entries[0].ID)
+
+	entry, err := log.Append(Entry{Outcome: OutcomeFailed})
+	require.NoError(t, err)
+	assert.Len(t, entry.ID, 16)
+	assert.False(t, entry.Time.IsZero())
+	entries, err = log.Query(Filter{}, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "entries are not appended to the cut off entry")
+	assert.Equal(t, entry.ID, entries[0].ID)
+}
+
+func TestRecordUpstream(t *testing.T) {
+	RecordUpstream(context.Background(), map[string]string{"code": "0"}, nil, time.Millisecond) // not audited
+
+	ctx, recorder := WithRecorder(context.Background())
+	RecordUpstream(ctx, map[string]string{"code": "0"}, nil, 1500*time.Microsecond)
+	RecordUpstream(ctx, (*struct{})(nil), errors.New("device offline"), 2*time.Second)
+
+	calls := recorder.Calls()
+	require.Len(t, calls, 2)
+	assert.JSONEq(t, `{"code":"0"}`, string(calls[0].Response))
+	assert.Equal(t, 1.5, calls[0].LatencyMs)
+	assert.Empty(t, calls[1].Response, "failed requests have no response")
+	assert.Equal(t, "device offline", calls[1].Error)
+	assert.Equal(t, 2000.0, calls[1].LatencyMs)
+}
+
+func TestActor(t *testing.T) {
+	assert.Len(t, Actor("vault:home"), 32)
+	assert.Equal(t, Actor("vault:home"), Actor("vault:home"))
+	assert.NotEqual(t, Actor("vault:home"), Actor("vault:office"))
+}
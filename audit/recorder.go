@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+type recorderKey struct{}
+
+// Recorder collects the commands sent to the Ecoflow cloud while a call is handled.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// WithRecorder returns a context that records the commands sent with it, see RecordUpstream.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	recorder := &Recorder{}
+	return context.WithValue(ctx, recorderKey{}, recorder), recorder
+}
+
+// RecordUpstream records a command sent to the Ecoflow cloud with the response or the error. It does nothing if the
+// context has no recorder, i.e. the call is not audited.
+func RecordUpstream(ctx context.Context, response interface{}, err error, latency time.Duration) {
+	recorder, ok := ctx.Value(recorderKey{}).(*Recorder)
+	if !ok {
+		return
+	}
+	call := Call{LatencyMs: Milliseconds(latency)}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	// the response is nil if the request failed
+	if data, marshalErr := json.Marshal(response); marshalErr == nil && string(data) != "null" {
+		call.Response = data
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.calls = append(recorder.calls, call)
+}
+
+// Calls returns the recorded commands.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
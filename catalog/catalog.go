@@ -0,0 +1,59 @@
+package catalog
+
+import (
+	"sort"
+	"strings"
+)
+
+// Family groups the products that share the same parameters and commands.
+type Family string
+
+const (
+	FamilyUnknown        Family = "unknown"
+	FamilyPowerStation   Family = "power_station"
+	FamilyPowerStream    Family = "powerstream"
+	FamilySmartPlug      Family = "smart_plug"
+	FamilySmartHomePanel Family = "smart_home_panel"
+)
+
+// Model describes an Ecoflow product.
+type Model struct {
+	// Prefix is the serial number prefix of the product.
+	Prefix string `json:"prefix"`
+	Name   string `json:"name"`
+	Family Family `json:"family"`
+	// Commands are the commands the model supports.
+	Commands []Command `json:"commands"`
+}
+
+// Unknown is returned for serial numbers of products that are not in the catalog.
+var Unknown = Model{Name: "Unknown", Family: FamilyUnknown, Commands: []Command{}}
+
+var models = []Model{
+	{Prefix: "R331", Name: "DELTA 2", Family: FamilyPowerStation, Commands: powerStationCommands(acOutputValues(acOutputUS, acOutputEU, acOutputJP), Range{200, 1200}, Range{4, 8}, generatorCommand)},
+	{Prefix: "R351", Name: "DELTA 2 Max", Family: FamilyPowerStation, Commands: powerStationCommands(acOutputValues(acOutputUS, acOutputEU, acOutputJP), Range{200, 2400}, Range{4, 8}, generatorCommand)},
+	{Prefix: "R601", Name: "RIVER 2", Family: FamilyPowerStation, Commands: powerStationCommands(acOutputValues(acOutputUS, acOutputEU, acOutputJP), Range{100, 360}, Range{4, 8})},
+	{Prefix: "R611", Name: "RIVER 2 Max", Family: FamilyPowerStation, Commands: powerStationCommands(acOutputValues(acOutputUS, acOutputEU, acOutputJP), Range{100, 660}, Range{4, 8})},
+	{Prefix: "R621", Name: "RIVER 2 Pro", Family: FamilyPowerStation, Commands: powerStationCommands(acOutputValues(acOutputUS, acOutputEU, acOutputJP), Range{100, 940}, Range{4, 8})},
+	{Prefix: "HW51", Name: "PowerStream", Family: FamilyPowerStream, Commands: powerStreamCommands},
+	{Prefix: "HW52", Name: "Smart Plug", Family: FamilySmartPlug, Commands: smartPlugCommands},
+	{Prefix: "SP10", Name: "Smart Home Panel", Family: FamilySmartHomePanel, Commands: smartHomePanelCommands},
+}
+
+func init() {
+	// the longest prefix wins when prefixes overlap
+	sort.Slice(models, func(i, j int) bool {
+		return len(models[i].Prefix) > len(models[j].Prefix)
+	})
+}
+
+// Identify returns the model of the device with the serial number. The Ecoflow API doesn't report the product of a
+// device, but the first characters of the serial number identify it.
+func Identify(sn string) Model {
+	for _, m := range models {
+		if strings.HasPrefix(sn, m.Prefix) {
+			return m
+		}
+	}
+	return Unknown
+}
@@ -0,0 +1,47 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentify(t *testing.T) {
+	assert.Equal(t, "DELTA 2", Identify("R331ZEB4ZEAL0528").Name)
+	assert.Equal(t, FamilyPowerStation, Identify("R601ZCB5HXXXXX").Family)
+	assert.Equal(t, FamilySmartPlug, Identify("HW52ZDH4SF123456").Family)
+	assert.Equal(t, Unknown, Identify("XYZ123"))
+	assert.Equal(t, Unknown, Identify(""))
+}
+
+func TestLookupCommand(t *testing.T) {
+	command, ok := LookupCommand("R331ZEB4ZEAL0528", FamilyPowerStation, "input/speed")
+	assert.True(t, ok)
+	assert.Equal(t, Range{200, 1200}, command.Ranges["watts"])
+
+	command, ok = LookupCommand("R351ZFB4HF000000", FamilyPowerStation, "input/speed")
+	assert.True(t, ok)
+	assert.True(t, command.Ranges["watts"].Contains(2400))
+
+	_, ok = LookupCommand("R331ZEB4ZEAL0528", FamilySmartPlug, "relay")
+	assert.False(t, ok, "a power station doesn't support smart plug commands")
+
+	_, ok = LookupCommand("R601ZCB5HX000000", FamilyPowerStation, "generator")
+	assert.False(t, ok, "a RIVER 2 can't control a Smart Generator")
+
+	_, ok = LookupCommand("HW52ZDH4SF123456", FamilySmartPlug, "custom_load")
+	assert.False(t, ok, "a smart plug doesn't support unknown commands")
+
+	command, ok = LookupCommand("XYZ123", FamilyPowerStation, "input/speed")
+	assert.True(t, ok, "unknown models get the generic commands of the family")
+	assert.Equal(t, Range{1, 3600}, command.Ranges["watts"])
+}
+
+func TestCommand_Accepts(t *testing.T) {
+	command, _ := LookupCommand("R331ZEB4ZEAL0528", FamilyPowerStation, "out/ac")
+	assert.True(t, command.Accepts("out_voltage", 230))
+	assert.False(t, command.Accepts("out_voltage", 200))
+	assert.False(t, command.Accepts("out_voltage", 110), "no variant of the model has a 110 V output")
+	assert.Equal(t, map[string][]int{"out_freq": {50, 60}, "out_voltage": {100, 120, 220, 230, 240}}, command.Values)
+	assert.True(t, command.Accepts("xboost", 1), "fields without values accept all values")
+}
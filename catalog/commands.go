@@ -0,0 +1,158 @@
+package catalog
+
+import "slices"
+
+// Range is the inclusive range of valid values of a request field.
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Contains returns whether the value is within the range.
+func (r Range) Contains(value float64) bool {
+	return value >= r.Min && value <= r.Max
+}
+
+// Command is a command a device supports. Name is the route of the command below
+// /api/{family}/{serial_number}/, without path parameters, e.g. "out/ac" or "tasks".
+type Command struct {
+	Name string `json:"name"`
+	// Ranges are the valid ranges of the numeric fields of the request.
+	Ranges map[string]Range `json:"ranges,omitempty"`
+	// Values are the valid values of the numeric fields of the request that only accept some values, e.g. voltages.
+	Values map[string][]int `json:"values,omitempty"`
+}
+
+// Range returns the valid range of the field and whether the field has one.
+func (c Command) Range(field string) (Range, bool) {
+	r, ok := c.Ranges[field]
+	return r, ok
+}
+
+// Accepts returns whether the field accepts the value. Fields without a list of values accept all values.
+func (c Command) Accepts(field string, value int) bool {
+	values, ok := c.Values[field]
+	if !ok {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Command returns the command of the model with the name.
+func (m Model) Command(name string) (Command, bool) {
+	for _, command := range m.Commands {
+		if command.Name == name {
+			return command, true
+		}
+	}
+	return Command{}, false
+}
+
+// LookupCommand returns the command of the device with the serial number, or false if the device doesn't support it.
+// Devices of unknown models get the generic command of the family, so new models are not locked out.
+func LookupCommand(sn string, family Family, name string) (Command, bool) {
+	model := Identify(sn)
+	if model.Family == FamilyUnknown {
+		return Model{Commands: genericCommands[family]}.Command(name)
+	}
+	if model.Family != family {
+		return Command{}, false
+	}
+	return model.Command(name)
+}
+
+// acOutput is the AC output of a regional variant of a power station. The serial number doesn't tell the variants of a
+// model apart, so a model accepts the outputs of all its variants.
+type acOutput struct {
+	voltages    []int
+	frequencies []int
+}
+
+var (
+	acOutputUS = acOutput{voltages: []int{120}, frequencies: []int{60}}
+	acOutputEU = acOutput{voltages: []int{220, 230, 240}, frequencies: []int{50}}
+	acOutputJP = acOutput{voltages: []int{100}, frequencies: []int{50, 60}}
+)
+
+// acOutputValues returns the valid values of the out/ac command for the variants of a model.
+func acOutputValues(variants ...acOutput) map[string][]int {
+	var voltages, frequencies []int
+	for _, variant := range variants {
+		voltages = append(voltages, variant.voltages...)
+		frequencies = append(frequencies, variant.frequencies...)
+	}
+	slices.Sort(voltages)
+	slices.Sort(frequencies)
+	return map[string][]int{
+		"out_freq":    slices.Compact(frequencies),
+		"out_voltage": slices.Compact(voltages),
+	}
+}
+
+// powerStationCommands returns the commands of power stations with the AC output values, AC charging power and car
+// charging current ranges of the model, followed by the commands only some models support.
+func powerStationCommands(acOutputs map[string][]int, chargingWatts, carInputAmps Range, extra ...Command) []Command {
+	commands := []Command{
+		{Name: "out/ac", Values: acOutputs},
+		{Name: "out/dc"},
+		{Name: "out/car"},
+		{Name: "input/speed", Ranges: map[string]Range{"watts": chargingWatts}},
+		{Name: "input/car", Ranges: map[string]Range{"amps": carInputAmps}},
+		{Name: "input/ac/pause"},
+		{Name: "input/ac/mode"},
+		{Name: "input/pv_type"},
+		{Name: "standby"},
+		{Name: "battery/charge_limit", Ranges: map[string]Range{"percent": {50, 100}}},
+		{Name: "battery/discharge_limit", Ranges: map[string]Range{"percent": {0, 30}}},
+		{Name: "beep"},
+		{Name: "screen/brightness", Ranges: map[string]Range{"level": {0, 3}}},
+	}
+	return append(commands, extra...)
+}
+
+// generatorCommand starts and stops a Smart Generator, only the DELTA models can control one.
+var generatorCommand = Command{Name: "generator", Ranges: map[string]Range{
+	"start_percent": {0, 30},
+	"stop_percent":  {50, 100},
+}}
+
+var smartPlugCommands = []Command{
+	{Name: "relay"},
+	{Name: "brightness", Ranges: map[string]Range{"brightness": {0, 1023}}},
+	{Name: "max_power", Ranges: map[string]Range{"watts": {1, 2500}}},
+	{Name: "tasks"},
+}
+
+var powerStreamCommands = []Command{
+	{Name: "custom_load", Ranges: map[string]Range{"watts": {0, 600}}},
+	{Name: "supply_priority"},
+	{Name: "battery/lower_limit", Ranges: map[string]Range{"percent": {1, 30}}},
+	{Name: "battery/upper_limit", Ranges: map[string]Range{"percent": {70, 100}}},
+	{Name: "brightness", Ranges: map[string]Range{"brightness": {0, 1023}}},
+}
+
+var smartHomePanelCommands = []Command{
+	{Name: "circuits/source"},
+	{Name: "circuits/priority"},
+	{Name: "backup_reserve", Ranges: map[string]Range{
+		"reserve_percent":      {0, 30},
+		"charge_limit_percent": {50, 100},
+	}},
+	{Name: "charging_windows", Ranges: map[string]Range{
+		"charge_watts":   {1, 7200},
+		"target_percent": {50, 100},
+	}},
+}
+
+// genericCommands are the commands of devices of unknown models, with the widest ranges of the family.
+var genericCommands = map[Family][]Command{
+	FamilyPowerStation:   powerStationCommands(acOutputValues(acOutputUS, acOutputEU, acOutputJP), Range{1, 3600}, Range{4, 10}, generatorCommand),
+	FamilySmartPlug:      smartPlugCommands,
+	FamilyPowerStream:    powerStreamCommands,
+	FamilySmartHomePanel: smartHomePanelCommands,
+}
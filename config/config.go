@@ -0,0 +1,639 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"go-ecoflow-api-server/constants"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of all environment variables read by the server, e.g. ECOFLOW_ADDR.
+const EnvPrefix = "ECOFLOW_"
+
+// Config holds the complete server configuration.
+//
+// Values are merged in the following order, each step overriding the previous one:
+//  1. built-in defaults (see Default)
+//  2. configuration file (YAML or TOML, selected by -config or ECOFLOW_CONFIG)
+//  3. ECOFLOW_* environment variables
+//  4. command line flags
+type Config struct {
+	Server        ServerConfig        `yaml:"server" toml:"server"`
+	Log           LogConfig           `yaml:"log" toml:"log"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit" toml:"rate_limit"`
+	Vault         VaultConfig         `yaml:"vault" toml:"vault"`
+	ClientCache   ClientCacheConfig   `yaml:"client_cache" toml:"client_cache"`
+	Upstream      UpstreamConfig      `yaml:"upstream" toml:"upstream"`
+	Stream        StreamConfig        `yaml:"stream" toml:"stream"`
+	WebSocket     WebSocketConfig     `yaml:"websocket" toml:"websocket"`
+	Bulk          BulkConfig          `yaml:"bulk" toml:"bulk"`
+	MQTT          MQTTConfig          `yaml:"mqtt" toml:"mqtt"`
+	Metrics       MetricsConfig       `yaml:"metrics" toml:"metrics"`
+	History       HistoryConfig       `yaml:"history" toml:"history"`
+	Schedules     SchedulesConfig     `yaml:"schedules" toml:"schedules"`
+	Rules         RulesConfig         `yaml:"rules" toml:"rules"`
+	Webhooks      WebhooksConfig      `yaml:"webhooks" toml:"webhooks"`
+	HomeAssistant HomeAssistantConfig `yaml:"homeassistant" toml:"homeassistant"`
+	Audit         AuditConfig         `yaml:"audit" toml:"audit"`
+}
+
+// ServerConfig contains the HTTP server settings.
+type ServerConfig struct {
+	Address           string        `yaml:"address" toml:"address"`
+	RequestTimeout    time.Duration `yaml:"request_timeout" toml:"request_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" toml:"max_header_bytes"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// LogConfig contains the logger settings.
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"`
+}
+
+// RateLimitConfig contains the settings of the per-client rate limiter.
+type RateLimitConfig struct {
+	Limit  int           `yaml:"limit" toml:"limit"`
+	Window time.Duration `yaml:"window" toml:"window"`
+}
+
+// VaultConfig contains the settings of the server-side credential vault. The vault is enabled when File is set.
+type VaultConfig struct {
+	File         string `yaml:"file" toml:"file"`
+	KeyFile      string `yaml:"key_file" toml:"key_file"`
+	AllowHeaders bool   `yaml:"allow_headers" toml:"allow_headers"`
+}
+
+// ClientCacheConfig contains the settings of the Ecoflow client cache. Caching is disabled when Size is 0.
+type ClientCacheConfig struct {
+	Size int           `yaml:"size" toml:"size"`
+	TTL  time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+// UpstreamConfig contains the settings of the calls to the Ecoflow cloud.
+type UpstreamConfig struct {
+	Timeout             time.Duration `yaml:"timeout" toml:"timeout"`
+	RetryAttempts       int           `yaml:"retry_attempts" toml:"retry_attempts"`
+	RetryBaseDelay      time.Duration `yaml:"retry_base_delay" toml:"retry_base_delay"`
+	RetryMaxDelay       time.Duration `yaml:"retry_max_delay" toml:"retry_max_delay"`
+	BreakerThreshold    int           `yaml:"breaker_threshold" toml:"breaker_threshold"`
+	BreakerOpenDuration time.Duration `yaml:"breaker_open_duration" toml:"breaker_open_duration"`
+}
+
+// StreamConfig contains the settings of the device parameter streams.
+type StreamConfig struct {
+	PollInterval      time.Duration `yaml:"poll_interval" toml:"poll_interval"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" toml:"heartbeat_interval"`
+	HistorySize       int           `yaml:"history_size" toml:"history_size"`
+	Linger            time.Duration `yaml:"linger" toml:"linger"`
+}
+
+// WebSocketConfig contains the settings of the WebSocket connections.
+type WebSocketConfig struct {
+	SendQueue    int           `yaml:"send_queue" toml:"send_queue"`
+	PingInterval time.Duration `yaml:"ping_interval" toml:"ping_interval"`
+}
+
+// BulkConfig contains the settings of the bulk commands.
+type BulkConfig struct {
+	Concurrency int `yaml:"concurrency" toml:"concurrency"`
+	MaxDevices  int `yaml:"max_devices" toml:"max_devices"`
+}
+
+// MQTTConfig contains the settings of the telemetry ingestion from the Ecoflow MQTT broker. It requires the credential
+// vault: the devices of the vault accounts listed in Accounts, or of all vault accounts if it's empty, are ingested.
+type MQTTConfig struct {
+	Enabled         bool          `yaml:"enabled" toml:"enabled"`
+	Accounts        []string      `yaml:"accounts" toml:"accounts"`
+	APIURL          string        `yaml:"api_url" toml:"api_url"`
+	MaxAge          time.Duration `yaml:"max_age" toml:"max_age"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" toml:"refresh_interval"`
+}
+
+// MetricsConfig contains the settings of the Prometheus metrics endpoint, it is disabled by default. The device gauges
+// are exported for the devices of the vault accounts, they are polled every DevicePollInterval unless they are ingested
+// from the MQTT broker.
+type MetricsConfig struct {
+	Enabled            bool          `yaml:"enabled" toml:"enabled"`
+	Token              string        `yaml:"token" toml:"token"`
+	DevicePollInterval time.Duration `yaml:"device_poll_interval" toml:"device_poll_interval"`
+}
+
+// HistoryConfig contains the settings of the device history. Like the device metrics, the history is recorded for the
+// devices of the vault accounts. Raw samples are kept for RawRetention, afterward only their rollups with the min, max
+// and average of every RollupInterval are kept until Retention.
+type HistoryConfig struct {
+	Enabled        bool          `yaml:"enabled" toml:"enabled"`
+	Dir            string        `yaml:"dir" toml:"dir"`
+	Interval       time.Duration `yaml:"interval" toml:"interval"`
+	Params         []string      `yaml:"params" toml:"params"`
+	RollupInterval time.Duration `yaml:"rollup_interval" toml:"rollup_interval"`
+	RawRetention   time.Duration `yaml:"raw_retention" toml:"raw_retention"`
+	Retention      time.Duration `yaml:"retention" toml:"retention"`
+}
+
+// SchedulesConfig contains the settings of the scheduled commands. Schedules require the credential vault, they are
+// managed with API keys and their commands are sent with the credentials of the vault account of the key. RunHistory is
+// the number of runs kept per schedule.
+type SchedulesConfig struct {
+	Enabled    bool   `yaml:"enabled" toml:"enabled"`
+	File       string `yaml:"file" toml:"file"`
+	RunHistory int    `yaml:"run_history" toml:"run_history"`
+}
+
+// RulesConfig contains the settings of the rules engine. Rules require the credential vault, they are evaluated over
+// the parameters of the devices of the vault accounts every Interval and their commands are sent with the credentials
+// of the account. History is the number of evaluations kept per rule.
+type RulesConfig struct {
+	Enabled  bool          `yaml:"enabled" toml:"enabled"`
+	File     string        `yaml:"file" toml:"file"`
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+	History  int           `yaml:"history" toml:"history"`
+}
+
+// WebhooksConfig contains the settings of the webhook subscriptions. Webhooks require the credential vault, the devices
+// of the vault accounts are checked every Interval. Every delivery attempt is limited by Timeout, failed deliveries
+// are retried after RetryBaseDelay, doubling up to RetryMaxDelay, and are dead letters after MaxAttempts. History is
+// the number of completed deliveries and dead letters kept per subscription.
+type WebhooksConfig struct {
+	Enabled        bool          `yaml:"enabled" toml:"enabled"`
+	File           string        `yaml:"file" toml:"file"`
+	Interval       time.Duration `yaml:"interval" toml:"interval"`
+	Timeout        time.Duration `yaml:"timeout" toml:"timeout"`
+	MaxAttempts    int           `yaml:"max_attempts" toml:"max_attempts"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay" toml:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay" toml:"retry_max_delay"`
+	History        int           `yaml:"history" toml:"history"`
+}
+
+// HomeAssistantConfig contains the settings of the Home Assistant bridge. It requires the credential vault: the power
+// stations of the vault accounts are published to the MQTT broker of Home Assistant every Interval, and their outputs
+// are switched with the credentials of the account.
+type HomeAssistantConfig struct {
+	Enabled         bool          `yaml:"enabled" toml:"enabled"`
+	Broker          string        `yaml:"broker" toml:"broker"`
+	Username        string        `yaml:"username" toml:"username"`
+	Password        string        `yaml:"password" toml:"password"`
+	ClientID        string        `yaml:"client_id" toml:"client_id"`
+	DiscoveryPrefix string        `yaml:"discovery_prefix" toml:"discovery_prefix"`
+	TopicPrefix     string        `yaml:"topic_prefix" toml:"topic_prefix"`
+	Interval        time.Duration `yaml:"interval" toml:"interval"`
+}
+
+// AuditConfig contains the settings of the audit log. The mutating API calls are appended to File, the log is queried
+// at /api/audit with Token as bearer token.
+type AuditConfig struct {
+	Enabled bool   `yaml:"enabled" toml:"enabled"`
+	File    string `yaml:"file" toml:"file"`
+	Token   string `yaml:"token" toml:"token"`
+}
+
+// Enabled reports whether the credential vault is configured.
+func (v VaultConfig) Enabled() bool {
+	return v.File != ""
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address:           ":8080",
+			RequestTimeout:    constants.RequestTimeout,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      constants.RequestTimeout + 15*time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    64 << 10,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Log: LogConfig{
+			Level: "debug",
+		},
+		RateLimit: RateLimitConfig{
+			Limit:  constants.RateLimit,
+			Window: constants.RateLimitWindowLength,
+		},
+		Vault: VaultConfig{
+			AllowHeaders: true,
+		},
+		ClientCache: ClientCacheConfig{
+			Size: 1000,
+			TTL:  10 * time.Minute,
+		},
+		Upstream: UpstreamConfig{
+			Timeout:             constants.UpstreamTimeout,
+			RetryAttempts:       3,
+			RetryBaseDelay:      200 * time.Millisecond,
+			RetryMaxDelay:       2 * time.Second,
+			BreakerThreshold:    5,
+			BreakerOpenDuration: 30 * time.Second,
+		},
+		Stream: StreamConfig{
+			PollInterval:      10 * time.Second,
+			HeartbeatInterval: 15 * time.Second,
+			HistorySize:       100,
+			Linger:            time.Minute,
+		},
+		WebSocket: WebSocketConfig{
+			SendQueue:    256,
+			PingInterval: 30 * time.Second,
+		},
+		Bulk: BulkConfig{
+			Concurrency: 4,
+			MaxDevices:  100,
+		},
+		MQTT: MQTTConfig{
+			APIURL:          "https://api.ecoflow.com",
+			MaxAge:          time.Minute,
+			RefreshInterval: 10 * time.Minute,
+		},
+		Metrics: MetricsConfig{
+			DevicePollInterval: time.Minute,
+		},
+		History: HistoryConfig{
+			Dir:      "history",
+			Interval: time.Minute,
+			Params: []string{
+				"pd.soc", "bms_bmsStatus.soc", "pd.wattsInSum", "pd.wattsOutSum", "inv.inputWatts", "inv.outputWatts",
+				"mppt.inWatts", "bms_bmsStatus.temp",
+			},
+			RollupInterval: time.Hour,
+			RawRetention:   7 * 24 * time.Hour,
+			Retention:      365 * 24 * time.Hour,
+		},
+		Schedules: SchedulesConfig{
+			File:       "schedules.json",
+			RunHistory: 100,
+		},
+		Rules: RulesConfig{
+			File:     "rules.json",
+			Interval: 10 * time.Second,
+			History:  100,
+		},
+		Webhooks: WebhooksConfig{
+			File:           "webhooks.json",
+			Interval:       30 * time.Second,
+			Timeout:        10 * time.Second,
+			MaxAttempts:    6,
+			RetryBaseDelay: 10 * time.Second,
+			RetryMaxDelay:  10 * time.Minute,
+			History:        100,
+		},
+		HomeAssistant: HomeAssistantConfig{
+			Broker:          "tcp://localhost:1883",
+			ClientID:        "go-ecoflow-api-server",
+			DiscoveryPrefix: "homeassistant",
+			TopicPrefix:     "ecoflow",
+			Interval:        10 * time.Second,
+		},
+		Audit: AuditConfig{
+			File: "audit.jsonl",
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the optional config file, the environment and the command line
+// arguments (without the program name). getenv is usually os.Getenv. The result is validated before it is returned.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+
+	path := configPath(args, getenv)
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	fs := cfg.flagSet(path)
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		value := getenv(EnvName(f.Name))
+		if value == "" || envErr != nil {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			envErr = fmt.Errorf("invalid value %q for %s: %w", value, EnvName(f.Name), err)
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// EnvName returns the environment variable that corresponds to the given flag name, e.g. "rate-limit" -> "ECOFLOW_RATE_LIMIT".
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// flagSet binds every configurable option to a flag. The current values of cfg are used as flag defaults,
+// so parsing only overrides what is explicitly set.
+func (c *Config) flagSet(path string) *flag.FlagSet {
+	fs := flag.NewFlagSet("go-ecoflow-api-server", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fs.String("config", path, "path to a YAML (.yaml, .yml) or TOML (.toml) configuration file")
+	fs.StringVar(&c.Server.Address, "addr", c.Server.Address, "address the HTTP server listens on")
+	fs.DurationVar(&c.Server.RequestTimeout, "request-timeout", c.Server.RequestTimeout, "maximum duration of an API request")
+	fs.DurationVar(&c.Server.ReadTimeout, "read-timeout", c.Server.ReadTimeout, "maximum duration for reading an entire request, including the body")
+	fs.DurationVar(&c.Server.ReadHeaderTimeout, "read-header-timeout", c.Server.ReadHeaderTimeout, "maximum duration for reading request headers")
+	fs.DurationVar(&c.Server.WriteTimeout, "write-timeout", c.Server.WriteTimeout, "maximum duration before timing out writes of a response, must be greater than the request timeout")
+	fs.DurationVar(&c.Server.IdleTimeout, "idle-timeout", c.Server.IdleTimeout, "maximum time to wait for the next request on a keep-alive connection")
+	fs.IntVar(&c.Server.MaxHeaderBytes, "max-header-bytes", c.Server.MaxHeaderBytes, "maximum size of request headers in bytes")
+	fs.DurationVar(&c.Server.ShutdownTimeout, "shutdown-timeout", c.Server.ShutdownTimeout, "grace period for in-flight requests and background tasks on SIGTERM/SIGINT")
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "log level: debug, info, warn or error")
+	fs.IntVar(&c.RateLimit.Limit, "rate-limit", c.RateLimit.Limit, "maximum number of requests per client within the rate limit window")
+	fs.DurationVar(&c.RateLimit.Window, "rate-limit-window", c.RateLimit.Window, "length of the rate limit window")
+	fs.StringVar(&c.Vault.File, "vault-file", c.Vault.File, "path to the encrypted credential vault, enables API key authentication")
+	fs.StringVar(&c.Vault.KeyFile, "vault-key-file", c.Vault.KeyFile, "path to the file with the base64 encoded vault master key")
+	fs.BoolVar(&c.Vault.AllowHeaders, "vault-allow-headers", c.Vault.AllowHeaders, "accept Ecoflow keys in request headers when the vault is enabled")
+	fs.IntVar(&c.ClientCache.Size, "client-cache-size", c.ClientCache.Size, "maximum number of cached Ecoflow clients, 0 disables the cache")
+	fs.DurationVar(&c.ClientCache.TTL, "client-cache-ttl", c.ClientCache.TTL, "time after which an unused Ecoflow client is evicted from the cache")
+	fs.DurationVar(&c.Upstream.Timeout, "upstream-timeout", c.Upstream.Timeout, "maximum duration of a single call to the Ecoflow API, the request timeout still applies")
+	fs.IntVar(&c.Upstream.RetryAttempts, "upstream-retry-attempts", c.Upstream.RetryAttempts, "maximum number of attempts of an idempotent Ecoflow API call, 1 disables retries")
+	fs.DurationVar(&c.Upstream.RetryBaseDelay, "upstream-retry-base-delay", c.Upstream.RetryBaseDelay, "backoff before the first retry, doubled on every further retry")
+	fs.DurationVar(&c.Upstream.RetryMaxDelay, "upstream-retry-max-delay", c.Upstream.RetryMaxDelay, "maximum backoff between retries")
+	fs.IntVar(&c.Upstream.BreakerThreshold, "upstream-breaker-threshold", c.Upstream.BreakerThreshold, "consecutive Ecoflow API failures that open the circuit breaker of an account")
+	fs.DurationVar(&c.Upstream.BreakerOpenDuration, "upstream-breaker-open-duration", c.Upstream.BreakerOpenDuration, "time an open circuit breaker rejects calls before probing the Ecoflow API again")
+	fs.DurationVar(&c.Stream.PollInterval, "stream-poll-interval", c.Stream.PollInterval, "interval at which streamed devices are polled from the Ecoflow API")
+	fs.DurationVar(&c.Stream.HeartbeatInterval, "stream-heartbeat-interval", c.Stream.HeartbeatInterval, "interval of the heartbeats sent on idle streams")
+	fs.IntVar(&c.Stream.HistorySize, "stream-history-size", c.Stream.HistorySize, "number of events kept per device to resume streams with Last-Event-ID")
+	fs.DurationVar(&c.Stream.Linger, "stream-linger", c.Stream.Linger, "time a device is still watched after its last stream closed, so clients can resume")
+	fs.IntVar(&c.WebSocket.SendQueue, "websocket-send-queue", c.WebSocket.SendQueue, "number of messages queued per WebSocket connection before telemetry is dropped")
+	fs.DurationVar(&c.WebSocket.PingInterval, "websocket-ping-interval", c.WebSocket.PingInterval, "interval of the pings sent on WebSocket connections")
+	fs.IntVar(&c.Bulk.Concurrency, "bulk-concurrency", c.Bulk.Concurrency, "number of devices a bulk command is sent to at the same time")
+	fs.IntVar(&c.Bulk.MaxDevices, "bulk-max-devices", c.Bulk.MaxDevices, "maximum number of devices of a bulk command")
+	fs.BoolVar(&c.MQTT.Enabled, "mqtt-enabled", c.MQTT.Enabled, "ingest the parameters of the vault accounts' devices from the Ecoflow MQTT broker")
+	fs.Var((*stringList)(&c.MQTT.Accounts), "mqtt-accounts", "comma separated vault accounts to ingest, all accounts if empty")
+	fs.StringVar(&c.MQTT.APIURL, "mqtt-api-url", c.MQTT.APIURL, "Ecoflow API used to obtain the MQTT certification and to read the device parameters for the ingestion and the device metrics")
+	fs.DurationVar(&c.MQTT.MaxAge, "mqtt-max-age", c.MQTT.MaxAge, "time ingested parameters are served instead of calling the Ecoflow API")
+	fs.DurationVar(&c.MQTT.RefreshInterval, "mqtt-refresh-interval", c.MQTT.RefreshInterval, "interval at which the device lists of the ingested accounts are refreshed")
+	fs.BoolVar(&c.Metrics.Enabled, "metrics-enabled", c.Metrics.Enabled, "serve Prometheus metrics at /metrics")
+	fs.StringVar(&c.Metrics.Token, "metrics-token", c.Metrics.Token, "bearer token required to scrape /metrics, the endpoint is public if empty")
+	fs.DurationVar(&c.Metrics.DevicePollInterval, "metrics-device-poll-interval", c.Metrics.DevicePollInterval, "interval at which the devices of the vault accounts are polled for the device metrics and the history, unless they are ingested from MQTT")
+	fs.BoolVar(&c.History.Enabled, "history-enabled", c.History.Enabled, "record the history of the parameters of the vault accounts' devices")
+	fs.StringVar(&c.History.Dir, "history-dir", c.History.Dir, "directory of the history segment files")
+	fs.DurationVar(&c.History.Interval, "history-interval", c.History.Interval, "interval at which the device parameters are recorded")
+	fs.Var((*stringList)(&c.History.Params), "history-params", "comma separated parameters to record")
+	fs.DurationVar(&c.History.RollupInterval, "history-rollup-interval", c.History.RollupInterval, "resolution of the downsampled history, must divide 24h")
+	fs.DurationVar(&c.History.RawRetention, "history-raw-retention", c.History.RawRetention, "time raw samples are kept before only their rollups are available")
+	fs.DurationVar(&c.History.Retention, "history-retention", c.History.Retention, "time the downsampled history is kept")
+	fs.BoolVar(&c.Schedules.Enabled, "schedules-enabled", c.Schedules.Enabled, "run scheduled power station commands, managed at /api/schedules with API keys")
+	fs.StringVar(&c.Schedules.File, "schedules-file", c.Schedules.File, "path to the file with the schedules and their runs")
+	fs.IntVar(&c.Schedules.RunHistory, "schedules-run-history", c.Schedules.RunHistory, "number of runs kept per schedule")
+	fs.BoolVar(&c.Rules.Enabled, "rules-enabled", c.Rules.Enabled, "run rules that send power station commands on device parameters, managed at /api/rules with API keys")
+	fs.StringVar(&c.Rules.File, "rules-file", c.Rules.File, "path to the file with the rules and their evaluations")
+	fs.DurationVar(&c.Rules.Interval, "rules-interval", c.Rules.Interval, "interval at which the rules are evaluated")
+	fs.IntVar(&c.Rules.History, "rules-history", c.Rules.History, "number of evaluations kept per rule")
+	fs.BoolVar(&c.Webhooks.Enabled, "webhooks-enabled", c.Webhooks.Enabled, "send device events to webhook subscriptions, managed at /api/webhooks with API keys")
+	fs.StringVar(&c.Webhooks.File, "webhooks-file", c.Webhooks.File, "path to the file with the webhook subscriptions and their deliveries")
+	fs.DurationVar(&c.Webhooks.Interval, "webhooks-interval", c.Webhooks.Interval, "interval at which the devices are checked for webhook events")
+	fs.DurationVar(&c.Webhooks.Timeout, "webhooks-timeout", c.Webhooks.Timeout, "timeout of every webhook delivery attempt")
+	fs.IntVar(&c.Webhooks.MaxAttempts, "webhooks-max-attempts", c.Webhooks.MaxAttempts, "number of attempts before a webhook delivery is a dead letter")
+	fs.DurationVar(&c.Webhooks.RetryBaseDelay, "webhooks-retry-base-delay", c.Webhooks.RetryBaseDelay, "delay before the first retry of a webhook delivery, doubled with every retry")
+	fs.DurationVar(&c.Webhooks.RetryMaxDelay, "webhooks-retry-max-delay", c.Webhooks.RetryMaxDelay, "maximum delay between the retries of a webhook delivery")
+	fs.IntVar(&c.Webhooks.History, "webhooks-history", c.Webhooks.History, "number of completed deliveries and dead letters kept per webhook subscription")
+	fs.BoolVar(&c.HomeAssistant.Enabled, "homeassistant-enabled", c.HomeAssistant.Enabled, "publish the power stations of the vault accounts to Home Assistant with MQTT discovery")
+	fs.StringVar(&c.HomeAssistant.Broker, "homeassistant-broker", c.HomeAssistant.Broker, "URL of the MQTT broker of Home Assistant")
+	fs.StringVar(&c.HomeAssistant.Username, "homeassistant-username", c.HomeAssistant.Username, "username for the MQTT broker of Home Assistant")
+	fs.StringVar(&c.HomeAssistant.Password, "homeassistant-password", c.HomeAssistant.Password, "password for the MQTT broker of Home Assistant")
+	fs.StringVar(&c.HomeAssistant.ClientID, "homeassistant-client-id", c.HomeAssistant.ClientID, "MQTT client ID of the Home Assistant bridge")
+	fs.StringVar(&c.HomeAssistant.DiscoveryPrefix, "homeassistant-discovery-prefix", c.HomeAssistant.DiscoveryPrefix, "discovery prefix of the MQTT integration of Home Assistant")
+	fs.StringVar(&c.HomeAssistant.TopicPrefix, "homeassistant-topic-prefix", c.HomeAssistant.TopicPrefix, "prefix of the state and command topics of the power stations")
+	fs.DurationVar(&c.HomeAssistant.Interval, "homeassistant-interval", c.HomeAssistant.Interval, "interval at which the states of the power stations are published to Home Assistant")
+	fs.BoolVar(&c.Audit.Enabled, "audit-enabled", c.Audit.Enabled, "record the mutating API calls in the audit log, queried at /api/audit")
+	fs.StringVar(&c.Audit.File, "audit-file", c.Audit.File, "path to the audit log file, entries are appended as JSON Lines")
+	fs.StringVar(&c.Audit.Token, "audit-token", c.Audit.Token, "bearer token required to query and export the audit log")
+
+	return fs
+}
+
+// stringList is a comma separated list flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// Usage writes the description of all flags and their environment variables to w.
+func Usage(w io.Writer) {
+	fs := Default().flagSet("")
+	fs.SetOutput(w)
+	_, _ = fmt.Fprintf(w, "Usage of %s:\n", fs.Name())
+	fs.VisitAll(func(f *flag.Flag) {
+		_, _ = fmt.Fprintf(w, "  -%s (env %s, default %q)\n    \t%s\n", f.Name, EnvName(f.Name), f.DefValue, f.Usage)
+	})
+}
+
+// configPath finds the config file location. The -config flag has precedence over ECOFLOW_CONFIG.
+func configPath(args []string, getenv func(string) string) string {
+	path := getenv(EnvName("config"))
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			path = value
+		} else if i+1 < len(args) {
+			path = args[i+1]
+		}
+	}
+	return path
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("can't read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		_, err = toml.Decode(string(data), c)
+	default:
+		return fmt.Errorf("unsupported config file format %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("can't parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks that the configuration is usable and returns all problems found.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Address == "" {
+		errs = append(errs, errors.New("server address must not be empty"))
+	}
+	if c.Server.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("request timeout must be greater than 0"))
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.ReadHeaderTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("read, read header and idle timeouts must be greater than 0"))
+	}
+	if c.Server.WriteTimeout <= c.Server.RequestTimeout {
+		errs = append(errs, errors.New("write timeout must be greater than the request timeout"))
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		errs = append(errs, errors.New("max header bytes must be greater than 0"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown timeout must be greater than 0"))
+	}
+	if _, err := parseLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	if c.RateLimit.Limit <= 0 {
+		errs = append(errs, errors.New("rate limit must be greater than 0"))
+	}
+	if c.RateLimit.Window <= 0 {
+		errs = append(errs, errors.New("rate limit window must be greater than 0"))
+	}
+	if c.Vault.Enabled() && c.Vault.KeyFile == "" {
+		errs = append(errs, errors.New("vault key file must be set when the vault is enabled"))
+	}
+	if c.Upstream.Timeout <= 0 {
+		errs = append(errs, errors.New("upstream timeout must be greater than 0"))
+	}
+	if c.Upstream.RetryAttempts < 1 {
+		errs = append(errs, errors.New("upstream retry attempts must be at least 1"))
+	}
+	if c.Upstream.RetryBaseDelay < 0 || c.Upstream.RetryMaxDelay < c.Upstream.RetryBaseDelay {
+		errs = append(errs, errors.New("upstream retry delays must not be negative and the max delay must not be less than the base delay"))
+	}
+	if c.Upstream.BreakerThreshold < 1 || c.Upstream.BreakerOpenDuration <= 0 {
+		errs = append(errs, errors.New("upstream breaker threshold must be at least 1 and the open duration greater than 0"))
+	}
+	if c.Stream.PollInterval <= 0 || c.Stream.HeartbeatInterval <= 0 {
+		errs = append(errs, errors.New("stream poll and heartbeat intervals must be greater than 0"))
+	}
+	if c.Stream.HistorySize < 1 || c.Stream.Linger < 0 {
+		errs = append(errs, errors.New("stream history size must be at least 1 and the linger must not be negative"))
+	}
+	if c.WebSocket.SendQueue < 1 || c.WebSocket.PingInterval <= 0 {
+		errs = append(errs, errors.New("websocket send queue must be at least 1 and the ping interval greater than 0"))
+	}
+	if c.Bulk.Concurrency < 1 || c.Bulk.MaxDevices < 1 {
+		errs = append(errs, errors.New("bulk concurrency and max devices must be at least 1"))
+	}
+	if c.MQTT.Enabled && !c.Vault.Enabled() {
+		errs = append(errs, errors.New("mqtt ingestion requires the credential vault"))
+	}
+	if c.MQTT.MaxAge <= 0 || c.MQTT.RefreshInterval <= 0 {
+		errs = append(errs, errors.New("mqtt max age and refresh interval must be greater than 0"))
+	}
+	if c.Metrics.DevicePollInterval <= 0 {
+		errs = append(errs, errors.New("metrics device poll interval must be greater than 0"))
+	}
+	if c.History.Enabled && !c.Vault.Enabled() {
+		errs = append(errs, errors.New("history requires the credential vault"))
+	}
+	if c.History.Enabled && (c.History.Dir == "" || len(c.History.Params) == 0) {
+		errs = append(errs, errors.New("history dir and params must not be empty"))
+	}
+	if c.History.Interval <= 0 || c.History.RollupInterval <= 0 || 24*time.Hour%c.History.RollupInterval != 0 {
+		errs = append(errs, errors.New("history interval must be greater than 0 and the rollup interval must divide 24h"))
+	}
+	if c.History.RawRetention <= 0 || c.History.Retention < c.History.RawRetention {
+		errs = append(errs, errors.New("history raw retention must be greater than 0 and not exceed the retention"))
+	}
+	if c.Schedules.Enabled && (!c.Vault.Enabled() || c.Schedules.File == "") {
+		errs = append(errs, errors.New("schedules require the credential vault and a schedules file"))
+	}
+	if c.Schedules.RunHistory < 1 {
+		errs = append(errs, errors.New("schedules run history must be at least 1"))
+	}
+	if c.Rules.Enabled && (!c.Vault.Enabled() || c.Rules.File == "") {
+		errs = append(errs, errors.New("rules require the credential vault and a rules file"))
+	}
+	if c.Rules.Interval <= 0 || c.Rules.History < 1 {
+		errs = append(errs, errors.New("rules interval must be greater than 0 and the history at least 1"))
+	}
+	if c.Webhooks.Enabled && (!c.Vault.Enabled() || c.Webhooks.File == "") {
+		errs = append(errs, errors.New("webhooks require the credential vault and a webhooks file"))
+	}
+	if c.Webhooks.Interval <= 0 || c.Webhooks.Timeout <= 0 || c.Webhooks.MaxAttempts < 1 || c.Webhooks.History < 1 {
+		errs = append(errs, errors.New("webhooks interval and timeout must be greater than 0, the max attempts and the history at least 1"))
+	}
+	if c.Webhooks.RetryBaseDelay <= 0 || c.Webhooks.RetryMaxDelay < c.Webhooks.RetryBaseDelay {
+		errs = append(errs, errors.New("webhooks retry base delay must be greater than 0 and not exceed the retry max delay"))
+	}
+	if c.HomeAssistant.Enabled && (!c.Vault.Enabled() || c.HomeAssistant.Broker == "" || c.HomeAssistant.ClientID == "") {
+		errs = append(errs, errors.New("home assistant bridge requires the credential vault, a broker and a client id"))
+	}
+	if !validTopicPrefix(c.HomeAssistant.DiscoveryPrefix) || !validTopicPrefix(c.HomeAssistant.TopicPrefix) {
+		errs = append(errs, errors.New("home assistant discovery and topic prefixes must not be empty or contain wildcards"))
+	}
+	if c.HomeAssistant.Interval <= 0 {
+		errs = append(errs, errors.New("home assistant interval must be greater than 0"))
+	}
+	if c.Audit.Enabled && (c.Audit.File == "" || c.Audit.Token == "") {
+		errs = append(errs, errors.New("audit log requires an audit file and a token"))
+	}
+	if c.ClientCache.Size < 0 {
+		errs = append(errs, errors.New("client cache size must not be negative"))
+	}
+	if c.ClientCache.Size > 0 && c.ClientCache.TTL <= 0 {
+		errs = append(errs, errors.New("client cache ttl must be greater than 0"))
+	}
+	return errors.Join(errs...)
+}
+
+// SlogLevel returns the configured log level. The level is validated by Load, so it falls back to debug only
+// for configurations that were never validated.
+func (l LogConfig) SlogLevel() slog.Level {
+	level, err := parseLevel(l.Level)
+	if err != nil {
+		return slog.LevelDebug
+	}
+	return level
+}
+
+func parseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", level)
+	}
+	return l, nil
+}
+
+// validTopicPrefix reports whether the MQTT topic prefix is not empty and has no wildcards.
+func validTopicPrefix(prefix string) bool {
+	return prefix != "" && !strings.ContainsAny(prefix, "+#")
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, slog.LevelDebug, cfg.Log.SlogLevel())
+	assert.False(t, cfg.Metrics.Enabled, "devices are only polled for metrics when asked to")
+}
+
+func TestLoad_Files(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+server:
+  address: ":9090"
+  request_timeout: 10s
+log:
+  level: info
+rate_limit:
+  limit: 120
+  window: 2m
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+[server]
+address = ":9090"
+request_timeout = "10s"
+
+[log]
+level = "info"
+
+[rate_limit]
+limit = 120
+window = "2m"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, tt.file, tt.content)
+
+			cfg, err := Load([]string{"-config", path}, env(nil))
+			require.NoError(t, err)
+			assert.Equal(t, ":9090", cfg.Server.Address)
+			assert.Equal(t, 10*time.Second, cfg.Server.RequestTimeout)
+			assert.Equal(t, slog.LevelInfo, cfg.Log.SlogLevel())
+			assert.Equal(t, 120, cfg.RateLimit.Limit)
+			assert.Equal(t, 2*time.Minute, cfg.RateLimit.Window)
+		})
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "config.yml", "server:\n  address: \":7000\"\nlog:\n  level: error\nrate_limit:\n  limit: 5\n")
+
+	cfg, err := Load([]string{"--addr=:9000"}, env(map[string]string{
+		"ECOFLOW_CONFIG":     path,
+		"ECOFLOW_ADDR":       ":8000",
+		"ECOFLOW_LOG_LEVEL":  "warn",
+		"ECOFLOW_RATE_LIMIT": "",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9000", cfg.Server.Address, "flag overrides env and file")
+	assert.Equal(t, "warn", cfg.Log.Level, "env overrides file")
+	assert.Equal(t, 5, cfg.RateLimit.Limit, "empty env does not override file")
+}
+
+func TestLoad_ListFlag(t *testing.T) {
+	cfg, err := Load([]string{"-mqtt-enabled", "-vault-file", "vault.json", "-vault-key-file", "vault.key"}, env(map[string]string{
+		"ECOFLOW_MQTT_ACCOUNTS": "home, cabin,",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"home", "cabin"}, cfg.MQTT.Accounts)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "invalid log level", args: []string{"-log-level", "verbose"}},
+		{name: "zero rate limit", args: []string{"-rate-limit", "0"}},
+		{name: "negative timeout", args: []string{"-request-timeout", "-1s"}},
+		{name: "invalid env value", env: map[string]string{"ECOFLOW_RATE_LIMIT_WINDOW": "soon"}},
+		{name: "unknown flag", args: []string{"-unknown"}},
+		{name: "missing file", args: []string{"-config", "does-not-exist.yaml"}},
+		{name: "unsupported file", args: []string{"-config", "config.json"}},
+		{name: "retry max delay below base delay", args: []string{"-upstream-retry-base-delay", "5s", "-upstream-retry-max-delay", "1s"}},
+		{name: "zero breaker threshold", args: []string{"-upstream-breaker-threshold", "0"}},
+		{name: "mqtt without vault", args: []string{"-mqtt-enabled"}},
+		{name: "zero metrics poll interval", args: []string{"-metrics-device-poll-interval", "0"}},
+		{name: "history without vault", args: []string{"-history-enabled"}},
+		{name: "history rollup interval not dividing a day", args: []string{"-history-rollup-interval", "7h"}},
+		{name: "zero bulk concurrency", args: []string{"-bulk-concurrency", "0"}},
+		{name: "schedules without vault", args: []string{"-schedules-enabled"}},
+		{name: "rules without vault", args: []string{"-rules-enabled"}},
+		{name: "zero rules interval", args: []string{"-rules-interval", "0"}},
+		{name: "webhooks without vault", args: []string{"-webhooks-enabled"}},
+		{name: "webhooks retry delays", args: []string{"-webhooks-retry-base-delay", "1h", "-webhooks-retry-max-delay", "1m"}},
+		{name: "home assistant without vault", args: []string{"-homeassistant-enabled"}},
+		{name: "home assistant topic prefix wildcard", args: []string{"-homeassistant-topic-prefix", "ecoflow/#"}},
+		{name: "audit without token", args: []string{"-audit-enabled"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args, env(tt.env))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -5,13 +5,23 @@ import "time"
 const (
 	HeaderAuthorization = "Authorization"
 	HeaderXSecretToken  = "X-Secret-Token"
+	HeaderXAPIKey       = "X-API-Key"
+	// HeaderDataSource is set when a response is not served from the Ecoflow API.
+	HeaderDataSource = "X-Data-Source"
 )
 
+// DataSourceMQTT marks responses served from the parameters pushed by the Ecoflow MQTT broker.
+const DataSourceMQTT = "mqtt"
+
 const (
-	RequestTimeout = 30 * time.Second
+	RequestTimeout  = 30 * time.Second
+	UpstreamTimeout = 20 * time.Second
 )
 
 const (
 	RateLimit             = 60
 	RateLimitWindowLength = time.Minute
 )
+
+// ProblemTypePrefix is the prefix of the RFC 7807 problem type URIs, the error code is appended to it.
+const ProblemTypePrefix = "urn:go-ecoflow-api-server:error:"
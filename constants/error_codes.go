@@ -6,10 +6,26 @@ const (
 	ErrInvalidJsonBody        = "0003"
 	ErrInvalidParameters      = "0004"
 	ErrRateLimitExceeded      = "0005"
+	ErrUpstreamCanceled       = "0006"
+	ErrUpstreamTimeout        = "0007"
+	ErrUpstreamUnavailable    = "0008"
 
-	ErrGetDevicesList         = "0100"
-	ErrGetAllDeviceParameters = "0101"
-	ErrGetDeviceParameters    = "0102"
+	ErrUpstreamUnauthorized   = "0010"
+	ErrUpstreamForbidden      = "0011"
+	ErrDeviceNotFound         = "0012"
+	ErrDeviceOffline          = "0013"
+	ErrUpstreamBadResponse    = "0014"
+	ErrUpstreamOutage         = "0015"
+	ErrUpstreamInvalidRequest = "0016"
+	ErrCommandUnsupported     = "0017"
+
+	ErrGetDevicesList          = "0100"
+	ErrGetAllDeviceParameters  = "0101"
+	ErrGetDeviceParameters     = "0102"
+	ErrStreamUnavailable       = "0103"
+	ErrGetDeviceHistory        = "0104"
+	ErrGetDeviceStatus         = "0105"
+	ErrDeviceStatusUnsupported = "0106"
 
 	ErrEnableCarOut = "0200"
 	ErrEnableDcOut  = "0201"
@@ -18,4 +34,47 @@ const (
 	ErrPowerStationSetChargingSpeed = "0204"
 	ErrPowerStationSetCarInput      = "0205"
 	ErrPowerStationSetStandBy       = "0206"
+
+	ErrPowerStationSetChargeLimit    = "0207"
+	ErrPowerStationSetDischargeLimit = "0208"
+	ErrPowerStationSetGenerator      = "0209"
+	ErrPowerStationSetBeep           = "0210"
+	ErrPowerStationSetAcChargePause  = "0211"
+	ErrPowerStationSetAcChargeMode   = "0212"
+	ErrPowerStationSetPvChargeType   = "0213"
+	ErrPowerStationSetScreen         = "0214"
+	ErrPowerStationBulkSelect        = "0215"
+
+	ErrSmartPlugSetRelay      = "0300"
+	ErrSmartPlugSetBrightness = "0301"
+	ErrSmartPlugSetMaxPower   = "0302"
+	ErrSmartPlugSetTask       = "0303"
+	ErrSmartPlugDeleteTask    = "0304"
+
+	ErrPowerStreamSetCustomLoad        = "0400"
+	ErrPowerStreamSetSupplyPriority    = "0401"
+	ErrPowerStreamSetBatteryLowerLimit = "0402"
+	ErrPowerStreamSetBatteryUpperLimit = "0403"
+	ErrPowerStreamSetBrightness        = "0404"
+
+	ErrSmartHomePanelGetCircuits        = "0500"
+	ErrSmartHomePanelSetCircuitSource   = "0501"
+	ErrSmartHomePanelSetCircuitPriority = "0502"
+	ErrSmartHomePanelSetBackupReserve   = "0503"
+	ErrSmartHomePanelSetChargingWindow  = "0504"
+
+	ErrScheduleRequiresAPIKey = "0600"
+	ErrScheduleNotFound       = "0601"
+	ErrSaveSchedule           = "0602"
+
+	ErrRuleRequiresAPIKey = "0700"
+	ErrRuleNotFound       = "0701"
+	ErrSaveRule           = "0702"
+
+	ErrWebhookRequiresAPIKey   = "0800"
+	ErrWebhookNotFound         = "0801"
+	ErrSaveWebhook             = "0802"
+	ErrWebhookDeliveryNotFound = "0803"
+
+	ErrReadAuditLog = "0900"
 )
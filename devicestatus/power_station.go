@@ -0,0 +1,110 @@
+package devicestatus
+
+// PowerStation is the status of portable power stations like DELTA 2 and RIVER 2.
+type PowerStation struct {
+	Battery      PowerStationBattery      `json:"battery"`
+	Input        PowerStationInput        `json:"input"`
+	Output       PowerStationOutput       `json:"output"`
+	Temperatures PowerStationTemperatures `json:"temperatures"`
+	Switches     PowerStationSwitches     `json:"switches"`
+}
+
+type PowerStationBattery struct {
+	StateOfChargePercent *float64 `json:"state_of_charge_percent"`
+	StateOfHealthPercent *float64 `json:"state_of_health_percent"`
+	// RemainingSeconds is the time until the battery is full while charging or empty while discharging.
+	RemainingSeconds          *float64 `json:"remaining_seconds"`
+	ChargeRemainingSeconds    *float64 `json:"charge_remaining_seconds"`
+	DischargeRemainingSeconds *float64 `json:"discharge_remaining_seconds"`
+	VoltageVolts              *float64 `json:"voltage_volts"`
+	CurrentAmperes            *float64 `json:"current_amperes"`
+	DesignCapacityAmpereHours *float64 `json:"design_capacity_ampere_hours"`
+	FullCapacityAmpereHours   *float64 `json:"full_capacity_ampere_hours"`
+	RemainingAmpereHours      *float64 `json:"remaining_ampere_hours"`
+	Cycles                    *int     `json:"cycles"`
+	ChargeLimitPercent        *float64 `json:"charge_limit_percent"`
+	DischargeLimitPercent     *float64 `json:"discharge_limit_percent"`
+}
+
+type PowerStationInput struct {
+	TotalWatts *float64 `json:"total_watts"`
+	ACWatts    *float64 `json:"ac_watts"`
+	SolarWatts *float64 `json:"solar_watts"`
+}
+
+type PowerStationOutput struct {
+	TotalWatts       *float64 `json:"total_watts"`
+	ACWatts          *float64 `json:"ac_watts"`
+	USBWatts         *float64 `json:"usb_watts"`
+	USBCWatts        *float64 `json:"usb_c_watts"`
+	CarWatts         *float64 `json:"car_watts"`
+	ACVoltageVolts   *float64 `json:"ac_voltage_volts"`
+	ACFrequencyHertz *float64 `json:"ac_frequency_hertz"`
+}
+
+type PowerStationTemperatures struct {
+	BatteryCelsius  *float64 `json:"battery_celsius"`
+	InverterCelsius *float64 `json:"inverter_celsius"`
+	MPPTCelsius     *float64 `json:"mppt_celsius"`
+}
+
+type PowerStationSwitches struct {
+	ACEnabled     *bool `json:"ac_enabled"`
+	XBoostEnabled *bool `json:"xboost_enabled"`
+	DCEnabled     *bool `json:"dc_enabled"`
+	CarEnabled    *bool `json:"car_enabled"`
+}
+
+func decodePowerStation(p params) *PowerStation {
+	return &PowerStation{
+		Battery: PowerStationBattery{
+			StateOfChargePercent:      firstOf(p.float("pd.soc", 1), p.float("bms_bmsStatus.soc", 1)),
+			StateOfHealthPercent:      p.float("bms_bmsStatus.soh", 1),
+			RemainingSeconds:          p.float("pd.remainTime", 60),
+			ChargeRemainingSeconds:    p.float("bms_emsStatus.chgRemainTime", 60),
+			DischargeRemainingSeconds: p.float("bms_emsStatus.dsgRemainTime", 60),
+			VoltageVolts:              p.float("bms_bmsStatus.vol", 0.001),
+			CurrentAmperes:            p.float("bms_bmsStatus.amp", 0.001),
+			DesignCapacityAmpereHours: p.float("bms_bmsStatus.designCap", 0.001),
+			FullCapacityAmpereHours:   p.float("bms_bmsStatus.fullCap", 0.001),
+			RemainingAmpereHours:      p.float("bms_bmsStatus.remainCap", 0.001),
+			Cycles:                    p.int("bms_bmsStatus.cycles"),
+			ChargeLimitPercent:        p.float("bms_emsStatus.maxChargeSoc", 1),
+			DischargeLimitPercent:     p.float("bms_emsStatus.minDsgSoc", 1),
+		},
+		Input: PowerStationInput{
+			TotalWatts: p.float("pd.wattsInSum", 1),
+			ACWatts:    p.float("inv.inputWatts", 1),
+			SolarWatts: p.float("mppt.inWatts", 0.1),
+		},
+		Output: PowerStationOutput{
+			TotalWatts:       p.float("pd.wattsOutSum", 1),
+			ACWatts:          p.float("inv.outputWatts", 1),
+			USBWatts:         p.sum(1, "pd.usb1Watts", "pd.usb2Watts", "pd.qcUsb1Watts", "pd.qcUsb2Watts"),
+			USBCWatts:        p.sum(1, "pd.typec1Watts", "pd.typec2Watts"),
+			CarWatts:         p.float("pd.carWatts", 1),
+			ACVoltageVolts:   p.float("inv.cfgAcOutVol", 0.001),
+			ACFrequencyHertz: p.float("inv.cfgAcOutFreq", 1),
+		},
+		Temperatures: PowerStationTemperatures{
+			BatteryCelsius:  p.float("bms_bmsStatus.temp", 1),
+			InverterCelsius: p.float("inv.outTemp", 1),
+			MPPTCelsius:     p.float("mppt.mpptTemp", 1),
+		},
+		Switches: PowerStationSwitches{
+			ACEnabled:     p.bool("inv.cfgAcEnabled"),
+			XBoostEnabled: p.bool("inv.cfgAcXboost"),
+			DCEnabled:     p.bool("pd.dcOutState"),
+			CarEnabled:    p.bool("mppt.carState"),
+		},
+	}
+}
+
+func firstOf(values ...*float64) *float64 {
+	for _, value := range values {
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
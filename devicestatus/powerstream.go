@@ -0,0 +1,83 @@
+package devicestatus
+
+// PowerStream is the status of PowerStream micro-inverters. The inverter reports most values in tenths of their unit.
+type PowerStream struct {
+	PV1      PowerStreamPV       `json:"pv1"`
+	PV2      PowerStreamPV       `json:"pv2"`
+	Battery  PowerStreamBattery  `json:"battery"`
+	Inverter PowerStreamInverter `json:"inverter"`
+	Settings PowerStreamSettings `json:"settings"`
+}
+
+type PowerStreamPV struct {
+	InputWatts         *float64 `json:"input_watts"`
+	VoltageVolts       *float64 `json:"voltage_volts"`
+	CurrentAmperes     *float64 `json:"current_amperes"`
+	TemperatureCelsius *float64 `json:"temperature_celsius"`
+}
+
+type PowerStreamBattery struct {
+	StateOfChargePercent *float64 `json:"state_of_charge_percent"`
+	// InputWatts is positive while the battery is discharged into the inverter and negative while it is charged.
+	InputWatts         *float64 `json:"input_watts"`
+	VoltageVolts       *float64 `json:"voltage_volts"`
+	CurrentAmperes     *float64 `json:"current_amperes"`
+	TemperatureCelsius *float64 `json:"temperature_celsius"`
+}
+
+type PowerStreamInverter struct {
+	OutputWatts        *float64 `json:"output_watts"`
+	VoltageVolts       *float64 `json:"voltage_volts"`
+	CurrentAmperes     *float64 `json:"current_amperes"`
+	FrequencyHertz     *float64 `json:"frequency_hertz"`
+	TemperatureCelsius *float64 `json:"temperature_celsius"`
+}
+
+type PowerStreamSettings struct {
+	CustomLoadWatts *float64 `json:"custom_load_watts"`
+	// SupplyPriority is "supply" when the load is powered first, "battery" when the battery is charged first.
+	SupplyPriority           *string  `json:"supply_priority"`
+	BatteryLowerLimitPercent *float64 `json:"battery_lower_limit_percent"`
+	BatteryUpperLimitPercent *float64 `json:"battery_upper_limit_percent"`
+	LEDBrightnessPercent     *float64 `json:"led_brightness_percent"`
+	RatedPowerWatts          *float64 `json:"rated_power_watts"`
+}
+
+func decodePowerStream(p params) *PowerStream {
+	return &PowerStream{
+		PV1: PowerStreamPV{
+			InputWatts:         p.float("20_1.pv1InputWatts", 0.1),
+			VoltageVolts:       p.float("20_1.pv1InputVolt", 0.1),
+			CurrentAmperes:     p.float("20_1.pv1InputCur", 0.1),
+			TemperatureCelsius: p.float("20_1.pv1Temp", 0.1),
+		},
+		PV2: PowerStreamPV{
+			InputWatts:         p.float("20_1.pv2InputWatts", 0.1),
+			VoltageVolts:       p.float("20_1.pv2InputVolt", 0.1),
+			CurrentAmperes:     p.float("20_1.pv2InputCur", 0.1),
+			TemperatureCelsius: p.float("20_1.pv2Temp", 0.1),
+		},
+		Battery: PowerStreamBattery{
+			StateOfChargePercent: p.float("20_1.batSoc", 1),
+			InputWatts:           p.float("20_1.batInputWatts", 0.1),
+			VoltageVolts:         p.float("20_1.batInputVolt", 0.1),
+			CurrentAmperes:       p.float("20_1.batInputCur", 0.1),
+			TemperatureCelsius:   p.float("20_1.batTemp", 0.1),
+		},
+		Inverter: PowerStreamInverter{
+			OutputWatts:        p.float("20_1.invOutputWatts", 0.1),
+			VoltageVolts:       p.float("20_1.invOpVolt", 0.1),
+			CurrentAmperes:     p.float("20_1.invOutputCur", 0.001),
+			FrequencyHertz:     p.float("20_1.invFreq", 0.1),
+			TemperatureCelsius: p.float("20_1.invTemp", 0.1),
+		},
+		Settings: PowerStreamSettings{
+			CustomLoadWatts:          p.float("20_1.permanentWatts", 0.1),
+			SupplyPriority:           name(p.int("20_1.supplyPriority"), []string{"supply", "battery"}),
+			BatteryLowerLimitPercent: p.float("20_1.lowerLimit", 1),
+			BatteryUpperLimitPercent: p.float("20_1.upperLimit", 1),
+			LEDBrightnessPercent:     p.float("20_1.invBrightness", 100.0/maxBrightness),
+			RatedPowerWatts:          p.float("20_1.ratedPower", 0.1),
+		},
+	}
+}
@@ -0,0 +1,94 @@
+package devicestatus
+
+import "go-ecoflow-api-server/ingest"
+
+// SmartHomePanelCircuits is the number of load circuits of the Smart Home Panel, they are numbered from 0.
+const SmartHomePanelCircuits = 10
+
+// circuitSources names the power sources of circuits, the panel reports 0 for the grid, 1 for the battery and 2 when
+// the circuit is off.
+var circuitSources = []string{"grid", "battery", "off"}
+
+// SmartHomePanel is the status of Smart Home Panels.
+type SmartHomePanel struct {
+	BatteryPercent *float64 `json:"battery_percent"`
+	// BackupReservePercent is the state of charge the batteries are not discharged below, it is kept for outages.
+	BackupReservePercent *float64                `json:"backup_reserve_percent"`
+	ChargeLimitPercent   *float64                `json:"charge_limit_percent"`
+	Circuits             []SmartHomePanelCircuit `json:"circuits"`
+}
+
+type SmartHomePanelCircuit struct {
+	Circuit        int      `json:"circuit"`
+	Name           *string  `json:"name"`
+	PowerWatts     *float64 `json:"power_watts"`
+	CurrentAmperes *float64 `json:"current_amperes"`
+	// Mode is auto when the panel switches the source of the circuit itself, manual when the source was set.
+	Mode *string `json:"mode"`
+	// Source is grid, battery or off.
+	Source *string `json:"source"`
+	// Priority is the order in which circuits are powered by the batteries during an outage, 1 is powered first.
+	Priority      *int  `json:"priority"`
+	BackupEnabled *bool `json:"backup_enabled"`
+}
+
+func decodeSmartHomePanel(p params) *SmartHomePanel {
+	watts := p.list("heartbeat.hall1Watt")
+	currents := p.list("heartbeat.hall1Curr")
+	names := p.list("loadChInfo.info")
+	controls := p.list("heartbeat.loadCmdChCtrlInfos")
+	strategies := p.list("emergencyStrategy.chSta")
+
+	circuits := make([]SmartHomePanelCircuit, SmartHomePanelCircuits)
+	for i := range circuits {
+		control := element(controls, i)
+		strategy := element(strategies, i)
+		circuits[i] = SmartHomePanelCircuit{
+			Circuit:        i,
+			Name:           element(names, i).string("chName"),
+			PowerWatts:     number(watts, i),
+			CurrentAmperes: number(currents, i),
+			Mode:           name(control.int("ctrlMode"), []string{"auto", "manual"}),
+			Source:         name(control.int("ctrlSta"), circuitSources),
+			Priority:       strategy.int("priority"),
+			BackupEnabled:  strategy.bool("isEnable"),
+		}
+	}
+
+	return &SmartHomePanel{
+		BatteryPercent:       p.float("heartbeat.backupBatPer", 1),
+		BackupReservePercent: p.float("backupChaDiscCfg.discLower", 1),
+		ChargeLimitPercent:   p.float("backupChaDiscCfg.forceChargeHigh", 1),
+		Circuits:             circuits,
+	}
+}
+
+// element returns the parameters of the object at the index of the list, they are empty if there is none.
+func element(list []interface{}, i int) params {
+	if i >= len(list) {
+		return nil
+	}
+	object, _ := list[i].(map[string]interface{})
+	return object
+}
+
+// number returns the number at the index of the list.
+func number(list []interface{}, i int) *float64 {
+	if i >= len(list) {
+		return nil
+	}
+	value, ok := ingest.Number(list[i])
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+// name returns the name of an enumerated value, or nil if the value is unknown.
+func name(value *int, names []string) *string {
+	if value == nil || *value < 0 || *value >= len(names) {
+		return nil
+	}
+	result := names[*value]
+	return &result
+}
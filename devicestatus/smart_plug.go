@@ -0,0 +1,29 @@
+package devicestatus
+
+// maxBrightness is the brightness of LEDs at 100 percent, Ecoflow reports the brightness from 0 to 1023.
+const maxBrightness = 1023
+
+// SmartPlug is the status of Smart Plugs.
+type SmartPlug struct {
+	SwitchOn             *bool    `json:"switch_on"`
+	PowerWatts           *float64 `json:"power_watts"`
+	VoltageVolts         *float64 `json:"voltage_volts"`
+	CurrentAmperes       *float64 `json:"current_amperes"`
+	FrequencyHertz       *float64 `json:"frequency_hertz"`
+	TemperatureCelsius   *float64 `json:"temperature_celsius"`
+	MaxPowerWatts        *float64 `json:"max_power_watts"`
+	LEDBrightnessPercent *float64 `json:"led_brightness_percent"`
+}
+
+func decodeSmartPlug(p params) *SmartPlug {
+	return &SmartPlug{
+		SwitchOn:             p.bool("2_1.switchSta"),
+		PowerWatts:           p.float("2_1.watts", 0.1),
+		VoltageVolts:         p.float("2_1.volt", 1),
+		CurrentAmperes:       p.float("2_1.current", 0.001),
+		FrequencyHertz:       p.float("2_1.freq", 1),
+		TemperatureCelsius:   p.float("2_1.temp", 1),
+		MaxPowerWatts:        p.float("2_1.maxWatts", 1),
+		LEDBrightnessPercent: p.float("2_1.brightness", 100.0/maxBrightness),
+	}
+}
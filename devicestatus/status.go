@@ -0,0 +1,129 @@
+package devicestatus
+
+import (
+	"errors"
+	"go-ecoflow-api-server/catalog"
+	"go-ecoflow-api-server/ingest"
+	"strings"
+)
+
+// Version is the version of the status model. It is increased whenever a field changes its meaning or is removed, new
+// fields may be added without a new version.
+const Version = 1
+
+// ErrUnsupported is returned for devices whose family has no typed status.
+var ErrUnsupported = errors.New("typed status is not available for the device model")
+
+// Status is the typed status of a device. Exactly one of the family sections is set, according to Family. All values
+// are in SI units (temperatures in degrees Celsius, capacities in ampere-hours), the unit is part of the field name.
+// Values the device doesn't report are null.
+type Status struct {
+	Version        int             `json:"version"`
+	SerialNumber   string          `json:"serial_number"`
+	Model          string          `json:"model"`
+	Family         catalog.Family  `json:"family"`
+	PowerStation   *PowerStation   `json:"power_station,omitempty"`
+	SmartPlug      *SmartPlug      `json:"smart_plug,omitempty"`
+	PowerStream    *PowerStream    `json:"powerstream,omitempty"`
+	SmartHomePanel *SmartHomePanel `json:"smart_home_panel,omitempty"`
+}
+
+// decoders decode the parameters of a family into the family section of the status.
+var decoders = map[catalog.Family]func(p params, status *Status){
+	catalog.FamilyPowerStation:   func(p params, status *Status) { status.PowerStation = decodePowerStation(p) },
+	catalog.FamilySmartPlug:      func(p params, status *Status) { status.SmartPlug = decodeSmartPlug(p) },
+	catalog.FamilyPowerStream:    func(p params, status *Status) { status.PowerStream = decodePowerStream(p) },
+	catalog.FamilySmartHomePanel: func(p params, status *Status) { status.SmartHomePanel = decodeSmartHomePanel(p) },
+}
+
+// Decode returns the typed status of the device with all its parameters. Devices of unknown models that report the
+// parameters of power stations (pd.*) are decoded as power stations.
+func Decode(sn string, parameters map[string]interface{}) (*Status, error) {
+	model := catalog.Identify(sn)
+	status := &Status{Version: Version, SerialNumber: sn, Model: model.Name, Family: model.Family}
+	if model.Family == catalog.FamilyUnknown && hasPrefix(parameters, "pd.") {
+		status.Family = catalog.FamilyPowerStation
+	}
+
+	decode, ok := decoders[status.Family]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	decode(parameters, status)
+	return status, nil
+}
+
+func hasPrefix(parameters map[string]interface{}, prefix string) bool {
+	for key := range parameters {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// params reads typed values from the flat parameters. All methods return nil for parameters that are missing or not
+// numeric.
+type params map[string]interface{}
+
+// float returns the parameter multiplied by scale, e.g. 0.001 for millivolts.
+func (p params) float(key string, scale float64) *float64 {
+	value, ok := ingest.Number(p[key])
+	if !ok {
+		return nil
+	}
+	value *= scale
+	return &value
+}
+
+func (p params) int(key string) *int {
+	value, ok := ingest.Number(p[key])
+	if !ok {
+		return nil
+	}
+	result := int(value)
+	return &result
+}
+
+// bool returns whether the parameter is non-zero, switches are reported as 0 and 1 or as booleans.
+func (p params) bool(key string) *bool {
+	if value, ok := p[key].(bool); ok {
+		return &value
+	}
+	value, ok := ingest.Number(p[key])
+	if !ok {
+		return nil
+	}
+	result := value != 0
+	return &result
+}
+
+func (p params) string(key string) *string {
+	value, ok := p[key].(string)
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+// list returns the elements of an array parameter, e.g. the values of all circuits of a panel.
+func (p params) list(key string) []interface{} {
+	list, _ := p[key].([]interface{})
+	return list
+}
+
+// sum returns the sum of the parameters that are present, e.g. the output of all USB ports.
+func (p params) sum(scale float64, keys ...string) *float64 {
+	var result *float64
+	for _, key := range keys {
+		value := p.float(key, scale)
+		if value == nil {
+			continue
+		}
+		if result == nil {
+			result = new(float64)
+		}
+		*result += *value
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package devicestatus
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/catalog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode_PowerStation(t *testing.T) {
+	status, err := Decode("R331ZEB4ZEAL0528", map[string]interface{}{
+		"pd.soc":                  87.0,
+		"pd.remainTime":           -90.0,
+		"pd.wattsInSum":           320.0,
+		"mppt.inWatts":            1205.0,
+		"pd.usb1Watts":            5.0,
+		"pd.qcUsb2Watts":          12.0,
+		"bms_bmsStatus.vol":       52100.0,
+		"bms_bmsStatus.designCap": 40000.0,
+		"bms_bmsStatus.cycles":    12.0,
+		"inv.cfgAcOutVol":         220000.0,
+		"inv.cfgAcEnabled":        1.0,
+		"mppt.carState":           0.0,
+		"pd.model":                "not a number",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Version, status.Version)
+	assert.Equal(t, "DELTA 2", status.Model)
+	assert.Equal(t, catalog.FamilyPowerStation, status.Family)
+	assert.Nil(t, status.SmartPlug)
+
+	station := status.PowerStation
+	require.NotNil(t, station)
+	assert.Equal(t, 87.0, *station.Battery.StateOfChargePercent)
+	assert.Equal(t, -5400.0, *station.Battery.RemainingSeconds)
+	assert.InDelta(t, 52.1, *station.Battery.VoltageVolts, 1e-9)
+	assert.Equal(t, 40.0, *station.Battery.DesignCapacityAmpereHours)
+	assert.Equal(t, 12, *station.Battery.Cycles)
+	assert.Equal(t, 120.5, *station.Input.SolarWatts)
+	assert.Equal(t, 17.0, *station.Output.USBWatts)
+	assert.Nil(t, station.Output.USBCWatts, "ports the device doesn't report are null")
+	assert.Equal(t, 220.0, *station.Output.ACVoltageVolts)
+	assert.True(t, *station.Switches.ACEnabled)
+	assert.False(t, *station.Switches.CarEnabled)
+	assert.Nil(t, station.Switches.DCEnabled)
+
+	data, err := json.Marshal(status)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"usb_c_watts":null`)
+	assert.NotContains(t, string(data), `"smart_plug"`)
+}
+
+func TestDecode_SmartPlug(t *testing.T) {
+	status, err := Decode("HW52ZDH4SF123456", map[string]interface{}{
+		"2_1.switchSta":  true,
+		"2_1.watts":      1234.0,
+		"2_1.current":    560.0,
+		"2_1.brightness": 1023.0,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, status.SmartPlug)
+	assert.Nil(t, status.PowerStation)
+	assert.True(t, *status.SmartPlug.SwitchOn)
+	assert.InDelta(t, 123.4, *status.SmartPlug.PowerWatts, 1e-9)
+	assert.InDelta(t, 0.56, *status.SmartPlug.CurrentAmperes, 1e-9)
+	assert.InDelta(t, 100, *status.SmartPlug.LEDBrightnessPercent, 1e-9)
+	assert.Nil(t, status.SmartPlug.VoltageVolts)
+}
+
+func TestDecode_PowerStream(t *testing.T) {
+	status, err := Decode("HW51ZOH4SF123456", map[string]interface{}{
+		"20_1.pv1InputWatts":  2345.0,
+		"20_1.pv1InputVolt":   356.0,
+		"20_1.batSoc":         64.0,
+		"20_1.batInputWatts":  -1200.0,
+		"20_1.invOutputWatts": 1800.0,
+		"20_1.invOutputCur":   780.0,
+		"20_1.invFreq":        500.0,
+		"20_1.permanentWatts": 2000.0,
+		"20_1.supplyPriority": 1.0,
+		"20_1.lowerLimit":     10.0,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "PowerStream", status.Model)
+	require.NotNil(t, status.PowerStream)
+	assert.Nil(t, status.PowerStation)
+
+	stream := status.PowerStream
+	assert.InDelta(t, 234.5, *stream.PV1.InputWatts, 1e-9)
+	assert.InDelta(t, 35.6, *stream.PV1.VoltageVolts, 1e-9)
+	assert.Nil(t, stream.PV2.InputWatts, "unused inputs are null")
+	assert.Equal(t, 64.0, *stream.Battery.StateOfChargePercent)
+	assert.InDelta(t, -120, *stream.Battery.InputWatts, 1e-9)
+	assert.InDelta(t, 180, *stream.Inverter.OutputWatts, 1e-9)
+	assert.InDelta(t, 0.78, *stream.Inverter.CurrentAmperes, 1e-9)
+	assert.InDelta(t, 50, *stream.Inverter.FrequencyHertz, 1e-9)
+	assert.InDelta(t, 200, *stream.Settings.CustomLoadWatts, 1e-9)
+	assert.Equal(t, "battery", *stream.Settings.SupplyPriority)
+	assert.Equal(t, 10.0, *stream.Settings.BatteryLowerLimitPercent)
+	assert.Nil(t, stream.Settings.BatteryUpperLimitPercent)
+}
+
+func TestDecode_SmartHomePanel(t *testing.T) {
+	status, err := Decode("SP10ZAW5ZE9E0052", map[string]interface{}{
+		"heartbeat.backupBatPer":     73.0,
+		"backupChaDiscCfg.discLower": 20.0,
+		"heartbeat.hall1Watt":        []interface{}{120.5, 0.0, 33.0},
+		"loadChInfo.info":            []interface{}{map[string]interface{}{"chName": "Kitchen", "iconInfo": 10.0}},
+		"heartbeat.loadCmdChCtrlInfos": []interface{}{
+			map[string]interface{}{"ctrlMode": 1.0, "ctrlSta": 1.0},
+			map[string]interface{}{"ctrlMode": 0.0, "ctrlSta": 0.0},
+		},
+		"emergencyStrategy.chSta": []interface{}{map[string]interface{}{"priority": 2.0, "isEnable": 1.0}},
+	})
+	require.NoError(t, err)
+	panel := status.SmartHomePanel
+	require.NotNil(t, panel)
+	assert.Equal(t, 73.0, *panel.BatteryPercent)
+	assert.Equal(t, 20.0, *panel.BackupReservePercent)
+	assert.Nil(t, panel.ChargeLimitPercent)
+	require.Len(t, panel.Circuits, SmartHomePanelCircuits)
+
+	kitchen := panel.Circuits[0]
+	assert.Equal(t, "Kitchen", *kitchen.Name)
+	assert.Equal(t, 120.5, *kitchen.PowerWatts)
+	assert.Equal(t, "manual", *kitchen.Mode)
+	assert.Equal(t, "battery", *kitchen.Source)
+	assert.Equal(t, 2, *kitchen.Priority)
+	assert.True(t, *kitchen.BackupEnabled)
+
+	assert.Equal(t, "grid", *panel.Circuits[1].Source)
+	assert.Nil(t, panel.Circuits[1].Name)
+	assert.Equal(t, 9, panel.Circuits[9].Circuit)
+	assert.Nil(t, panel.Circuits[9].PowerWatts, "circuits the panel doesn't report are null")
+}
+
+func TestDecode_UnknownModels(t *testing.T) {
+	status, err := Decode("XX01", map[string]interface{}{"pd.soc": 50.0})
+	require.NoError(t, err)
+	assert.Equal(t, "Unknown", status.Model)
+	assert.Equal(t, catalog.FamilyPowerStation, status.Family, "power station parameters are recognized")
+	assert.Equal(t, 50.0, *status.PowerStation.Battery.StateOfChargePercent)
+
+	_, err = Decode("XX01", map[string]interface{}{"20_1.watts": 5.0})
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
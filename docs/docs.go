@@ -15,25 +15,114 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/devices": {
+        "/api/audit": {
             "get": {
-                "description": "Returns a list of all devices associated with the user",
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Returns the mutating calls, newest first. Every entry has the hashed identity of the caller, the device, the request body, the responses of the Ecoflow cloud, the latency and the outcome. The audit log is protected with the audit token.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Devices"
+                    "Audit"
+                ],
+                "summary": "Query the audit log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hashed identity of the caller",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device Serial Number",
+                        "name": "serial_number",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "succeeded",
+                            "rejected",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Outcome of the call",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "PUT",
+                        "description": "HTTP method",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "/api/power_station/{serial_number}/out/ac",
+                        "description": "Route pattern",
+                        "name": "route",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the range (RFC 3339), inclusive",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the range (RFC 3339), exclusive",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of entries, 100 by default and at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get a list of devices",
                 "responses": {
                     "200": {
-                        "description": "List of devices retrieved successfully",
+                        "description": "Entries retrieved successfully",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/audit.Entry"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Bearer token is missing or invalid",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Error retrieving device list",
+                        "description": "Error reading the audit log",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -41,34 +130,87 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/devices/{serial_number}/parameters": {
+        "/api/audit/export": {
             "get": {
-                "description": "Retrieves all available parameters for a device using its serial number",
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Streams the mutating calls selected by the filter as JSON Lines, oldest first. The audit log is protected with the audit token.",
                 "produces": [
-                    "application/json"
+                    "application/x-ndjson"
                 ],
                 "tags": [
-                    "Devices"
+                    "Audit"
                 ],
-                "summary": "Get all parameters for a device",
+                "summary": "Export the audit log",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hashed identity of the caller",
+                        "name": "actor",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Device Serial Number",
                         "name": "serial_number",
-                        "in": "path",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "succeeded",
+                            "rejected",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Outcome of the call",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "PUT",
+                        "description": "HTTP method",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "/api/power_station/{serial_number}/out/ac",
+                        "description": "Route pattern",
+                        "name": "route",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the range (RFC 3339), inclusive",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the range (RFC 3339), exclusive",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Parameters retrieved successfully",
+                        "description": "One audit.Entry per line",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SuccessResponse"
+                            "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "Error retrieving device parameters",
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Bearer token is missing or invalid",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -76,52 +218,49 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/devices/{serial_number}/parameters/query": {
-            "post": {
-                "description": "Queries specific parameters for a device using its serial number",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/devices": {
+            "get": {
+                "description": "Returns a list of all devices associated with the user",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Devices"
                 ],
-                "summary": "Query specific parameters for a device",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Device Serial Number",
-                        "name": "serial_number",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "List of parameters to query",
-                        "name": "parameters",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.QueryParametersRequest"
-                        }
-                    }
-                ],
+                "summary": "Get a list of devices",
                 "responses": {
                     "200": {
-                        "description": "Requested parameters retrieved successfully",
+                        "description": "List of devices retrieved successfully",
                         "schema": {
                             "$ref": "#/definitions/handlers.SuccessResponse"
                         }
                     },
-                    "400": {
-                        "description": "Error Invalid JSON Body",
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Error retrieving device parameters",
+                        "description": "Error retrieving device list",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -129,52 +268,125 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/power_station/{serial_number}/car-input": {
-            "put": {
-                "description": "Allows setting the car input charging current (in amps) for a specific power station identified by its serial number.",
-                "consumes": [
+        "/api/devices/{serial_number}/capabilities": {
+            "get": {
+                "description": "Returns the model of a device derived from its serial number, the commands it supports and the valid ranges and values of their fields. Commands that are not listed are rejected with 422 before they reach the Ecoflow API. Devices of unknown models have no commands listed, the commands of their family are accepted with the widest ranges. The Ecoflow API is not called.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "Devices"
+                ],
+                "summary": "Get the capabilities of a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device Serial Number",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Capabilities of the device",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.CapabilitiesResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/devices/{serial_number}/history": {
+            "get": {
+                "description": "Returns the recorded parameters of a device between from and to, aggregated per step with min, max and avg. Only the parameters of the devices of vault accounts are recorded, the history is queried with an API key of the account. Steps without samples are omitted.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Power Station"
+                    "Devices"
                 ],
-                "summary": "Set the car input charging current for a power station.",
+                "summary": "Get the history of device parameters",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Serial Number of the Power Station",
+                        "description": "Device Serial Number",
                         "name": "serial_number",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Car Input Charging Request Body",
-                        "name": "requestBody",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.InputAmpsRequest"
-                        }
+                        "type": "string",
+                        "example": "pd.soc,pd.wattsInSum",
+                        "description": "Comma separated parameters, all recorded parameters by default",
+                        "name": "params",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the range (RFC 3339), 24 hours before to by default. It is clamped to the retention of the history",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the range (RFC 3339), now by default",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "15m",
+                        "description": "Aggregation step as a Go duration, 5m by default",
+                        "name": "step",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully set the car input current",
+                        "description": "History retrieved successfully",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.HistoryResponse"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
-                        "description": "Invalid request parameters or JSON body",
+                        "description": "Invalid range, step or parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error occurred while processing the request",
+                        "description": "Error reading the history",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -182,52 +394,70 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/power_station/{serial_number}/charging-speed": {
-            "put": {
-                "description": "Allows setting the charging speed in watts for a specific power station identified by its serial number.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/devices/{serial_number}/parameters": {
+            "get": {
+                "description": "Retrieves all available parameters for a device using its serial number. Devices ingested from the Ecoflow MQTT broker are served from the pushed parameters while they are fresh, the response then has the X-Data-Source: mqtt and Last-Modified headers.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Power Station"
+                    "Devices"
                 ],
-                "summary": "Set the charging speed (in watts) for a power station.",
+                "summary": "Get all parameters for a device",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Serial Number of the Power Station",
+                        "description": "Device Serial Number",
                         "name": "serial_number",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Charging Speed Request Body",
-                        "name": "requestBody",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.SetChargingSpeedRequest"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully set the charging speed",
+                        "description": "Parameters retrieved successfully",
                         "schema": {
                             "$ref": "#/definitions/handlers.SuccessResponse"
                         }
                     },
-                    "400": {
-                        "description": "Invalid request parameters or JSON body",
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error occurred while processing the request",
+                        "description": "Error retrieving device parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -235,9 +465,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/power_station/{serial_number}/out/ac": {
-            "put": {
-                "description": "Enables or disables the AC output switch for the power station with additional settings, like XBoost state, output frequency, and voltage.",
+        "/api/devices/{serial_number}/parameters/query": {
+            "post": {
+                "description": "Queries specific parameters for a device using its serial number",
                 "consumes": [
                     "application/json"
                 ],
@@ -245,36 +475,266 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Power Station"
+                    "Devices"
                 ],
-                "summary": "Enable/Disable AC Output with settings",
+                "summary": "Query specific parameters for a device",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Serial number of the power station",
+                        "description": "Device Serial Number",
                         "name": "serial_number",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Request body containing AC state, XBoost state, output frequency, and output voltage",
-                        "name": "requestBody",
+                        "description": "List of parameters to query",
+                        "name": "parameters",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.EnableAcRequest"
+                            "$ref": "#/definitions/handlers.QueryParametersRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully toggled AC output state with defined settings",
+                        "description": "Requested parameters retrieved successfully",
                         "schema": {
                             "$ref": "#/definitions/handlers.SuccessResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid input or request",
+                        "description": "Error Invalid JSON Body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error retrieving device parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/devices/{serial_number}/status": {
+            "get": {
+                "description": "Returns the status of a device decoded from all its parameters: battery, input and output power per port, temperatures and switch states in SI units. The model is derived from the serial number, the family section matching the model is set. Values the device doesn't report are null. The status is versioned, see the version field.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Devices"
+                ],
+                "summary": "Get the typed status of a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device Serial Number",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Status retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/devicestatus.Status"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Typed status is not available for the device model",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error retrieving device parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/devices/{serial_number}/stream": {
+            "get": {
+                "description": "Streams the parameters of a device as Server-Sent Events. The stream starts with a \"snapshot\" event with all parameters, followed by \"delta\" events with the changed (\"params\") and removed (\"removed\") parameters. \"error\" events are sent when the parameters can't be read. A comment line is sent every heartbeat interval. Clients that reconnect with the Last-Event-ID header receive the events they missed, or a new snapshot if the events are no longer available.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Devices"
+                ],
+                "summary": "Stream device parameters",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device Serial Number",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last event received, to resume the stream",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of snapshot, delta and error events",
+                        "schema": {
+                            "$ref": "#/definitions/telemetry.Event"
+                        }
+                    },
+                    "503": {
+                        "description": "Server is shutting down",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/bulk": {
+            "post": {
+                "description": "Sends the command, e.g. out/ac, with the same payload to the listed power stations or to the power stations of the account matching the selector. The commands run concurrently, the result of every device is returned in the order of the devices. Every command counts against the rate limit. With stop_on_failure the devices that were not started when a command failed are skipped.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Send a command to many power stations",
+                "parameters": [
+                    {
+                        "description": "Request body containing the command and the devices",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BulkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Results of the devices, also if commands failed",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.BulkResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -284,13 +744,31 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/api/power_station/{serial_number}/out/car": {
+        "/api/power_station/{serial_number}/battery/charge_limit": {
             "put": {
-                "description": "Enables or disables the car charger for the power station based on the provided state (on/off).",
+                "description": "Sets the state of charge in percent the power station stops charging at, from 50 to 100.",
                 "consumes": [
                     "application/json"
                 ],
@@ -300,7 +778,7 @@ const docTemplate = `{
                 "tags": [
                     "Power Station"
                 ],
-                "summary": "Enable/Disable Car Charger",
+                "summary": "Set the charge limit of the power station",
                 "parameters": [
                     {
                         "type": "string",
@@ -310,18 +788,18 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Request body containing the state",
+                        "description": "Request body containing the percent",
                         "name": "requestBody",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.ChangeStateRequest"
+                            "$ref": "#/definitions/handlers.BatteryLimitRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully toggled car charger state",
+                        "description": "Successfully set the charge limit",
                         "schema": {
                             "$ref": "#/definitions/handlers.SuccessResponse"
                         }
@@ -332,18 +810,60 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/api/power_station/{serial_number}/out/dc": {
+        "/api/power_station/{serial_number}/battery/discharge_limit": {
             "put": {
-                "description": "Enables or disables the DC output switch for the power station based on the provided state (on/off).",
+                "description": "Sets the state of charge in percent the power station stops discharging at, from 0 to 30.",
                 "consumes": [
                     "application/json"
                 ],
@@ -353,7 +873,7 @@ const docTemplate = `{
                 "tags": [
                     "Power Station"
                 ],
-                "summary": "Enable/Disable DC Output",
+                "summary": "Set the discharge limit of the power station",
                 "parameters": [
                     {
                         "type": "string",
@@ -363,18 +883,18 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Request body containing the state",
+                        "description": "Request body containing the percent",
                         "name": "requestBody",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.ChangeStateRequest"
+                            "$ref": "#/definitions/handlers.BatteryLimitRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully toggled DC output state",
+                        "description": "Successfully set the discharge limit",
                         "schema": {
                             "$ref": "#/definitions/handlers.SuccessResponse"
                         }
@@ -385,18 +905,60 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/api/power_station/{serial_number}/standby": {
+        "/api/power_station/{serial_number}/beep": {
             "put": {
-                "description": "Allows setting standby time and standby type for a specific power station identified by its serial number.",
+                "description": "Switches the beeps of the power station on or off, off is the silent mode of the Ecoflow app.",
                 "consumes": [
                     "application/json"
                 ],
@@ -406,111 +968,5221 @@ const docTemplate = `{
                 "tags": [
                     "Power Station"
                 ],
-                "summary": "Set standby settings for a power station.",
+                "summary": "Switch the beeper of the power station on or off",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Serial Number of the Power Station",
+                        "description": "Serial number of the power station",
                         "name": "serial_number",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Standby Request Body",
+                        "description": "Request body containing the state",
                         "name": "requestBody",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.StandByRequest"
+                            "$ref": "#/definitions/handlers.ChangeStateRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully set the standby settings",
+                        "description": "Successfully switched the beeper",
                         "schema": {
                             "$ref": "#/definitions/handlers.SuccessResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request parameters or JSON body",
+                        "description": "Invalid input or request",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error occurred while processing the request",
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
-                    }
-                }
-            }
-        }
-    },
-    "definitions": {
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/car-input": {
+            "put": {
+                "description": "Allows setting the car input charging current (in amps) for a specific power station identified by its serial number.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Set the car input charging current for a power station.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial Number of the Power Station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Car Input Charging Request Body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.InputAmpsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the car input current",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request parameters or JSON body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred while processing the request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/charging-speed": {
+            "put": {
+                "description": "Allows setting the charging speed in watts for a specific power station identified by its serial number.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Set the charging speed (in watts) for a power station.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial Number of the Power Station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Charging Speed Request Body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SetChargingSpeedRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the charging speed",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request parameters or JSON body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred while processing the request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/generator": {
+            "put": {
+                "description": "Sets the state of charge in percent the power station starts the Smart Generator at (0-30) and stops it at (50-100). Only the DELTA models control a Smart Generator. The start and stop levels are two commands sent one after the other, the change is not atomic: if the stop level is rejected the start level is already set, the applied detail of the error tells which levels were set.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Set the Smart Generator auto start and stop of the power station",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the power station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the start and stop state of charge",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.GeneratorRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the start and stop state of charge",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.GeneratorResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/input/ac/mode": {
+            "put": {
+                "description": "Sets the AC charging power to the lowest (slow) or highest (fast) charging power of the model, see the capabilities of the device. Paused AC charging stays paused. Models that are not in the catalog are rejected, their charging power is unknown.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Switch AC charging of the power station between slow and fast",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the power station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the mode",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AcChargeModeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully switched the AC charging mode",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/input/ac/pause": {
+            "put": {
+                "description": "Pauses or resumes AC charging while the charging power is kept. The power station resumes charging when it is plugged in again and when the charging speed is set.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Pause or resume AC charging of the power station",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the power station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing whether AC charging is paused",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AcChargePauseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully paused or resumed AC charging",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/input/pv_type": {
+            "put": {
+                "description": "Sets whether the PV inputs detect the charge type (auto), charge from solar panels (mppt) or from a DC adapter (adapter).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Set the PV charge type of the power station",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the power station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the charge types",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PvChargeTypeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the PV charge type",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/out/ac": {
+            "put": {
+                "description": "Enables or disables the AC output switch for the power station with additional settings, like XBoost state, output frequency, and voltage.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Enable/Disable AC Output with settings",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the power station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing AC state, XBoost state, output frequency, and output voltage",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.EnableAcRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully toggled AC output state with defined settings",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/out/car": {
+            "put": {
+                "description": "Enables or disables the car charger for the power station based on the provided state (on/off).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Enable/Disable Car Charger",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the power station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the state",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ChangeStateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully toggled car charger state",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/out/dc": {
+            "put": {
+                "description": "Enables or disables the DC output switch for the power station based on the provided state (on/off).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Enable/Disable DC Output",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the power station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the state",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ChangeStateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully toggled DC output state",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/screen/brightness": {
+            "put": {
+                "description": "Sets the brightness level of the screen, from 0 to 3, the screen timeout is kept.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Set the screen brightness of the power station",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the power station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the brightness level",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ScreenBrightnessRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the screen brightness",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/power_station/{serial_number}/standby": {
+            "put": {
+                "description": "Allows setting standby time and standby type for a specific power station identified by its serial number.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Power Station"
+                ],
+                "summary": "Set standby settings for a power station.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial Number of the Power Station",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Standby Request Body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StandByRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the standby settings",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request parameters or JSON body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred while processing the request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/powerstream/{serial_number}/battery/lower_limit": {
+            "put": {
+                "description": "Sets the state of charge in percent the PowerStream stops discharging the battery at, from 1 to 30.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PowerStream"
+                ],
+                "summary": "Set the lower battery limit of the PowerStream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the PowerStream",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the limit",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatteryLimitRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the lower battery limit",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/powerstream/{serial_number}/battery/upper_limit": {
+            "put": {
+                "description": "Sets the state of charge in percent the PowerStream stops charging the battery at, from 70 to 100.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PowerStream"
+                ],
+                "summary": "Set the upper battery limit of the PowerStream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the PowerStream",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the limit",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatteryLimitRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the upper battery limit",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/powerstream/{serial_number}/brightness": {
+            "put": {
+                "description": "Sets the brightness of the LED indicator of the PowerStream, from 0 (off) to 1023.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PowerStream"
+                ],
+                "summary": "Set the LED brightness of the PowerStream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the PowerStream",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the brightness",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BrightnessRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the brightness",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/powerstream/{serial_number}/custom_load": {
+            "put": {
+                "description": "Sets the power in watts the PowerStream feeds into the home, from 0 to 600.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PowerStream"
+                ],
+                "summary": "Set the custom load power of the PowerStream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the PowerStream",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the custom load power",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CustomLoadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the custom load power",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/powerstream/{serial_number}/supply_priority": {
+            "put": {
+                "description": "Sets whether the PowerStream powers the load first (supply) or charges the battery first (battery).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PowerStream"
+                ],
+                "summary": "Set the power supply priority of the PowerStream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the PowerStream",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the priority",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SupplyPriorityRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the supply priority",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/rules": {
+            "get": {
+                "description": "Returns the rules of the vault account of the API key with their state.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "List rules",
+                "responses": {
+                    "200": {
+                        "description": "Rules of the account",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/rules.Rule"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a rule that sends a power station command, e.g. out/ac, with the payload to the devices when the condition over the parameters of the device becomes true. The conditions are validated, the command is validated against the capabilities of the devices.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Create a rule",
+                "parameters": [
+                    {
+                        "description": "Request body containing the rule",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rule created",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/rules.Rule"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the rule",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/rules/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Get a rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/rules.Rule"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replaces the rule and arms it again. The cooldown of an earlier firing still applies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Update a rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the rule",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rule updated",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/rules.Rule"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the rule",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the rule and its evaluation log.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Delete a rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rule deleted",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the rules",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/rules/{id}/evaluations": {
+            "get": {
+                "description": "Returns the latest logged evaluations of the rule, newest first: when it fired, with the response of the command for every device, would have fired in dry-run mode, was held back by the cooldown, was armed again, or couldn't be evaluated because the parameters were not available.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Get the evaluations of a rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Evaluations of the rule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/rules.Evaluation"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules": {
+            "get": {
+                "description": "Returns the schedules of the vault account of the API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "List schedules",
+                "responses": {
+                    "200": {
+                        "description": "Schedules of the account",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/handlers.ScheduleResponse"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a schedule that sends a power station command, e.g. out/ac, with the payload to the devices at the times of the cron expression. The command is validated against the capabilities of the devices. Only occurrences after the creation are run.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Create a schedule",
+                "parameters": [
+                    {
+                        "description": "Request body containing the schedule",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Schedule created",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.ScheduleResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the schedule",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Get a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Schedule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.ScheduleResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Schedule not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replaces the schedule. Occurrences before the update are not run anymore, regardless of the missed runs policy.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Update a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the schedule",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Schedule updated",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.ScheduleResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Schedule not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the schedule",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the schedule and its runs.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Delete a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Schedule deleted",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Schedule not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the schedules",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/runs": {
+            "get": {
+                "description": "Returns the latest runs of the schedule, newest first, with the response of the command for every device. Missed occurrences are listed as missed runs.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Get the runs of a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Runs of the schedule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/schedule.Run"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Schedule not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/smart_home_panel/{serial_number}/backup_reserve": {
+            "put": {
+                "description": "Sets the state of charge the batteries are not discharged below, it is kept for outages (0-30), and the state of charge they are charged to (50-100).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Home Panel"
+                ],
+                "summary": "Set the emergency backup reserve",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the Smart Home Panel",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the reserve and the charge limit",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BackupReserveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the backup reserve",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/smart_home_panel/{serial_number}/charging_windows/{window}": {
+            "put": {
+                "description": "Creates or replaces the grid charging window with the index (0-9). The panel charges the batteries from the grid every day between start and end.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Home Panel"
+                ],
+                "summary": "Configure a grid charging window",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the Smart Home Panel",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Index of the window, from 0 to 9",
+                        "name": "window",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the window",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ChargingWindowRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully configured the window",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/smart_home_panel/{serial_number}/circuits": {
+            "get": {
+                "description": "Lists the 10 load circuits of the Smart Home Panel with their name, load, power source and backup priority. Values the panel doesn't report are null.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Home Panel"
+                ],
+                "summary": "List the circuits of the Smart Home Panel",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the Smart Home Panel",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Circuits of the panel",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.CircuitsResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device is not a Smart Home Panel",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/smart_home_panel/{serial_number}/circuits/priority": {
+            "put": {
+                "description": "Sets the order in which the circuits are powered by the batteries during an outage. The panel configures all circuits at once, so the request must contain every circuit (0-9) exactly once with a unique priority from 1 to 10.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Home Panel"
+                ],
+                "summary": "Set the backup priority of the circuits",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the Smart Home Panel",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the priorities of all circuits",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CircuitPriorityRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the priorities",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/smart_home_panel/{serial_number}/circuits/{circuit}/source": {
+            "put": {
+                "description": "Powers the circuit (0-9) from the grid or the battery, or lets the panel switch the source automatically.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Home Panel"
+                ],
+                "summary": "Switch a circuit between grid and battery",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the Smart Home Panel",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Circuit, from 0 to 9",
+                        "name": "circuit",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the source",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CircuitSourceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully switched the circuit",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/smart_plug/{serial_number}/brightness": {
+            "put": {
+                "description": "Sets the brightness of the LED indicator of the smart plug, from 0 (off) to 1023.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Plug"
+                ],
+                "summary": "Set the LED brightness of the smart plug",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the smart plug",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the brightness",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BrightnessRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the brightness",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/smart_plug/{serial_number}/max_power": {
+            "put": {
+                "description": "Sets the load in watts above which the smart plug switches its relay off, from 1 to 2500.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Plug"
+                ],
+                "summary": "Set the max-power watchdog of the smart plug",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the smart plug",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the maximum load",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SmartPlugMaxPowerRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully set the maximum load",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/smart_plug/{serial_number}/relay": {
+            "put": {
+                "description": "Switches the relay of the smart plug, and with it the connected load, on or off.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Plug"
+                ],
+                "summary": "Switch the smart plug on/off",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the smart plug",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the state",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ChangeStateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully switched the relay",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/smart_plug/{serial_number}/tasks/{task_index}": {
+            "put": {
+                "description": "Creates or replaces the scheduled task with the index (0-9). The task switches the relay on or off at the time of day on the given days.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Plug"
+                ],
+                "summary": "Schedule the smart plug on/off",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the smart plug",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Index of the task, from 0 to 9",
+                        "name": "task_index",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the task",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SmartPlugTaskRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully scheduled the task",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the scheduled task with the index (0-9).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Smart Plug"
+                ],
+                "summary": "Delete a scheduled task of the smart plug",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Serial number of the smart plug",
+                        "name": "serial_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Index of the task, from 0 to 9",
+                        "name": "task_index",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully deleted the task",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Ecoflow rejected the access key or secret key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Device is not linked to the Ecoflow account",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Device is offline",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Device model doesn't support the command",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ecoflow API returned an unexpected response",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Ecoflow API did not respond in time",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/status/upstream": {
+            "get": {
+                "description": "Returns the circuit breaker state of the account of the caller, if it called the Ecoflow API recently, i.e. its breaker is not closed or it was used within the breaker open duration. The breakers of other accounts are not listed. Accounts are identified by the vault account name or by a hash of the access key and the secret key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Status"
+                ],
+                "summary": "Get the Ecoflow API circuit breaker state",
+                "responses": {
+                    "200": {
+                        "description": "Circuit breaker state",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.UpstreamStatusResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks": {
+            "get": {
+                "description": "Returns the webhook subscriptions of the vault account of the API key. Their secrets are not returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "List webhook subscriptions",
+                "responses": {
+                    "200": {
+                        "description": "Subscriptions of the account",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/webhook.Subscription"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a subscription that sends the events of the devices of the account to the URL: devices going offline and online, the state of charge crossing the battery threshold, and fault codes turning non-zero and zero again. The payloads are signed with the secret, which is only returned by this request.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Create a webhook subscription",
+                "parameters": [
+                    {
+                        "description": "Request body containing the subscription",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Subscription created",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/webhook.Subscription"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the subscription",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks/{id}": {
+            "get": {
+                "description": "Returns the subscription without its secret.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get a webhook subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Subscription",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/webhook.Subscription"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replaces the subscription, the secret is kept if the request has none. Pending deliveries are sent to the new URL.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Update a webhook subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body containing the subscription",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Subscription updated",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/webhook.Subscription"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the subscription",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the subscription, its pending deliveries, its delivery log and its dead letters.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Delete a webhook subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Subscription deleted",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the subscriptions",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks/{id}/dead_letters": {
+            "get": {
+                "description": "Returns the deliveries of the subscription that failed every attempt, newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get the dead letters of a webhook subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Dead letters of the subscription",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/webhook.Delivery"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks/{id}/dead_letters/{delivery_id}/retry": {
+            "post": {
+                "description": "Removes the delivery from the dead letters and sends its event again as a new delivery with fresh attempts.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Retry a dead letter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Delivery ID of the dead letter",
+                        "name": "delivery_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "New pending delivery",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/webhook.Delivery"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription or dead letter not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error saving the delivery",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Returns the pending and the latest completed deliveries of the subscription, newest first, with the number of attempts and the result of the last attempt.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get the deliveries of a webhook subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deliveries of the subscription",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/webhook.Delivery"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Request is not authenticated with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/ws": {
+            "get": {
+                "description": "Upgrades the connection to a WebSocket. Clients send WebSocketRequest messages to subscribe to the parameters of several devices and to send power station commands, the server answers with WebSocketMessage messages. See the README for the protocol.",
+                "tags": [
+                    "Devices"
+                ],
+                "summary": "Multiplexed telemetry and commands over WebSocket",
+                "parameters": [
+                    {
+                        "description": "Message sent by the client",
+                        "name": "message",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebSocketRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Message sent by the server",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebSocketMessage"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "audit.Call": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "response": {
+                    "description": "Response is the CmdSetResponse of the Ecoflow cloud",
+                    "type": "object"
+                }
+            }
+        },
+        "audit.Entry": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "Actor is the hashed identity of the caller, see Actor",
+                    "type": "string"
+                },
+                "error_code": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "outcome": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "request": {
+                    "description": "Request is the request body, bodies that are not JSON are kept as a string",
+                    "type": "object"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "route": {
+                    "description": "Route is the route pattern of the call, e.g. /api/power_station/{serial_number}/out/ac",
+                    "type": "string"
+                },
+                "serial_number": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "upstream": {
+                    "description": "Upstream are the commands sent to the Ecoflow cloud for the call",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/audit.Call"
+                    }
+                }
+            }
+        },
+        "catalog.Command": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "ranges": {
+                    "description": "Ranges are the valid ranges of the numeric fields of the request.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/catalog.Range"
+                    }
+                },
+                "values": {
+                    "description": "Values are the valid values of the numeric fields of the request that only accept some values, e.g. voltages.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        }
+                    }
+                }
+            }
+        },
+        "catalog.Family": {
+            "type": "string",
+            "enum": [
+                "unknown",
+                "power_station",
+                "powerstream",
+                "smart_plug",
+                "smart_home_panel"
+            ],
+            "x-enum-varnames": [
+                "FamilyUnknown",
+                "FamilyPowerStation",
+                "FamilyPowerStream",
+                "FamilySmartPlug",
+                "FamilySmartHomePanel"
+            ]
+        },
+        "catalog.Model": {
+            "type": "object",
+            "properties": {
+                "commands": {
+                    "description": "Commands are the commands the model supports.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/catalog.Command"
+                    }
+                },
+                "family": {
+                    "$ref": "#/definitions/catalog.Family"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "description": "Prefix is the serial number prefix of the product.",
+                    "type": "string"
+                }
+            }
+        },
+        "catalog.Range": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "number"
+                },
+                "min": {
+                    "type": "number"
+                }
+            }
+        },
+        "devicestatus.PowerStation": {
+            "type": "object",
+            "properties": {
+                "battery": {
+                    "$ref": "#/definitions/devicestatus.PowerStationBattery"
+                },
+                "input": {
+                    "$ref": "#/definitions/devicestatus.PowerStationInput"
+                },
+                "output": {
+                    "$ref": "#/definitions/devicestatus.PowerStationOutput"
+                },
+                "switches": {
+                    "$ref": "#/definitions/devicestatus.PowerStationSwitches"
+                },
+                "temperatures": {
+                    "$ref": "#/definitions/devicestatus.PowerStationTemperatures"
+                }
+            }
+        },
+        "devicestatus.PowerStationBattery": {
+            "type": "object",
+            "properties": {
+                "charge_limit_percent": {
+                    "type": "number"
+                },
+                "charge_remaining_seconds": {
+                    "type": "number"
+                },
+                "current_amperes": {
+                    "type": "number"
+                },
+                "cycles": {
+                    "type": "integer"
+                },
+                "design_capacity_ampere_hours": {
+                    "type": "number"
+                },
+                "discharge_limit_percent": {
+                    "type": "number"
+                },
+                "discharge_remaining_seconds": {
+                    "type": "number"
+                },
+                "full_capacity_ampere_hours": {
+                    "type": "number"
+                },
+                "remaining_ampere_hours": {
+                    "type": "number"
+                },
+                "remaining_seconds": {
+                    "description": "RemainingSeconds is the time until the battery is full while charging or empty while discharging.",
+                    "type": "number"
+                },
+                "state_of_charge_percent": {
+                    "type": "number"
+                },
+                "state_of_health_percent": {
+                    "type": "number"
+                },
+                "voltage_volts": {
+                    "type": "number"
+                }
+            }
+        },
+        "devicestatus.PowerStationInput": {
+            "type": "object",
+            "properties": {
+                "ac_watts": {
+                    "type": "number"
+                },
+                "solar_watts": {
+                    "type": "number"
+                },
+                "total_watts": {
+                    "type": "number"
+                }
+            }
+        },
+        "devicestatus.PowerStationOutput": {
+            "type": "object",
+            "properties": {
+                "ac_frequency_hertz": {
+                    "type": "number"
+                },
+                "ac_voltage_volts": {
+                    "type": "number"
+                },
+                "ac_watts": {
+                    "type": "number"
+                },
+                "car_watts": {
+                    "type": "number"
+                },
+                "total_watts": {
+                    "type": "number"
+                },
+                "usb_c_watts": {
+                    "type": "number"
+                },
+                "usb_watts": {
+                    "type": "number"
+                }
+            }
+        },
+        "devicestatus.PowerStationSwitches": {
+            "type": "object",
+            "properties": {
+                "ac_enabled": {
+                    "type": "boolean"
+                },
+                "car_enabled": {
+                    "type": "boolean"
+                },
+                "dc_enabled": {
+                    "type": "boolean"
+                },
+                "xboost_enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "devicestatus.PowerStationTemperatures": {
+            "type": "object",
+            "properties": {
+                "battery_celsius": {
+                    "type": "number"
+                },
+                "inverter_celsius": {
+                    "type": "number"
+                },
+                "mppt_celsius": {
+                    "type": "number"
+                }
+            }
+        },
+        "devicestatus.PowerStream": {
+            "type": "object",
+            "properties": {
+                "battery": {
+                    "$ref": "#/definitions/devicestatus.PowerStreamBattery"
+                },
+                "inverter": {
+                    "$ref": "#/definitions/devicestatus.PowerStreamInverter"
+                },
+                "pv1": {
+                    "$ref": "#/definitions/devicestatus.PowerStreamPV"
+                },
+                "pv2": {
+                    "$ref": "#/definitions/devicestatus.PowerStreamPV"
+                },
+                "settings": {
+                    "$ref": "#/definitions/devicestatus.PowerStreamSettings"
+                }
+            }
+        },
+        "devicestatus.PowerStreamBattery": {
+            "type": "object",
+            "properties": {
+                "current_amperes": {
+                    "type": "number"
+                },
+                "input_watts": {
+                    "description": "InputWatts is positive while the battery is discharged into the inverter and negative while it is charged.",
+                    "type": "number"
+                },
+                "state_of_charge_percent": {
+                    "type": "number"
+                },
+                "temperature_celsius": {
+                    "type": "number"
+                },
+                "voltage_volts": {
+                    "type": "number"
+                }
+            }
+        },
+        "devicestatus.PowerStreamInverter": {
+            "type": "object",
+            "properties": {
+                "current_amperes": {
+                    "type": "number"
+                },
+                "frequency_hertz": {
+                    "type": "number"
+                },
+                "output_watts": {
+                    "type": "number"
+                },
+                "temperature_celsius": {
+                    "type": "number"
+                },
+                "voltage_volts": {
+                    "type": "number"
+                }
+            }
+        },
+        "devicestatus.PowerStreamPV": {
+            "type": "object",
+            "properties": {
+                "current_amperes": {
+                    "type": "number"
+                },
+                "input_watts": {
+                    "type": "number"
+                },
+                "temperature_celsius": {
+                    "type": "number"
+                },
+                "voltage_volts": {
+                    "type": "number"
+                }
+            }
+        },
+        "devicestatus.PowerStreamSettings": {
+            "type": "object",
+            "properties": {
+                "battery_lower_limit_percent": {
+                    "type": "number"
+                },
+                "battery_upper_limit_percent": {
+                    "type": "number"
+                },
+                "custom_load_watts": {
+                    "type": "number"
+                },
+                "led_brightness_percent": {
+                    "type": "number"
+                },
+                "rated_power_watts": {
+                    "type": "number"
+                },
+                "supply_priority": {
+                    "description": "SupplyPriority is \"supply\" when the load is powered first, \"battery\" when the battery is charged first.",
+                    "type": "string"
+                }
+            }
+        },
+        "devicestatus.SmartHomePanel": {
+            "type": "object",
+            "properties": {
+                "backup_reserve_percent": {
+                    "description": "BackupReservePercent is the state of charge the batteries are not discharged below, it is kept for outages.",
+                    "type": "number"
+                },
+                "battery_percent": {
+                    "type": "number"
+                },
+                "charge_limit_percent": {
+                    "type": "number"
+                },
+                "circuits": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/devicestatus.SmartHomePanelCircuit"
+                    }
+                }
+            }
+        },
+        "devicestatus.SmartHomePanelCircuit": {
+            "type": "object",
+            "properties": {
+                "backup_enabled": {
+                    "type": "boolean"
+                },
+                "circuit": {
+                    "type": "integer"
+                },
+                "current_amperes": {
+                    "type": "number"
+                },
+                "mode": {
+                    "description": "Mode is auto when the panel switches the source of the circuit itself, manual when the source was set.",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "power_watts": {
+                    "type": "number"
+                },
+                "priority": {
+                    "description": "Priority is the order in which circuits are powered by the batteries during an outage, 1 is powered first.",
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "Source is grid, battery or off.",
+                    "type": "string"
+                }
+            }
+        },
+        "devicestatus.SmartPlug": {
+            "type": "object",
+            "properties": {
+                "current_amperes": {
+                    "type": "number"
+                },
+                "frequency_hertz": {
+                    "type": "number"
+                },
+                "led_brightness_percent": {
+                    "type": "number"
+                },
+                "max_power_watts": {
+                    "type": "number"
+                },
+                "power_watts": {
+                    "type": "number"
+                },
+                "switch_on": {
+                    "type": "boolean"
+                },
+                "temperature_celsius": {
+                    "type": "number"
+                },
+                "voltage_volts": {
+                    "type": "number"
+                }
+            }
+        },
+        "devicestatus.Status": {
+            "type": "object",
+            "properties": {
+                "family": {
+                    "$ref": "#/definitions/catalog.Family"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "power_station": {
+                    "$ref": "#/definitions/devicestatus.PowerStation"
+                },
+                "powerstream": {
+                    "$ref": "#/definitions/devicestatus.PowerStream"
+                },
+                "serial_number": {
+                    "type": "string"
+                },
+                "smart_home_panel": {
+                    "$ref": "#/definitions/devicestatus.SmartHomePanel"
+                },
+                "smart_plug": {
+                    "$ref": "#/definitions/devicestatus.SmartPlug"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.AcChargeModeRequest": {
+            "type": "object",
+            "properties": {
+                "mode": {
+                    "description": "Mode is slow to charge with the lowest or fast to charge with the highest AC charging power of the model",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.AcChargePauseRequest": {
+            "type": "object",
+            "properties": {
+                "paused": {
+                    "description": "Paused pauses AC charging while true, the power station resumes charging when it is plugged in again",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.BackupReserveRequest": {
+            "type": "object",
+            "properties": {
+                "charge_limit_percent": {
+                    "description": "ChargeLimitPercent is the state of charge the batteries are charged to, from 50 to 100",
+                    "type": "integer"
+                },
+                "reserve_percent": {
+                    "description": "ReservePercent is the state of charge kept for outages, from 0 to 30",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.BatteryLimitRequest": {
+            "type": "object",
+            "properties": {
+                "percent": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.BrightnessRequest": {
+            "type": "object",
+            "properties": {
+                "brightness": {
+                    "description": "Brightness of the LED indicator, from 0 (off) to 1023",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.BulkRequest": {
+            "type": "object",
+            "properties": {
+                "command": {
+                    "description": "Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Payload is the request body of the command",
+                    "type": "object"
+                },
+                "selector": {
+                    "description": "Selector selects the devices of the account the command is sent to",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/handlers.DeviceSelector"
+                        }
+                    ]
+                },
+                "serial_numbers": {
+                    "description": "SerialNumbers are the devices the command is sent to, either they or the selector must be set",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "stop_on_failure": {
+                    "description": "StopOnFailure skips the devices that were not started yet when a command fails",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.BulkResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.BulkResult"
+                    }
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.BulkResult": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "description": "Body is the response of the command, it is not set for skipped devices",
+                    "type": "object"
+                },
+                "result": {
+                    "description": "Result is succeeded, failed or skipped",
+                    "type": "string"
+                },
+                "serial_number": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is the HTTP status of the command, it is not set for skipped devices",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.CapabilitiesResponse": {
+            "type": "object",
+            "properties": {
+                "model": {
+                    "$ref": "#/definitions/catalog.Model"
+                },
+                "serial_number": {
+                    "type": "string"
+                }
+            }
+        },
         "handlers.ChangeStateRequest": {
             "type": "object",
             "properties": {
-                "state": {
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ChargingWindowRequest": {
+            "type": "object",
+            "properties": {
+                "charge_watts": {
+                    "description": "ChargeWatts is the power the batteries are charged with from the grid, from 1 to 7200",
+                    "type": "integer"
+                },
+                "enabled": {
+                    "description": "Enabled disables the window without removing it when false, defaults to true",
+                    "type": "boolean"
+                },
+                "end": {
+                    "type": "string"
+                },
+                "start": {
+                    "description": "Start and End of the window, HH:MM in the time zone of the panel. Windows may span midnight.",
+                    "type": "string"
+                },
+                "target_percent": {
+                    "description": "TargetPercent is the state of charge the batteries are charged to, from 50 to 100",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.CircuitPriority": {
+            "type": "object",
+            "properties": {
+                "backup_enabled": {
+                    "description": "BackupEnabled is false for circuits that are not powered by the batteries during an outage",
+                    "type": "boolean"
+                },
+                "circuit": {
+                    "type": "integer"
+                },
+                "priority": {
+                    "description": "Priority is the order in which the circuits are powered by the batteries during an outage, 1 is powered first",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.CircuitPriorityRequest": {
+            "type": "object",
+            "properties": {
+                "circuits": {
+                    "description": "Circuits contains every circuit exactly once",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.CircuitPriority"
+                    }
+                }
+            }
+        },
+        "handlers.CircuitSourceRequest": {
+            "type": "object",
+            "properties": {
+                "source": {
+                    "description": "Source is grid or battery to power the circuit from it, auto lets the panel switch the source",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.CircuitsResponse": {
+            "type": "object",
+            "properties": {
+                "circuits": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/devicestatus.SmartHomePanelCircuit"
+                    }
+                },
+                "serial_number": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.CustomLoadRequest": {
+            "type": "object",
+            "properties": {
+                "watts": {
+                    "description": "Watts the inverter feeds into the home, from 0 to 600 with a resolution of 0.1",
+                    "type": "number"
+                }
+            }
+        },
+        "handlers.DeviceSelector": {
+            "type": "object",
+            "properties": {
+                "model": {
+                    "description": "Model is the model name, e.g. DELTA 2, all power stations are selected if it is empty",
+                    "type": "string"
+                },
+                "online": {
+                    "description": "Online selects only the devices that are online",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.EnableAcRequest": {
+            "type": "object",
+            "properties": {
+                "ac_state": {
+                    "type": "string"
+                },
+                "out_freq": {
+                    "type": "integer"
+                },
+                "out_voltage": {
+                    "type": "integer"
+                },
+                "xboost_state": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ErrorField": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "details": {
+                    "description": "Optional"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/handlers.ErrorField"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.GeneratorRequest": {
+            "type": "object",
+            "properties": {
+                "start_percent": {
+                    "description": "StartPercent is the state of charge the Smart Generator is started at, from 0 to 30",
+                    "type": "integer"
+                },
+                "stop_percent": {
+                    "description": "StopPercent is the state of charge the Smart Generator is stopped at, from 50 to 100",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.GeneratorResponse": {
+            "type": "object",
+            "properties": {
+                "start": {
+                    "type": "object"
+                },
+                "stop": {
+                    "type": "object"
+                }
+            }
+        },
+        "handlers.HistoryResponse": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "serial_number": {
+                    "type": "string"
+                },
+                "series": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/history.Point"
+                        }
+                    }
+                },
+                "step_seconds": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.InputAmpsRequest": {
+            "type": "object",
+            "properties": {
+                "amps": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.PvChargeTypeRequest": {
+            "type": "object",
+            "properties": {
+                "type": {
+                    "description": "Type is the charge type of the PV input: auto, mppt or adapter",
+                    "type": "string"
+                },
+                "type2": {
+                    "description": "Type2 is the charge type of the second PV input of models that have two, it defaults to type",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.QueryParametersRequest": {
+            "type": "object",
+            "properties": {
+                "parameters": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.RuleActionRequest": {
+            "type": "object",
+            "properties": {
+                "command": {
+                    "description": "Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac",
+                    "type": "string",
+                    "example": "out/ac"
+                },
+                "payload": {
+                    "description": "Payload is the request body of the command",
+                    "type": "object"
+                },
+                "serial_numbers": {
+                    "description": "SerialNumbers are the devices the command is sent to, the evaluated device by default",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.RuleRequest": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/handlers.RuleActionRequest"
+                },
+                "clear": {
+                    "description": "Clear arms the rule again after it fired, by default when the condition is false",
+                    "type": "string",
+                    "example": "bms_bmsStatus.soc \u003e 30"
+                },
+                "condition": {
+                    "description": "Condition fires the rule when it becomes true",
+                    "type": "string",
+                    "example": "bms_bmsStatus.soc \u003c 20"
+                },
+                "cooldown_seconds": {
+                    "description": "CooldownSeconds is the minimum time between two firings of the rule",
+                    "type": "integer",
+                    "example": 600
+                },
+                "dry_run": {
+                    "description": "DryRun rules are evaluated and logged but don't send their command",
+                    "type": "boolean"
+                },
+                "enabled": {
+                    "description": "Enabled is true by default",
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "AC off on low battery"
+                },
+                "serial_number": {
+                    "description": "SerialNumber is the device whose parameters the conditions are evaluated over",
+                    "type": "string",
+                    "example": "R601ZEB4ZEAL0528"
+                }
+            }
+        },
+        "handlers.ScheduleRequest": {
+            "type": "object",
+            "properties": {
+                "command": {
+                    "description": "Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac",
+                    "type": "string",
+                    "example": "out/ac"
+                },
+                "cron": {
+                    "description": "Cron is a cron expression with minute, hour, day of month, month and day of week",
+                    "type": "string",
+                    "example": "0 23 * * *"
+                },
+                "enabled": {
+                    "description": "Enabled is true by default",
+                    "type": "boolean"
+                },
+                "missed_runs": {
+                    "description": "MissedRuns is skip (default) or run_once, see the README",
+                    "type": "string",
+                    "enum": [
+                        "skip",
+                        "run_once"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "AC off at night"
+                },
+                "payload": {
+                    "description": "Payload is the request body of the command",
+                    "type": "object"
+                },
+                "serial_numbers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "time_zone": {
+                    "description": "TimeZone is the IANA time zone of the cron expression, UTC by default",
+                    "type": "string",
+                    "example": "Europe/Berlin"
+                }
+            }
+        },
+        "handlers.ScheduleResponse": {
+            "type": "object",
+            "properties": {
+                "account": {
+                    "description": "Account is the vault account the command is sent for",
+                    "type": "string"
+                },
+                "command": {
+                    "description": "Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "cron": {
+                    "description": "Cron is the cron expression of the times the command is sent, see Cron",
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_scheduled_at": {
+                    "description": "LastScheduledAt is the time of the last occurrence that was run or missed, occurrences after it are due",
+                    "type": "string"
+                },
+                "missed_runs": {
+                    "description": "MissedRuns is the policy for occurrences that were missed, skip or run_once",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "next_run_at": {
+                    "description": "NextRunAt is the time of the next occurrence, it is not set for disabled schedules",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Payload is the request body of the command",
+                    "type": "object"
+                },
+                "serial_numbers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "time_zone": {
+                    "description": "TimeZone is the IANA time zone of the cron expression, e.g. Europe/Berlin",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ScreenBrightnessRequest": {
+            "type": "object",
+            "properties": {
+                "level": {
+                    "description": "Level is the brightness level of the screen, from 0 to 3",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.SetChargingSpeedRequest": {
+            "type": "object",
+            "properties": {
+                "watts": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.SmartPlugMaxPowerRequest": {
+            "type": "object",
+            "properties": {
+                "watts": {
+                    "description": "Watts is the load above which the smart plug switches off, from 1 to 2500",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.SmartPlugTaskRequest": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "description": "Days the task runs on (sun, mon, tue, wed, thu, fri, sat), every day if empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "enabled": {
+                    "description": "Enabled disables the task without deleting it when false, defaults to true",
+                    "type": "boolean"
+                },
+                "state": {
+                    "description": "State the relay is switched to, on or off",
+                    "type": "string"
+                },
+                "time": {
+                    "description": "Time of day in the time zone of the smart plug, HH:MM",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.StandByRequest": {
+            "type": "object",
+            "properties": {
+                "stand_by": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.SuccessResponse": {
+            "type": "object",
+            "properties": {
+                "data": {},
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.SupplyPriorityRequest": {
+            "type": "object",
+            "properties": {
+                "priority": {
+                    "description": "Priority is supply to power the load first or battery to charge the battery first",
                     "type": "string"
                 }
             }
         },
-        "handlers.EnableAcRequest": {
+        "handlers.UpstreamStatusResponse": {
             "type": "object",
             "properties": {
-                "ac_state": {
+                "breakers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/resilience.BreakerStatus"
+                    }
+                }
+            }
+        },
+        "handlers.WebSocketMessage": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "object"
+                },
+                "error": {
+                    "$ref": "#/definitions/handlers.ErrorField"
+                },
+                "event_id": {
+                    "description": "EventID, Time, Params and Removed describe snapshot and delta messages. Resync is set on snapshots sent after\ntelemetry messages were dropped because the client didn't read them fast enough.",
                     "type": "string"
                 },
-                "out_freq": {
-                    "type": "integer"
+                "id": {
+                    "type": "string"
                 },
-                "out_voltage": {
+                "params": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "resync": {
+                    "type": "boolean"
+                },
+                "serial_number": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status and Body are the HTTP status and response body of a command.",
                     "type": "integer"
                 },
-                "xboost_state": {
+                "time": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type is one of ack, result, error, snapshot, delta or telemetry_error.",
                     "type": "string"
                 }
             }
         },
-        "handlers.ErrorField": {
+        "handlers.WebSocketRequest": {
             "type": "object",
             "properties": {
-                "code": {
+                "api_key": {
                     "type": "string"
                 },
-                "details": {
-                    "description": "Optional"
+                "authorization": {
+                    "description": "Authorization, SecretToken and APIKey authenticate the connection when the headers can't be set, e.g. in\nbrowsers. They are only accepted in the first message.",
+                    "type": "string"
                 },
-                "message": {
+                "command": {
+                    "type": "string"
+                },
+                "device_type": {
+                    "description": "DeviceType and Command select the command route, e.g. \"power_station\" and \"out/ac\" for\nPUT /api/power_station/{serial_number}/out/ac. DeviceType defaults to power_station.",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID is copied to the ack, result or error message that answers the request.",
+                    "type": "string"
+                },
+                "params": {
+                    "description": "Params are the parameters to subscribe to or unsubscribe from: exact names or prefixes ending with \"*\", e.g.\n\"bms_bmsStatus.*\". An empty list subscribes to all parameters or unsubscribes from the device.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "secret_token": {
+                    "type": "string"
+                },
+                "serial_number": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type is one of auth, subscribe, unsubscribe or command.",
                     "type": "string"
                 }
             }
         },
-        "handlers.ErrorResponse": {
+        "handlers.WebhookRequest": {
             "type": "object",
             "properties": {
-                "error": {
-                    "$ref": "#/definitions/handlers.ErrorField"
+                "battery_threshold": {
+                    "description": "BatteryThreshold is the state of charge in percent the battery events are sent for",
+                    "type": "number",
+                    "example": 20
                 },
-                "success": {
+                "enabled": {
+                    "description": "Enabled is true by default",
                     "type": "boolean"
+                },
+                "events": {
+                    "description": "Events filters the events, all events are sent if it's empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string",
+                        "enum": [
+                            "device.offline",
+                            "device.online",
+                            "battery.below",
+                            "battery.above",
+                            "fault.raised",
+                            "fault.cleared"
+                        ]
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "On-call"
+                },
+                "secret": {
+                    "description": "Secret signs the payloads, a random secret is generated if it's empty. On update, the secret is kept if it's empty.",
+                    "type": "string"
+                },
+                "serial_numbers": {
+                    "description": "SerialNumbers filters the devices, the events of all devices are sent if it's empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "url": {
+                    "description": "URL receives the events as POST requests",
+                    "type": "string",
+                    "example": "https://example.com/ecoflow"
                 }
             }
         },
-        "handlers.InputAmpsRequest": {
+        "history.Point": {
             "type": "object",
             "properties": {
-                "amps": {
+                "avg": {
+                    "type": "number"
+                },
+                "count": {
                     "type": "integer"
+                },
+                "max": {
+                    "type": "number"
+                },
+                "min": {
+                    "type": "number"
+                },
+                "time": {
+                    "type": "string"
                 }
             }
         },
-        "handlers.QueryParametersRequest": {
+        "resilience.BreakerState": {
+            "type": "string",
+            "enum": [
+                "closed",
+                "open",
+                "half_open"
+            ],
+            "x-enum-comments": {
+                "StateClosed": "calls pass through",
+                "StateHalfOpen": "a single probe call is allowed to test the upstream",
+                "StateOpen": "calls are rejected until the open duration is over"
+            },
+            "x-enum-varnames": [
+                "StateClosed",
+                "StateOpen",
+                "StateHalfOpen"
+            ]
+        },
+        "resilience.BreakerStatus": {
             "type": "object",
             "properties": {
-                "parameters": {
+                "account": {
+                    "type": "string"
+                },
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "opened_at": {
+                    "type": "string"
+                },
+                "retry_after": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/resilience.BreakerState"
+                }
+            }
+        },
+        "rules.Action": {
+            "type": "object",
+            "properties": {
+                "command": {
+                    "description": "Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Payload is the request body of the command",
+                    "type": "object"
+                },
+                "serial_numbers": {
                     "type": "array",
                     "items": {
                         "type": "string"
@@ -518,31 +6190,268 @@ const docTemplate = `{
                 }
             }
         },
-        "handlers.SetChargingSpeedRequest": {
+        "rules.DeviceRun": {
             "type": "object",
             "properties": {
-                "watts": {
+                "body": {
+                    "type": "object"
+                },
+                "serial_number": {
+                    "type": "string"
+                },
+                "status": {
                     "type": "integer"
                 }
             }
         },
-        "handlers.StandByRequest": {
+        "rules.Evaluation": {
             "type": "object",
             "properties": {
-                "stand_by": {
+                "devices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rules.DeviceRun"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "result": {
+                    "description": "Result is fired, failed, dry_run, cooldown, cleared or unavailable",
+                    "type": "string"
+                },
+                "rule_id": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "values": {
+                    "description": "Values are the values of the parameters the conditions reference",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number"
+                    }
+                }
+            }
+        },
+        "rules.Rule": {
+            "type": "object",
+            "properties": {
+                "account": {
+                    "description": "Account is the vault account the parameters are read and the command is sent for",
+                    "type": "string"
+                },
+                "action": {
+                    "$ref": "#/definitions/rules.Action"
+                },
+                "clear": {
+                    "description": "Clear arms the rule again after it fired, !(Condition) if it's empty",
+                    "type": "string"
+                },
+                "condition": {
+                    "description": "Condition fires the rule, see Expression",
+                    "type": "string"
+                },
+                "cooldown_seconds": {
+                    "description": "CooldownSeconds is the minimum time between two firings of the rule",
                     "type": "integer"
                 },
-                "type": {
+                "created_at": {
+                    "type": "string"
+                },
+                "dry_run": {
+                    "description": "DryRun rules are evaluated and logged but don't send their command",
+                    "type": "boolean"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_fired_at": {
+                    "type": "string"
+                },
+                "last_result": {
+                    "description": "LastResult is the result of the latest logged evaluation",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "serial_number": {
+                    "description": "SerialNumber is the device whose parameters the conditions are evaluated over",
+                    "type": "string"
+                },
+                "triggered": {
+                    "description": "Triggered is true after the rule fired until the clear condition is true",
+                    "type": "boolean"
+                },
+                "updated_at": {
                     "type": "string"
                 }
             }
         },
-        "handlers.SuccessResponse": {
+        "schedule.DeviceRun": {
             "type": "object",
             "properties": {
-                "data": {},
-                "success": {
+                "body": {
+                    "type": "object"
+                },
+                "serial_number": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "schedule.Run": {
+            "type": "object",
+            "properties": {
+                "devices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/schedule.DeviceRun"
+                    }
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "missed": {
+                    "description": "Missed is the number of occurrences before ScheduledAt that were not run",
+                    "type": "integer"
+                },
+                "result": {
+                    "description": "Result is succeeded, failed if the command failed on a device, or missed if the occurrence was not run",
+                    "type": "string"
+                },
+                "schedule_id": {
+                    "type": "string"
+                },
+                "scheduled_at": {
+                    "description": "ScheduledAt is the time of the occurrence",
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "telemetry.Event": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "params": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "description": "LastStatusCode is the HTTP status of the last attempt, it is not set if the request failed",
+                    "type": "integer"
+                },
+                "next_attempt_at": {
+                    "description": "NextAttemptAt is the time of the next attempt of a pending delivery",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Payload is the request body, the event",
+                    "type": "object"
+                },
+                "status": {
+                    "description": "Status is pending, delivered or dead",
+                    "type": "string"
+                },
+                "subscription_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.Subscription": {
+            "type": "object",
+            "properties": {
+                "account": {
+                    "description": "Account is the vault account whose devices are watched",
+                    "type": "string"
+                },
+                "battery_threshold": {
+                    "description": "BatteryThreshold is the state of charge in percent the battery events are sent for, there are none if it's 0",
+                    "type": "number"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "enabled": {
                     "type": "boolean"
+                },
+                "events": {
+                    "description": "Events filters the events, all events are sent if it's empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "secret": {
+                    "description": "Secret signs the payloads, see Sign",
+                    "type": "string"
+                },
+                "serial_numbers": {
+                    "description": "SerialNumbers filters the devices, the events of all devices are sent if it's empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "description": "URL receives the events as POST requests",
+                    "type": "string"
                 }
             }
         }
@@ -554,6 +6463,12 @@ const docTemplate = `{
             "name": "Authorization",
             "in": "header"
         },
+        "X-API-Key": {
+            "description": "Server-issued API key, replaces Authorization and X-Secret-Token when the credential vault is enabled",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
         "X-Secret-Token": {
             "description": "Ecoflow Secret Token",
             "type": "apiKey",
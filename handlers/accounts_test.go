@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newAccountRouter returns a router with the routes of the handler created by newHandler, e.g. the schedule endpoints.
+// Requests with the X-API-Key header belong to the vault account named by the header, see accountRequest. The fake
+// Ecoflow API knows no devices.
+func newAccountRouter(t *testing.T, newHandler func(baseHandler *BaseHandler) RouteRegistrar) chi.Router {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(upstream.Close)
+	baseHandler := newUpstreamHandler(upstream)
+	baseHandler.Identity = func(r *http.Request) string {
+		if account := r.Header.Get(constants.HeaderXAPIKey); account != "" {
+			return service.VaultIdentity(account)
+		}
+		return service.HeaderIdentity(r)
+	}
+	router := chi.NewRouter()
+	newHandler(baseHandler).RegisterRoutes(router)
+	return router
+}
+
+// accountRequest sends the request for the vault account to the router, without an account it is sent with Ecoflow
+// keys.
+func accountRequest(router chi.Router, method, path, account, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if account != "" {
+		req.Header.Set(constants.HeaderXAPIKey, account)
+	} else {
+		req.Header.Set(constants.HeaderAuthorization, "Bearer access")
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+	"go-ecoflow-api-server/audit"
+	"go-ecoflow-api-server/constants"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditHandler struct {
+	*BaseHandler
+	log *audit.Log
+}
+
+func NewAuditHandler(baseHandler *BaseHandler, log *audit.Log) *AuditHandler {
+	return &AuditHandler{BaseHandler: baseHandler, log: log}
+}
+
+func (h *AuditHandler) RegisterRoutes(router chi.Router) {
+	router.Get("/api/audit", h.GetAuditLog())
+	router.Get("/api/audit/export", h.ExportAuditLog())
+}
+
+// GetAuditLog handles querying the audit log
+// @Summary Query the audit log
+// @Description Returns the mutating calls, newest first. Every entry has the hashed identity of the caller, the device, the request body, the responses of the Ecoflow cloud, the latency and the outcome. The audit log is protected with the audit token.
+// @Tags Audit
+// @Produce json
+// @Param actor query string false "Hashed identity of the caller"
+// @Param serial_number query string false "Device Serial Number"
+// @Param outcome query string false "Outcome of the call" Enums(succeeded, rejected, failed)
+// @Param method query string false "HTTP method" example(PUT)
+// @Param route query string false "Route pattern" example(/api/power_station/{serial_number}/out/ac)
+// @Param from query string false "Start of the range (RFC 3339), inclusive"
+// @Param to query string false "End of the range (RFC 3339), exclusive"
+// @Param limit query int false "Maximum number of entries, 100 by default and at most 1000"
+// @Success 200 {object} SuccessResponse{data=[]audit.Entry} "Entries retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid filter"
+// @Failure 401 {object} ErrorResponse "Bearer token is missing or invalid"
+// @Failure 500 {object} ErrorResponse "Error reading the audit log"
+// @Security Authorization
+// @Router /api/audit [get]
+func (h *AuditHandler) GetAuditLog() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, ok := h.auditFilter(w, r)
+		if !ok {
+			return
+		}
+		limit := defaultAuditLimit
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var err error
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxAuditLimit {
+				h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. limit must be between 1 and 1000", map[string]string{
+					"limit": value,
+				})
+				return
+			}
+		}
+
+		entries, err := h.log.Query(filter, limit)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusInternalServerError, constants.ErrReadAuditLog, "Failed to read the audit log", map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		h.RespondWithSuccess(w, entries)
+	}
+}
+
+// ExportAuditLog handles exporting the audit log
+// @Summary Export the audit log
+// @Description Streams the mutating calls selected by the filter as JSON Lines, oldest first. The audit log is protected with the audit token.
+// @Tags Audit
+// @Produce application/x-ndjson
+// @Param actor query string false "Hashed identity of the caller"
+// @Param serial_number query string false "Device Serial Number"
+// @Param outcome query string false "Outcome of the call" Enums(succeeded, rejected, failed)
+// @Param method query string false "HTTP method" example(PUT)
+// @Param route query string false "Route pattern" example(/api/power_station/{serial_number}/out/ac)
+// @Param from query string false "Start of the range (RFC 3339), inclusive"
+// @Param to query string false "End of the range (RFC 3339), exclusive"
+// @Success 200 {string} string "One audit.Entry per line"
+// @Failure 400 {object} ErrorResponse "Invalid filter"
+// @Failure 401 {object} ErrorResponse "Bearer token is missing or invalid"
+// @Security Authorization
+// @Router /api/audit/export [get]
+func (h *AuditHandler) ExportAuditLog() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, ok := h.auditFilter(w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		// the status is already sent when the log can't be read to the end, the export is cut off
+		if err := h.log.Export(w, filter); err != nil {
+			h.Logger.Error("Failed to export the audit log", "error", err)
+		}
+	}
+}
+
+// auditFilter parses the filter of the audit log from the query parameters.
+func (h *AuditHandler) auditFilter(w http.ResponseWriter, r *http.Request) (audit.Filter, bool) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Actor:        query.Get("actor"),
+		SerialNumber: query.Get("serial_number"),
+		Outcome:      query.Get("outcome"),
+		Method:       strings.ToUpper(query.Get("method")),
+		Route:        query.Get("route"),
+	}
+	if filter.Outcome != "" && !slices.Contains(audit.Outcomes, filter.Outcome) {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. Unknown outcome", map[string]string{
+			"outcome":  filter.Outcome,
+			"outcomes": strings.Join(audit.Outcomes, ","),
+		})
+		return audit.Filter{}, false
+	}
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		var err error
+		if *bound.value, err = parseTime(query.Get(bound.name), time.Time{}); err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. "+bound.name+" must be an RFC 3339 time", map[string]string{
+				bound.name: query.Get(bound.name),
+			})
+			return audit.Filter{}, false
+		}
+	}
+	return filter, true
+}
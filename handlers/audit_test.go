@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/audit"
+	"go-ecoflow-api-server/constants"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuditRouter(t *testing.T) chi.Router {
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = log.Close() })
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, entry := range []audit.Entry{
+		{Actor: audit.Actor("vault:home"), Method: http.MethodPut, SerialNumber: "R601ZEB4ZEAL0528", Outcome: audit.OutcomeSucceeded},
+		{Actor: audit.Actor("vault:home"), Method: http.MethodPut, SerialNumber: "R331ZEB4ZEAL0528", Outcome: audit.OutcomeRejected},
+		{Actor: audit.Actor("vault:office"), Method: http.MethodPost, Outcome: audit.OutcomeFailed},
+	} {
+		entry.Time = start.Add(time.Duration(i) * time.Minute)
+		_, err = log.Append(entry)
+		require.NoError(t, err)
+	}
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(upstream.Close)
+	router := chi.NewRouter()
+	NewAuditHandler(newUpstreamHandler(upstream), log).RegisterRoutes(router)
+	return router
+}
+
+func TestAuditHandler_GetAuditLog(t *testing.T) {
+	router := newAuditRouter(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "newest first", query: "", expected: []string{"", "R331ZEB4ZEAL0528", "R601ZEB4ZEAL0528"}},
+		{name: "limit", query: "?limit=1", expected: []string{""}},
+		{name: "actor", query: "?actor=" + audit.Actor("vault:home"), expected: []string{"R331ZEB4ZEAL0528", "R601ZEB4ZEAL0528"}},
+		{name: "serial number and outcome", query: "?serial_number=R601ZEB4ZEAL0528&outcome=succeeded", expected: []string{"R601ZEB4ZEAL0528"}},
+		{name: "method", query: "?method=post", expected: []string{""}},
+		{name: "range", query: "?from=2025-03-01T12:01:00Z&to=2025-03-01T12:02:00Z", expected: []string{"R331ZEB4ZEAL0528"}},
+		{name: "no match", query: "?outcome=failed&method=PUT", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit"+tt.query, nil))
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var response struct {
+				Data []audit.Entry `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			serialNumbers := make([]string, 0, len(response.Data))
+			for _, entry := range response.Data {
+				serialNumbers = append(serialNumbers, entry.SerialNumber)
+			}
+			assert.Equal(t, tt.expected, serialNumbers)
+		})
+	}
+}
+
+func TestAuditHandler_ValidatesFilters(t *testing.T) {
+	router := newAuditRouter(t)
+
+	for _, query := range []string{"?outcome=unknown", "?from=yesterday", "?to=2025-03-01", "?limit=0", "?limit=1001"} {
+		t.Run(query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit"+query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+constants.ErrInvalidParameters+`"`)
+		})
+	}
+}
+
+func TestAuditHandler_ExportAuditLog(t *testing.T) {
+	router := newAuditRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/export?actor="+audit.Actor("vault:home"), nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"serial_number":"R601ZEB4ZEAL0528"`, "oldest first")
+	assert.Contains(t, lines[1], `"serial_number":"R331ZEB4ZEAL0528"`)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/export?outcome=unknown", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
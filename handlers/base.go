@@ -1,20 +1,55 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/httplog/v2"
 	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/audit"
+	"go-ecoflow-api-server/catalog"
 	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/metrics"
+	"go-ecoflow-api-server/resilience"
+	"go-ecoflow-api-server/service"
+	"go-ecoflow-api-server/telemetry"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// StatusClientClosedRequest is returned when the client went away before the upstream call finished (nginx convention).
+const StatusClientClosedRequest = 499
+
 // ClientProvider is a function type that takes an HTTP request and returns an ecoflow client and an error.
 type ClientProvider func(r *http.Request) (*ecoflow.Client, error)
 
+// PushedState provides the device parameters pushed by the Ecoflow MQTT broker, see ingest.Store.
+type PushedState interface {
+	// Fresh returns the parameters of the device and the time of their last update if they are recent enough.
+	Fresh(account, sn string) (map[string]interface{}, time.Time, bool)
+	// Source returns the source of the pushed parameters of the device or nil if they are not available.
+	Source(account, sn string) telemetry.Source
+}
+
 // BaseHandler provides utility methods for HTTP response handling and client retrieval in API handlers.
 type BaseHandler struct {
 	Logger   *httplog.Logger
 	Provider ClientProvider
+	// UpstreamTimeout limits every call to the Ecoflow cloud, constants.UpstreamTimeout is used when it's zero.
+	UpstreamTimeout time.Duration
+	// Guard retries reads and tracks the circuit breakers of the accounts. Calls are executed directly when it's nil.
+	Guard *resilience.Guard
+	// Identity returns the account a request belongs to, it is used to select the circuit breaker.
+	Identity func(r *http.Request) string
+	// Pushed provides the parameters of the devices ingested from the Ecoflow MQTT broker. All parameters are read from
+	// the Ecoflow API when it's nil.
+	Pushed PushedState
+	// Metrics records the upstream calls and rate limit rejections, nothing is recorded when it's nil.
+	Metrics *metrics.Registry
 }
 
 // SuccessResponse represents a successful API response.
@@ -65,7 +100,12 @@ func (b *BaseHandler) RespondWithSuccess(w http.ResponseWriter, data interface{}
 }
 
 // RespondWithError sends a standardized error response as JSON, including the HTTP status code, error code, message, and details.
-func (b *BaseHandler) RespondWithError(w http.ResponseWriter, statusCode int, code, message string, details interface{}) {
+// Clients that accept application/problem+json get an RFC 7807 problem document instead, see ProblemDetails.
+func (b *BaseHandler) RespondWithError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string, details interface{}) {
+	if acceptsProblemJSON(r) {
+		b.respondWithProblem(w, r, statusCode, code, message, details)
+		return
+	}
 	response := ErrorResponse{
 		Success: false,
 		Error: ErrorField{
@@ -77,13 +117,325 @@ func (b *BaseHandler) RespondWithError(w http.ResponseWriter, statusCode int, co
 	b.RespondWithJSON(w, statusCode, response)
 }
 
+// UpstreamContext returns the context for a call to the Ecoflow cloud: the request context bounded by the upstream
+// timeout. The upstream call is therefore cancelled when the client disconnects, the request times out or the
+// upstream deadline is exceeded. The returned cancel function must always be called.
+func (b *BaseHandler) UpstreamContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return b.upstreamContext(r.Context())
+}
+
+func (b *BaseHandler) upstreamContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := b.UpstreamTimeout
+	if timeout <= 0 {
+		timeout = constants.UpstreamTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// upstreamErrorResponse describes the response sent for a class of upstream failures.
+type upstreamErrorResponse struct {
+	status  int
+	code    string
+	message string
+}
+
+// upstreamErrorResponses maps the classes of failed Ecoflow calls to HTTP statuses and error codes, so clients can tell
+// invalid keys from offline devices and Ecoflow outages.
+var upstreamErrorResponses = map[service.ErrorClass]upstreamErrorResponse{
+	service.ErrorClassInvalidRequest: {http.StatusBadRequest, constants.ErrUpstreamInvalidRequest, "Request was rejected by the Ecoflow client"},
+	service.ErrorClassUnauthorized:   {http.StatusUnauthorized, constants.ErrUpstreamUnauthorized, "Ecoflow rejected the access key or secret key"},
+	service.ErrorClassForbidden:      {http.StatusForbidden, constants.ErrUpstreamForbidden, "Ecoflow keys are not allowed to perform this action"},
+	service.ErrorClassDeviceNotFound: {http.StatusNotFound, constants.ErrDeviceNotFound, "Device is not linked to the Ecoflow account"},
+	service.ErrorClassDeviceOffline:  {http.StatusConflict, constants.ErrDeviceOffline, "Device is offline"},
+	service.ErrorClassBadResponse:    {http.StatusBadGateway, constants.ErrUpstreamBadResponse, "Ecoflow API returned an unexpected response"},
+	service.ErrorClassUnavailable:    {http.StatusServiceUnavailable, constants.ErrUpstreamOutage, "Ecoflow API is unavailable"},
+	service.ErrorClassTimeout:        {http.StatusGatewayTimeout, constants.ErrUpstreamTimeout, "Ecoflow API did not respond in time"},
+	service.ErrorClassCanceled:       {StatusClientClosedRequest, constants.ErrUpstreamCanceled, "Request was cancelled before Ecoflow API responded"},
+}
+
+// RespondWithUpstreamError sends the error response for a failed call to the Ecoflow cloud. The error is classified
+// (see service.ClassifyError) and reported with the status and error code of its class, the original error and the
+// Ecoflow error code are added to the details. Errors that can't be classified use the provided code.
+func (b *BaseHandler) RespondWithUpstreamError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error, code string, details interface{}) {
+	var openErr *resilience.OpenError
+	if errors.As(err, &openErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+		b.RespondWithError(w, r, http.StatusServiceUnavailable, constants.ErrUpstreamUnavailable, err.Error(), details)
+		return
+	}
+
+	upstreamErr := service.ClassifyError(err)
+	class := upstreamErr.Class
+	// the upstream call may fail with a network error after the deadline fired or the client went away
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		class = service.ErrorClassTimeout
+	case errors.Is(ctx.Err(), context.Canceled):
+		class = service.ErrorClassCanceled
+	}
+
+	response, ok := upstreamErrorResponses[class]
+	if !ok {
+		b.RespondWithError(w, r, http.StatusInternalServerError, code, err.Error(), details)
+		return
+	}
+	b.RespondWithError(w, r, response.status, response.code, response.message, upstreamErrorDetails(details, upstreamErr))
+}
+
+// upstreamErrorDetails adds the upstream error to the details of the response.
+func upstreamErrorDetails(details interface{}, upstreamErr *service.UpstreamError) interface{} {
+	fields, ok := details.(map[string]string)
+	if !ok && details != nil {
+		return details
+	}
+
+	result := map[string]string{"error": upstreamErr.Error()}
+	for k, v := range fields {
+		result[k] = v
+	}
+	if upstreamErr.Code != "" {
+		result["ecoflow_code"] = upstreamErr.Code
+	}
+	return result
+}
+
+// account returns the identity of the account the request belongs to.
+func (b *BaseHandler) account(r *http.Request) string {
+	if b.Identity == nil {
+		return ""
+	}
+	return b.Identity(r)
+}
+
+// readUpstream executes an idempotent call to the Ecoflow cloud, it is retried on transient failures.
+func readUpstream[T any](ctx context.Context, b *BaseHandler, r *http.Request, call func(ctx context.Context) (T, error)) (T, error) {
+	return guardUpstream(ctx, b, b.account(r), call, false)
+}
+
+// writeUpstream executes a call that changes the state of a device. It is not retried once the command was sent. The
+// command and its result are recorded in the audit log, see audit.RecordUpstream.
+func writeUpstream[T any](ctx context.Context, b *BaseHandler, r *http.Request, call func(ctx context.Context) (T, error)) (T, error) {
+	start := time.Now()
+	result, err := guardUpstream(ctx, b, b.account(r), call, true)
+	audit.RecordUpstream(ctx, result, err, time.Since(start))
+	return result, err
+}
+
+// guardUpstream executes the call for the account through the Guard, see resilience.Guard.
+func guardUpstream[T any](ctx context.Context, b *BaseHandler, account string, call func(ctx context.Context) (T, error), write bool) (T, error) {
+	var result T
+	operation := upstreamOperation(result)
+	attempt := func(ctx context.Context) error {
+		var err error
+		start := time.Now()
+		result, err = call(ctx)
+		if err == nil {
+			err = checkResponseCode(result)
+		}
+		b.observeUpstream(operation, start, err)
+		return err
+	}
+	if b.Guard == nil {
+		err := attempt(ctx)
+		return result, err
+	}
+
+	execute := b.Guard.Read
+	if write {
+		execute = b.Guard.Write
+	}
+	err := execute(ctx, account, attempt)
+	return result, err
+}
+
+// upstreamOperation names the Ecoflow API call by the type of its result.
+func upstreamOperation(result interface{}) string {
+	switch result.(type) {
+	case *ecoflow.DeviceListResponse:
+		return "device_list"
+	case map[string]interface{}:
+		return "get_all_parameters"
+	case *ecoflow.GetCmdResponse:
+		return "get_parameters"
+	case *ecoflow.CmdSetResponse:
+		return "set_parameters"
+	}
+	return "other"
+}
+
+func (b *BaseHandler) observeUpstream(operation string, start time.Time, err error) {
+	class := ""
+	if err != nil {
+		class = string(service.ClassifyError(err).Class)
+	}
+	b.Metrics.ObserveUpstream(operation, time.Since(start), class)
+}
+
+// checkResponseCode returns an error if result is a command response with a non-zero code. go-ecoflow returns such
+// responses without an error, e.g. when the device is offline.
+func checkResponseCode(result interface{}) error {
+	response, ok := result.(*ecoflow.CmdSetResponse)
+	if !ok || response == nil || response.Code == "0" {
+		return nil
+	}
+	return service.NewResponseError(response.Code, response.Message)
+}
+
 // GetEcoflowClientOrRespondWithError retrieves an Ecoflow client using the request context or sends an error response if unavailable.
 // Returns the client and a boolean indicating success (true) or failure (false).
 func (b *BaseHandler) GetEcoflowClientOrRespondWithError(r *http.Request, w http.ResponseWriter) (*ecoflow.Client, bool) {
 	client, err := b.Provider(r)
 	if err != nil {
-		b.RespondWithError(w, http.StatusUnauthorized, constants.ErrInvalidAuthHeader, err.Error(), nil)
+		b.RespondWithError(w, r, http.StatusUnauthorized, constants.ErrInvalidAuthHeader, err.Error(), nil)
 		return nil, false
 	}
 	return client, true
 }
+
+// allParameters returns all parameters of the device. Devices ingested from the Ecoflow MQTT broker are served from the
+// pushed parameters while they are fresh, the X-Data-Source and Last-Modified headers are set then. It responds with
+// the error and returns false if the parameters can't be read.
+func (h *BaseHandler) allParameters(w http.ResponseWriter, r *http.Request, sn, code string) (map[string]interface{}, bool) {
+	client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+	if !ok {
+		return nil, false
+	}
+
+	if account := h.account(r); h.Pushed != nil && account != "" {
+		if params, updated, ok := h.Pushed.Fresh(account, sn); ok {
+			w.Header().Set(constants.HeaderDataSource, constants.DataSourceMQTT)
+			w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+			return params, true
+		}
+	}
+
+	ctx, cancel := h.UpstreamContext(r)
+	defer cancel()
+
+	params, err := readUpstream(ctx, h, r, func(ctx context.Context) (map[string]interface{}, error) {
+		return client.GetDeviceAllParameters(ctx, sn)
+	})
+	if err != nil {
+		h.RespondWithUpstreamError(ctx, w, r, err, code, map[string]string{
+			"serial_number": sn,
+		})
+		return nil, false
+	}
+	return params, true
+}
+
+// pathIndex returns the path parameter, or responds with an error if it isn't a number between 0 and max.
+func (h *BaseHandler) pathIndex(w http.ResponseWriter, r *http.Request, sn, name string, max int) (int, bool) {
+	value := r.PathValue(name)
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index > max {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, fmt.Sprintf("Invalid request. %s must be between 0 and %d", name, max), map[string]string{
+			"serial_number": sn,
+			name:            value,
+		})
+		return 0, false
+	}
+	return index, true
+}
+
+// command returns the command of the device, or responds with 422 if the model of the device doesn't support it. It is
+// called before the request is validated, so no upstream call is made for unsupported commands.
+func (h *BaseHandler) command(w http.ResponseWriter, r *http.Request, sn string, family catalog.Family, name string) (catalog.Command, bool) {
+	command, ok := catalog.LookupCommand(sn, family, name)
+	if !ok {
+		model := catalog.Identify(sn)
+		h.RespondWithError(w, r, http.StatusUnprocessableEntity, constants.ErrCommandUnsupported, "The device model doesn't support the command", map[string]string{
+			"serial_number": sn,
+			"model":         model.Name,
+			"command":       string(family) + "/" + name,
+		})
+	}
+	return command, ok
+}
+
+// checkRange responds with an error if the value is outside the valid range of the field for the device model.
+func (h *BaseHandler) checkRange(w http.ResponseWriter, r *http.Request, sn string, command catalog.Command, field string, value float64) bool {
+	valid, ok := command.Range(field)
+	if !ok || valid.Contains(value) {
+		return true
+	}
+	h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, fmt.Sprintf("Invalid request. %s must be between %s and %s", field, formatFloat(valid.Min), formatFloat(valid.Max)), map[string]string{
+		"serial_number": sn,
+		field:           formatFloat(value),
+	})
+	return false
+}
+
+// checkValue responds with an error if the device model doesn't accept the value of the field.
+func (h *BaseHandler) checkValue(w http.ResponseWriter, r *http.Request, sn string, command catalog.Command, field string, value int) bool {
+	if command.Accepts(field, value) {
+		return true
+	}
+	values := make([]string, 0, len(command.Values[field]))
+	for _, v := range command.Values[field] {
+		values = append(values, strconv.Itoa(v))
+	}
+	h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, fmt.Sprintf("Invalid request. %s must be one of %s", field, strings.Join(values, ", ")), map[string]string{
+		"serial_number": sn,
+		field:           strconv.Itoa(value),
+	})
+	return false
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// checkSerialNumbers responds with 400 if a serial number of the list is empty, not unique or can't be part of a path.
+func (h *BaseHandler) checkSerialNumbers(w http.ResponseWriter, r *http.Request, serialNumbers []string) bool {
+	seen := make(map[string]bool, len(serialNumbers))
+	for _, sn := range serialNumbers {
+		if sn == "" || strings.Contains(sn, "/") || seen[sn] {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. serial_numbers must be unique and not empty", map[string]string{
+				"serial_number": sn,
+			})
+			return false
+		}
+		seen[sn] = true
+	}
+	return true
+}
+
+// vaultAccount returns the vault account of the request or responds with 403 and the code if the request is not
+// authenticated with an API key. Resources that the server acts on by itself, e.g. schedules, can't be managed with the
+// Ecoflow keys in the headers, the server doesn't store them.
+func (h *BaseHandler) vaultAccount(w http.ResponseWriter, r *http.Request, code, message string) (string, bool) {
+	if _, ok := h.GetEcoflowClientOrRespondWithError(r, w); !ok {
+		return "", false
+	}
+	account, ok := service.VaultAccount(h.account(r))
+	if !ok {
+		h.RespondWithError(w, r, http.StatusForbidden, code, message, nil)
+		return "", false
+	}
+	return account, true
+}
+
+// checkPowerStationCommand responds with 400 if the command is not the route of a power station command, the payload is
+// missing or the serial numbers are invalid, and with 422 if a device doesn't support the command.
+func (h *BaseHandler) checkPowerStationCommand(w http.ResponseWriter, r *http.Request, serialNumbers []string, command string, payload json.RawMessage) bool {
+	if !wsCommandPattern.MatchString(command) || len(payload) == 0 {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. command must be the route of a power station command, e.g. out/ac, and payload is mandatory", map[string]string{
+			"command": command,
+		})
+		return false
+	}
+	if len(serialNumbers) == 0 {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. serial_numbers is mandatory", nil)
+		return false
+	}
+	if !h.checkSerialNumbers(w, r, serialNumbers) {
+		return false
+	}
+	for _, sn := range serialNumbers {
+		if _, ok := h.command(w, r, sn, catalog.FamilyPowerStation, command); !ok {
+			return false
+		}
+	}
+	return true
+}
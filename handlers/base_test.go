@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"github.com/go-chi/httplog/v2"
 	"github.com/tess1o/go-ecoflow"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/metrics"
 )
 
 func TestBaseHandler_GetEcoflowClientOrRespondWithError(t *testing.T) {
@@ -66,3 +68,73 @@ func TestBaseHandler_GetEcoflowClientOrRespondWithError(t *testing.T) {
 		})
 	}
 }
+
+func TestBaseHandler_RespondWithError_ContentNegotiation(t *testing.T) {
+	tests := []struct {
+		name                string
+		accept              string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "default envelope",
+			expectedContentType: "application/json",
+			expectedBody:        `{"success":false,"error":{"code":"0004","message":"Invalid request","details":{"state":"maybe"}}}`,
+		},
+		{
+			name:                "json preferred",
+			accept:              "application/json",
+			expectedContentType: "application/json",
+			expectedBody:        `{"success":false,"error":{"code":"0004","message":"Invalid request","details":{"state":"maybe"}}}`,
+		},
+		{
+			name:                "problem json",
+			accept:              "application/json;q=0.5, application/problem+json",
+			expectedContentType: ContentTypeProblemJSON,
+			expectedBody: `{"type":"urn:go-ecoflow-api-server:error:0004","title":"Bad Request","status":400,"detail":"Invalid request",
+				"instance":"/api/power_station/R331/out/dc?dry=1","code":"0004","details":{"state":"maybe"}}`,
+		},
+		{
+			name:                "problem json refused",
+			accept:              "application/problem+json;q=0",
+			expectedContentType: "application/json",
+			expectedBody:        `{"success":false,"error":{"code":"0004","message":"Invalid request","details":{"state":"maybe"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewBaseHandler(httplog.NewLogger("test-logger", httplog.Options{}), nil)
+			req := httptest.NewRequest(http.MethodPut, "/api/power_station/R331/out/dc?dry=1", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.RespondWithError(rec, req, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request", map[string]string{"state": "maybe"})
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestGuardUpstream_ObservesCalls(t *testing.T) {
+	registry := metrics.New()
+	b := &BaseHandler{Metrics: registry}
+
+	_, err := guardUpstream(context.Background(), b, "", func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+		return &ecoflow.CmdSetResponse{Code: "0"}, nil
+	}, true)
+	assert.NoError(t, err)
+	_, err = guardUpstream(context.Background(), b, "", func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+		return &ecoflow.CmdSetResponse{Code: "1006", Message: "current device is not allowed to get device info"}, nil
+	}, true)
+	assert.Error(t, err)
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, recorder.Body.String(), `ecoflow_upstream_request_duration_seconds_count{operation="set_parameters"} 2`)
+	assert.Contains(t, recorder.Body.String(), `ecoflow_upstream_errors_total{class="device_not_found",operation="set_parameters"} 1`)
+}
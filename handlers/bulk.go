@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/catalog"
+	"go-ecoflow-api-server/constants"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	BulkSucceeded = "succeeded"
+	BulkFailed    = "failed"
+	BulkSkipped   = "skipped"
+)
+
+// BulkHandler applies one command to many power stations. The commands are dispatched to the power station handlers,
+// so every device gets the same validation, error codes and upstream handling as a regular request.
+type BulkHandler struct {
+	*BaseHandler
+	dispatcher  *CommandDispatcher
+	concurrency int
+	maxDevices  int
+}
+
+func NewBulkHandler(baseHandler *BaseHandler, dispatcher *CommandDispatcher, concurrency, maxDevices int) *BulkHandler {
+	return &BulkHandler{BaseHandler: baseHandler, dispatcher: dispatcher, concurrency: concurrency, maxDevices: maxDevices}
+}
+
+func (h *BulkHandler) RegisterRoutes(router chi.Router) {
+	router.Post("/api/power_station/bulk", h.PowerStationBulkCommand())
+}
+
+type BulkRequest struct {
+	// Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac
+	Command string `json:"command"`
+	// Payload is the request body of the command
+	Payload json.RawMessage `json:"payload" swaggertype:"object"`
+	// SerialNumbers are the devices the command is sent to, either they or the selector must be set
+	SerialNumbers []string `json:"serial_numbers,omitempty"`
+	// Selector selects the devices of the account the command is sent to
+	Selector *DeviceSelector `json:"selector,omitempty"`
+	// StopOnFailure skips the devices that were not started yet when a command fails
+	StopOnFailure bool `json:"stop_on_failure"`
+}
+
+// DeviceSelector selects power stations from the devices linked to the Ecoflow account. Devices of unknown models are
+// never selected.
+type DeviceSelector struct {
+	// Model is the model name, e.g. DELTA 2, all power stations are selected if it is empty
+	Model string `json:"model,omitempty"`
+	// Online selects only the devices that are online
+	Online bool `json:"online,omitempty"`
+}
+
+type BulkResponse struct {
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+	Results   []BulkResult `json:"results"`
+}
+
+// BulkResult is the result of the command for one device, the results are in the order of the devices.
+type BulkResult struct {
+	SerialNumber string `json:"serial_number"`
+	// Result is succeeded, failed or skipped
+	Result string `json:"result"`
+	// Status is the HTTP status of the command, it is not set for skipped devices
+	Status int `json:"status,omitempty"`
+	// Body is the response of the command, it is not set for skipped devices
+	Body json.RawMessage `json:"body,omitempty" swaggertype:"object"`
+}
+
+// PowerStationBulkCommand sends one command to many power stations.
+//
+// @Summary Send a command to many power stations
+// @Description Sends the command, e.g. out/ac, with the same payload to the listed power stations or to the power stations of the account matching the selector. The commands run concurrently, the result of every device is returned in the order of the devices. Every command counts against the rate limit. With stop_on_failure the devices that were not started when a command failed are skipped.
+// @Tags Power Station
+// @Accept json
+// @Produce json
+// @Param requestBody body BulkRequest true "Request body containing the command and the devices"
+// @Success 200 {object} SuccessResponse{data=BulkResponse} "Results of the devices, also if commands failed"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/power_station/bulk [post]
+func (h *BulkHandler) PowerStationBulkCommand() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var requestBody BulkRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if !wsCommandPattern.MatchString(requestBody.Command) {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. command must be the route of a power station command, e.g. out/ac", map[string]string{
+				"command": requestBody.Command,
+			})
+			return
+		}
+
+		if len(requestBody.Payload) == 0 {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. payload is mandatory", map[string]string{
+				"command": requestBody.Command,
+			})
+			return
+		}
+
+		if (len(requestBody.SerialNumbers) == 0) == (requestBody.Selector == nil) {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. Either serial_numbers or selector must be set", nil)
+			return
+		}
+
+		devices := requestBody.SerialNumbers
+		if requestBody.Selector != nil {
+			var ok bool
+			devices, ok = h.selectDevices(w, r, *requestBody.Selector)
+			if !ok {
+				return
+			}
+		}
+
+		if !h.checkDevices(w, r, devices) {
+			return
+		}
+
+		h.RespondWithSuccess(w, h.run(r, devices, requestBody))
+	}
+}
+
+// checkDevices responds with an error if the list of devices is too long or contains an invalid or duplicate serial
+// number.
+func (h *BulkHandler) checkDevices(w http.ResponseWriter, r *http.Request, devices []string) bool {
+	if len(devices) > h.maxDevices {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. Too many devices", map[string]string{
+			"devices":     strconv.Itoa(len(devices)),
+			"max_devices": strconv.Itoa(h.maxDevices),
+		})
+		return false
+	}
+	return h.checkSerialNumbers(w, r, devices)
+}
+
+// selectDevices returns the power stations of the account that match the selector.
+func (h *BulkHandler) selectDevices(w http.ResponseWriter, r *http.Request, selector DeviceSelector) ([]string, bool) {
+	client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+	if !ok {
+		return nil, false
+	}
+
+	ctx, cancel := h.UpstreamContext(r)
+	defer cancel()
+
+	ecoflowResponse, err := readUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.DeviceListResponse, error) {
+		return client.GetDeviceList(ctx)
+	})
+	if err != nil {
+		h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationBulkSelect, nil)
+		return nil, false
+	}
+
+	devices := make([]string, 0, len(ecoflowResponse.Devices))
+	for _, device := range ecoflowResponse.Devices {
+		model := catalog.Identify(device.SN)
+		if model.Family != catalog.FamilyPowerStation ||
+			(selector.Model != "" && !strings.EqualFold(model.Name, selector.Model)) ||
+			(selector.Online && device.Online != 1) {
+			continue
+		}
+		devices = append(devices, device.SN)
+	}
+	return devices, true
+}
+
+// run sends the command to the devices, at most h.concurrency at a time.
+func (h *BulkHandler) run(r *http.Request, devices []string, request BulkRequest) BulkResponse {
+	results := make([]BulkResult, len(devices))
+	slots := make(chan struct{}, h.concurrency)
+	var (
+		wg      sync.WaitGroup
+		stopped atomic.Bool
+	)
+	for i, sn := range devices {
+		slots <- struct{}{}
+		if stopped.Load() || r.Context().Err() != nil {
+			<-slots
+			results[i] = BulkResult{SerialNumber: sn, Result: BulkSkipped}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, sn string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			result := h.dispatcher.Dispatch(r.Context(), DispatchRequest{
+				Method:     http.MethodPut,
+				Path:       "/api/power_station/" + url.PathEscape(sn) + "/" + request.Command,
+				RemoteAddr: r.RemoteAddr,
+				Header:     r.Header,
+				Body:       request.Payload,
+			})
+			results[i] = BulkResult{SerialNumber: sn, Result: BulkSucceeded, Status: result.Status, Body: result.Body}
+			if result.Status >= http.StatusBadRequest {
+				results[i].Result = BulkFailed
+				if request.StopOnFailure {
+					stopped.Store(true)
+				}
+			}
+		}(i, sn)
+	}
+	wg.Wait()
+
+	response := BulkResponse{Results: results}
+	for _, result := range results {
+		switch result.Result {
+		case BulkSucceeded:
+			response.Succeeded++
+		case BulkFailed:
+			response.Failed++
+		case BulkSkipped:
+			response.Skipped++
+		}
+	}
+	return response
+}
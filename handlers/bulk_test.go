@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/constants"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBulkRouter returns a router with the bulk endpoint whose commands are sent to the fake Ecoflow API. The commands
+// sent to the API are recorded by serial number.
+func newBulkRouter(t *testing.T, concurrency int) (chi.Router, func() []string) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"code":"0","data":[{"sn":"R331ZEB4ZEAL0528","online":1},{"sn":"R351ZFB4HF000000","online":0},{"sn":"HW52ZDH4SF123456","online":1}]}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var command map[string]interface{}
+		_ = json.Unmarshal(body, &command)
+		mu.Lock()
+		sent = append(sent, command["sn"].(string))
+		mu.Unlock()
+		if command["sn"] == "R331ZEB4ZEAL0999" {
+			_, _ = w.Write([]byte(`{"code":"9999","message":"current device is offline"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":"0","message":"Success"}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	baseHandler := newUpstreamHandler(upstream)
+	dispatcher := NewCommandDispatcher(nil, NewPowerStationHandler(baseHandler))
+	router := chi.NewRouter()
+	NewBulkHandler(baseHandler, dispatcher, concurrency, 3).RegisterRoutes(router)
+	return router, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), sent...)
+	}
+}
+
+func postBulk(router chi.Router, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/power_station/bulk", strings.NewReader(body)))
+	return rec
+}
+
+func bulkResponse(t *testing.T, rec *httptest.ResponseRecorder) BulkResponse {
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var response struct {
+		Data BulkResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response.Data
+}
+
+func TestBulkHandler_SendsCommandToDevices(t *testing.T) {
+	router, sent := newBulkRouter(t, 2)
+
+	rec := postBulk(router, `{"command":"out/dc","payload":{"state":"off"},"serial_numbers":["R331ZEB4ZEAL0528","HW52ZDH4SF123456","R331ZEB4ZEAL0999"]}`)
+	response := bulkResponse(t, rec)
+
+	assert.Equal(t, 1, response.Succeeded)
+	assert.Equal(t, 2, response.Failed)
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, BulkResult{SerialNumber: "R331ZEB4ZEAL0528", Result: BulkSucceeded, Status: http.StatusOK, Body: response.Results[0].Body}, response.Results[0])
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Results[1].Status, "the smart plug is rejected by the power station handler")
+	assert.Contains(t, string(response.Results[1].Body), constants.ErrCommandUnsupported)
+	assert.Equal(t, http.StatusConflict, response.Results[2].Status)
+	assert.Contains(t, string(response.Results[2].Body), constants.ErrDeviceOffline)
+	assert.ElementsMatch(t, []string{"R331ZEB4ZEAL0528", "R331ZEB4ZEAL0999"}, sent())
+}
+
+func TestBulkHandler_StopsOnFailure(t *testing.T) {
+	router, sent := newBulkRouter(t, 1)
+
+	rec := postBulk(router, `{"command":"out/dc","payload":{"state":"off"},"serial_numbers":["R331ZEB4ZEAL0999","R331ZEB4ZEAL0528","R351ZFB4HF000000"],"stop_on_failure":true}`)
+	response := bulkResponse(t, rec)
+
+	assert.Equal(t, 1, response.Failed)
+	assert.Equal(t, 2, response.Skipped)
+	assert.Equal(t, BulkResult{SerialNumber: "R351ZFB4HF000000", Result: BulkSkipped}, response.Results[2])
+	assert.Equal(t, []string{"R331ZEB4ZEAL0999"}, sent())
+}
+
+func TestBulkHandler_SelectsDevices(t *testing.T) {
+	router, sent := newBulkRouter(t, 2)
+
+	response := bulkResponse(t, postBulk(router, `{"command":"out/dc","payload":{"state":"off"},"selector":{}}`))
+	assert.Equal(t, 2, response.Succeeded, "all power stations, the smart plug is not selected")
+	assert.ElementsMatch(t, []string{"R331ZEB4ZEAL0528", "R351ZFB4HF000000"}, sent())
+
+	response = bulkResponse(t, postBulk(router, `{"command":"out/dc","payload":{"state":"off"},"selector":{"online":true}}`))
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, "R331ZEB4ZEAL0528", response.Results[0].SerialNumber)
+
+	response = bulkResponse(t, postBulk(router, `{"command":"out/dc","payload":{"state":"off"},"selector":{"model":"delta 2 max"}}`))
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, "R351ZFB4HF000000", response.Results[0].SerialNumber)
+}
+
+func TestBulkHandler_ValidatesRequest(t *testing.T) {
+	router, sent := newBulkRouter(t, 2)
+
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{name: "invalid json", body: `{`, code: constants.ErrInvalidJsonBody},
+		{name: "invalid command", body: `{"command":"../out/dc","payload":{},"serial_numbers":["R331ZEB4ZEAL0528"]}`, code: constants.ErrInvalidParameters},
+		{name: "missing payload", body: `{"command":"out/dc","serial_numbers":["R331ZEB4ZEAL0528"]}`, code: constants.ErrInvalidParameters},
+		{name: "no devices", body: `{"command":"out/dc","payload":{}}`, code: constants.ErrInvalidParameters},
+		{name: "devices and selector", body: `{"command":"out/dc","payload":{},"serial_numbers":["R331ZEB4ZEAL0528"],"selector":{}}`, code: constants.ErrInvalidParameters},
+		{name: "duplicate device", body: `{"command":"out/dc","payload":{},"serial_numbers":["R331ZEB4ZEAL0528","R331ZEB4ZEAL0528"]}`, code: constants.ErrInvalidParameters},
+		{name: "too many devices", body: `{"command":"out/dc","payload":{},"serial_numbers":["R1","R2","R3","R4"]}`, code: constants.ErrInvalidParameters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postBulk(router, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+	assert.Empty(t, sent())
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/go-chi/chi/v5"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/catalog"
 	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/devicestatus"
 	"net/http"
 )
 
@@ -20,6 +23,8 @@ func (h *DeviceHandler) RegisterRoutes(router chi.Router) {
 	router.Get("/api/devices", h.GetDevicesList())
 	router.Get("/api/devices/{serial_number}/parameters", h.GetDeviceParametersAll())
 	router.Post("/api/devices/{serial_number}/parameters/query", h.GetDeviceParametersQuery())
+	router.Get("/api/devices/{serial_number}/status", h.GetDeviceStatus())
+	router.Get("/api/devices/{serial_number}/capabilities", h.GetDeviceCapabilities())
 }
 
 // GetDevicesList handles retrieving a list of devices
@@ -28,7 +33,11 @@ func (h *DeviceHandler) RegisterRoutes(router chi.Router) {
 // @Tags Devices
 // @Produce json
 // @Success 200 {object} SuccessResponse "List of devices retrieved successfully"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
 // @Failure 500 {object} ErrorResponse "Error retrieving device list"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
 // @Router /api/devices [get]
 func (h *DeviceHandler) GetDevicesList() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -37,9 +46,14 @@ func (h *DeviceHandler) GetDevicesList() func(w http.ResponseWriter, r *http.Req
 			return
 		}
 
-		ecoflowResponse, err := client.GetDeviceList(context.Background())
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := readUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.DeviceListResponse, error) {
+			return client.GetDeviceList(ctx)
+		})
 		if err != nil {
-			h.RespondWithError(w, http.StatusInternalServerError, constants.ErrGetDevicesList, err.Error(), nil)
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrGetDevicesList, nil)
 			return
 		}
 		h.RespondWithSuccess(w, ecoflowResponse)
@@ -48,29 +62,83 @@ func (h *DeviceHandler) GetDevicesList() func(w http.ResponseWriter, r *http.Req
 
 // GetDeviceParametersAll handles retrieving all parameters for a specific device
 // @Summary Get all parameters for a device
-// @Description Retrieves all available parameters for a device using its serial number
+// @Description Retrieves all available parameters for a device using its serial number. Devices ingested from the Ecoflow MQTT broker are served from the pushed parameters while they are fresh, the response then has the X-Data-Source: mqtt and Last-Modified headers.
 // @Tags Devices
 // @Produce json
 // @Param serial_number path string true "Device Serial Number"
 // @Success 200 {object} SuccessResponse "Parameters retrieved successfully"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
 // @Failure 500 {object} ErrorResponse "Error retrieving device parameters"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
 // @Router /api/devices/{serial_number}/parameters [get]
 func (h *DeviceHandler) GetDeviceParametersAll() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		sn := r.PathValue("serial_number")
+		params, ok := h.allParameters(w, r, sn, constants.ErrGetAllDeviceParameters)
 		if !ok {
 			return
 		}
+		h.RespondWithSuccess(w, params)
+	}
+}
 
+// GetDeviceStatus handles retrieving the typed status of a device
+// @Summary Get the typed status of a device
+// @Description Returns the status of a device decoded from all its parameters: battery, input and output power per port, temperatures and switch states in SI units. The model is derived from the serial number, the family section matching the model is set. Values the device doesn't report are null. The status is versioned, see the version field.
+// @Tags Devices
+// @Produce json
+// @Param serial_number path string true "Device Serial Number"
+// @Success 200 {object} SuccessResponse{data=devicestatus.Status} "Status retrieved successfully"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Typed status is not available for the device model"
+// @Failure 500 {object} ErrorResponse "Error retrieving device parameters"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/devices/{serial_number}/status [get]
+func (h *DeviceHandler) GetDeviceStatus() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
 		sn := r.PathValue("serial_number")
-		ecoflowResponse, err := client.GetDeviceAllParameters(context.Background(), sn)
+		params, ok := h.allParameters(w, r, sn, constants.ErrGetDeviceStatus)
+		if !ok {
+			return
+		}
+		status, err := devicestatus.Decode(sn, params)
 		if err != nil {
-			h.RespondWithError(w, http.StatusInternalServerError, constants.ErrGetAllDeviceParameters, err.Error(), map[string]string{
+			h.RespondWithError(w, r, http.StatusUnprocessableEntity, constants.ErrDeviceStatusUnsupported, err.Error(), map[string]string{
 				"serial_number": sn,
+				"model":         catalog.Identify(sn).Name,
 			})
 			return
 		}
-		h.RespondWithSuccess(w, ecoflowResponse)
+		h.RespondWithSuccess(w, status)
+	}
+}
+
+// CapabilitiesResponse is the model of a device and the commands it supports
+type CapabilitiesResponse struct {
+	SerialNumber string        `json:"serial_number"`
+	Model        catalog.Model `json:"model"`
+}
+
+// GetDeviceCapabilities handles retrieving the capabilities of a device
+// @Summary Get the capabilities of a device
+// @Description Returns the model of a device derived from its serial number, the commands it supports and the valid ranges and values of their fields. Commands that are not listed are rejected with 422 before they reach the Ecoflow API. Devices of unknown models have no commands listed, the commands of their family are accepted with the widest ranges. The Ecoflow API is not called.
+// @Tags Devices
+// @Produce json
+// @Param serial_number path string true "Device Serial Number"
+// @Success 200 {object} SuccessResponse{data=CapabilitiesResponse} "Capabilities of the device"
+// @Router /api/devices/{serial_number}/capabilities [get]
+func (h *DeviceHandler) GetDeviceCapabilities() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+		h.RespondWithSuccess(w, CapabilitiesResponse{SerialNumber: sn, Model: catalog.Identify(sn)})
 	}
 }
 
@@ -89,7 +157,13 @@ type QueryParametersRequest struct {
 // @Param parameters body QueryParametersRequest true "List of parameters to query"
 // @Success 200 {object} SuccessResponse "Requested parameters retrieved successfully"
 // @Failure 400 {object} ErrorResponse "Error Invalid JSON Body"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
 // @Failure 500 {object} ErrorResponse "Error retrieving device parameters"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
 // @Router /api/devices/{serial_number}/parameters/query [post]
 func (h *DeviceHandler) GetDeviceParametersQuery() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -101,7 +175,7 @@ func (h *DeviceHandler) GetDeviceParametersQuery() func(http.ResponseWriter, *ht
 
 		err := json.NewDecoder(r.Body).Decode(&requestBody)
 		if err != nil {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
 				"serial_number": sn,
 				"error":         err.Error(),
 			})
@@ -109,7 +183,7 @@ func (h *DeviceHandler) GetDeviceParametersQuery() func(http.ResponseWriter, *ht
 		}
 
 		if len(requestBody.Parameters) == 0 {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidJsonBody, "No parameters provided", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "No parameters provided", map[string]string{
 				"serial_number": sn,
 			})
 			return
@@ -120,9 +194,14 @@ func (h *DeviceHandler) GetDeviceParametersQuery() func(http.ResponseWriter, *ht
 			return
 		}
 
-		ecoflowResponse, err := client.GetDeviceParameters(context.Background(), sn, requestBody.Parameters)
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := readUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.GetCmdResponse, error) {
+			return client.GetDeviceParameters(ctx, sn, requestBody.Parameters)
+		})
 		if err != nil {
-			h.RespondWithError(w, http.StatusInternalServerError, constants.ErrGetDeviceParameters, err.Error(), map[string]string{
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrGetDeviceParameters, map[string]string{
 				"serial_number": sn,
 			})
 			return
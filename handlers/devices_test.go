@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"github.com/go-chi/httplog/v2"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/ingest"
+	"go-ecoflow-api-server/resilience"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newUpstreamHandler returns a BaseHandler whose clients talk to the given fake Ecoflow API.
+func newUpstreamHandler(upstream *httptest.Server) *BaseHandler {
+	logger := httplog.NewLogger("test-logger", httplog.Options{})
+	return NewBaseHandler(logger, func(r *http.Request) (*ecoflow.Client, error) {
+		return ecoflow.NewEcoflowClient("access", "secret", ecoflow.WithBaseUrl(upstream.URL)), nil
+	})
+}
+
+func TestDeviceHandler_GetDevicesList_PropagatesCancellation(t *testing.T) {
+	upstreamCancelled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(upstreamCancelled)
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name           string
+		timeout        time.Duration
+		cancelRequest  bool
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "client disconnects",
+			timeout:        time.Minute,
+			cancelRequest:  true,
+			expectedStatus: StatusClientClosedRequest,
+			expectedCode:   constants.ErrUpstreamCanceled,
+		},
+		{
+			name:           "upstream deadline exceeded",
+			timeout:        50 * time.Millisecond,
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedCode:   constants.ErrUpstreamTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamCancelled = make(chan struct{})
+			handler := NewDeviceHandler(newUpstreamHandler(upstream))
+			handler.UpstreamTimeout = tt.timeout
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			req := httptest.NewRequest(http.MethodGet, "/api/devices", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+
+			if tt.cancelRequest {
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					cancel()
+				}()
+			}
+			handler.GetDevicesList()(rec, req)
+
+			select {
+			case <-upstreamCancelled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("upstream call was not cancelled")
+			}
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedCode)
+		})
+	}
+}
+
+func TestDeviceHandler_GetDevicesList_RetriesAndOpensBreaker(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	handler := NewDeviceHandler(newUpstreamHandler(upstream))
+	handler.Guard = resilience.NewGuard(resilience.Config{MaxAttempts: 2, FailureThreshold: 2, OpenDuration: time.Minute})
+	handler.Identity = func(r *http.Request) string { return "account" }
+
+	rec := httptest.NewRecorder()
+	handler.GetDevicesList()(rec, httptest.NewRequest(http.MethodGet, "/api/devices", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), constants.ErrUpstreamOutage)
+	assert.Equal(t, int32(2), calls.Load(), "idempotent read must be retried")
+
+	rec = httptest.NewRecorder()
+	handler.GetDevicesList()(rec, httptest.NewRequest(http.MethodGet, "/api/devices", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), constants.ErrUpstreamUnavailable)
+	assert.Equal(t, int32(2), calls.Load(), "open breaker must not call the upstream")
+
+	rec = httptest.NewRecorder()
+	NewStatusHandler(handler.BaseHandler).GetUpstreamStatus()(rec, httptest.NewRequest(http.MethodGet, "/api/status/upstream", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"state":"open"`)
+
+	handler.Identity = func(r *http.Request) string { return "other" }
+	rec = httptest.NewRecorder()
+	NewStatusHandler(handler.BaseHandler).GetUpstreamStatus()(rec, httptest.NewRequest(http.MethodGet, "/api/status/upstream", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"breakers":[]`, "the breakers of other accounts are not listed")
+}
+
+func TestDeviceHandler_GetDevicesList_ClassifiesUpstreamErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		upstreamStatus int
+		upstreamBody   string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "invalid keys",
+			upstreamStatus: http.StatusOK,
+			upstreamBody:   `{"code":"8521","message":"signature is wrong"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   constants.ErrUpstreamUnauthorized,
+		},
+		{
+			name:           "device not linked",
+			upstreamStatus: http.StatusOK,
+			upstreamBody:   `{"code":"1006","message":"current device is not allowed to get device info"}`,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   constants.ErrDeviceNotFound,
+		},
+		{
+			name:           "forbidden",
+			upstreamStatus: http.StatusForbidden,
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   constants.ErrUpstreamForbidden,
+		},
+		{
+			name:           "invalid response",
+			upstreamStatus: http.StatusOK,
+			upstreamBody:   `<html>`,
+			expectedStatus: http.StatusBadGateway,
+			expectedCode:   constants.ErrUpstreamBadResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.upstreamStatus)
+				_, _ = w.Write([]byte(tt.upstreamBody))
+			}))
+			defer upstream.Close()
+
+			rec := httptest.NewRecorder()
+			NewDeviceHandler(newUpstreamHandler(upstream)).GetDevicesList()(rec, httptest.NewRequest(http.MethodGet, "/api/devices", nil))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedCode)
+		})
+	}
+}
+
+func TestDeviceHandler_GetDeviceParametersAll_ServesPushedParameters(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"code":"0","data":{"pd.soc":40}}`))
+	}))
+	defer upstream.Close()
+
+	store := ingest.NewStore(time.Minute)
+	store.Track("vault:home", "SN1")
+	store.Replace("vault:home", "SN1", map[string]interface{}{"pd.soc": 50})
+	store.Track("vault:home", "SN2")
+
+	handler := NewDeviceHandler(newUpstreamHandler(upstream))
+	handler.Identity = func(r *http.Request) string { return "vault:home" }
+	handler.Pushed = store
+	get := func(sn string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/devices/"+sn+"/parameters", nil)
+		req.SetPathValue("serial_number", sn)
+		rec := httptest.NewRecorder()
+		handler.GetDeviceParametersAll()(rec, req)
+		return rec
+	}
+
+	rec := get("SN1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"success":true,"data":{"pd.soc":50}}`, rec.Body.String())
+	assert.Equal(t, constants.DataSourceMQTT, rec.Header().Get(constants.HeaderDataSource))
+	assert.NotEmpty(t, rec.Header().Get("Last-Modified"))
+	assert.Zero(t, calls.Load())
+
+	// devices without fresh parameters are read from the Ecoflow API
+	rec = get("SN2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"success":true,"data":{"pd.soc":40}}`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get(constants.HeaderDataSource))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestDeviceHandler_GetDeviceStatus(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sn") != "R331ZEB4ZEAL0528" {
+			_, _ = w.Write([]byte(`{"code":"0","data":{"20_1.watts":5}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":"0","data":{"pd.soc":87,"inv.cfgAcEnabled":1}}`))
+	}))
+	defer upstream.Close()
+
+	handler := NewDeviceHandler(newUpstreamHandler(upstream))
+	get := func(sn string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/devices/"+sn+"/status", nil)
+		req.SetPathValue("serial_number", sn)
+		rec := httptest.NewRecorder()
+		handler.GetDeviceStatus()(rec, req)
+		return rec
+	}
+
+	rec := get("R331ZEB4ZEAL0528")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"family":"power_station"`)
+	assert.Contains(t, rec.Body.String(), `"state_of_charge_percent":87`)
+	assert.Contains(t, rec.Body.String(), `"ac_enabled":true`)
+
+	rec = get("KT21ZCH2ZF170012")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), constants.ErrDeviceStatusUnsupported)
+}
+
+func TestDeviceHandler_GetDeviceCapabilities(t *testing.T) {
+	handler := NewDeviceHandler(NewBaseHandler(httplog.NewLogger("test-logger", httplog.Options{}), nil))
+	get := func(sn string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/devices/"+sn+"/capabilities", nil)
+		req.SetPathValue("serial_number", sn)
+		rec := httptest.NewRecorder()
+		handler.GetDeviceCapabilities()(rec, req)
+		return rec
+	}
+
+	rec := get("R331ZEB4ZEAL0528")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"DELTA 2"`)
+	assert.Contains(t, rec.Body.String(), `{"name":"input/speed","ranges":{"watts":{"min":200,"max":1200}}}`)
+
+	rec = get("XYZ123")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"family":"unknown","commands":[]`)
+}
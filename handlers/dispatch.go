@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// RouteRegistrar is implemented by the handlers that register API routes.
+type RouteRegistrar interface {
+	RegisterRoutes(router chi.Router)
+}
+
+// CommandDispatcher executes API requests without an HTTP connection, e.g. commands sent over a WebSocket. The
+// requests are routed through the same handlers as regular requests, so validation, error codes and upstream
+// handling are identical.
+type CommandDispatcher struct {
+	router chi.Router
+}
+
+// NewCommandDispatcher returns a dispatcher for the routes of the registrars. The middlewares are applied to all
+// dispatched requests, e.g. the rate limit shared with the HTTP routes.
+func NewCommandDispatcher(middlewares []func(http.Handler) http.Handler, registrars ...RouteRegistrar) *CommandDispatcher {
+	router := chi.NewRouter()
+	router.Use(middlewares...)
+	for _, registrar := range registrars {
+		registrar.RegisterRoutes(router)
+	}
+	return &CommandDispatcher{router: router}
+}
+
+// DispatchRequest is a request to dispatch. Header must contain the credentials of the caller, they are passed to the
+// client provider like the headers of a regular request. RemoteAddr is the address of the connection the request was
+// received on.
+type DispatchRequest struct {
+	Method     string
+	Path       string
+	RemoteAddr string
+	Header     http.Header
+	Body       []byte
+}
+
+// DispatchResult is the response of a dispatched request.
+type DispatchResult struct {
+	Status int
+	Body   json.RawMessage
+}
+
+// Dispatch executes the request.
+func (d *CommandDispatcher) Dispatch(ctx context.Context, request DispatchRequest) DispatchResult {
+	// the context of an API request carries its chi routing state, the router would route the dispatched request with it
+	ctx = context.WithValue(ctx, chi.RouteCtxKey, nil)
+	r, err := http.NewRequestWithContext(ctx, request.Method, request.Path, bytes.NewReader(request.Body))
+	if err != nil {
+		return DispatchResult{Status: http.StatusBadRequest, Body: json.RawMessage(`null`)}
+	}
+	r.RemoteAddr = request.RemoteAddr
+	r.Header = request.Header.Clone()
+	r.Header.Set("Content-Type", "application/json")
+
+	w := &bufferedResponse{header: make(http.Header)}
+	d.router.ServeHTTP(w, r)
+
+	result := DispatchResult{Status: w.status, Body: bytes.TrimSpace(w.body.Bytes())}
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	if !json.Valid(result.Body) {
+		// e.g. the plain text 404 and 405 responses of the router
+		result.Body, _ = json.Marshal(w.body.String())
+	}
+	return result
+}
+
+// bufferedResponse is an http.ResponseWriter that keeps the response in memory.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(data)
+}
+
+func (b *bufferedResponse) WriteHeader(statusCode int) {
+	if b.status == 0 {
+		b.status = statusCode
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/history"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHistoryRange = 24 * time.Hour
+	defaultHistoryStep  = 5 * time.Minute
+	// maxHistoryPoints limits the number of steps of a history query.
+	maxHistoryPoints = 10000
+)
+
+type HistoryHandler struct {
+	*BaseHandler
+	store  *history.Store
+	params []string
+}
+
+// NewHistoryHandler returns the handler of the device history, params are the recorded parameters.
+func NewHistoryHandler(baseHandler *BaseHandler, store *history.Store, params []string) *HistoryHandler {
+	return &HistoryHandler{BaseHandler: baseHandler, store: store, params: params}
+}
+
+func (h *HistoryHandler) RegisterRoutes(router chi.Router) {
+	router.Get("/api/devices/{serial_number}/history", h.GetDeviceHistory())
+}
+
+// HistoryResponse contains the recorded parameters of a device, aggregated per step.
+type HistoryResponse struct {
+	SerialNumber string                     `json:"serial_number"`
+	From         time.Time                  `json:"from"`
+	To           time.Time                  `json:"to"`
+	StepSeconds  int64                      `json:"step_seconds"`
+	Series       map[string][]history.Point `json:"series"`
+}
+
+// GetDeviceHistory handles retrieving the recorded parameters of a device
+// @Summary Get the history of device parameters
+// @Description Returns the recorded parameters of a device between from and to, aggregated per step with min, max and avg. Only the parameters of the devices of vault accounts are recorded, the history is queried with an API key of the account. Steps without samples are omitted.
+// @Tags Devices
+// @Produce json
+// @Param serial_number path string true "Device Serial Number"
+// @Param params query string false "Comma separated parameters, all recorded parameters by default" example(pd.soc,pd.wattsInSum)
+// @Param from query string false "Start of the range (RFC 3339), 24 hours before to by default. It is clamped to the retention of the history"
+// @Param to query string false "End of the range (RFC 3339), now by default"
+// @Param step query string false "Aggregation step as a Go duration, 5m by default" example(15m)
+// @Success 200 {object} SuccessResponse{data=HistoryResponse} "History retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid range, step or parameters"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 500 {object} ErrorResponse "Error reading the history"
+// @Router /api/devices/{serial_number}/history [get]
+func (h *HistoryHandler) GetDeviceHistory() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := h.GetEcoflowClientOrRespondWithError(r, w); !ok {
+			return
+		}
+		sn := r.PathValue("serial_number")
+		query := r.URL.Query()
+
+		params := h.params
+		if value := query.Get("params"); value != "" {
+			params = strings.Split(value, ",")
+			for _, param := range params {
+				if !slices.Contains(h.params, param) {
+					h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. Parameter is not recorded", map[string]string{
+						"param":    param,
+						"recorded": strings.Join(h.params, ","),
+					})
+					return
+				}
+			}
+		}
+
+		to, err := parseTime(query.Get("to"), time.Now())
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. to must be an RFC 3339 time", map[string]string{
+				"to": query.Get("to"),
+			})
+			return
+		}
+		from, err := parseTime(query.Get("from"), to.Add(-defaultHistoryRange))
+		if err != nil || !from.Before(to) {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. from must be an RFC 3339 time before to", map[string]string{
+				"from": query.Get("from"),
+			})
+			return
+		}
+		// older samples are deleted, the range is clamped to the retention of the history
+		if oldest := h.store.Oldest(); from.Before(oldest) && oldest.Before(to) {
+			from = oldest
+		}
+		step := defaultHistoryStep
+		if value := query.Get("step"); value != "" {
+			step, err = time.ParseDuration(value)
+			if err != nil || step < time.Second {
+				h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. step must be a duration of at least 1s", map[string]string{
+					"step": value,
+				})
+				return
+			}
+		}
+		if to.Sub(from)/step > maxHistoryPoints {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. Range has too many steps, increase the step", map[string]string{
+				"step": step.String(),
+			})
+			return
+		}
+
+		series, err := h.store.Query(h.account(r), sn, params, from, to, step)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusInternalServerError, constants.ErrGetDeviceHistory, "Failed to read the device history", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+		h.RespondWithSuccess(w, HistoryResponse{
+			SerialNumber: sn,
+			From:         from.UTC(),
+			To:           to.UTC(),
+			StepSeconds:  int64(step / time.Second),
+			Series:       series,
+		})
+	}
+}
+
+func parseTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
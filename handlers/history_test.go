@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/history"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryHandler_GetDeviceHistory(t *testing.T) {
+	store, err := history.Open(history.Config{
+		Dir:             t.TempDir(),
+		SegmentDuration: 24 * time.Hour,
+		RollupInterval:  time.Hour,
+		RawRetention:    24 * time.Hour,
+		Retention:       48 * time.Hour,
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	sampled := time.Now().UTC().Truncate(time.Hour)
+	require.NoError(t, store.Record(sampled, "vault:home", "SN1", map[string]float64{"pd.soc": 50}))
+	require.NoError(t, store.Record(sampled.Add(time.Minute), "vault:home", "SN1", map[string]float64{"pd.soc": 70}))
+
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	defer upstream.Close()
+	handler := NewHistoryHandler(newUpstreamHandler(upstream), store, []string{"pd.soc", "pd.wattsInSum"})
+	handler.Identity = func(r *http.Request) string { return "vault:home" }
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/devices/SN1/history?"+query, nil)
+		req.SetPathValue("serial_number", "SN1")
+		rec := httptest.NewRecorder()
+		handler.GetDeviceHistory()(rec, req)
+		return rec
+	}
+
+	from := sampled.Format(time.RFC3339)
+	to := sampled.Add(time.Hour).Format(time.RFC3339)
+	rec := get("params=pd.soc&step=1h&from=" + from + "&to=" + to)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"success":true,"data":{"serial_number":"SN1","from":"`+from+`","to":"`+to+`","step_seconds":3600,
+		"series":{"pd.soc":[{"time":"`+from+`","min":50,"max":70,"avg":60,"count":2}]}}}`, rec.Body.String())
+
+	// all recorded parameters of the last day by default
+	rec = get("")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"pd.wattsInSum":[]`)
+	assert.Contains(t, rec.Body.String(), `"step_seconds":300`)
+
+	// the range is clamped to the retention
+	rec = get("step=24h&from=1970-01-01T00:00:00Z")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"from":"`+store.Oldest().UTC().Format(time.RFC3339)+`"`)
+	assert.Contains(t, rec.Body.String(), `"count":2`)
+
+	for _, query := range []string{
+		"params=pd.unknown",
+		"from=yesterday",
+		"from=" + to + "&to=" + from,
+		"step=0s",
+		"step=1s&from=2024-01-01T00:00:00Z",
+	} {
+		rec = get(query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Contains(t, rec.Body.String(), constants.ErrInvalidParameters, query)
+	}
+}
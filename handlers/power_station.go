@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/catalog"
 	"go-ecoflow-api-server/constants"
 	"net/http"
 )
@@ -25,6 +26,14 @@ func (h *PowerStationHandler) RegisterRoutes(router chi.Router) {
 	router.Put("/api/power_station/{serial_number}/input/speed", h.PowerStationSetChargingSpeed())
 	router.Put("/api/power_station/{serial_number}/input/car", h.PowerStationSetCarInput())
 	router.Put("/api/power_station/{serial_number}/standby", h.PowerStationSetStandBy())
+	router.Put("/api/power_station/{serial_number}/input/ac/pause", h.PowerStationSetAcChargePause())
+	router.Put("/api/power_station/{serial_number}/input/ac/mode", h.PowerStationSetAcChargeMode())
+	router.Put("/api/power_station/{serial_number}/input/pv_type", h.PowerStationSetPvChargeType())
+	router.Put("/api/power_station/{serial_number}/battery/charge_limit", h.PowerStationSetChargeLimit())
+	router.Put("/api/power_station/{serial_number}/battery/discharge_limit", h.PowerStationSetDischargeLimit())
+	router.Put("/api/power_station/{serial_number}/generator", h.PowerStationSetGenerator())
+	router.Put("/api/power_station/{serial_number}/beep", h.PowerStationSetBeep())
+	router.Put("/api/power_station/{serial_number}/screen/brightness", h.PowerStationSetScreenBrightness())
 }
 
 type ChangeStateRequest struct {
@@ -42,16 +51,28 @@ type ChangeStateRequest struct {
 // @Param requestBody body ChangeStateRequest true "Request body containing the state"
 // @Success 200 {object} SuccessResponse "Successfully toggled car charger state"
 // @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
 // @Router /api/power_station/{serial_number}/out/car [put]
 func (h *PowerStationHandler) PowerStationSetEnableCarCharging() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sn := r.PathValue("serial_number")
 
+		_, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "out/car")
+		if !ok {
+			return
+		}
+
 		var requestBody ChangeStateRequest
 		err := json.NewDecoder(r.Body).Decode(&requestBody)
 		if err != nil {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
 				"serial_number": sn,
 				"error":         err.Error(),
 			})
@@ -59,7 +80,7 @@ func (h *PowerStationHandler) PowerStationSetEnableCarCharging() func(http.Respo
 		}
 
 		if requestBody.State != "on" && requestBody.State != "off" {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. State must be 'on' or 'off'", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. State must be 'on' or 'off'", map[string]string{
 				"serial_number": sn,
 				"state":         requestBody.State,
 			})
@@ -78,11 +99,17 @@ func (h *PowerStationHandler) PowerStationSetEnableCarCharging() func(http.Respo
 			newState = ecoflow.SettingDisabled
 		}
 
-		ecoflowResponse, err := client.GetPowerStation(sn).SetCarChargerSwitch(context.Background(), newState)
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStation(sn).SetCarChargerSwitch(ctx, newState)
+		})
 		if err != nil {
-			h.RespondWithError(w, http.StatusInternalServerError, constants.ErrEnableCarOut, err.Error(), map[string]string{
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrEnableCarOut, map[string]string{
 				"serial_number": sn,
 			})
+			return
 		}
 		h.RespondWithSuccess(w, ecoflowResponse)
 	}
@@ -98,17 +125,29 @@ func (h *PowerStationHandler) PowerStationSetEnableCarCharging() func(http.Respo
 // @Param requestBody body ChangeStateRequest true "Request body containing the state"
 // @Success 200 {object} SuccessResponse "Successfully toggled DC output state"
 // @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
 // @Router /api/power_station/{serial_number}/out/dc [put]
 func (h *PowerStationHandler) PowerStationEnableDc() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sn := r.PathValue("serial_number")
 
+		_, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "out/dc")
+		if !ok {
+			return
+		}
+
 		var requestBody ChangeStateRequest
 
 		err := json.NewDecoder(r.Body).Decode(&requestBody)
 		if err != nil {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
 				"serial_number": sn,
 				"error":         err.Error(),
 			})
@@ -116,7 +155,7 @@ func (h *PowerStationHandler) PowerStationEnableDc() func(http.ResponseWriter, *
 		}
 
 		if requestBody.State != "on" && requestBody.State != "off" {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. State must be 'on' or 'off'", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. State must be 'on' or 'off'", map[string]string{
 				"serial_number": sn,
 				"state":         requestBody.State,
 			})
@@ -135,11 +174,17 @@ func (h *PowerStationHandler) PowerStationEnableDc() func(http.ResponseWriter, *
 			newState = ecoflow.SettingDisabled
 		}
 
-		ecoflowResponse, err := client.GetPowerStation(sn).SetDcSwitch(context.Background(), newState)
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStation(sn).SetDcSwitch(ctx, newState)
+		})
 		if err != nil {
-			h.RespondWithError(w, http.StatusInternalServerError, constants.ErrEnableDcOut, err.Error(), map[string]string{
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrEnableDcOut, map[string]string{
 				"serial_number": sn,
 			})
+			return
 		}
 		h.RespondWithSuccess(w, ecoflowResponse)
 	}
@@ -162,16 +207,28 @@ type EnableAcRequest struct {
 // @Param requestBody body EnableAcRequest true "Request body containing AC state, XBoost state, output frequency, and output voltage"
 // @Success 200 {object} SuccessResponse "Successfully toggled AC output state with defined settings"
 // @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
 // @Router /api/power_station/{serial_number}/out/ac [put]
 func (h *PowerStationHandler) PowerStationEnableAc() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sn := r.PathValue("serial_number")
 
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "out/ac")
+		if !ok {
+			return
+		}
+
 		var requestBody EnableAcRequest
 		err := json.NewDecoder(r.Body).Decode(&requestBody)
 		if err != nil {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
 				"serial_number": sn,
 				"error":         err.Error(),
 			})
@@ -179,7 +236,7 @@ func (h *PowerStationHandler) PowerStationEnableAc() func(http.ResponseWriter, *
 		}
 
 		if requestBody.AcState != "on" && requestBody.AcState != "off" {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. ac_state must be 'on' or 'off'", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. ac_state must be 'on' or 'off'", map[string]string{
 				"serial_number": sn,
 				"ac_state":      requestBody.AcState,
 			})
@@ -187,26 +244,18 @@ func (h *PowerStationHandler) PowerStationEnableAc() func(http.ResponseWriter, *
 		}
 
 		if requestBody.XBoostState != "on" && requestBody.XBoostState != "off" {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. xboost_state must be 'on' or 'off'", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. xboost_state must be 'on' or 'off'", map[string]string{
 				"serial_number": sn,
 				"xboost_state":  requestBody.XBoostState,
 			})
 			return
 		}
 
-		if requestBody.OutFreq != 50 && requestBody.OutFreq != 60 {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. out_freq must be 50 or 60", map[string]string{
-				"serial_number": sn,
-				"out_freq":      fmt.Sprintf("%d", requestBody.OutFreq),
-			})
+		if !h.checkValue(w, r, sn, command, "out_freq", requestBody.OutFreq) {
 			return
 		}
 
-		if requestBody.OutVoltage == 0 {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. out_voltage must not be 0", map[string]string{
-				"serial_number": sn,
-				"out_voltage":   fmt.Sprintf("%d", requestBody.OutVoltage),
-			})
+		if !h.checkValue(w, r, sn, command, "out_voltage", requestBody.OutVoltage) {
 			return
 		}
 
@@ -236,12 +285,18 @@ func (h *PowerStationHandler) PowerStationEnableAc() func(http.ResponseWriter, *
 			newOutFreq = ecoflow.GridFrequency60Hz
 		}
 
-		ecoflowResponse, err := client.GetPowerStation(sn).SetAcEnabled(context.Background(), newAcState, newXBoostState, newOutFreq, requestBody.OutVoltage)
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStation(sn).SetAcEnabled(ctx, newAcState, newXBoostState, newOutFreq, requestBody.OutVoltage)
+		})
 
 		if err != nil {
-			h.RespondWithError(w, http.StatusInternalServerError, constants.ErrEnableAcOut, err.Error(), map[string]string{
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrEnableAcOut, map[string]string{
 				"serial_number": sn,
 			})
+			return
 		}
 		h.RespondWithSuccess(w, ecoflowResponse)
 	}
@@ -261,27 +316,35 @@ type SetChargingSpeedRequest struct {
 // @Param requestBody body SetChargingSpeedRequest true "Charging Speed Request Body"
 // @Success 200 {object} SuccessResponse "Successfully set the charging speed"
 // @Failure 400 {object} ErrorResponse "Invalid request parameters or JSON body"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
 // @Failure 500 {object} ErrorResponse "Internal server error occurred while processing the request"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
 // @Router /api/power_station/{serial_number}/charging-speed [put]
 func (h *PowerStationHandler) PowerStationSetChargingSpeed() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sn := r.PathValue("serial_number")
 
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "input/speed")
+		if !ok {
+			return
+		}
+
 		var requestBody SetChargingSpeedRequest
 		err := json.NewDecoder(r.Body).Decode(&requestBody)
 		if err != nil {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
 				"serial_number": sn,
 				"error":         err.Error(),
 			})
 			return
 		}
 
-		if requestBody.Watts <= 0 {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. watts must be greater than 0", map[string]string{
-				"serial_number": sn,
-				"watts":         fmt.Sprintf("%d", requestBody.Watts),
-			})
+		if !h.checkRange(w, r, sn, command, "watts", float64(requestBody.Watts)) {
 			return
 		}
 
@@ -290,11 +353,26 @@ func (h *PowerStationHandler) PowerStationSetChargingSpeed() func(http.ResponseW
 			return
 		}
 
-		ecoflowResponse, err := client.GetPowerStation(sn).SetAcChargingSettings(context.Background(), requestBody.Watts, 0)
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		// the pause flag is sent with the charging power, a paused charger stays paused
+		paused, err := h.currentSetting(ctx, r, client, sn, powerStationAcChargePauseParam)
 		if err != nil {
-			h.RespondWithError(w, http.StatusInternalServerError, constants.ErrPowerStationSetChargingSpeed, err.Error(), map[string]string{
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetChargingSpeed, map[string]string{
 				"serial_number": sn,
 			})
+			return
+		}
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStation(sn).SetAcChargingSettings(ctx, requestBody.Watts, ecoflow.SettingSwitcher(paused))
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetChargingSpeed, map[string]string{
+				"serial_number": sn,
+			})
+			return
 		}
 		h.RespondWithSuccess(w, ecoflowResponse)
 	}
@@ -314,26 +392,34 @@ type InputAmpsRequest struct {
 // @Param requestBody body InputAmpsRequest true "Car Input Charging Request Body"
 // @Success 200 {object} SuccessResponse "Successfully set the car input current"
 // @Failure 400 {object} ErrorResponse "Invalid request parameters or JSON body"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
 // @Failure 500 {object} ErrorResponse "Internal server error occurred while processing the request"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
 // @Router /api/power_station/{serial_number}/car-input [put]
 func (h *PowerStationHandler) PowerStationSetCarInput() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sn := r.PathValue("serial_number")
 
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "input/car")
+		if !ok {
+			return
+		}
+
 		var requestBody InputAmpsRequest
 		err := json.NewDecoder(r.Body).Decode(&requestBody)
 		if err != nil {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
 				"error":         err.Error(),
 				"serial_number": sn,
 			})
 			return
 		}
-		if requestBody.InputAmps < 4 || requestBody.InputAmps > 10 {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. amps must be between 4 and 10", map[string]string{
-				"serial_number": sn,
-				"amps":          fmt.Sprintf("%d", requestBody.InputAmps),
-			})
+		if !h.checkRange(w, r, sn, command, "amps", float64(requestBody.InputAmps)) {
 			return
 		}
 
@@ -342,11 +428,17 @@ func (h *PowerStationHandler) PowerStationSetCarInput() func(http.ResponseWriter
 			return
 		}
 
-		ecoflowResponse, err := client.GetPowerStation(sn).Set12VDcChargingCurrent(context.Background(), requestBody.InputAmps*1000)
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStation(sn).Set12VDcChargingCurrent(ctx, requestBody.InputAmps*1000)
+		})
 		if err != nil {
-			h.RespondWithError(w, http.StatusInternalServerError, constants.ErrPowerStationSetCarInput, err.Error(), map[string]string{
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetCarInput, map[string]string{
 				"serial_number": sn,
 			})
+			return
 		}
 		h.RespondWithSuccess(w, ecoflowResponse)
 	}
@@ -367,17 +459,29 @@ type StandByRequest struct {
 // @Param requestBody body StandByRequest true "Standby Request Body"
 // @Success 200 {object} SuccessResponse "Successfully set the standby settings"
 // @Failure 400 {object} ErrorResponse "Invalid request parameters or JSON body"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
 // @Failure 500 {object} ErrorResponse "Internal server error occurred while processing the request"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
 // @Router /api/power_station/{serial_number}/standby [put]
 func (h *PowerStationHandler) PowerStationSetStandBy() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sn := r.PathValue("serial_number")
 
+		_, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "standby")
+		if !ok {
+			return
+		}
+
 		var requestBody StandByRequest
 
 		err := json.NewDecoder(r.Body).Decode(&requestBody)
 		if err != nil {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
 				"serial_number": sn,
 				"error":         err.Error(),
 			})
@@ -385,7 +489,7 @@ func (h *PowerStationHandler) PowerStationSetStandBy() func(http.ResponseWriter,
 		}
 
 		if requestBody.StandBy < 0 {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. stand_by must be greater than 0", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. stand_by must be greater than 0", map[string]string{
 				"serial_number": sn,
 				"stand_by":      fmt.Sprintf("%d", requestBody.StandBy),
 			})
@@ -393,7 +497,7 @@ func (h *PowerStationHandler) PowerStationSetStandBy() func(http.ResponseWriter,
 		}
 
 		if requestBody.Type != "device" && requestBody.Type != "ac" && requestBody.Type != "car" && requestBody.Type != "lcd" {
-			h.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. type must be 'device', 'ac', 'car' or 'lcd'", map[string]string{
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. type must be 'device', 'ac', 'car' or 'lcd'", map[string]string{
 				"serial_number": sn,
 				"type":          requestBody.Type,
 			})
@@ -404,11 +508,17 @@ func (h *PowerStationHandler) PowerStationSetStandBy() func(http.ResponseWriter,
 		if !ok {
 			return
 		}
-		ecoflowResponse, err := handleStandbyType(context.Background(), client.GetPowerStation(sn), requestBody.Type, requestBody.StandBy)
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return handleStandbyType(ctx, client.GetPowerStation(sn), requestBody.Type, requestBody.StandBy)
+		})
 		if err != nil {
-			h.RespondWithError(w, http.StatusInternalServerError, constants.ErrPowerStationSetStandBy, err.Error(), map[string]string{
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetStandBy, map[string]string{
 				"serial_number": sn,
 			})
+			return
 		}
 		h.RespondWithSuccess(w, ecoflowResponse)
 	}
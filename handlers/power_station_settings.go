@@ -0,0 +1,695 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/catalog"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/ingest"
+	"go-ecoflow-api-server/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// the AC charging power, the AC charging pause and the screen timeout are sent with the settings that change, they
+	// are read first
+	powerStationAcChargingWattsParam = "mppt.cfgChgWatts"
+	powerStationAcChargePauseParam   = "mppt.chgPauseFlag"
+	powerStationScreenTimeoutParam   = "pd.lcdOffSec"
+)
+
+// powerStationPvChargeTypes maps the PV charge types to the values of the power station.
+var powerStationPvChargeTypes = map[string]ecoflow.PowerStationPvChargeType{
+	"auto":    ecoflow.PowerStationPvChargeTypeAuto,
+	"mppt":    ecoflow.PowerStationPvChargeTypeMppt,
+	"adapter": ecoflow.PowerStationPvChargeTypeAdapter,
+}
+
+// PowerStationSetChargeLimit sets the state of charge the power station stops charging at.
+//
+// @Summary Set the charge limit of the power station
+// @Description Sets the state of charge in percent the power station stops charging at, from 50 to 100.
+// @Tags Power Station
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the power station"
+// @Param requestBody body BatteryLimitRequest true "Request body containing the percent"
+// @Success 200 {object} SuccessResponse "Successfully set the charge limit"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/power_station/{serial_number}/battery/charge_limit [put]
+func (h *PowerStationHandler) PowerStationSetChargeLimit() func(http.ResponseWriter, *http.Request) {
+	return h.setBatteryLimit("battery/charge_limit", constants.ErrPowerStationSetChargeLimit, func(ctx context.Context, ps *ecoflow.PowerStation, percent int) (*ecoflow.CmdSetResponse, error) {
+		return ps.SetMaxChargeSoC(ctx, percent)
+	})
+}
+
+// PowerStationSetDischargeLimit sets the state of charge the power station stops discharging at.
+//
+// @Summary Set the discharge limit of the power station
+// @Description Sets the state of charge in percent the power station stops discharging at, from 0 to 30.
+// @Tags Power Station
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the power station"
+// @Param requestBody body BatteryLimitRequest true "Request body containing the percent"
+// @Success 200 {object} SuccessResponse "Successfully set the discharge limit"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/power_station/{serial_number}/battery/discharge_limit [put]
+func (h *PowerStationHandler) PowerStationSetDischargeLimit() func(http.ResponseWriter, *http.Request) {
+	return h.setBatteryLimit("battery/discharge_limit", constants.ErrPowerStationSetDischargeLimit, func(ctx context.Context, ps *ecoflow.PowerStation, percent int) (*ecoflow.CmdSetResponse, error) {
+		return ps.SetMinDischargeSoC(ctx, percent)
+	})
+}
+
+// setBatteryLimit returns the handler of the charge and discharge limits, they only differ in the command.
+func (h *PowerStationHandler) setBatteryLimit(name, errorCode string, set func(context.Context, *ecoflow.PowerStation, int) (*ecoflow.CmdSetResponse, error)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStation, name)
+		if !ok {
+			return
+		}
+
+		var requestBody BatteryLimitRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.Percent == nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. percent is mandatory", map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		if !h.checkRange(w, r, sn, command, "percent", float64(*requestBody.Percent)) {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return set(ctx, client.GetPowerStation(sn), *requestBody.Percent)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, errorCode, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type GeneratorRequest struct {
+	// StartPercent is the state of charge the Smart Generator is started at, from 0 to 30
+	StartPercent *int `json:"start_percent"`
+	// StopPercent is the state of charge the Smart Generator is stopped at, from 50 to 100
+	StopPercent *int `json:"stop_percent"`
+}
+
+// GeneratorResponse contains the responses to the start and stop level commands.
+type GeneratorResponse struct {
+	Start *ecoflow.CmdSetResponse `json:"start" swaggertype:"object"`
+	Stop  *ecoflow.CmdSetResponse `json:"stop" swaggertype:"object"`
+}
+
+// PowerStationSetGenerator sets the states of charge the power station starts and stops the Smart Generator at.
+//
+// @Summary Set the Smart Generator auto start and stop of the power station
+// @Description Sets the state of charge in percent the power station starts the Smart Generator at (0-30) and stops it at (50-100). Only the DELTA models control a Smart Generator. The start and stop levels are two commands sent one after the other, the change is not atomic: if the stop level is rejected the start level is already set, the applied detail of the error tells which levels were set.
+// @Tags Power Station
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the power station"
+// @Param requestBody body GeneratorRequest true "Request body containing the start and stop state of charge"
+// @Success 200 {object} SuccessResponse{data=GeneratorResponse} "Successfully set the start and stop state of charge"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/power_station/{serial_number}/generator [put]
+func (h *PowerStationHandler) PowerStationSetGenerator() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "generator")
+		if !ok {
+			return
+		}
+
+		var requestBody GeneratorRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.StartPercent == nil || requestBody.StopPercent == nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. start_percent and stop_percent are mandatory", map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		if !h.checkRange(w, r, sn, command, "start_percent", float64(*requestBody.StartPercent)) ||
+			!h.checkRange(w, r, sn, command, "stop_percent", float64(*requestBody.StopPercent)) {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		// the start and stop levels are separate commands, the details tell which one failed and which one was set
+		ps := client.GetPowerStation(sn)
+		startResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return ps.SetSoCToTurnOnSmartGenerator(ctx, *requestBody.StartPercent)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetGenerator, map[string]string{
+				"serial_number": sn,
+				"setting":       "start_percent",
+				"applied":       "none",
+			})
+			return
+		}
+
+		stopResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return ps.SetSoCToTurnOffSmartGenerator(ctx, *requestBody.StopPercent)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetGenerator, map[string]string{
+				"serial_number": sn,
+				"setting":       "stop_percent",
+				"applied":       "start_percent",
+			})
+			return
+		}
+		h.RespondWithSuccess(w, GeneratorResponse{Start: startResponse, Stop: stopResponse})
+	}
+}
+
+// PowerStationSetBeep switches the beeper of the power station on or off.
+//
+// @Summary Switch the beeper of the power station on or off
+// @Description Switches the beeps of the power station on or off, off is the silent mode of the Ecoflow app.
+// @Tags Power Station
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the power station"
+// @Param requestBody body ChangeStateRequest true "Request body containing the state"
+// @Success 200 {object} SuccessResponse "Successfully switched the beeper"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/power_station/{serial_number}/beep [put]
+func (h *PowerStationHandler) PowerStationSetBeep() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		_, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "beep")
+		if !ok {
+			return
+		}
+
+		var requestBody ChangeStateRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.State != "on" && requestBody.State != "off" {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. State must be 'on' or 'off'", map[string]string{
+				"serial_number": sn,
+				"state":         requestBody.State,
+			})
+			return
+		}
+
+		// the power station has a silent mode, beeps are on while it is disabled
+		quietMode := ecoflow.SettingEnabled
+		if requestBody.State == "on" {
+			quietMode = ecoflow.SettingDisabled
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStation(sn).SetBuzzerSilentMode(ctx, quietMode)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetBeep, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type AcChargePauseRequest struct {
+	// Paused pauses AC charging while true, the power station resumes charging when it is plugged in again
+	Paused *bool `json:"paused"`
+}
+
+// PowerStationSetAcChargePause pauses or resumes AC charging of the power station.
+//
+// @Summary Pause or resume AC charging of the power station
+// @Description Pauses or resumes AC charging while the charging power is kept. The power station resumes charging when it is plugged in again and when the charging speed is set.
+// @Tags Power Station
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the power station"
+// @Param requestBody body AcChargePauseRequest true "Request body containing whether AC charging is paused"
+// @Success 200 {object} SuccessResponse "Successfully paused or resumed AC charging"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/power_station/{serial_number}/input/ac/pause [put]
+func (h *PowerStationHandler) PowerStationSetAcChargePause() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		_, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "input/ac/pause")
+		if !ok {
+			return
+		}
+
+		var requestBody AcChargePauseRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.Paused == nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. paused is mandatory", map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		pauseFlag := ecoflow.SettingDisabled
+		if *requestBody.Paused {
+			pauseFlag = ecoflow.SettingEnabled
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		watts, err := h.currentSetting(ctx, r, client, sn, powerStationAcChargingWattsParam)
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetAcChargePause, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStation(sn).SetAcChargingSettings(ctx, watts, pauseFlag)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetAcChargePause, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type AcChargeModeRequest struct {
+	// Mode is slow to charge with the lowest or fast to charge with the highest AC charging power of the model
+	Mode string `json:"mode"`
+}
+
+// PowerStationSetAcChargeMode switches AC charging of the power station between slow and fast.
+//
+// @Summary Switch AC charging of the power station between slow and fast
+// @Description Sets the AC charging power to the lowest (slow) or highest (fast) charging power of the model, see the capabilities of the device. Paused AC charging stays paused. Models that are not in the catalog are rejected, their charging power is unknown.
+// @Tags Power Station
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the power station"
+// @Param requestBody body AcChargeModeRequest true "Request body containing the mode"
+// @Success 200 {object} SuccessResponse "Successfully switched the AC charging mode"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/power_station/{serial_number}/input/ac/mode [put]
+func (h *PowerStationHandler) PowerStationSetAcChargeMode() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		_, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "input/ac/mode")
+		if !ok {
+			return
+		}
+
+		// the modes are the limits of the charging speed of the model
+		speed, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "input/speed")
+		if !ok {
+			return
+		}
+		chargingWatts, _ := speed.Range("watts")
+		// the generic range of unknown models is wider than the charging power of any model, fast could overload it
+		if model := catalog.Identify(sn); model.Family == catalog.FamilyUnknown {
+			h.RespondWithError(w, r, http.StatusUnprocessableEntity, constants.ErrCommandUnsupported, "The charging power of the device model is unknown, set the charging power with input/speed", map[string]string{
+				"serial_number": sn,
+				"model":         model.Name,
+				"command":       string(catalog.FamilyPowerStation) + "/input/ac/mode",
+			})
+			return
+		}
+
+		var requestBody AcChargeModeRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		var watts int
+		switch requestBody.Mode {
+		case "slow":
+			watts = int(chargingWatts.Min)
+		case "fast":
+			watts = int(chargingWatts.Max)
+		default:
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. mode must be 'slow' or 'fast'", map[string]string{
+				"serial_number": sn,
+				"mode":          requestBody.Mode,
+			})
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		paused, err := h.currentSetting(ctx, r, client, sn, powerStationAcChargePauseParam)
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetAcChargeMode, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStation(sn).SetAcChargingSettings(ctx, watts, ecoflow.SettingSwitcher(paused))
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetAcChargeMode, map[string]string{
+				"serial_number": sn,
+				"watts":         strconv.Itoa(watts),
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type PvChargeTypeRequest struct {
+	// Type is the charge type of the PV input: auto, mppt or adapter
+	Type string `json:"type"`
+	// Type2 is the charge type of the second PV input of models that have two, it defaults to type
+	Type2 string `json:"type2,omitempty"`
+}
+
+// PowerStationSetPvChargeType sets the charge type of the PV inputs of the power station.
+//
+// @Summary Set the PV charge type of the power station
+// @Description Sets whether the PV inputs detect the charge type (auto), charge from solar panels (mppt) or from a DC adapter (adapter).
+// @Tags Power Station
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the power station"
+// @Param requestBody body PvChargeTypeRequest true "Request body containing the charge types"
+// @Success 200 {object} SuccessResponse "Successfully set the PV charge type"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/power_station/{serial_number}/input/pv_type [put]
+func (h *PowerStationHandler) PowerStationSetPvChargeType() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		_, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "input/pv_type")
+		if !ok {
+			return
+		}
+
+		var requestBody PvChargeTypeRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.Type2 == "" {
+			requestBody.Type2 = requestBody.Type
+		}
+		chargeType, known := powerStationPvChargeTypes[requestBody.Type]
+		chargeType2, known2 := powerStationPvChargeTypes[requestBody.Type2]
+		if !known || !known2 {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. type and type2 must be 'auto', 'mppt' or 'adapter'", map[string]string{
+				"serial_number": sn,
+				"type":          requestBody.Type,
+				"type2":         requestBody.Type2,
+			})
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStation(sn).SetPvChargingTypeSettings(ctx, chargeType, chargeType2)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetPvChargeType, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type ScreenBrightnessRequest struct {
+	// Level is the brightness level of the screen, from 0 to 3
+	Level *int `json:"level"`
+}
+
+// PowerStationSetScreenBrightness sets the brightness of the screen of the power station.
+//
+// @Summary Set the screen brightness of the power station
+// @Description Sets the brightness level of the screen, from 0 to 3, the screen timeout is kept.
+// @Tags Power Station
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the power station"
+// @Param requestBody body ScreenBrightnessRequest true "Request body containing the brightness level"
+// @Success 200 {object} SuccessResponse "Successfully set the screen brightness"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/power_station/{serial_number}/screen/brightness [put]
+func (h *PowerStationHandler) PowerStationSetScreenBrightness() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStation, "screen/brightness")
+		if !ok {
+			return
+		}
+
+		var requestBody ScreenBrightnessRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.Level == nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. level is mandatory", map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		if !h.checkRange(w, r, sn, command, "level", float64(*requestBody.Level)) {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		timeout, err := h.currentSetting(ctx, r, client, sn, powerStationScreenTimeoutParam)
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetScreen, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		// go-ecoflow always sends brightness level 3 with the screen timeout
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.SetDeviceParameter(ctx, powerStationCommand(sn, ecoflow.ModuleTypePd, "lcdCfg", map[string]interface{}{
+				"delayOff":   timeout,
+				"brighLevel": *requestBody.Level,
+			}))
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStationSetScreen, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+// currentSetting reads the current value of a setting of the power station. Some commands set several settings at
+// once, the settings that don't change are sent with their current value.
+func (h *PowerStationHandler) currentSetting(ctx context.Context, r *http.Request, client *ecoflow.Client, sn, param string) (int, error) {
+	response, err := readUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.GetCmdResponse, error) {
+		return client.GetDeviceParameters(ctx, sn, []string{param})
+	})
+	if err != nil {
+		return 0, err
+	}
+	value, ok := ingest.Number(response.Data[param])
+	if !ok {
+		return 0, &service.UpstreamError{Class: service.ErrorClassBadResponse, Err: fmt.Errorf("%s is missing in the response", param)}
+	}
+	return int(value), nil
+}
+
+// powerStationCommand returns a command of the power station, it is sent with client.SetDeviceParameter.
+func powerStationCommand(sn string, moduleType ecoflow.ModuleType, operateType string, params map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          strconv.FormatInt(time.Now().UnixMilli(), 10),
+		"version":     "1.0",
+		"sn":          sn,
+		"moduleType":  moduleType,
+		"operateType": operateType,
+		"params":      params,
+	}
+}
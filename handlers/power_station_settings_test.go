@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/constants"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPowerStationHandler_SendsSettings(t *testing.T) {
+	var commands []map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			_, _ = w.Write([]byte(`{"code":"0","data":{"mppt.cfgChgWatts":800,"mppt.chgPauseFlag":1,"pd.lcdOffSec":300}}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var command map[string]interface{}
+		_ = json.Unmarshal(body, &command)
+		commands = append(commands, command)
+		_, _ = w.Write([]byte(`{"code":"0","message":"Success"}`))
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewPowerStationHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	type command struct {
+		operateType string
+		params      map[string]interface{}
+	}
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		commands []command
+	}{
+		{
+			name:     "charge limit",
+			path:     "/battery/charge_limit",
+			body:     `{"percent":90}`,
+			commands: []command{{"upsConfig", map[string]interface{}{"maxChgSoc": 90.0}}},
+		},
+		{
+			name:     "discharge limit",
+			path:     "/battery/discharge_limit",
+			body:     `{"percent":10}`,
+			commands: []command{{"dsgCfg", map[string]interface{}{"minDsgSoc": 10.0}}},
+		},
+		{
+			name: "generator",
+			path: "/generator",
+			body: `{"start_percent":20,"stop_percent":80}`,
+			commands: []command{
+				{"openOilSoc", map[string]interface{}{"openOilSoc": 20.0}},
+				{"closeOilSoc", map[string]interface{}{"closeOilSoc": 80.0}},
+			},
+		},
+		{
+			name:     "beep off",
+			path:     "/beep",
+			body:     `{"state":"off"}`,
+			commands: []command{{"quietMode", map[string]interface{}{"enabled": 1.0}}},
+		},
+		{
+			name:     "pause keeps the charging power",
+			path:     "/input/ac/pause",
+			body:     `{"paused":true}`,
+			commands: []command{{"acChgCfg", map[string]interface{}{"chgWatts": 800.0, "chgPauseFlag": 1.0}}},
+		},
+		{
+			name:     "fast charging keeps the pause",
+			path:     "/input/ac/mode",
+			body:     `{"mode":"fast"}`,
+			commands: []command{{"acChgCfg", map[string]interface{}{"chgWatts": 1200.0, "chgPauseFlag": 1.0}}},
+		},
+		{
+			name:     "charging speed keeps the pause",
+			path:     "/input/speed",
+			body:     `{"watts":500}`,
+			commands: []command{{"acChgCfg", map[string]interface{}{"chgWatts": 500.0, "chgPauseFlag": 1.0}}},
+		},
+		{
+			name:     "slow charging",
+			path:     "/input/ac/mode",
+			body:     `{"mode":"slow"}`,
+			commands: []command{{"acChgCfg", map[string]interface{}{"chgWatts": 200.0, "chgPauseFlag": 1.0}}},
+		},
+		{
+			name:     "pv charge type",
+			path:     "/input/pv_type",
+			body:     `{"type":"mppt"}`,
+			commands: []command{{"chaType", map[string]interface{}{"chaType": 1.0, "chaType2": 1.0}}},
+		},
+		{
+			name:     "screen brightness keeps the timeout",
+			path:     "/screen/brightness",
+			body:     `{"level":1}`,
+			commands: []command{{"lcdCfg", map[string]interface{}{"delayOff": 300.0, "brighLevel": 1.0}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands = nil
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/power_station/R331ZEB4ZEAL0528"+tt.path, strings.NewReader(tt.body)))
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			require.Len(t, commands, len(tt.commands))
+			for i, expected := range tt.commands {
+				assert.Equal(t, "R331ZEB4ZEAL0528", commands[i]["sn"])
+				assert.Equal(t, expected.operateType, commands[i]["operateType"])
+				assert.Equal(t, expected.params, commands[i]["params"])
+			}
+		})
+	}
+}
+
+func TestPowerStationHandler_ValidatesSettingsBeforeUpstream(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewPowerStationHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "charge limit too low", path: "/R331ZEB4ZEAL0528/battery/charge_limit", body: `{"percent":40}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "discharge limit too high", path: "/R331ZEB4ZEAL0528/battery/discharge_limit", body: `{"percent":31}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "missing discharge limit", path: "/R331ZEB4ZEAL0528/battery/discharge_limit", body: `{}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "generator start too high", path: "/R331ZEB4ZEAL0528/generator", body: `{"start_percent":40,"stop_percent":80}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "missing generator start", path: "/R331ZEB4ZEAL0528/generator", body: `{"stop_percent":80}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "generator on a RIVER", path: "/R601ZCB5HX000000/generator", body: `{"start_percent":20,"stop_percent":80}`, status: http.StatusUnprocessableEntity, code: constants.ErrCommandUnsupported},
+		{name: "unknown beep state", path: "/R331ZEB4ZEAL0528/beep", body: `{"state":"loud"}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "missing pause", path: "/R331ZEB4ZEAL0528/input/ac/pause", body: `{}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "unknown charge mode", path: "/R331ZEB4ZEAL0528/input/ac/mode", body: `{"mode":"turbo"}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "charge mode of an unknown model", path: "/X999ZEB4ZEAL0528/input/ac/mode", body: `{"mode":"fast"}`, status: http.StatusUnprocessableEntity, code: constants.ErrCommandUnsupported},
+		{name: "unknown pv charge type", path: "/R331ZEB4ZEAL0528/input/pv_type", body: `{"type":"wind"}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "screen brightness too high", path: "/R331ZEB4ZEAL0528/screen/brightness", body: `{"level":4}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "smart plug", path: "/HW52ZDH4SF123456/beep", body: `{"state":"on"}`, status: http.StatusUnprocessableEntity, code: constants.ErrCommandUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/power_station"+tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+	assert.Zero(t, calls.Load())
+}
+
+func TestPowerStationHandler_SetGenerator_ReportsBothCommands(t *testing.T) {
+	var calls atomic.Int32
+	var offline atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if offline.Load() && calls.Load()%2 == 0 {
+			_, _ = w.Write([]byte(`{"code":"9999","message":"current device is offline"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":"0","message":"Success"}`))
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewPowerStationHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+	send := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/power_station/R331ZEB4ZEAL0528/generator", strings.NewReader(`{"start_percent":20,"stop_percent":80}`)))
+		return rec
+	}
+
+	rec := send()
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"success":true,"data":{"start":{"code":"0","message":"Success"},"stop":{"code":"0","message":"Success"}}}`, rec.Body.String())
+
+	// the stop level is rejected after the start level was set
+	offline.Store(true)
+	rec = send()
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"setting":"stop_percent"`)
+	assert.Contains(t, rec.Body.String(), `"applied":"start_percent"`)
+	assert.Equal(t, int32(4), calls.Load())
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"go-ecoflow-api-server/constants"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPowerStationHandler_ChecksCommandResponseCode(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":"9999","message":"current device is offline"}`))
+	}))
+	defer upstream.Close()
+
+	handler := NewPowerStationHandler(newUpstreamHandler(upstream))
+	req := httptest.NewRequest(http.MethodPut, "/api/power_station/R331ZEB4ZEAL0528/out/dc", strings.NewReader(`{"state":"on"}`))
+	rec := httptest.NewRecorder()
+	handler.PowerStationEnableDc()(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), constants.ErrDeviceOffline)
+	assert.Contains(t, rec.Body.String(), `"ecoflow_code":"9999"`)
+}
+
+func TestPowerStationHandler_ValidatesCapabilitiesBeforeUpstream(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewPowerStationHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+		// detail is a part of the error that identifies the rejected field
+		detail string
+	}{
+		{name: "smart plug", path: "/api/power_station/HW52ZDH4SF123456/out/dc", body: `{"state":"on"}`, status: http.StatusUnprocessableEntity, code: constants.ErrCommandUnsupported},
+		{name: "smart home panel", path: "/api/power_station/SP10ZAW5ZE9E0052/input/speed", body: `{"watts":500}`, status: http.StatusUnprocessableEntity, code: constants.ErrCommandUnsupported},
+		{name: "charging power above the model maximum", path: "/api/power_station/R331ZEB4ZEAL0528/input/speed", body: `{"watts":1500}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "charging power below the model minimum", path: "/api/power_station/R601ZCB5HX000000/input/speed", body: `{"watts":50}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "unsupported voltage", path: "/api/power_station/R331ZEB4ZEAL0528/out/ac", body: `{"ac_state":"on","xboost_state":"off","out_freq":50,"out_voltage":200}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters, detail: `"out_voltage":"200"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tt.code+`"`)
+			assert.Contains(t, rec.Body.String(), tt.detail)
+		})
+	}
+	assert.Zero(t, calls.Load())
+}
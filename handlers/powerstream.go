@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/catalog"
+	"go-ecoflow-api-server/constants"
+	"math"
+	"net/http"
+)
+
+const (
+	// go-ecoflow validates the custom load in watts but sends it as is, while the inverter expects tenths of a watt
+	powerStreamSetPermanentWattsCmd = "WN511_SET_PERMANENT_WATTS_PACK"
+)
+
+// powerStreamSupplyPriorities maps the supply priorities to the values of the inverter.
+var powerStreamSupplyPriorities = map[string]int{
+	"supply":  0,
+	"battery": 1,
+}
+
+type PowerStreamHandler struct {
+	*BaseHandler
+}
+
+func NewPowerStreamHandler(baseHandler *BaseHandler) *PowerStreamHandler {
+	return &PowerStreamHandler{baseHandler}
+}
+
+func (h *PowerStreamHandler) RegisterRoutes(router chi.Router) {
+	router.Put("/api/powerstream/{serial_number}/custom_load", h.PowerStreamSetCustomLoad())
+	router.Put("/api/powerstream/{serial_number}/supply_priority", h.PowerStreamSetSupplyPriority())
+	router.Put("/api/powerstream/{serial_number}/battery/lower_limit", h.PowerStreamSetBatteryLowerLimit())
+	router.Put("/api/powerstream/{serial_number}/battery/upper_limit", h.PowerStreamSetBatteryUpperLimit())
+	router.Put("/api/powerstream/{serial_number}/brightness", h.PowerStreamSetBrightness())
+}
+
+type CustomLoadRequest struct {
+	// Watts the inverter feeds into the home, from 0 to 600 with a resolution of 0.1
+	Watts *float64 `json:"watts"`
+}
+
+// PowerStreamSetCustomLoad sets the custom load power of the PowerStream.
+//
+// @Summary Set the custom load power of the PowerStream
+// @Description Sets the power in watts the PowerStream feeds into the home, from 0 to 600.
+// @Tags PowerStream
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the PowerStream"
+// @Param requestBody body CustomLoadRequest true "Request body containing the custom load power"
+// @Success 200 {object} SuccessResponse "Successfully set the custom load power"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/powerstream/{serial_number}/custom_load [put]
+func (h *PowerStreamHandler) PowerStreamSetCustomLoad() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStream, "custom_load")
+		if !ok {
+			return
+		}
+
+		var requestBody CustomLoadRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.Watts == nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. watts is mandatory", map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		if !h.checkRange(w, r, sn, command, "watts", *requestBody.Watts) {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.SetDeviceParameter(ctx, deviceCommand(sn, powerStreamSetPermanentWattsCmd, map[string]interface{}{
+				"permanentWatts": int(math.Round(*requestBody.Watts * 10)),
+			}))
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStreamSetCustomLoad, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type SupplyPriorityRequest struct {
+	// Priority is supply to power the load first or battery to charge the battery first
+	Priority string `json:"priority"`
+}
+
+// PowerStreamSetSupplyPriority sets the power supply priority of the PowerStream.
+//
+// @Summary Set the power supply priority of the PowerStream
+// @Description Sets whether the PowerStream powers the load first (supply) or charges the battery first (battery).
+// @Tags PowerStream
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the PowerStream"
+// @Param requestBody body SupplyPriorityRequest true "Request body containing the priority"
+// @Success 200 {object} SuccessResponse "Successfully set the supply priority"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/powerstream/{serial_number}/supply_priority [put]
+func (h *PowerStreamHandler) PowerStreamSetSupplyPriority() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		_, ok := h.command(w, r, sn, catalog.FamilyPowerStream, "supply_priority")
+		if !ok {
+			return
+		}
+
+		var requestBody SupplyPriorityRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		priority, known := powerStreamSupplyPriorities[requestBody.Priority]
+		if !known {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. priority must be 'supply' or 'battery'", map[string]string{
+				"serial_number": sn,
+				"priority":      requestBody.Priority,
+			})
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStreamMicroInverter(sn).SetPowerSupplyPriority(ctx, priority)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStreamSetSupplyPriority, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type BatteryLimitRequest struct {
+	Percent *int `json:"percent"`
+}
+
+// PowerStreamSetBatteryLowerLimit sets the state of charge the PowerStream stops discharging the battery at.
+//
+// @Summary Set the lower battery limit of the PowerStream
+// @Description Sets the state of charge in percent the PowerStream stops discharging the battery at, from 1 to 30.
+// @Tags PowerStream
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the PowerStream"
+// @Param requestBody body BatteryLimitRequest true "Request body containing the limit"
+// @Success 200 {object} SuccessResponse "Successfully set the lower battery limit"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/powerstream/{serial_number}/battery/lower_limit [put]
+func (h *PowerStreamHandler) PowerStreamSetBatteryLowerLimit() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStream, "battery/lower_limit")
+		if !ok {
+			return
+		}
+
+		var requestBody BatteryLimitRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.Percent == nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. percent is mandatory", map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		if !h.checkRange(w, r, sn, command, "percent", float64(*requestBody.Percent)) {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStreamMicroInverter(sn).SetLowerLimitSettingsForBatterDischarging(ctx, float64(*requestBody.Percent))
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStreamSetBatteryLowerLimit, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+// PowerStreamSetBatteryUpperLimit sets the state of charge the PowerStream stops charging the battery at.
+//
+// @Summary Set the upper battery limit of the PowerStream
+// @Description Sets the state of charge in percent the PowerStream stops charging the battery at, from 70 to 100.
+// @Tags PowerStream
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the PowerStream"
+// @Param requestBody body BatteryLimitRequest true "Request body containing the limit"
+// @Success 200 {object} SuccessResponse "Successfully set the upper battery limit"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/powerstream/{serial_number}/battery/upper_limit [put]
+func (h *PowerStreamHandler) PowerStreamSetBatteryUpperLimit() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStream, "battery/upper_limit")
+		if !ok {
+			return
+		}
+
+		var requestBody BatteryLimitRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.Percent == nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. percent is mandatory", map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		if !h.checkRange(w, r, sn, command, "percent", float64(*requestBody.Percent)) {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStreamMicroInverter(sn).SetUpperLimitSettingsForBatterCharging(ctx, float64(*requestBody.Percent))
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStreamSetBatteryUpperLimit, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+// PowerStreamSetBrightness sets the brightness of the LED indicator.
+//
+// @Summary Set the LED brightness of the PowerStream
+// @Description Sets the brightness of the LED indicator of the PowerStream, from 0 (off) to 1023.
+// @Tags PowerStream
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the PowerStream"
+// @Param requestBody body BrightnessRequest true "Request body containing the brightness"
+// @Success 200 {object} SuccessResponse "Successfully set the brightness"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/powerstream/{serial_number}/brightness [put]
+func (h *PowerStreamHandler) PowerStreamSetBrightness() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		command, ok := h.command(w, r, sn, catalog.FamilyPowerStream, "brightness")
+		if !ok {
+			return
+		}
+
+		var requestBody BrightnessRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.Brightness == nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. brightness is mandatory", map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		if !h.checkRange(w, r, sn, command, "brightness", float64(*requestBody.Brightness)) {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetPowerStreamMicroInverter(sn).SetLightBrightness(ctx, float64(*requestBody.Brightness))
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrPowerStreamSetBrightness, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
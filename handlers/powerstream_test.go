@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/constants"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPowerStreamHandler_SendsCommands(t *testing.T) {
+	var command map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		command = nil
+		_ = json.Unmarshal(body, &command)
+		_, _ = w.Write([]byte(`{"code":"0","message":"Success"}`))
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewPowerStreamHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		cmdCode string
+		params  map[string]interface{}
+	}{
+		{
+			name:    "custom load is sent in tenths of a watt",
+			path:    "/custom_load",
+			body:    `{"watts":250.5}`,
+			cmdCode: "WN511_SET_PERMANENT_WATTS_PACK",
+			params:  map[string]interface{}{"permanentWatts": 2505.0},
+		},
+		{
+			name:    "supply priority",
+			path:    "/supply_priority",
+			body:    `{"priority":"battery"}`,
+			cmdCode: "WN511_SET_SUPPLY_PRIORITY_PACK",
+			params:  map[string]interface{}{"supplyPriority": 1.0},
+		},
+		{
+			name:    "lower battery limit",
+			path:    "/battery/lower_limit",
+			body:    `{"percent":10}`,
+			cmdCode: "WN511_SET_BAT_LOWER_PACK",
+			params:  map[string]interface{}{"lowerLimit": 10.0},
+		},
+		{
+			name:    "upper battery limit",
+			path:    "/battery/upper_limit",
+			body:    `{"percent":100}`,
+			cmdCode: "WN511_SET_BAT_UPPER_PACK",
+			params:  map[string]interface{}{"upperLimit": 100.0},
+		},
+		{
+			name:    "brightness",
+			path:    "/brightness",
+			body:    `{"brightness":1023}`,
+			cmdCode: "WN511_SET_BRIGHTNESS_PACK",
+			params:  map[string]interface{}{"brightness": 1023.0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/powerstream/HW51ZOH4SF123456"+tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Equal(t, "HW51ZOH4SF123456", command["sn"])
+			assert.Equal(t, tt.cmdCode, command["cmdCode"])
+			assert.Equal(t, tt.params, command["params"])
+		})
+	}
+}
+
+func TestPowerStreamHandler_ValidatesBeforeUpstream(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewPowerStreamHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		code string
+	}{
+		{name: "invalid json", path: "/custom_load", body: `{`, code: constants.ErrInvalidJsonBody},
+		{name: "missing custom load", path: "/custom_load", body: `{}`, code: constants.ErrInvalidParameters},
+		{name: "custom load too high", path: "/custom_load", body: `{"watts":600.1}`, code: constants.ErrInvalidParameters},
+		{name: "negative custom load", path: "/custom_load", body: `{"watts":-1}`, code: constants.ErrInvalidParameters},
+		{name: "unknown supply priority", path: "/supply_priority", body: `{"priority":"grid"}`, code: constants.ErrInvalidParameters},
+		{name: "lower limit too low", path: "/battery/lower_limit", body: `{"percent":0}`, code: constants.ErrInvalidParameters},
+		{name: "lower limit too high", path: "/battery/lower_limit", body: `{"percent":31}`, code: constants.ErrInvalidParameters},
+		{name: "missing lower limit", path: "/battery/lower_limit", body: `{}`, code: constants.ErrInvalidParameters},
+		{name: "upper limit too low", path: "/battery/upper_limit", body: `{"percent":69}`, code: constants.ErrInvalidParameters},
+		{name: "upper limit too high", path: "/battery/upper_limit", body: `{"percent":101}`, code: constants.ErrInvalidParameters},
+		{name: "brightness too high", path: "/brightness", body: `{"brightness":1024}`, code: constants.ErrInvalidParameters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/powerstream/HW51ZOH4SF123456"+tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+	assert.Zero(t, calls.Load())
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/constants"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentTypeProblemJSON is the media type of RFC 7807 problem documents.
+const ContentTypeProblemJSON = "application/problem+json"
+
+// ProblemDetails is an RFC 7807 problem document. It is sent instead of ErrorResponse when the client accepts
+// application/problem+json. Type is derived from the error code, Code and Details are extension members that carry the
+// same information as ErrorField.
+type ProblemDetails struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Status   int         `json:"status"`
+	Detail   string      `json:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty"`
+	Code     string      `json:"code"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+// ProblemType returns the problem type URI of an error code from constants, e.g. "urn:go-ecoflow-api-server:error:0010".
+func ProblemType(code string) string {
+	return constants.ProblemTypePrefix + code
+}
+
+func (b *BaseHandler) respondWithProblem(w http.ResponseWriter, r *http.Request, statusCode int, code, message string, details interface{}) {
+	title := http.StatusText(statusCode)
+	if statusCode == StatusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+	problem := ProblemDetails{
+		Type:     ProblemType(code),
+		Title:    title,
+		Status:   statusCode,
+		Detail:   message,
+		Instance: r.URL.RequestURI(),
+		Code:     code,
+		Details:  details,
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblemJSON)
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		b.Logger.Error("failed to write problem response", "error", err)
+	}
+}
+
+// acceptsProblemJSON reports whether the Accept header of the request contains application/problem+json with a
+// non-zero quality. Without it the default ErrorResponse envelope is used.
+func acceptsProblemJSON(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil || mediaType != ContentTypeProblemJSON {
+				continue
+			}
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
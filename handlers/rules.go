@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/rules"
+	"net/http"
+)
+
+// RuleHandler manages the rules that send power station commands when the parameters of a device cross a threshold.
+// Rules belong to the vault account of the API key they are managed with, their commands are sent with the credentials
+// of the account.
+type RuleHandler struct {
+	*BaseHandler
+	store *rules.Store
+}
+
+func NewRuleHandler(baseHandler *BaseHandler, store *rules.Store) *RuleHandler {
+	return &RuleHandler{BaseHandler: baseHandler, store: store}
+}
+
+func (h *RuleHandler) RegisterRoutes(router chi.Router) {
+	router.Get("/api/rules", h.ListRules())
+	router.Post("/api/rules", h.CreateRule())
+	router.Get("/api/rules/{id}", h.GetRule())
+	router.Put("/api/rules/{id}", h.UpdateRule())
+	router.Delete("/api/rules/{id}", h.DeleteRule())
+	router.Get("/api/rules/{id}/evaluations", h.GetRuleEvaluations())
+}
+
+type RuleRequest struct {
+	Name string `json:"name" example:"AC off on low battery"`
+	// SerialNumber is the device whose parameters the conditions are evaluated over
+	SerialNumber string `json:"serial_number" example:"R601ZEB4ZEAL0528"`
+	// Condition fires the rule when it becomes true
+	Condition string `json:"condition" example:"bms_bmsStatus.soc < 20"`
+	// Clear arms the rule again after it fired, by default when the condition is false
+	Clear string `json:"clear" example:"bms_bmsStatus.soc > 30"`
+	// CooldownSeconds is the minimum time between two firings of the rule
+	CooldownSeconds int               `json:"cooldown_seconds" example:"600"`
+	Action          RuleActionRequest `json:"action"`
+	// DryRun rules are evaluated and logged but don't send their command
+	DryRun bool `json:"dry_run"`
+	// Enabled is true by default
+	Enabled *bool `json:"enabled"`
+}
+
+type RuleActionRequest struct {
+	// SerialNumbers are the devices the command is sent to, the evaluated device by default
+	SerialNumbers []string `json:"serial_numbers"`
+	// Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac
+	Command string `json:"command" example:"out/ac"`
+	// Payload is the request body of the command
+	Payload json.RawMessage `json:"payload" swaggertype:"object"`
+}
+
+// ListRules lists the rules of the account
+// @Summary List rules
+// @Description Returns the rules of the vault account of the API key with their state.
+// @Tags Rules
+// @Produce json
+// @Success 200 {object} SuccessResponse{data=[]rules.Rule} "Rules of the account"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Router /api/rules [get]
+func (h *RuleHandler) ListRules() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.ruleAccount(w, r)
+		if !ok {
+			return
+		}
+		h.RespondWithSuccess(w, h.store.List(account))
+	}
+}
+
+// CreateRule creates a rule
+// @Summary Create a rule
+// @Description Creates a rule that sends a power station command, e.g. out/ac, with the payload to the devices when the condition over the parameters of the device becomes true. The conditions are validated, the command is validated against the capabilities of the devices.
+// @Tags Rules
+// @Accept json
+// @Produce json
+// @Param requestBody body RuleRequest true "Request body containing the rule"
+// @Success 200 {object} SuccessResponse{data=rules.Rule} "Rule created"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Error saving the rule"
+// @Router /api/rules [post]
+func (h *RuleHandler) CreateRule() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.ruleAccount(w, r)
+		if !ok {
+			return
+		}
+		rule, ok := h.ruleFromRequest(w, r)
+		if !ok {
+			return
+		}
+		rule.Account = account
+
+		created, err := h.store.Create(rule)
+		if err != nil {
+			h.respondWithStoreError(w, r, "", err)
+			return
+		}
+		h.RespondWithSuccess(w, created)
+	}
+}
+
+// GetRule returns a rule
+// @Summary Get a rule
+// @Tags Rules
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Success 200 {object} SuccessResponse{data=rules.Rule} "Rule"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Rule not found"
+// @Router /api/rules/{id} [get]
+func (h *RuleHandler) GetRule() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.ruleAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		rule, err := h.store.Get(account, id)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, rule)
+	}
+}
+
+// UpdateRule replaces a rule
+// @Summary Update a rule
+// @Description Replaces the rule and arms it again. The cooldown of an earlier firing still applies.
+// @Tags Rules
+// @Accept json
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Param requestBody body RuleRequest true "Request body containing the rule"
+// @Success 200 {object} SuccessResponse{data=rules.Rule} "Rule updated"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Rule not found"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Error saving the rule"
+// @Router /api/rules/{id} [put]
+func (h *RuleHandler) UpdateRule() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.ruleAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		rule, ok := h.ruleFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		updated, err := h.store.Update(account, id, rule)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, updated)
+	}
+}
+
+// DeleteRule deletes a rule
+// @Summary Delete a rule
+// @Description Deletes the rule and its evaluation log.
+// @Tags Rules
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Success 200 {object} SuccessResponse "Rule deleted"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Rule not found"
+// @Failure 500 {object} ErrorResponse "Error saving the rules"
+// @Router /api/rules/{id} [delete]
+func (h *RuleHandler) DeleteRule() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.ruleAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		if err := h.store.Delete(account, id); err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, nil)
+	}
+}
+
+// GetRuleEvaluations returns the evaluation log of a rule
+// @Summary Get the evaluations of a rule
+// @Description Returns the latest logged evaluations of the rule, newest first: when it fired, with the response of the command for every device, would have fired in dry-run mode, was held back by the cooldown, was armed again, or couldn't be evaluated because the parameters were not available.
+// @Tags Rules
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Success 200 {object} SuccessResponse{data=[]rules.Evaluation} "Evaluations of the rule"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Rule not found"
+// @Router /api/rules/{id}/evaluations [get]
+func (h *RuleHandler) GetRuleEvaluations() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.ruleAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		evaluations, err := h.store.Evaluations(account, id)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, evaluations)
+	}
+}
+
+// ruleAccount returns the vault account of the request or responds with 403 if the request is not authenticated with
+// an API key.
+func (h *RuleHandler) ruleAccount(w http.ResponseWriter, r *http.Request) (string, bool) {
+	return h.vaultAccount(w, r, constants.ErrRuleRequiresAPIKey, "Rules can only be managed with an API key of a vault account")
+}
+
+// ruleFromRequest decodes and validates the rule of the request body.
+func (h *RuleHandler) ruleFromRequest(w http.ResponseWriter, r *http.Request) (rules.Rule, bool) {
+	var requestBody RuleRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			"error": err.Error(),
+		})
+		return rules.Rule{}, false
+	}
+
+	rule := rules.Rule{
+		Name:            requestBody.Name,
+		SerialNumber:    requestBody.SerialNumber,
+		Condition:       requestBody.Condition,
+		Clear:           requestBody.Clear,
+		CooldownSeconds: requestBody.CooldownSeconds,
+		Action: rules.Action{
+			SerialNumbers: requestBody.Action.SerialNumbers,
+			Command:       requestBody.Action.Command,
+			Payload:       requestBody.Action.Payload,
+		},
+		DryRun:  requestBody.DryRun,
+		Enabled: requestBody.Enabled == nil || *requestBody.Enabled,
+	}
+	if len(rule.Action.SerialNumbers) == 0 && rule.SerialNumber != "" {
+		rule.Action.SerialNumbers = []string{rule.SerialNumber}
+	}
+	if rule.SerialNumber == "" {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. serial_number is mandatory", nil)
+		return rules.Rule{}, false
+	}
+	if !h.checkSerialNumbers(w, r, []string{rule.SerialNumber}) {
+		return rules.Rule{}, false
+	}
+	if err = rule.Validate(); err != nil {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. "+err.Error(), map[string]string{
+			"condition": rule.Condition,
+			"clear":     rule.Clear,
+		})
+		return rules.Rule{}, false
+	}
+	if !h.checkPowerStationCommand(w, r, rule.Action.SerialNumbers, rule.Action.Command, rule.Action.Payload) {
+		return rules.Rule{}, false
+	}
+	return rule, true
+}
+
+func (h *RuleHandler) respondWithStoreError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	if errors.Is(err, rules.ErrRuleNotFound) {
+		h.RespondWithError(w, r, http.StatusNotFound, constants.ErrRuleNotFound, "Rule not found", map[string]string{
+			"id": id,
+		})
+		return
+	}
+	h.RespondWithError(w, r, http.StatusInternalServerError, constants.ErrSaveRule, "Failed to save the rules", map[string]string{
+		"error": err.Error(),
+	})
+}
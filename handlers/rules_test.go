@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/rules"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRuleRouter returns a router with the rule endpoints, see newAccountRouter.
+func newRuleRouter(t *testing.T) chi.Router {
+	store, err := rules.Open(filepath.Join(t.TempDir(), "rules.json"), 10)
+	require.NoError(t, err)
+	return newAccountRouter(t, func(baseHandler *BaseHandler) RouteRegistrar { return NewRuleHandler(baseHandler, store) })
+}
+
+func TestRuleHandler_ManagesRules(t *testing.T) {
+	router := newRuleRouter(t)
+
+	rec := accountRequest(router, http.MethodPost, "/api/rules", "home", `{"name":"AC off","serial_number":"R601ZEB4ZEAL0528","condition":"bms_bmsStatus.soc < 20","clear":"bms_bmsStatus.soc > 30","cooldown_seconds":600,"action":{"command":"out/ac","payload":{"state":"off"}}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var created struct {
+		Data rules.Rule `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	id := created.Data.ID
+	assert.Equal(t, "home", created.Data.Account)
+	assert.Equal(t, []string{"R601ZEB4ZEAL0528"}, created.Data.Action.SerialNumbers, "the evaluated device by default")
+	assert.True(t, created.Data.Enabled, "enabled by default")
+	assert.False(t, created.Data.Triggered)
+
+	rec = accountRequest(router, http.MethodGet, "/api/rules", "home", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), id)
+	rec = accountRequest(router, http.MethodGet, "/api/rules", "office", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String(), "rules of other accounts are not listed")
+
+	rec = accountRequest(router, http.MethodPut, "/api/rules/"+id, "home", `{"name":"DC on","serial_number":"R601ZEB4ZEAL0528","condition":"mppt.inWatts > 300","action":{"serial_numbers":["R331ZEB4ZEAL0528"],"command":"out/dc","payload":{"state":"on"}},"dry_run":true}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = accountRequest(router, http.MethodGet, "/api/rules/"+id, "home", "")
+	assert.Contains(t, rec.Body.String(), `"command":"out/dc"`)
+	assert.Contains(t, rec.Body.String(), `"dry_run":true`)
+
+	rec = accountRequest(router, http.MethodGet, "/api/rules/"+id+"/evaluations", "home", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String())
+
+	rec = accountRequest(router, http.MethodDelete, "/api/rules/"+id, "office", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "rules of other accounts are not found")
+	rec = accountRequest(router, http.MethodDelete, "/api/rules/"+id, "home", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = accountRequest(router, http.MethodGet, "/api/rules/"+id, "home", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"`+constants.ErrRuleNotFound+`"`)
+}
+
+func TestRuleHandler_ValidatesRules(t *testing.T) {
+	router := newRuleRouter(t)
+
+	tests := []struct {
+		name    string
+		account string
+		body    string
+		status  int
+		code    string
+	}{
+		{name: "ecoflow keys", body: `{"serial_number":"R601ZEB4ZEAL0528","condition":"bms_bmsStatus.soc < 20","action":{"command":"out/ac","payload":{"state":"off"}}}`, status: http.StatusForbidden, code: constants.ErrRuleRequiresAPIKey},
+		{name: "invalid json", account: "home", body: `{`, status: http.StatusBadRequest, code: constants.ErrInvalidJsonBody},
+		{name: "no device", account: "home", body: `{"condition":"bms_bmsStatus.soc < 20","action":{"serial_numbers":["R601ZEB4ZEAL0528"],"command":"out/ac","payload":{"state":"off"}}}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "invalid condition", account: "home", body: `{"serial_number":"R601ZEB4ZEAL0528","condition":"bms_bmsStatus.soc","action":{"command":"out/ac","payload":{"state":"off"}}}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "invalid clear condition", account: "home", body: `{"serial_number":"R601ZEB4ZEAL0528","condition":"bms_bmsStatus.soc < 20","clear":"bms_bmsStatus.soc >","action":{"command":"out/ac","payload":{"state":"off"}}}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "negative cooldown", account: "home", body: `{"serial_number":"R601ZEB4ZEAL0528","condition":"bms_bmsStatus.soc < 20","cooldown_seconds":-1,"action":{"command":"out/ac","payload":{"state":"off"}}}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "missing payload", account: "home", body: `{"serial_number":"R601ZEB4ZEAL0528","condition":"bms_bmsStatus.soc < 20","action":{"command":"out/ac"}}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "unknown command", account: "home", body: `{"serial_number":"R601ZEB4ZEAL0528","condition":"bms_bmsStatus.soc < 20","action":{"command":"out/usb","payload":{"state":"off"}}}`, status: http.StatusUnprocessableEntity, code: constants.ErrCommandUnsupported},
+		{name: "smart plug action", account: "home", body: `{"serial_number":"R601ZEB4ZEAL0528","condition":"bms_bmsStatus.soc < 20","action":{"serial_numbers":["HW52ZDH4SF123456"],"command":"out/ac","payload":{"state":"off"}}}`, status: http.StatusUnprocessableEntity, code: constants.ErrCommandUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := accountRequest(router, http.MethodPost, "/api/rules", tt.account, tt.body)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+	rec := accountRequest(router, http.MethodGet, "/api/rules", "home", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String())
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/schedule"
+	"net/http"
+	"time"
+)
+
+// ScheduleHandler manages the schedules of power station commands. Schedules belong to the vault account of the API key
+// they are managed with, their commands are sent with the credentials of the account.
+type ScheduleHandler struct {
+	*BaseHandler
+	store *schedule.Store
+}
+
+func NewScheduleHandler(baseHandler *BaseHandler, store *schedule.Store) *ScheduleHandler {
+	return &ScheduleHandler{BaseHandler: baseHandler, store: store}
+}
+
+func (h *ScheduleHandler) RegisterRoutes(router chi.Router) {
+	router.Get("/api/schedules", h.ListSchedules())
+	router.Post("/api/schedules", h.CreateSchedule())
+	router.Get("/api/schedules/{id}", h.GetSchedule())
+	router.Put("/api/schedules/{id}", h.UpdateSchedule())
+	router.Delete("/api/schedules/{id}", h.DeleteSchedule())
+	router.Get("/api/schedules/{id}/runs", h.GetScheduleRuns())
+}
+
+type ScheduleRequest struct {
+	Name string `json:"name" example:"AC off at night"`
+	// Cron is a cron expression with minute, hour, day of month, month and day of week
+	Cron string `json:"cron" example:"0 23 * * *"`
+	// TimeZone is the IANA time zone of the cron expression, UTC by default
+	TimeZone      string   `json:"time_zone" example:"Europe/Berlin"`
+	SerialNumbers []string `json:"serial_numbers"`
+	// Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac
+	Command string `json:"command" example:"out/ac"`
+	// Payload is the request body of the command
+	Payload json.RawMessage `json:"payload" swaggertype:"object"`
+	// MissedRuns is skip (default) or run_once, see the README
+	MissedRuns string `json:"missed_runs" enums:"skip,run_once"`
+	// Enabled is true by default
+	Enabled *bool `json:"enabled"`
+}
+
+type ScheduleResponse struct {
+	schedule.Schedule
+	// NextRunAt is the time of the next occurrence, it is not set for disabled schedules
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+// ListSchedules lists the schedules of the account
+// @Summary List schedules
+// @Description Returns the schedules of the vault account of the API key.
+// @Tags Schedules
+// @Produce json
+// @Success 200 {object} SuccessResponse{data=[]ScheduleResponse} "Schedules of the account"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Router /api/schedules [get]
+func (h *ScheduleHandler) ListSchedules() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.scheduleAccount(w, r)
+		if !ok {
+			return
+		}
+		schedules := h.store.List(account)
+		response := make([]ScheduleResponse, 0, len(schedules))
+		for _, s := range schedules {
+			response = append(response, newScheduleResponse(s))
+		}
+		h.RespondWithSuccess(w, response)
+	}
+}
+
+// CreateSchedule creates a schedule
+// @Summary Create a schedule
+// @Description Creates a schedule that sends a power station command, e.g. out/ac, with the payload to the devices at the times of the cron expression. The command is validated against the capabilities of the devices. Only occurrences after the creation are run.
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Param requestBody body ScheduleRequest true "Request body containing the schedule"
+// @Success 200 {object} SuccessResponse{data=ScheduleResponse} "Schedule created"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Error saving the schedule"
+// @Router /api/schedules [post]
+func (h *ScheduleHandler) CreateSchedule() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.scheduleAccount(w, r)
+		if !ok {
+			return
+		}
+		s, ok := h.scheduleFromRequest(w, r)
+		if !ok {
+			return
+		}
+		s.Account = account
+
+		created, err := h.store.Create(s)
+		if err != nil {
+			h.respondWithStoreError(w, r, "", err)
+			return
+		}
+		h.RespondWithSuccess(w, newScheduleResponse(created))
+	}
+}
+
+// GetSchedule returns a schedule
+// @Summary Get a schedule
+// @Tags Schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} SuccessResponse{data=ScheduleResponse} "Schedule"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Schedule not found"
+// @Router /api/schedules/{id} [get]
+func (h *ScheduleHandler) GetSchedule() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.scheduleAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		s, err := h.store.Get(account, id)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, newScheduleResponse(s))
+	}
+}
+
+// UpdateSchedule replaces a schedule
+// @Summary Update a schedule
+// @Description Replaces the schedule. Occurrences before the update are not run anymore, regardless of the missed runs policy.
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Param requestBody body ScheduleRequest true "Request body containing the schedule"
+// @Success 200 {object} SuccessResponse{data=ScheduleResponse} "Schedule updated"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Schedule not found"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Error saving the schedule"
+// @Router /api/schedules/{id} [put]
+func (h *ScheduleHandler) UpdateSchedule() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.scheduleAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		s, ok := h.scheduleFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		updated, err := h.store.Update(account, id, s)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, newScheduleResponse(updated))
+	}
+}
+
+// DeleteSchedule deletes a schedule
+// @Summary Delete a schedule
+// @Description Deletes the schedule and its runs.
+// @Tags Schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} SuccessResponse "Schedule deleted"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Schedule not found"
+// @Failure 500 {object} ErrorResponse "Error saving the schedules"
+// @Router /api/schedules/{id} [delete]
+func (h *ScheduleHandler) DeleteSchedule() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.scheduleAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		if err := h.store.Delete(account, id); err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, nil)
+	}
+}
+
+// GetScheduleRuns returns the run history of a schedule
+// @Summary Get the runs of a schedule
+// @Description Returns the latest runs of the schedule, newest first, with the response of the command for every device. Missed occurrences are listed as missed runs.
+// @Tags Schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} SuccessResponse{data=[]schedule.Run} "Runs of the schedule"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Schedule not found"
+// @Router /api/schedules/{id}/runs [get]
+func (h *ScheduleHandler) GetScheduleRuns() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.scheduleAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		runs, err := h.store.Runs(account, id)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, runs)
+	}
+}
+
+// scheduleAccount returns the vault account of the request or responds with 403 if the request is not authenticated
+// with an API key.
+func (h *ScheduleHandler) scheduleAccount(w http.ResponseWriter, r *http.Request) (string, bool) {
+	return h.vaultAccount(w, r, constants.ErrScheduleRequiresAPIKey, "Schedules can only be managed with an API key of a vault account")
+}
+
+// scheduleFromRequest decodes and validates the schedule of the request body.
+func (h *ScheduleHandler) scheduleFromRequest(w http.ResponseWriter, r *http.Request) (schedule.Schedule, bool) {
+	var requestBody ScheduleRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			"error": err.Error(),
+		})
+		return schedule.Schedule{}, false
+	}
+
+	s := schedule.Schedule{
+		Name:          requestBody.Name,
+		Cron:          requestBody.Cron,
+		TimeZone:      requestBody.TimeZone,
+		SerialNumbers: requestBody.SerialNumbers,
+		Command:       requestBody.Command,
+		Payload:       requestBody.Payload,
+		MissedRuns:    requestBody.MissedRuns,
+		Enabled:       requestBody.Enabled == nil || *requestBody.Enabled,
+	}
+	if s.TimeZone == "" {
+		s.TimeZone = "UTC"
+	}
+	if s.MissedRuns == "" {
+		s.MissedRuns = schedule.MissedRunsSkip
+	}
+	if err = s.Validate(); err != nil {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. "+err.Error(), map[string]string{
+			"cron":      s.Cron,
+			"time_zone": s.TimeZone,
+		})
+		return schedule.Schedule{}, false
+	}
+	if !h.checkPowerStationCommand(w, r, s.SerialNumbers, s.Command, s.Payload) {
+		return schedule.Schedule{}, false
+	}
+	return s, true
+}
+
+func (h *ScheduleHandler) respondWithStoreError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	if errors.Is(err, schedule.ErrScheduleNotFound) {
+		h.RespondWithError(w, r, http.StatusNotFound, constants.ErrScheduleNotFound, "Schedule not found", map[string]string{
+			"id": id,
+		})
+		return
+	}
+	h.RespondWithError(w, r, http.StatusInternalServerError, constants.ErrSaveSchedule, "Failed to save the schedules", map[string]string{
+		"error": err.Error(),
+	})
+}
+
+func newScheduleResponse(s schedule.Schedule) ScheduleResponse {
+	response := ScheduleResponse{Schedule: s}
+	if s.Enabled {
+		if next := s.Next(time.Now()); !next.IsZero() {
+			next = next.UTC()
+			response.NextRunAt = &next
+		}
+	}
+	return response
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/schedule"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newScheduleRouter returns a router with the schedule endpoints, see newAccountRouter.
+func newScheduleRouter(t *testing.T) chi.Router {
+	store, err := schedule.Open(filepath.Join(t.TempDir(), "schedules.json"), 10)
+	require.NoError(t, err)
+	return newAccountRouter(t, func(baseHandler *BaseHandler) RouteRegistrar { return NewScheduleHandler(baseHandler, store) })
+}
+
+func TestScheduleHandler_ManagesSchedules(t *testing.T) {
+	router := newScheduleRouter(t)
+
+	rec := accountRequest(router, http.MethodPost, "/api/schedules", "home", `{"name":"AC off","cron":"0 23 * * *","time_zone":"Europe/Berlin","serial_numbers":["R331ZEB4ZEAL0528"],"command":"out/ac","payload":{"state":"off"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var created struct {
+		Data ScheduleResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	id := created.Data.ID
+	assert.Equal(t, "home", created.Data.Account)
+	assert.Equal(t, schedule.MissedRunsSkip, created.Data.MissedRuns, "skip by default")
+	assert.True(t, created.Data.Enabled, "enabled by default")
+	require.NotNil(t, created.Data.NextRunAt)
+	assert.Equal(t, 0, created.Data.NextRunAt.Minute())
+
+	rec = accountRequest(router, http.MethodGet, "/api/schedules", "home", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), id)
+	rec = accountRequest(router, http.MethodGet, "/api/schedules", "office", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String(), "schedules of other accounts are not listed")
+
+	rec = accountRequest(router, http.MethodPut, "/api/schedules/"+id, "home", `{"name":"Slow charging","cron":"0 22 * * *","serial_numbers":["R331ZEB4ZEAL0528"],"command":"input/speed","payload":{"watts":400},"missed_runs":"run_once","enabled":false}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = accountRequest(router, http.MethodGet, "/api/schedules/"+id, "home", "")
+	assert.Contains(t, rec.Body.String(), `"command":"input/speed"`)
+	assert.Contains(t, rec.Body.String(), `"time_zone":"UTC"`)
+	assert.NotContains(t, rec.Body.String(), "next_run_at", "disabled schedules don't run")
+
+	rec = accountRequest(router, http.MethodGet, "/api/schedules/"+id+"/runs", "home", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String())
+
+	rec = accountRequest(router, http.MethodDelete, "/api/schedules/"+id, "office", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "schedules of other accounts are not found")
+	rec = accountRequest(router, http.MethodDelete, "/api/schedules/"+id, "home", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = accountRequest(router, http.MethodGet, "/api/schedules/"+id, "home", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"`+constants.ErrScheduleNotFound+`"`)
+}
+
+func TestScheduleHandler_ValidatesSchedules(t *testing.T) {
+	router := newScheduleRouter(t)
+
+	tests := []struct {
+		name    string
+		account string
+		body    string
+		status  int
+		code    string
+	}{
+		{name: "ecoflow keys", body: `{"cron":"0 23 * * *","serial_numbers":["R331ZEB4ZEAL0528"],"command":"out/ac","payload":{"state":"off"}}`, status: http.StatusForbidden, code: constants.ErrScheduleRequiresAPIKey},
+		{name: "invalid json", account: "home", body: `{`, status: http.StatusBadRequest, code: constants.ErrInvalidJsonBody},
+		{name: "invalid cron", account: "home", body: `{"cron":"0 23 * *","serial_numbers":["R331ZEB4ZEAL0528"],"command":"out/ac","payload":{"state":"off"}}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "invalid time zone", account: "home", body: `{"cron":"0 23 * * *","time_zone":"Berlin","serial_numbers":["R331ZEB4ZEAL0528"],"command":"out/ac","payload":{"state":"off"}}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "invalid missed runs", account: "home", body: `{"cron":"0 23 * * *","missed_runs":"all","serial_numbers":["R331ZEB4ZEAL0528"],"command":"out/ac","payload":{"state":"off"}}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "missing payload", account: "home", body: `{"cron":"0 23 * * *","serial_numbers":["R331ZEB4ZEAL0528"],"command":"out/ac"}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "no devices", account: "home", body: `{"cron":"0 23 * * *","command":"out/ac","payload":{"state":"off"}}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "unknown command", account: "home", body: `{"cron":"0 23 * * *","serial_numbers":["R331ZEB4ZEAL0528"],"command":"out/usb","payload":{"state":"off"}}`, status: http.StatusUnprocessableEntity, code: constants.ErrCommandUnsupported},
+		{name: "smart plug", account: "home", body: `{"cron":"0 23 * * *","serial_numbers":["HW52ZDH4SF123456"],"command":"out/ac","payload":{"state":"off"}}`, status: http.StatusUnprocessableEntity, code: constants.ErrCommandUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := accountRequest(router, http.MethodPost, "/api/schedules", tt.account, tt.body)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+	rec := accountRequest(router, http.MethodGet, "/api/schedules", "home", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String())
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-ecoflow-api-server/constants"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allCircuits returns the body of a priority request for all circuits, circuit i gets priority 10-i.
+func allCircuits() string {
+	circuits := make([]string, 10)
+	for i := range circuits {
+		circuits[i] = fmt.Sprintf(`{"circuit":%d,"priority":%d,"backup_enabled":%t}`, i, 10-i, i != 9)
+	}
+	return `{"circuits":[` + strings.Join(circuits, ",") + `]}`
+}
+
+func TestSmartHomePanelHandler_GetCircuits(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":"0","message":"Success","data":{"heartbeat.hall1Watt":[120.5,0],"loadChInfo.info":[{"chName":"Kitchen","iconInfo":10}],"heartbeat.loadCmdChCtrlInfos":[{"ctrlMode":1,"ctrlSta":1}]}}`))
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewSmartHomePanelHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/smart_home_panel/SP10ZAW5ZE9E0052/circuits", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var response struct {
+		Data CircuitsResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Data.Circuits, 10)
+	kitchen := response.Data.Circuits[0]
+	assert.Equal(t, "Kitchen", *kitchen.Name)
+	assert.Equal(t, 120.5, *kitchen.PowerWatts)
+	assert.Equal(t, "battery", *kitchen.Source)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/smart_home_panel/R331ZEB4ZEAL0528/circuits", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), constants.ErrDeviceStatusUnsupported)
+}
+
+func TestSmartHomePanelHandler_SendsCommands(t *testing.T) {
+	var command map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		command = nil
+		_ = json.Unmarshal(body, &command)
+		_, _ = w.Write([]byte(`{"code":"0","message":"Success"}`))
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewSmartHomePanelHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		params map[string]interface{}
+	}{
+		{
+			name:   "circuit source",
+			path:   "/circuits/3/source",
+			body:   `{"source":"battery"}`,
+			params: map[string]interface{}{"cmdSet": 11.0, "id": 16.0, "ch": 3.0, "ctrlMode": 1.0, "sta": 1.0},
+		},
+		{
+			name:   "automatic circuit source",
+			path:   "/circuits/0/source",
+			body:   `{"source":"auto"}`,
+			params: map[string]interface{}{"cmdSet": 11.0, "id": 16.0, "ch": 0.0, "ctrlMode": 0.0, "sta": 0.0},
+		},
+		{
+			name:   "backup reserve",
+			path:   "/backup_reserve",
+			body:   `{"reserve_percent":20,"charge_limit_percent":95}`,
+			params: map[string]interface{}{"cmdSet": 11.0, "id": 29.0, "discLower": 20.0, "forceChargeHigh": 95.0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/smart_home_panel/SP10ZAW5ZE9E0052"+tt.path, strings.NewReader(tt.body)))
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Equal(t, "SP10ZAW5ZE9E0052", command["sn"])
+			assert.Equal(t, "TCP", command["operateType"])
+			assert.Equal(t, tt.params, command["params"])
+		})
+	}
+
+	t.Run("circuit priority", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/smart_home_panel/SP10ZAW5ZE9E0052/circuits/priority", strings.NewReader(allCircuits())))
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		params := command["params"].(map[string]interface{})
+		assert.Equal(t, 64.0, params["id"])
+		channels := params["chSta"].([]interface{})
+		require.Len(t, channels, 10)
+		assert.Equal(t, map[string]interface{}{"priority": 10.0, "isEnable": 1.0}, channels[0])
+		assert.Equal(t, map[string]interface{}{"priority": 1.0, "isEnable": 0.0}, channels[9])
+	})
+
+	t.Run("charging window", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		body := `{"start":"23:00","end":"06:30","charge_watts":1500,"target_percent":90}`
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/smart_home_panel/SP10ZAW5ZE9E0052/charging_windows/1", strings.NewReader(body)))
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		params := command["params"].(map[string]interface{})
+		assert.Equal(t, 81.0, params["id"])
+		assert.Equal(t, 1.0, params["cfgIndex"])
+		cfg := params["cfg"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"hightBattery": 90.0, "chChargeWatt": 1500.0}, cfg["param"])
+		timeRange := cfg["comCfg"].(map[string]interface{})["timeRange"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"hour": 23.0, "min": 0.0, "sec": 0.0}, timeRange["startTime"])
+		assert.Equal(t, map[string]interface{}{"hour": 6.0, "min": 30.0, "sec": 0.0}, timeRange["endTime"])
+		assert.Equal(t, 1.0, timeRange["isEnable"])
+	})
+}
+
+func TestSmartHomePanelHandler_ValidatesBeforeUpstream(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewSmartHomePanelHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	duplicateCircuit := strings.Replace(allCircuits(), `"circuit":1,`, `"circuit":0,`, 1)
+	duplicatePriority := strings.Replace(allCircuits(), `"priority":9,`, `"priority":10,`, 1)
+	tests := []struct {
+		name string
+		path string
+		body string
+		code string
+	}{
+		{name: "invalid json", path: "/circuits/0/source", body: `{`, code: constants.ErrInvalidJsonBody},
+		{name: "circuit out of range", path: "/circuits/10/source", body: `{"source":"grid"}`, code: constants.ErrInvalidParameters},
+		{name: "unknown source", path: "/circuits/0/source", body: `{"source":"solar"}`, code: constants.ErrInvalidParameters},
+		{name: "missing circuits", path: "/circuits/priority", body: `{"circuits":[{"circuit":0,"priority":1}]}`, code: constants.ErrInvalidParameters},
+		{name: "duplicate circuit", path: "/circuits/priority", body: duplicateCircuit, code: constants.ErrInvalidParameters},
+		{name: "duplicate priority", path: "/circuits/priority", body: duplicatePriority, code: constants.ErrInvalidParameters},
+		{name: "reserve too high", path: "/backup_reserve", body: `{"reserve_percent":31,"charge_limit_percent":100}`, code: constants.ErrInvalidParameters},
+		{name: "charge limit too low", path: "/backup_reserve", body: `{"reserve_percent":10,"charge_limit_percent":49}`, code: constants.ErrInvalidParameters},
+		{name: "missing reserve", path: "/backup_reserve", body: `{"charge_limit_percent":100}`, code: constants.ErrInvalidParameters},
+		{name: "missing charge limit", path: "/backup_reserve", body: `{"reserve_percent":10}`, code: constants.ErrInvalidParameters},
+		{name: "window out of range", path: "/charging_windows/10", body: `{}`, code: constants.ErrInvalidParameters},
+		{name: "invalid window time", path: "/charging_windows/0", body: `{"start":"23:00","end":"24:00","charge_watts":1000,"target_percent":90}`, code: constants.ErrInvalidParameters},
+		{name: "empty window", path: "/charging_windows/0", body: `{"start":"23:00","end":"23:00","charge_watts":1000,"target_percent":90}`, code: constants.ErrInvalidParameters},
+		{name: "charge watts too high", path: "/charging_windows/0", body: `{"start":"23:00","end":"06:00","charge_watts":7201,"target_percent":90}`, code: constants.ErrInvalidParameters},
+		{name: "target too low", path: "/charging_windows/0", body: `{"start":"23:00","end":"06:00","charge_watts":1000,"target_percent":40}`, code: constants.ErrInvalidParameters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/smart_home_panel/SP10ZAW5ZE9E0052"+tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+	assert.Zero(t, calls.Load())
+}
@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/catalog"
+	"go-ecoflow-api-server/constants"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	smartPlugMaxTaskIndex = 9
+
+	// go-ecoflow doesn't wrap these commands, they are sent as raw device parameters
+	smartPlugSetMaxWattsCmd = "WN511_SOCKET_SET_MAX_WATTS"
+	smartPlugSetTimeTaskCmd = "WN511_SOCKET_SET_TIME_TASK"
+)
+
+// smartPlugWeekdays maps the days of a scheduled task to the bits of the weekday mask of the smart plug.
+var smartPlugWeekdays = map[string]int{
+	"sun": 1 << 0,
+	"mon": 1 << 1,
+	"tue": 1 << 2,
+	"wed": 1 << 3,
+	"thu": 1 << 4,
+	"fri": 1 << 5,
+	"sat": 1 << 6,
+}
+
+type SmartPlugHandler struct {
+	*BaseHandler
+}
+
+func NewSmartPlugHandler(baseHandler *BaseHandler) *SmartPlugHandler {
+	return &SmartPlugHandler{baseHandler}
+}
+
+func (h *SmartPlugHandler) RegisterRoutes(router chi.Router) {
+	router.Put("/api/smart_plug/{serial_number}/relay", h.SmartPlugSetRelay())
+	router.Put("/api/smart_plug/{serial_number}/brightness", h.SmartPlugSetBrightness())
+	router.Put("/api/smart_plug/{serial_number}/max_power", h.SmartPlugSetMaxPower())
+	router.Put("/api/smart_plug/{serial_number}/tasks/{task_index}", h.SmartPlugSetTask())
+	router.Delete("/api/smart_plug/{serial_number}/tasks/{task_index}", h.SmartPlugDeleteTask())
+}
+
+// SmartPlugSetRelay switches the relay of the smart plug on or off.
+//
+// @Summary Switch the smart plug on/off
+// @Description Switches the relay of the smart plug, and with it the connected load, on or off.
+// @Tags Smart Plug
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the smart plug"
+// @Param requestBody body ChangeStateRequest true "Request body containing the state"
+// @Success 200 {object} SuccessResponse "Successfully switched the relay"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/smart_plug/{serial_number}/relay [put]
+func (h *SmartPlugHandler) SmartPlugSetRelay() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		_, ok := h.command(w, r, sn, catalog.FamilySmartPlug, "relay")
+		if !ok {
+			return
+		}
+
+		var requestBody ChangeStateRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.State != "on" && requestBody.State != "off" {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. State must be 'on' or 'off'", map[string]string{
+				"serial_number": sn,
+				"state":         requestBody.State,
+			})
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		var newState ecoflow.SettingSwitcher
+		if requestBody.State == "on" {
+			newState = ecoflow.SettingEnabled
+		} else {
+			newState = ecoflow.SettingDisabled
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetSmartPlug(sn).SetRelaySwitch(ctx, newState)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrSmartPlugSetRelay, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type BrightnessRequest struct {
+	// Brightness of the LED indicator, from 0 (off) to 1023
+	Brightness *int `json:"brightness"`
+}
+
+// SmartPlugSetBrightness sets the brightness of the LED indicator.
+//
+// @Summary Set the LED brightness of the smart plug
+// @Description Sets the brightness of the LED indicator of the smart plug, from 0 (off) to 1023.
+// @Tags Smart Plug
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the smart plug"
+// @Param requestBody body BrightnessRequest true "Request body containing the brightness"
+// @Success 200 {object} SuccessResponse "Successfully set the brightness"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/smart_plug/{serial_number}/brightness [put]
+func (h *SmartPlugHandler) SmartPlugSetBrightness() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		command, ok := h.command(w, r, sn, catalog.FamilySmartPlug, "brightness")
+		if !ok {
+			return
+		}
+
+		var requestBody BrightnessRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.Brightness == nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. brightness is mandatory", map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+
+		if !h.checkRange(w, r, sn, command, "brightness", float64(*requestBody.Brightness)) {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetSmartPlug(sn).SetIndicatorBrightness(ctx, *requestBody.Brightness)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrSmartPlugSetBrightness, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type SmartPlugMaxPowerRequest struct {
+	// Watts is the load above which the smart plug switches off, from 1 to 2500
+	Watts int `json:"watts"`
+}
+
+// SmartPlugSetMaxPower sets the max-power watchdog of the smart plug.
+//
+// @Summary Set the max-power watchdog of the smart plug
+// @Description Sets the load in watts above which the smart plug switches its relay off, from 1 to 2500.
+// @Tags Smart Plug
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the smart plug"
+// @Param requestBody body SmartPlugMaxPowerRequest true "Request body containing the maximum load"
+// @Success 200 {object} SuccessResponse "Successfully set the maximum load"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/smart_plug/{serial_number}/max_power [put]
+func (h *SmartPlugHandler) SmartPlugSetMaxPower() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		command, ok := h.command(w, r, sn, catalog.FamilySmartPlug, "max_power")
+		if !ok {
+			return
+		}
+
+		var requestBody SmartPlugMaxPowerRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if !h.checkRange(w, r, sn, command, "watts", float64(requestBody.Watts)) {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.SetDeviceParameter(ctx, deviceCommand(sn, smartPlugSetMaxWattsCmd, map[string]interface{}{
+				"maxWatts": requestBody.Watts,
+			}))
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrSmartPlugSetMaxPower, map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+type SmartPlugTaskRequest struct {
+	// State the relay is switched to, on or off
+	State string `json:"state"`
+	// Time of day in the time zone of the smart plug, HH:MM
+	Time string `json:"time"`
+	// Days the task runs on (sun, mon, tue, wed, thu, fri, sat), every day if empty
+	Days []string `json:"days"`
+	// Enabled disables the task without deleting it when false, defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+// SmartPlugSetTask creates or replaces a scheduled task of the smart plug.
+//
+// @Summary Schedule the smart plug on/off
+// @Description Creates or replaces the scheduled task with the index (0-9). The task switches the relay on or off at the time of day on the given days.
+// @Tags Smart Plug
+// @Accept json
+// @Produce json
+// @Param serial_number path string true "Serial number of the smart plug"
+// @Param task_index path int true "Index of the task, from 0 to 9"
+// @Param requestBody body SmartPlugTaskRequest true "Request body containing the task"
+// @Success 200 {object} SuccessResponse "Successfully scheduled the task"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/smart_plug/{serial_number}/tasks/{task_index} [put]
+func (h *SmartPlugHandler) SmartPlugSetTask() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		_, ok := h.command(w, r, sn, catalog.FamilySmartPlug, "tasks")
+		if !ok {
+			return
+		}
+
+		taskIndex, ok := h.pathIndex(w, r, sn, "task_index", smartPlugMaxTaskIndex)
+		if !ok {
+			return
+		}
+
+		var requestBody SmartPlugTaskRequest
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+				"serial_number": sn,
+				"error":         err.Error(),
+			})
+			return
+		}
+
+		if requestBody.State != "on" && requestBody.State != "off" {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. State must be 'on' or 'off'", map[string]string{
+				"serial_number": sn,
+				"state":         requestBody.State,
+			})
+			return
+		}
+
+		at, err := time.Parse("15:04", requestBody.Time)
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. time must be formatted as HH:MM", map[string]string{
+				"serial_number": sn,
+				"time":          requestBody.Time,
+			})
+			return
+		}
+
+		weekdays := 0
+		for _, day := range requestBody.Days {
+			bit, known := smartPlugWeekdays[day]
+			if !known {
+				h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. days must be sun, mon, tue, wed, thu, fri or sat", map[string]string{
+					"serial_number": sn,
+					"day":           day,
+				})
+				return
+			}
+			weekdays |= bit
+		}
+		if weekdays == 0 {
+			weekdays = 1<<len(smartPlugWeekdays) - 1
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		enabled := requestBody.Enabled == nil || *requestBody.Enabled
+		relay := ecoflow.SettingDisabled
+		if requestBody.State == "on" {
+			relay = ecoflow.SettingEnabled
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.SetDeviceParameter(ctx, deviceCommand(sn, smartPlugSetTimeTaskCmd, map[string]interface{}{
+				"taskIndex": taskIndex,
+				"timeTask": map[string]interface{}{
+					"taskIndex": taskIndex,
+					"type":      relay,
+					"timeRange": map[string]interface{}{
+						"isConfig": true,
+						"isEnable": enabled,
+						"timeMode": 1, // weekly
+						"timeData": weekdays,
+						"startTime": map[string]interface{}{
+							"hour": at.Hour(),
+							"min":  at.Minute(),
+							"sec":  0,
+						},
+					},
+				},
+			}))
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrSmartPlugSetTask, map[string]string{
+				"serial_number": sn,
+				"task_index":    strconv.Itoa(taskIndex),
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+// SmartPlugDeleteTask deletes a scheduled task of the smart plug.
+//
+// @Summary Delete a scheduled task of the smart plug
+// @Description Deletes the scheduled task with the index (0-9).
+// @Tags Smart Plug
+// @Produce json
+// @Param serial_number path string true "Serial number of the smart plug"
+// @Param task_index path int true "Index of the task, from 0 to 9"
+// @Success 200 {object} SuccessResponse "Successfully deleted the task"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Ecoflow rejected the access key or secret key"
+// @Failure 404 {object} ErrorResponse "Device is not linked to the Ecoflow account"
+// @Failure 409 {object} ErrorResponse "Device is offline"
+// @Failure 422 {object} ErrorResponse "Device model doesn't support the command"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "Ecoflow API returned an unexpected response"
+// @Failure 503 {object} ErrorResponse "Ecoflow API is unavailable or the circuit breaker of the account is open, see Retry-After"
+// @Failure 504 {object} ErrorResponse "Ecoflow API did not respond in time"
+// @Router /api/smart_plug/{serial_number}/tasks/{task_index} [delete]
+func (h *SmartPlugHandler) SmartPlugDeleteTask() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn := r.PathValue("serial_number")
+
+		_, ok := h.command(w, r, sn, catalog.FamilySmartPlug, "tasks")
+		if !ok {
+			return
+		}
+
+		taskIndex, ok := h.pathIndex(w, r, sn, "task_index", smartPlugMaxTaskIndex)
+		if !ok {
+			return
+		}
+
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := h.UpstreamContext(r)
+		defer cancel()
+
+		ecoflowResponse, err := writeUpstream(ctx, h.BaseHandler, r, func(ctx context.Context) (*ecoflow.CmdSetResponse, error) {
+			return client.GetSmartPlug(sn).DeleteScheduledTasks(ctx, taskIndex)
+		})
+		if err != nil {
+			h.RespondWithUpstreamError(ctx, w, r, err, constants.ErrSmartPlugDeleteTask, map[string]string{
+				"serial_number": sn,
+				"task_index":    strconv.Itoa(taskIndex),
+			})
+			return
+		}
+		h.RespondWithSuccess(w, ecoflowResponse)
+	}
+}
+
+// deviceCommand returns the request of a command that go-ecoflow doesn't implement, for Client.SetDeviceParameter.
+func deviceCommand(sn, cmdCode string, params map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":      strconv.FormatInt(time.Now().UnixMilli(), 10),
+		"sn":      sn,
+		"cmdCode": cmdCode,
+		"params":  params,
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/constants"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmartPlugHandler_SendsCommands(t *testing.T) {
+	var command map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		command = nil
+		_ = json.Unmarshal(body, &command)
+		_, _ = w.Write([]byte(`{"code":"0","message":"Success"}`))
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewSmartPlugHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		cmdCode string
+		params  map[string]interface{}
+	}{
+		{
+			name:    "relay",
+			method:  http.MethodPut,
+			path:    "/api/smart_plug/HW52ZDH1RF3J0033/relay",
+			body:    `{"state":"on"}`,
+			cmdCode: "WN511_SOCKET_SET_PLUG_SWITCH_MESSAGE",
+			params:  map[string]interface{}{"plugSwitch": 1.0},
+		},
+		{
+			name:    "brightness",
+			method:  http.MethodPut,
+			path:    "/api/smart_plug/HW52ZDH1RF3J0033/brightness",
+			body:    `{"brightness":0}`,
+			cmdCode: "WN511_SOCKET_SET_BRIGHTNESS_PACK",
+			params:  map[string]interface{}{"brightness": 0.0},
+		},
+		{
+			name:    "max power",
+			method:  http.MethodPut,
+			path:    "/api/smart_plug/HW52ZDH1RF3J0033/max_power",
+			body:    `{"watts":1500}`,
+			cmdCode: smartPlugSetMaxWattsCmd,
+			params:  map[string]interface{}{"maxWatts": 1500.0},
+		},
+		{
+			name:    "scheduled task",
+			method:  http.MethodPut,
+			path:    "/api/smart_plug/HW52ZDH1RF3J0033/tasks/2",
+			body:    `{"state":"off","time":"22:30","days":["mon","fri"]}`,
+			cmdCode: smartPlugSetTimeTaskCmd,
+			params: map[string]interface{}{
+				"taskIndex": 2.0,
+				"timeTask": map[string]interface{}{
+					"taskIndex": 2.0,
+					"type":      0.0,
+					"timeRange": map[string]interface{}{
+						"isConfig":  true,
+						"isEnable":  true,
+						"timeMode":  1.0,
+						"timeData":  34.0,
+						"startTime": map[string]interface{}{"hour": 22.0, "min": 30.0, "sec": 0.0},
+					},
+				},
+			},
+		},
+		{
+			name:    "delete scheduled task",
+			method:  http.MethodDelete,
+			path:    "/api/smart_plug/HW52ZDH1RF3J0033/tasks/9",
+			cmdCode: "WN511_SOCKET_DELETE_TIME_TASK",
+			params:  map[string]interface{}{"taskIndex": 9.0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Equal(t, "HW52ZDH1RF3J0033", command["sn"])
+			assert.Equal(t, tt.cmdCode, command["cmdCode"])
+			assert.Equal(t, tt.params, command["params"])
+		})
+	}
+}
+
+func TestSmartPlugHandler_ValidatesBeforeUpstream(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer upstream.Close()
+
+	router := chi.NewRouter()
+	NewSmartPlugHandler(newUpstreamHandler(upstream)).RegisterRoutes(router)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   string
+	}{
+		{name: "invalid json", method: http.MethodPut, path: "/relay", body: `{`, code: constants.ErrInvalidJsonBody},
+		{name: "invalid state", method: http.MethodPut, path: "/relay", body: `{"state":"maybe"}`, code: constants.ErrInvalidParameters},
+		{name: "missing brightness", method: http.MethodPut, path: "/brightness", body: `{}`, code: constants.ErrInvalidParameters},
+		{name: "brightness too high", method: http.MethodPut, path: "/brightness", body: `{"brightness":1024}`, code: constants.ErrInvalidParameters},
+		{name: "max power too low", method: http.MethodPut, path: "/max_power", body: `{"watts":0}`, code: constants.ErrInvalidParameters},
+		{name: "max power too high", method: http.MethodPut, path: "/max_power", body: `{"watts":2501}`, code: constants.ErrInvalidParameters},
+		{name: "task index out of range", method: http.MethodPut, path: "/tasks/10", body: `{"state":"on","time":"07:00"}`, code: constants.ErrInvalidParameters},
+		{name: "task index not a number", method: http.MethodDelete, path: "/tasks/first", code: constants.ErrInvalidParameters},
+		{name: "invalid task time", method: http.MethodPut, path: "/tasks/0", body: `{"state":"on","time":"25:00"}`, code: constants.ErrInvalidParameters},
+		{name: "invalid task day", method: http.MethodPut, path: "/tasks/0", body: `{"state":"on","time":"07:00","days":["monday"]}`, code: constants.ErrInvalidParameters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/smart_plug/HW52ZDH1RF3J0033"+tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+	assert.Zero(t, calls.Load())
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+	"go-ecoflow-api-server/resilience"
+	"net/http"
+)
+
+type StatusHandler struct {
+	*BaseHandler
+}
+
+func NewStatusHandler(baseHandler *BaseHandler) *StatusHandler {
+	return &StatusHandler{baseHandler}
+}
+
+func (h *StatusHandler) RegisterRoutes(router chi.Router) {
+	router.Get("/api/status/upstream", h.GetUpstreamStatus())
+}
+
+// UpstreamStatusResponse describes the state of the circuit breakers protecting the Ecoflow API calls.
+type UpstreamStatusResponse struct {
+	Breakers []resilience.BreakerStatus `json:"breakers"`
+}
+
+// GetUpstreamStatus returns the circuit breaker state of the account of the caller
+// @Summary Get the Ecoflow API circuit breaker state
+// @Description Returns the circuit breaker state of the account of the caller, if it called the Ecoflow API recently, i.e. its breaker is not closed or it was used within the breaker open duration. The breakers of other accounts are not listed. Accounts are identified by the vault account name or by a hash of the access key and the secret key.
+// @Tags Status
+// @Produce json
+// @Success 200 {object} SuccessResponse{data=UpstreamStatusResponse} "Circuit breaker state"
+// @Router /api/status/upstream [get]
+func (h *StatusHandler) GetUpstreamStatus() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		breakers := []resilience.BreakerStatus{}
+		// the identities of the other accounts and their failures are not disclosed
+		if account := h.account(r); h.Guard != nil && account != "" {
+			if breaker, ok := h.Guard.AccountStatus(account); ok {
+				breakers = append(breakers, breaker)
+			}
+		}
+		h.RespondWithSuccess(w, UpstreamStatusResponse{Breakers: breakers})
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/telemetry"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// HeaderLastEventID is sent by SSE clients when they reconnect.
+const HeaderLastEventID = "Last-Event-ID"
+
+// DeviceFeeds subscribes clients to the shared telemetry feeds of the devices, see telemetry.Broker. It is used by
+// all handlers that push device parameters.
+type DeviceFeeds struct {
+	*BaseHandler
+	broker       *telemetry.Broker
+	pollInterval time.Duration
+	anonymous    atomic.Uint64
+}
+
+func NewDeviceFeeds(baseHandler *BaseHandler, broker *telemetry.Broker, pollInterval time.Duration) *DeviceFeeds {
+	return &DeviceFeeds{
+		BaseHandler:  baseHandler,
+		broker:       broker,
+		pollInterval: pollInterval,
+	}
+}
+
+// Subscribe subscribes to the parameters of the device, see telemetry.Broker.Subscribe. Feeds are shared per account,
+// subscribers without an account identity get their own feed. Parameters pushed by the Ecoflow MQTT broker are
+// used when they are available, otherwise the parameters are polled from the Ecoflow API with the client of the
+// subscriber.
+func (f *DeviceFeeds) Subscribe(client *ecoflow.Client, account, sn, lastEventID string) (*telemetry.Subscription, error) {
+	var source telemetry.Source
+	if f.Pushed != nil && account != "" {
+		source = f.Pushed.Source(account, sn)
+	}
+	if source == nil {
+		source = telemetry.NewPollingSource(f.fetchParameters(client, account), f.pollInterval)
+	}
+
+	key := account + "/" + sn
+	if account == "" {
+		key = fmt.Sprintf("anonymous-%d/%s", f.anonymous.Add(1), sn)
+	}
+	return f.broker.Subscribe(key, sn, source, lastEventID)
+}
+
+func (f *DeviceFeeds) fetchParameters(client *ecoflow.Client, account string) telemetry.FetchFunc {
+	return func(ctx context.Context, sn string) (map[string]interface{}, error) {
+		ctx, cancel := f.upstreamContext(ctx)
+		defer cancel()
+		return guardUpstream(ctx, f.BaseHandler, account, func(ctx context.Context) (map[string]interface{}, error) {
+			return client.GetDeviceAllParameters(ctx, sn)
+		}, false)
+	}
+}
+
+type StreamHandler struct {
+	*BaseHandler
+	feeds             *DeviceFeeds
+	heartbeatInterval time.Duration
+}
+
+func NewStreamHandler(baseHandler *BaseHandler, feeds *DeviceFeeds, heartbeatInterval time.Duration) *StreamHandler {
+	return &StreamHandler{
+		BaseHandler:       baseHandler,
+		feeds:             feeds,
+		heartbeatInterval: heartbeatInterval,
+	}
+}
+
+// RegisterRoutes registers the streaming routes. They are long-lived, so they must not be registered behind the
+// request timeout middleware.
+func (h *StreamHandler) RegisterRoutes(router chi.Router) {
+	router.Get("/api/devices/{serial_number}/stream", h.StreamDeviceParameters())
+}
+
+// StreamDeviceParameters streams the parameters of a device as Server-Sent Events
+// @Summary Stream device parameters
+// @Description Streams the parameters of a device as Server-Sent Events. The stream starts with a "snapshot" event with all parameters, followed by "delta" events with the changed ("params") and removed ("removed") parameters. "error" events are sent when the parameters can't be read. A comment line is sent every heartbeat interval. Clients that reconnect with the Last-Event-ID header receive the events they missed, or a new snapshot if the events are no longer available.
+// @Tags Devices
+// @Produce text/event-stream
+// @Param serial_number path string true "Device Serial Number"
+// @Param Last-Event-ID header string false "ID of the last event received, to resume the stream"
+// @Success 200 {object} telemetry.Event "Stream of snapshot, delta and error events"
+// @Failure 503 {object} ErrorResponse "Server is shutting down"
+// @Router /api/devices/{serial_number}/stream [get]
+func (h *StreamHandler) StreamDeviceParameters() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, ok := h.GetEcoflowClientOrRespondWithError(r, w)
+		if !ok {
+			return
+		}
+
+		sn := r.PathValue("serial_number")
+		subscription, err := h.feeds.Subscribe(client, h.account(r), sn, r.Header.Get(HeaderLastEventID))
+		if err != nil {
+			h.RespondWithError(w, r, http.StatusServiceUnavailable, constants.ErrStreamUnavailable, err.Error(), map[string]string{
+				"serial_number": sn,
+			})
+			return
+		}
+		defer subscription.Close()
+
+		// the server read and write timeouts are meant for regular requests, streams stay open until the client leaves
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Time{})
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no") // disable response buffering in nginx
+		w.WriteHeader(http.StatusOK)
+
+		for _, event := range subscription.Replay {
+			if err = h.writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err = rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(h.heartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-subscription.Events:
+				if !ok {
+					// the client was too slow or the server shuts down, the client reconnects with Last-Event-ID
+					return
+				}
+				err = h.writeEvent(w, event)
+			case <-heartbeat.C:
+				_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *StreamHandler) writeEvent(w http.ResponseWriter, event telemetry.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.ID != "" {
+		if _, err = fmt.Fprintf(w, "id: %s\n", event.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
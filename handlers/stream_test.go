@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"go-ecoflow-api-server/telemetry"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  telemetry.Event
+}
+
+// readEvents parses the events of an SSE stream, heartbeats are sent as empty events.
+func readEvents(t *testing.T, scanner *bufio.Scanner, count int) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	for len(events) < count && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			events = append(events, current)
+			current = sseEvent{}
+		case strings.HasPrefix(line, ": heartbeat"):
+			current.event = "heartbeat"
+		case strings.HasPrefix(line, "id: "):
+			current.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			current.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.data))
+		}
+	}
+	require.Len(t, events, count, "stream ended early: %v", scanner.Err())
+	return events
+}
+
+func TestStreamHandler_StreamDeviceParameters(t *testing.T) {
+	var polls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := polls.Add(1)
+		// soc changes on every second poll, unchanged polls must not produce events
+		_, _ = fmt.Fprintf(w, `{"code":"0","data":{"pd.soc":%d,"pd.watts":100}}`, 50+n/2)
+	}))
+	defer upstream.Close()
+
+	broker := telemetry.NewBroker(10, time.Minute)
+	defer broker.Close()
+	baseHandler := newUpstreamHandler(upstream)
+	handler := NewStreamHandler(baseHandler, NewDeviceFeeds(baseHandler, broker, 20*time.Millisecond), 30*time.Millisecond)
+	handler.Identity = func(r *http.Request) string { return "account" }
+	router := chi.NewRouter()
+	handler.RegisterRoutes(router)
+
+	// the stream must outlive the server timeouts
+	server := httptest.NewUnstartedServer(router)
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/devices/R331ZEB4ZEAL0528/stream")
+	require.NoError(t, err)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	scanner := bufio.NewScanner(resp.Body)
+	var snapshot, delta sseEvent
+	heartbeats := 0
+	started := time.Now()
+	for delta.id == "" || time.Since(started) < 300*time.Millisecond {
+		event := readEvents(t, scanner, 1)[0]
+		switch event.event {
+		case "snapshot":
+			snapshot = event
+		case "delta":
+			delta = event
+		case "heartbeat":
+			heartbeats++
+		}
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, map[string]interface{}{"pd.soc": 50.0, "pd.watts": 100.0}, snapshot.data.Params)
+	assert.Len(t, delta.data.Params, 1, "deltas contain only the changed parameters")
+	assert.Contains(t, delta.data.Params, "pd.soc")
+	assert.Positive(t, heartbeats)
+
+	// resume after the snapshot: the client gets the deltas it missed instead of a new snapshot
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/devices/R331ZEB4ZEAL0528/stream", nil)
+	req.Header.Set(HeaderLastEventID, snapshot.id)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	resumed := readEvents(t, bufio.NewScanner(resp.Body), 1)[0]
+	assert.Equal(t, "delta", resumed.event)
+	assert.NotEqual(t, snapshot.id, resumed.id)
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/webhook"
+	"net/http"
+)
+
+// WebhookHandler manages the webhook subscriptions to the events of the devices of the vault account of the API key.
+type WebhookHandler struct {
+	*BaseHandler
+	store *webhook.Store
+}
+
+func NewWebhookHandler(baseHandler *BaseHandler, store *webhook.Store) *WebhookHandler {
+	return &WebhookHandler{BaseHandler: baseHandler, store: store}
+}
+
+func (h *WebhookHandler) RegisterRoutes(router chi.Router) {
+	router.Get("/api/webhooks", h.ListWebhooks())
+	router.Post("/api/webhooks", h.CreateWebhook())
+	router.Get("/api/webhooks/{id}", h.GetWebhook())
+	router.Put("/api/webhooks/{id}", h.UpdateWebhook())
+	router.Delete("/api/webhooks/{id}", h.DeleteWebhook())
+	router.Get("/api/webhooks/{id}/deliveries", h.GetWebhookDeliveries())
+	router.Get("/api/webhooks/{id}/dead_letters", h.GetWebhookDeadLetters())
+	router.Post("/api/webhooks/{id}/dead_letters/{delivery_id}/retry", h.RetryWebhookDelivery())
+}
+
+type WebhookRequest struct {
+	Name string `json:"name" example:"On-call"`
+	// URL receives the events as POST requests
+	URL string `json:"url" example:"https://example.com/ecoflow"`
+	// Secret signs the payloads, a random secret is generated if it's empty. On update, the secret is kept if it's empty.
+	Secret string `json:"secret"`
+	// Events filters the events, all events are sent if it's empty
+	Events []string `json:"events" enums:"device.offline,device.online,battery.below,battery.above,fault.raised,fault.cleared"`
+	// SerialNumbers filters the devices, the events of all devices are sent if it's empty
+	SerialNumbers []string `json:"serial_numbers"`
+	// BatteryThreshold is the state of charge in percent the battery events are sent for
+	BatteryThreshold float64 `json:"battery_threshold" example:"20"`
+	// Enabled is true by default
+	Enabled *bool `json:"enabled"`
+}
+
+// ListWebhooks lists the webhook subscriptions of the account
+// @Summary List webhook subscriptions
+// @Description Returns the webhook subscriptions of the vault account of the API key. Their secrets are not returned.
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {object} SuccessResponse{data=[]webhook.Subscription} "Subscriptions of the account"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Router /api/webhooks [get]
+func (h *WebhookHandler) ListWebhooks() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.webhookAccount(w, r)
+		if !ok {
+			return
+		}
+		subscriptions := h.store.List(account)
+		for i := range subscriptions {
+			subscriptions[i].Secret = ""
+		}
+		h.RespondWithSuccess(w, subscriptions)
+	}
+}
+
+// CreateWebhook creates a webhook subscription
+// @Summary Create a webhook subscription
+// @Description Creates a subscription that sends the events of the devices of the account to the URL: devices going offline and online, the state of charge crossing the battery threshold, and fault codes turning non-zero and zero again. The payloads are signed with the secret, which is only returned by this request.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param requestBody body WebhookRequest true "Request body containing the subscription"
+// @Success 200 {object} SuccessResponse{data=webhook.Subscription} "Subscription created"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 500 {object} ErrorResponse "Error saving the subscription"
+// @Router /api/webhooks [post]
+func (h *WebhookHandler) CreateWebhook() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.webhookAccount(w, r)
+		if !ok {
+			return
+		}
+		subscription, ok := h.subscriptionFromRequest(w, r)
+		if !ok {
+			return
+		}
+		subscription.Account = account
+
+		created, err := h.store.Create(subscription)
+		if err != nil {
+			h.respondWithStoreError(w, r, "", err)
+			return
+		}
+		h.RespondWithSuccess(w, created)
+	}
+}
+
+// GetWebhook returns a webhook subscription
+// @Summary Get a webhook subscription
+// @Description Returns the subscription without its secret.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} SuccessResponse{data=webhook.Subscription} "Subscription"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Subscription not found"
+// @Router /api/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.webhookAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		subscription, err := h.store.Get(account, id)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		subscription.Secret = ""
+		h.RespondWithSuccess(w, subscription)
+	}
+}
+
+// UpdateWebhook replaces a webhook subscription
+// @Summary Update a webhook subscription
+// @Description Replaces the subscription, the secret is kept if the request has none. Pending deliveries are sent to the new URL.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param requestBody body WebhookRequest true "Request body containing the subscription"
+// @Success 200 {object} SuccessResponse{data=webhook.Subscription} "Subscription updated"
+// @Failure 400 {object} ErrorResponse "Invalid input or request"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Subscription not found"
+// @Failure 500 {object} ErrorResponse "Error saving the subscription"
+// @Router /api/webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.webhookAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		subscription, ok := h.subscriptionFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		updated, err := h.store.Update(account, id, subscription)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		updated.Secret = ""
+		h.RespondWithSuccess(w, updated)
+	}
+}
+
+// DeleteWebhook deletes a webhook subscription
+// @Summary Delete a webhook subscription
+// @Description Deletes the subscription, its pending deliveries, its delivery log and its dead letters.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} SuccessResponse "Subscription deleted"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Subscription not found"
+// @Failure 500 {object} ErrorResponse "Error saving the subscriptions"
+// @Router /api/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.webhookAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		if err := h.store.Delete(account, id); err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, nil)
+	}
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook subscription
+// @Summary Get the deliveries of a webhook subscription
+// @Description Returns the pending and the latest completed deliveries of the subscription, newest first, with the number of attempts and the result of the last attempt.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} SuccessResponse{data=[]webhook.Delivery} "Deliveries of the subscription"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Subscription not found"
+// @Router /api/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) GetWebhookDeliveries() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.webhookAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		deliveries, err := h.store.Deliveries(account, id)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, deliveries)
+	}
+}
+
+// GetWebhookDeadLetters returns the dead letters of a webhook subscription
+// @Summary Get the dead letters of a webhook subscription
+// @Description Returns the deliveries of the subscription that failed every attempt, newest first.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} SuccessResponse{data=[]webhook.Delivery} "Dead letters of the subscription"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Subscription not found"
+// @Router /api/webhooks/{id}/dead_letters [get]
+func (h *WebhookHandler) GetWebhookDeadLetters() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.webhookAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		deadLetters, err := h.store.DeadLetters(account, id)
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, deadLetters)
+	}
+}
+
+// RetryWebhookDelivery retries a dead letter
+// @Summary Retry a dead letter
+// @Description Removes the delivery from the dead letters and sends its event again as a new delivery with fresh attempts.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param delivery_id path string true "Delivery ID of the dead letter"
+// @Success 200 {object} SuccessResponse{data=webhook.Delivery} "New pending delivery"
+// @Failure 401 {object} ErrorResponse "Missing or invalid credentials"
+// @Failure 403 {object} ErrorResponse "Request is not authenticated with an API key"
+// @Failure 404 {object} ErrorResponse "Subscription or dead letter not found"
+// @Failure 500 {object} ErrorResponse "Error saving the delivery"
+// @Router /api/webhooks/{id}/dead_letters/{delivery_id}/retry [post]
+func (h *WebhookHandler) RetryWebhookDelivery() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := h.webhookAccount(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		delivery, err := h.store.Retry(account, id, r.PathValue("delivery_id"))
+		if err != nil {
+			h.respondWithStoreError(w, r, id, err)
+			return
+		}
+		h.RespondWithSuccess(w, delivery)
+	}
+}
+
+// webhookAccount returns the vault account of the request or responds with 403 if the request is not authenticated
+// with an API key.
+func (h *WebhookHandler) webhookAccount(w http.ResponseWriter, r *http.Request) (string, bool) {
+	return h.vaultAccount(w, r, constants.ErrWebhookRequiresAPIKey, "Webhooks can only be managed with an API key of a vault account")
+}
+
+// subscriptionFromRequest decodes and validates the subscription of the request body.
+func (h *WebhookHandler) subscriptionFromRequest(w http.ResponseWriter, r *http.Request) (webhook.Subscription, bool) {
+	var requestBody WebhookRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidJsonBody, "Invalid JSON Body", map[string]string{
+			"error": err.Error(),
+		})
+		return webhook.Subscription{}, false
+	}
+
+	subscription := webhook.Subscription{
+		Name:             requestBody.Name,
+		URL:              requestBody.URL,
+		Secret:           requestBody.Secret,
+		Events:           requestBody.Events,
+		SerialNumbers:    requestBody.SerialNumbers,
+		BatteryThreshold: requestBody.BatteryThreshold,
+		Enabled:          requestBody.Enabled == nil || *requestBody.Enabled,
+	}
+	if err = subscription.Validate(); err != nil {
+		h.RespondWithError(w, r, http.StatusBadRequest, constants.ErrInvalidParameters, "Invalid request. "+err.Error(), map[string]string{
+			"url": subscription.URL,
+		})
+		return webhook.Subscription{}, false
+	}
+	if !h.checkSerialNumbers(w, r, subscription.SerialNumbers) {
+		return webhook.Subscription{}, false
+	}
+	return subscription, true
+}
+
+func (h *WebhookHandler) respondWithStoreError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrSubscriptionNotFound):
+		h.RespondWithError(w, r, http.StatusNotFound, constants.ErrWebhookNotFound, "Webhook subscription not found", map[string]string{
+			"id": id,
+		})
+	case errors.Is(err, webhook.ErrDeliveryNotFound):
+		h.RespondWithError(w, r, http.StatusNotFound, constants.ErrWebhookDeliveryNotFound, "Dead letter not found", map[string]string{
+			"id":          id,
+			"delivery_id": r.PathValue("delivery_id"),
+		})
+	default:
+		h.RespondWithError(w, r, http.StatusInternalServerError, constants.ErrSaveWebhook, "Failed to save the webhooks", map[string]string{
+			"error": err.Error(),
+		})
+	}
+}
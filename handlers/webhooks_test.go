@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/webhook"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWebhookRouter returns a router with the webhook endpoints, see newAccountRouter.
+func newWebhookRouter(t *testing.T) chi.Router {
+	store, err := webhook.Open(filepath.Join(t.TempDir(), "webhooks.json"), 10)
+	require.NoError(t, err)
+	return newAccountRouter(t, func(baseHandler *BaseHandler) RouteRegistrar { return NewWebhookHandler(baseHandler, store) })
+}
+
+func TestWebhookHandler_ManagesSubscriptions(t *testing.T) {
+	router := newWebhookRouter(t)
+
+	rec := accountRequest(router, http.MethodPost, "/api/webhooks", "home", `{"name":"On-call","url":"https://example.com/ecoflow","events":["device.offline","battery.below"],"serial_numbers":["R601ZEB4ZEAL0528"],"battery_threshold":20}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var created struct {
+		Data webhook.Subscription `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	id := created.Data.ID
+	assert.Equal(t, "home", created.Data.Account)
+	assert.Len(t, created.Data.Secret, 64, "the generated secret is returned on creation")
+	assert.True(t, created.Data.Enabled, "enabled by default")
+
+	rec = accountRequest(router, http.MethodGet, "/api/webhooks", "home", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), id)
+	assert.NotContains(t, rec.Body.String(), created.Data.Secret, "secrets are not listed")
+	rec = accountRequest(router, http.MethodGet, "/api/webhooks", "office", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String(), "subscriptions of other accounts are not listed")
+
+	rec = accountRequest(router, http.MethodPut, "/api/webhooks/"+id, "home", `{"name":"Faults","url":"https://example.com/faults","events":["fault.raised"],"enabled":false}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = accountRequest(router, http.MethodGet, "/api/webhooks/"+id, "home", "")
+	assert.Contains(t, rec.Body.String(), `"url":"https://example.com/faults"`)
+	assert.Contains(t, rec.Body.String(), `"enabled":false`)
+	assert.NotContains(t, rec.Body.String(), `"secret"`)
+
+	rec = accountRequest(router, http.MethodGet, "/api/webhooks/"+id+"/deliveries", "home", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String())
+	rec = accountRequest(router, http.MethodGet, "/api/webhooks/"+id+"/dead_letters", "home", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String())
+	rec = accountRequest(router, http.MethodPost, "/api/webhooks/"+id+"/dead_letters/0123456789abcdef/retry", "home", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"`+constants.ErrWebhookDeliveryNotFound+`"`)
+
+	rec = accountRequest(router, http.MethodDelete, "/api/webhooks/"+id, "office", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "subscriptions of other accounts are not found")
+	rec = accountRequest(router, http.MethodDelete, "/api/webhooks/"+id, "home", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = accountRequest(router, http.MethodGet, "/api/webhooks/"+id+"/deliveries", "home", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"`+constants.ErrWebhookNotFound+`"`)
+}
+
+func TestWebhookHandler_ValidatesSubscriptions(t *testing.T) {
+	router := newWebhookRouter(t)
+
+	tests := []struct {
+		name    string
+		account string
+		body    string
+		status  int
+		code    string
+	}{
+		{name: "ecoflow keys", body: `{"url":"https://example.com/ecoflow"}`, status: http.StatusForbidden, code: constants.ErrWebhookRequiresAPIKey},
+		{name: "invalid json", account: "home", body: `{`, status: http.StatusBadRequest, code: constants.ErrInvalidJsonBody},
+		{name: "missing url", account: "home", body: `{"name":"On-call"}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "relative url", account: "home", body: `{"url":"/ecoflow"}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "unknown event", account: "home", body: `{"url":"https://example.com/ecoflow","events":["device.deleted"]}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "battery event without threshold", account: "home", body: `{"url":"https://example.com/ecoflow","events":["battery.below"]}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "threshold out of range", account: "home", body: `{"url":"https://example.com/ecoflow","battery_threshold":100}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+		{name: "duplicate serial number", account: "home", body: `{"url":"https://example.com/ecoflow","serial_numbers":["R601ZEB4ZEAL0528","R601ZEB4ZEAL0528"]}`, status: http.StatusBadRequest, code: constants.ErrInvalidParameters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := accountRequest(router, http.MethodPost, "/api/webhooks", tt.account, tt.body)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+	rec := accountRequest(router, http.MethodGet, "/api/webhooks", "home", "")
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rec.Body.String())
+}
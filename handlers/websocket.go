@@ -0,0 +1,616 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/telemetry"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	wsAuthTimeout           = 10 * time.Second
+	wsWriteTimeout          = 10 * time.Second
+	wsMaxMessageSize        = 64 << 10
+	wsMaxConcurrentCommands = 4
+)
+
+// WebSocket message types, see WebSocketRequest and WebSocketMessage.
+const (
+	WsTypeAuth           = "auth"
+	WsTypeSubscribe      = "subscribe"
+	WsTypeUnsubscribe    = "unsubscribe"
+	WsTypeCommand        = "command"
+	WsTypeAck            = "ack"
+	WsTypeResult         = "result"
+	WsTypeError          = "error"
+	WsTypeSnapshot       = "snapshot"
+	WsTypeDelta          = "delta"
+	WsTypeTelemetryError = "telemetry_error"
+)
+
+var wsCommandPattern = regexp.MustCompile(`^[a-z_]+(/[a-z_]+)*$`)
+
+// WebSocketRequest is a message sent by the client.
+type WebSocketRequest struct {
+	// Type is one of auth, subscribe, unsubscribe or command.
+	Type string `json:"type"`
+	// ID is copied to the ack, result or error message that answers the request.
+	ID           string `json:"id,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	// Params are the parameters to subscribe to or unsubscribe from: exact names or prefixes ending with "*", e.g.
+	// "bms_bmsStatus.*". An empty list subscribes to all parameters or unsubscribes from the device.
+	Params []string `json:"params,omitempty"`
+	// DeviceType and Command select the command route, e.g. "power_station" and "out/ac" for
+	// PUT /api/power_station/{serial_number}/out/ac. DeviceType defaults to power_station.
+	DeviceType string          `json:"device_type,omitempty"`
+	Command    string          `json:"command,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty" swaggertype:"object"`
+	// Authorization, SecretToken and APIKey authenticate the connection when the headers can't be set, e.g. in
+	// browsers. They are only accepted in the first message.
+	Authorization string `json:"authorization,omitempty"`
+	SecretToken   string `json:"secret_token,omitempty"`
+	APIKey        string `json:"api_key,omitempty"`
+}
+
+// WebSocketMessage is a message sent by the server.
+type WebSocketMessage struct {
+	// Type is one of ack, result, error, snapshot, delta or telemetry_error.
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	// EventID, Time, Params and Removed describe snapshot and delta messages. Resync is set on snapshots sent after
+	// telemetry messages were dropped because the client didn't read them fast enough.
+	EventID string                 `json:"event_id,omitempty"`
+	Time    *time.Time             `json:"time,omitempty"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	Removed []string               `json:"removed,omitempty"`
+	Resync  bool                   `json:"resync,omitempty"`
+	// Status and Body are the HTTP status and response body of a command.
+	Status int             `json:"status,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty" swaggertype:"object"`
+	Error  *ErrorField     `json:"error,omitempty"`
+}
+
+type WebSocketHandler struct {
+	*BaseHandler
+	feeds        *DeviceFeeds
+	dispatcher   *CommandDispatcher
+	sendQueue    int
+	pingInterval time.Duration
+	upgrader     websocket.Upgrader
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewWebSocketHandler(baseHandler *BaseHandler, feeds *DeviceFeeds, dispatcher *CommandDispatcher, sendQueue int, pingInterval time.Duration) *WebSocketHandler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebSocketHandler{
+		BaseHandler:  baseHandler,
+		feeds:        feeds,
+		dispatcher:   dispatcher,
+		sendQueue:    sendQueue,
+		pingInterval: pingInterval,
+		upgrader: websocket.Upgrader{
+			HandshakeTimeout: wsWriteTimeout,
+			// the API doesn't use cookies, a foreign page can't use the credentials of the browser, so cross-origin
+			// connections are safe to accept
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// RegisterRoutes registers the WebSocket route. The connections are long-lived and may authenticate with their first
+// message, so the route must not be registered behind the request timeout and auth headers middlewares.
+func (h *WebSocketHandler) RegisterRoutes(router chi.Router) {
+	router.Get("/api/ws", h.ServeWebSocket())
+}
+
+// Close closes all connections, it is called when the server shuts down.
+func (h *WebSocketHandler) Close() {
+	h.cancel()
+}
+
+// ServeWebSocket handles WebSocket connections
+// @Summary Multiplexed telemetry and commands over WebSocket
+// @Description Upgrades the connection to a WebSocket. Clients send WebSocketRequest messages to subscribe to the parameters of several devices and to send power station commands, the server answers with WebSocketMessage messages. See the README for the protocol.
+// @Tags Devices
+// @Param message body WebSocketRequest false "Message sent by the client"
+// @Success 101 {object} WebSocketMessage "Message sent by the server"
+// @Failure 401 {object} ErrorResponse "Invalid credentials"
+// @Router /api/ws [get]
+func (h *WebSocketHandler) ServeWebSocket() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := &wsConnection{
+			h:             h,
+			header:        authHeaders(r.Header),
+			remoteAddr:    r.RemoteAddr,
+			send:          make(chan WebSocketMessage, h.sendQueue),
+			commands:      make(chan struct{}, wsMaxConcurrentCommands),
+			subscriptions: make(map[string]*wsSubscription),
+		}
+		// connections that send credentials with the upgrade request are rejected before the upgrade
+		if len(c.header) > 0 {
+			var ok bool
+			if c.client, ok = h.GetEcoflowClientOrRespondWithError(r, w); !ok {
+				return
+			}
+			c.account = h.account(r)
+		}
+
+		conn, err := h.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return // the upgrader already responded
+		}
+		c.conn = conn
+		c.ctx, c.cancel = context.WithCancel(h.ctx)
+		c.serve(r)
+	}
+}
+
+// authHeaders returns the credential headers of the request.
+func authHeaders(header http.Header) http.Header {
+	result := make(http.Header)
+	for _, name := range []string{constants.HeaderAuthorization, constants.HeaderXSecretToken, constants.HeaderXAPIKey} {
+		if value := header.Get(name); value != "" {
+			result.Set(name, value)
+		}
+	}
+	return result
+}
+
+type wsConnection struct {
+	h          *WebSocketHandler
+	conn       *websocket.Conn
+	ctx        context.Context
+	cancel     context.CancelFunc
+	header     http.Header
+	client     *ecoflow.Client
+	account    string
+	remoteAddr string
+
+	send     chan WebSocketMessage
+	commands chan struct{}
+	wg       sync.WaitGroup
+
+	mu            sync.Mutex
+	subscriptions map[string]*wsSubscription
+}
+
+func (c *wsConnection) serve(r *http.Request) {
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		c.writeLoop()
+	}()
+	defer func() {
+		c.cancel()
+		c.mu.Lock()
+		for _, s := range c.subscriptions {
+			s.close()
+		}
+		c.mu.Unlock()
+		c.wg.Wait()
+		<-writerDone
+	}()
+
+	pongWait := 2 * c.h.pingInterval
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	if c.client == nil && !c.authenticate(r) {
+		return
+	}
+
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		request, ok := c.read()
+		if !ok {
+			return
+		}
+		switch request.Type {
+		case WsTypeSubscribe:
+			c.subscribe(request)
+		case WsTypeUnsubscribe:
+			c.unsubscribe(request)
+		case WsTypeCommand:
+			c.command(request)
+		default:
+			c.replyError(request, constants.ErrInvalidParameters, "Unknown message type, expected subscribe, unsubscribe or command")
+		}
+	}
+}
+
+// read reads the next request. Invalid JSON is reported to the client, false is returned when the connection failed.
+func (c *wsConnection) read() (WebSocketRequest, bool) {
+	for {
+		var request WebSocketRequest
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return request, false
+		}
+		if err = json.Unmarshal(data, &request); err != nil {
+			c.replyError(request, constants.ErrInvalidJsonBody, "Invalid JSON message: "+err.Error())
+			continue
+		}
+		return request, true
+	}
+}
+
+// authenticate expects an auth message with the credentials as the first message.
+func (c *wsConnection) authenticate(r *http.Request) bool {
+	_ = c.conn.SetReadDeadline(time.Now().Add(wsAuthTimeout))
+	request, ok := c.read()
+	if !ok {
+		return false
+	}
+	if request.Type != WsTypeAuth {
+		c.replyError(request, constants.ErrMandatoryHeaderMissing, "The first message must authenticate the connection")
+		c.cancel()
+		return false
+	}
+
+	authRequest, err := http.NewRequestWithContext(c.ctx, http.MethodGet, r.URL.String(), nil)
+	if err != nil {
+		return false
+	}
+	for name, value := range map[string]string{
+		constants.HeaderAuthorization: request.Authorization,
+		constants.HeaderXSecretToken:  request.SecretToken,
+		constants.HeaderXAPIKey:       request.APIKey,
+	} {
+		if value != "" {
+			authRequest.Header.Set(name, value)
+		}
+	}
+
+	client, err := c.h.Provider(authRequest)
+	if err != nil {
+		c.replyError(request, constants.ErrInvalidAuthHeader, err.Error())
+		c.cancel()
+		return false
+	}
+	c.client = client
+	c.account = c.h.account(authRequest)
+	c.header = authRequest.Header
+	c.reply(WebSocketMessage{Type: WsTypeAck, ID: request.ID})
+	return true
+}
+
+func (c *wsConnection) writeLoop() {
+	ping := time.NewTicker(c.h.pingInterval)
+	defer ping.Stop()
+	defer c.conn.Close()
+
+	for {
+		var err error
+		select {
+		case message := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			err = c.conn.WriteJSON(message)
+		case <-ping.C:
+			err = c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+		case <-c.ctx.Done():
+			// flush the pending replies, e.g. the error that closed the connection
+			for len(c.send) > 0 {
+				_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if c.conn.WriteJSON(<-c.send) != nil {
+					break
+				}
+			}
+			code := websocket.CloseNormalClosure
+			if c.h.ctx.Err() != nil {
+				code = websocket.CloseGoingAway
+			}
+			_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(wsWriteTimeout))
+			return
+		}
+		if err != nil {
+			c.cancel()
+			return
+		}
+	}
+}
+
+// reply queues a message that must not be dropped, it waits while the send queue is full.
+func (c *wsConnection) reply(message WebSocketMessage) {
+	select {
+	case c.send <- message:
+	case <-c.ctx.Done():
+	}
+}
+
+// push queues a telemetry message. It returns false if the send queue is full and the message was dropped.
+func (c *wsConnection) push(message WebSocketMessage) bool {
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *wsConnection) replyError(request WebSocketRequest, code, message string) {
+	var details map[string]string
+	if request.SerialNumber != "" {
+		details = map[string]string{"serial_number": request.SerialNumber}
+	}
+	c.reply(WebSocketMessage{
+		Type:         WsTypeError,
+		ID:           request.ID,
+		SerialNumber: request.SerialNumber,
+		Error:        &ErrorField{Code: code, Message: message, Details: details},
+	})
+}
+
+func (c *wsConnection) subscribe(request WebSocketRequest) {
+	if request.SerialNumber == "" {
+		c.replyError(request, constants.ErrInvalidParameters, "serial_number is mandatory")
+		return
+	}
+
+	c.mu.Lock()
+	s, ok := c.subscriptions[request.SerialNumber]
+	c.mu.Unlock()
+	if ok {
+		c.reply(WebSocketMessage{Type: WsTypeAck, ID: request.ID, SerialNumber: request.SerialNumber})
+		s.addPatterns(c, request.Params)
+		return
+	}
+
+	subscription, err := c.h.feeds.Subscribe(c.client, c.account, request.SerialNumber, "")
+	if err != nil {
+		c.replyError(request, constants.ErrStreamUnavailable, err.Error())
+		return
+	}
+	s = &wsSubscription{sn: request.SerialNumber, subscription: subscription, patterns: request.Params, state: make(map[string]interface{})}
+	c.mu.Lock()
+	c.subscriptions[request.SerialNumber] = s
+	c.mu.Unlock()
+
+	c.reply(WebSocketMessage{Type: WsTypeAck, ID: request.ID, SerialNumber: request.SerialNumber})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		s.forward(c)
+	}()
+}
+
+func (c *wsConnection) unsubscribe(request WebSocketRequest) {
+	c.mu.Lock()
+	s, ok := c.subscriptions[request.SerialNumber]
+	if ok && s.removePatterns(request.Params) {
+		delete(c.subscriptions, request.SerialNumber)
+		s.close()
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		c.replyError(request, constants.ErrInvalidParameters, "Not subscribed to the device")
+		return
+	}
+	c.reply(WebSocketMessage{Type: WsTypeAck, ID: request.ID, SerialNumber: request.SerialNumber})
+}
+
+func (c *wsConnection) command(request WebSocketRequest) {
+	deviceType := request.DeviceType
+	if deviceType == "" {
+		deviceType = "power_station"
+	}
+	if request.SerialNumber == "" || !wsCommandPattern.MatchString(deviceType) || !wsCommandPattern.MatchString(request.Command) {
+		c.replyError(request, constants.ErrInvalidParameters, "serial_number and a valid command, e.g. out/ac, are mandatory")
+		return
+	}
+	path := "/api/" + deviceType + "/" + url.PathEscape(request.SerialNumber) + "/" + request.Command
+
+	// the number of commands in flight is limited, further messages are not read until one finished
+	select {
+	case c.commands <- struct{}{}:
+	case <-c.ctx.Done():
+		return
+	}
+	c.wg.Add(1)
+	go func() {
+		defer func() {
+			<-c.commands
+			c.wg.Done()
+		}()
+		result := c.h.dispatcher.Dispatch(c.ctx, DispatchRequest{
+			Method:     http.MethodPut,
+			Path:       path,
+			RemoteAddr: c.remoteAddr,
+			Header:     c.header,
+			Body:       request.Payload,
+		})
+		c.reply(WebSocketMessage{Type: WsTypeResult, ID: request.ID, SerialNumber: request.SerialNumber, Status: result.Status, Body: result.Body})
+	}()
+}
+
+// wsSubscription forwards the events of a device feed that match the subscribed parameters. It keeps the state of the
+// device, so a client that was too slow can be resynchronized with a snapshot instead of being disconnected.
+type wsSubscription struct {
+	sn           string
+	subscription *telemetry.Subscription
+
+	mu       sync.Mutex
+	patterns []string
+	state    map[string]interface{}
+	eventID  string
+	lagging  bool
+	closed   bool
+}
+
+func (s *wsSubscription) forward(c *wsConnection) {
+	for _, event := range s.subscription.Replay {
+		s.deliver(c, event)
+	}
+	for {
+		select {
+		case event, ok := <-s.subscription.Events:
+			if !ok {
+				s.mu.Lock()
+				closed := s.closed
+				s.mu.Unlock()
+				if !closed && c.ctx.Err() == nil {
+					// the device may have been subscribed again meanwhile, that subscription is kept
+					c.mu.Lock()
+					current := c.subscriptions[s.sn] == s
+					if current {
+						delete(c.subscriptions, s.sn)
+					}
+					c.mu.Unlock()
+					if !current {
+						return
+					}
+					c.replyError(WebSocketRequest{SerialNumber: s.sn}, constants.ErrStreamUnavailable, "Telemetry feed of the device was closed, subscribe again")
+				}
+				return
+			}
+			s.deliver(c, event)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *wsSubscription) deliver(c *wsConnection, event telemetry.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := WebSocketMessage{SerialNumber: s.sn, EventID: event.ID, Time: &event.Time}
+	switch event.Type {
+	case telemetry.EventError:
+		message.Type = WsTypeTelemetryError
+		message.Error = &ErrorField{Code: constants.ErrGetAllDeviceParameters, Message: event.Error}
+		if !c.push(message) {
+			s.lagging = true
+		}
+		return
+	case telemetry.EventSnapshot:
+		s.state = copyParams(event.Params)
+	case telemetry.EventDelta:
+		for k, v := range event.Params {
+			s.state[k] = v
+		}
+		for _, k := range event.Removed {
+			delete(s.state, k)
+		}
+	}
+	s.eventID = event.ID
+
+	if event.Type == telemetry.EventSnapshot || s.lagging {
+		message.Type = WsTypeSnapshot
+		message.Params = s.filter(s.state)
+		message.Resync = s.lagging
+	} else {
+		message.Type = WsTypeDelta
+		message.Params = s.filter(event.Params)
+		for _, k := range event.Removed {
+			if s.matches(k) {
+				message.Removed = append(message.Removed, k)
+			}
+		}
+		if len(message.Params) == 0 && len(message.Removed) == 0 {
+			return
+		}
+	}
+	s.lagging = !c.push(message)
+}
+
+// addPatterns extends the subscription and sends a snapshot of the newly subscribed parameters.
+func (s *wsSubscription) addPatterns(c *wsConnection, patterns []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(patterns) == 0 {
+		s.patterns = nil
+	} else if len(s.patterns) > 0 {
+		s.patterns = append(s.patterns, patterns...)
+	}
+	if s.eventID == "" {
+		return // the first snapshot is still on its way
+	}
+	message := WebSocketMessage{Type: WsTypeSnapshot, SerialNumber: s.sn, EventID: s.eventID, Params: s.filter(s.state)}
+	s.lagging = !c.push(message)
+}
+
+// removePatterns reduces the subscription and reports whether nothing is subscribed anymore. Must be called with the
+// connection lock held.
+func (s *wsSubscription) removePatterns(patterns []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(patterns) == 0 || len(s.patterns) == 0 {
+		// parameters can't be excluded from a subscription to all parameters, the device is unsubscribed
+		return true
+	}
+	var remaining []string
+	for _, p := range s.patterns {
+		if !containsString(patterns, p) {
+			remaining = append(remaining, p)
+		}
+	}
+	s.patterns = remaining
+	return len(remaining) == 0
+}
+
+func (s *wsSubscription) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.subscription.Close()
+}
+
+func (s *wsSubscription) filter(params map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for k, v := range params {
+		if s.matches(k) {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// matches reports whether the parameter is subscribed: all parameters match when no patterns are given, patterns
+// ending with "*" match by prefix, others must be equal to the parameter.
+func (s *wsSubscription) matches(param string) bool {
+	if len(s.patterns) == 0 {
+		return true
+	}
+	for _, p := range s.patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(param, prefix) || p == param {
+			return true
+		}
+	}
+	return false
+}
+
+func copyParams(params map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		result[k] = v
+	}
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
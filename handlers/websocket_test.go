@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httplog/v2"
+	"github.com/gorilla/websocket"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/telemetry"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWebSocketServer(t *testing.T) string {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":"0","message":"Success","data":{"pd.soc":50,"pd.watts":100,"bms_bmsStatus.soc":49}}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	baseHandler := NewBaseHandler(httplog.NewLogger("test-logger", httplog.Options{}), func(r *http.Request) (*ecoflow.Client, error) {
+		if r.Header.Get(constants.HeaderAuthorization) == "" {
+			return nil, errors.New("authorization is missing")
+		}
+		return ecoflow.NewEcoflowClient("access", "secret", ecoflow.WithBaseUrl(upstream.URL)), nil
+	})
+	baseHandler.Identity = func(r *http.Request) string { return r.Header.Get(constants.HeaderAuthorization) }
+
+	broker := telemetry.NewBroker(10, time.Minute)
+	t.Cleanup(broker.Close)
+	feeds := NewDeviceFeeds(baseHandler, broker, 20*time.Millisecond)
+	dispatcher := NewCommandDispatcher(nil, NewPowerStationHandler(baseHandler))
+	handler := NewWebSocketHandler(baseHandler, feeds, dispatcher, 16, time.Second)
+	t.Cleanup(handler.Close)
+
+	router := chi.NewRouter()
+	handler.RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/api/ws"
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn, messageType string) WebSocketMessage {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		var message WebSocketMessage
+		require.NoError(t, conn.ReadJSON(&message))
+		if message.Type == messageType {
+			return message
+		}
+	}
+}
+
+func TestWebSocketHandler_SubscribeAndCommand(t *testing.T) {
+	url := newWebSocketServer(t)
+	header := http.Header{constants.HeaderAuthorization: {"Bearer access"}, constants.HeaderXSecretToken: {"secret"}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(WebSocketRequest{Type: WsTypeSubscribe, ID: "s1", SerialNumber: "R331ZEB4ZEAL0528", Params: []string{"pd.*"}}))
+	ack := readMessage(t, conn, WsTypeAck)
+	assert.Equal(t, "s1", ack.ID)
+	snapshot := readMessage(t, conn, WsTypeSnapshot)
+	assert.Equal(t, "R331ZEB4ZEAL0528", snapshot.SerialNumber)
+	assert.Equal(t, map[string]interface{}{"pd.soc": 50.0, "pd.watts": 100.0}, snapshot.Params)
+
+	// extending the subscription sends the newly subscribed parameters
+	require.NoError(t, conn.WriteJSON(WebSocketRequest{Type: WsTypeSubscribe, ID: "s2", SerialNumber: "R331ZEB4ZEAL0528", Params: []string{"bms_bmsStatus.soc"}}))
+	snapshot = readMessage(t, conn, WsTypeSnapshot)
+	assert.Contains(t, snapshot.Params, "bms_bmsStatus.soc")
+
+	require.NoError(t, conn.WriteJSON(WebSocketRequest{Type: WsTypeCommand, ID: "c1", SerialNumber: "R331ZEB4ZEAL0528", Command: "out/ac", Payload: []byte(`{"ac_state":"on","xboost_state":"off","out_freq":50,"out_voltage":230}`)}))
+	require.NoError(t, conn.WriteJSON(WebSocketRequest{Type: WsTypeCommand, ID: "c2", SerialNumber: "R331ZEB4ZEAL0528", Command: "out/ac", Payload: []byte(`{"ac_state":"maybe"}`)}))
+	results := map[string]WebSocketMessage{}
+	for len(results) < 2 {
+		result := readMessage(t, conn, WsTypeResult)
+		results[result.ID] = result
+	}
+	assert.Equal(t, http.StatusOK, results["c1"].Status)
+	assert.Equal(t, http.StatusBadRequest, results["c2"].Status)
+	assert.Contains(t, string(results["c2"].Body), constants.ErrInvalidParameters)
+
+	require.NoError(t, conn.WriteJSON(WebSocketRequest{Type: "reboot", ID: "x"}))
+	unknown := readMessage(t, conn, WsTypeError)
+	assert.Equal(t, "x", unknown.ID)
+	assert.Equal(t, constants.ErrInvalidParameters, unknown.Error.Code)
+}
+
+func TestWebSocketHandler_Authentication(t *testing.T) {
+	url := newWebSocketServer(t)
+
+	// credentials sent with the upgrade request are checked before the upgrade
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{constants.HeaderXAPIKey: {"unknown"}})
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// without headers the first message must authenticate the connection
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteJSON(WebSocketRequest{Type: WsTypeSubscribe, ID: "s1", SerialNumber: "R331ZEB4ZEAL0528"}))
+	rejected := readMessage(t, conn, WsTypeError)
+	assert.Equal(t, constants.ErrMandatoryHeaderMissing, rejected.Error.Code)
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "unexpected error: %v", err)
+	_ = conn.Close()
+
+	conn, _, err = websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteJSON(WebSocketRequest{Type: WsTypeAuth, ID: "a1", Authorization: "Bearer access", SecretToken: "secret"}))
+	assert.Equal(t, "a1", readMessage(t, conn, WsTypeAck).ID)
+	require.NoError(t, conn.WriteJSON(WebSocketRequest{Type: WsTypeSubscribe, SerialNumber: "R331ZEB4ZEAL0528"}))
+	assert.Len(t, readMessage(t, conn, WsTypeSnapshot).Params, 3)
+}
+
+func TestWsSubscription_ResyncsSlowClients(t *testing.T) {
+	c := &wsConnection{send: make(chan WebSocketMessage, 1)}
+	s := &wsSubscription{sn: "SN1", patterns: []string{"pd.soc"}, state: make(map[string]interface{})}
+
+	s.deliver(c, telemetry.Event{ID: "e-1", Type: telemetry.EventSnapshot, Params: map[string]interface{}{"pd.soc": 1.0, "pd.watts": 5.0}})
+	// deltas of parameters that aren't subscribed are not sent
+	s.deliver(c, telemetry.Event{ID: "e-2", Type: telemetry.EventDelta, Params: map[string]interface{}{"pd.watts": 6.0}})
+	// the queue is full, the delta is dropped
+	s.deliver(c, telemetry.Event{ID: "e-3", Type: telemetry.EventDelta, Params: map[string]interface{}{"pd.soc": 2.0}})
+
+	first := <-c.send
+	assert.Equal(t, WsTypeSnapshot, first.Type)
+	assert.Equal(t, map[string]interface{}{"pd.soc": 1.0}, first.Params)
+	assert.Empty(t, c.send)
+
+	s.deliver(c, telemetry.Event{ID: "e-4", Type: telemetry.EventDelta, Params: map[string]interface{}{"pd.watts": 7.0}})
+	resync := <-c.send
+	assert.Equal(t, WsTypeSnapshot, resync.Type)
+	assert.True(t, resync.Resync)
+	assert.Equal(t, "e-4", resync.EventID)
+	assert.Equal(t, map[string]interface{}{"pd.soc": 2.0}, resync.Params)
+}
+
+func TestWsSubscription_ClosedFeed(t *testing.T) {
+	closedFeed := func() *telemetry.Subscription {
+		events := make(chan telemetry.Event)
+		close(events)
+		return &telemetry.Subscription{Events: events}
+	}
+	c := &wsConnection{ctx: context.Background(), send: make(chan WebSocketMessage, 1), subscriptions: make(map[string]*wsSubscription)}
+	old := &wsSubscription{sn: "SN1", subscription: closedFeed(), state: make(map[string]interface{})}
+	current := &wsSubscription{sn: "SN1", subscription: closedFeed(), state: make(map[string]interface{})}
+	c.subscriptions["SN1"] = current
+
+	old.forward(c)
+	assert.Same(t, current, c.subscriptions["SN1"], "the feed of an older subscription doesn't remove the current one")
+	assert.Empty(t, c.send)
+
+	current.forward(c)
+	assert.Empty(t, c.subscriptions)
+	message := <-c.send
+	assert.Equal(t, WsTypeError, message.Type)
+	assert.Equal(t, constants.ErrStreamUnavailable, message.Error.Code)
+}
@@ -0,0 +1,57 @@
+package history
+
+import (
+	"context"
+	"go-ecoflow-api-server/ingest"
+	"log/slog"
+	"time"
+)
+
+// DeviceSource provides the parameters of the recorded devices, see ingest.Store.
+type DeviceSource interface {
+	Devices() []ingest.Device
+}
+
+// Recorder samples the selected parameters of the devices of a DeviceSource into the store.
+type Recorder struct {
+	store    *Store
+	source   DeviceSource
+	params   []string
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewRecorder(store *Store, source DeviceSource, params []string, interval time.Duration, logger *slog.Logger) *Recorder {
+	return &Recorder{store: store, source: source, params: params, interval: interval, logger: logger}
+}
+
+// Run records a sample of every device each interval until ctx is cancelled.
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.sample(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Recorder) sample(now time.Time) {
+	for _, device := range r.source.Devices() {
+		values := make(map[string]float64, len(r.params))
+		for _, param := range r.params {
+			if value, ok := ingest.Number(device.Params[param]); ok {
+				values[param] = value
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if err := r.store.Record(now, device.Account, device.SN, values); err != nil {
+			r.logger.Warn("Failed to record the device history", "serial_number", device.SN, "error", err)
+		}
+	}
+}
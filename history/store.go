@@ -0,0 +1,371 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rawKind    = "raw"
+	rollupKind = "rollup"
+)
+
+type Config struct {
+	Dir string
+	// SegmentDuration is the time covered by a segment file. A raw segment is rolled up once it is complete.
+	SegmentDuration time.Duration
+	// RollupInterval is the resolution of the rolled up samples, it must divide SegmentDuration.
+	RollupInterval time.Duration
+	// RawRetention is how long raw samples are kept, older samples are only available rolled up.
+	RawRetention time.Duration
+	// Retention is how long rolled up samples are kept.
+	Retention time.Duration
+}
+
+// Store is a file-backed time-series store for device parameters. Samples are appended to raw segment files which
+// cover SegmentDuration each. Complete segments are downsampled to rollup segments with the min, max, sum and count of
+// every RollupInterval, and the segments are deleted when they exceed their retention. Segments are JSON lines files
+// named {kind}-{start unix time}.jsonl.
+type Store struct {
+	cfg Config
+	now func() time.Time
+
+	mu           sync.Mutex
+	current      *os.File
+	currentStart time.Time
+}
+
+// record is a line of a segment file. Raw segments contain Values, rollup segments contain Aggregates.
+type record struct {
+	Time       int64                `json:"t"` // unix milliseconds, the start of the interval of rolled up samples
+	Account    string               `json:"account"`
+	SN         string               `json:"sn"`
+	Values     map[string]float64   `json:"values,omitempty"`
+	Aggregates map[string]Aggregate `json:"aggregates,omitempty"`
+}
+
+// Aggregate summarizes the samples of a parameter within an interval.
+type Aggregate struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Sum   float64 `json:"sum"`
+	Count int     `json:"count"`
+}
+
+func (a *Aggregate) add(other Aggregate) {
+	if a.Count == 0 {
+		*a = other
+		return
+	}
+	a.Min = math.Min(a.Min, other.Min)
+	a.Max = math.Max(a.Max, other.Max)
+	a.Sum += other.Sum
+	a.Count += other.Count
+}
+
+func single(value float64) Aggregate {
+	return Aggregate{Min: value, Max: value, Sum: value, Count: 1}
+}
+
+// Point is the aggregation of a parameter within a step of a query.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+	Count int       `json:"count"`
+}
+
+// Open opens the store in cfg.Dir, the directory is created if it doesn't exist. Complete segments that were not rolled
+// up yet are rolled up and expired segments are deleted.
+func Open(cfg Config) (*Store, error) {
+	if cfg.SegmentDuration <= 0 || cfg.RollupInterval <= 0 || cfg.SegmentDuration%cfg.RollupInterval != 0 {
+		return nil, errors.New("rollup interval must divide the segment duration")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("can't create history directory: %w", err)
+	}
+	s := &Store{cfg: cfg, now: time.Now}
+	if err := s.Maintain(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Record appends a sample of the parameters of the device.
+func (s *Store) Record(t time.Time, account, sn string, values map[string]float64) error {
+	line, err := json.Marshal(record{Time: t.UnixMilli(), Account: account, SN: sn, Values: values})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := s.segmentStart(t)
+	if s.current == nil || !start.Equal(s.currentStart) {
+		if err = s.closeCurrent(); err != nil {
+			return err
+		}
+		s.current, err = os.OpenFile(s.segmentPath(rawKind, start), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return fmt.Errorf("can't open history segment: %w", err)
+		}
+		s.currentStart = start
+		// the previous segment is complete now
+		if err = s.maintain(); err != nil {
+			return err
+		}
+	}
+	_, err = s.current.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the current segment.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeCurrent()
+}
+
+func (s *Store) closeCurrent() error {
+	if s.current == nil {
+		return nil
+	}
+	err := s.current.Close()
+	s.current = nil
+	return err
+}
+
+// Maintain rolls up the complete raw segments and deletes the expired segments.
+func (s *Store) Maintain() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maintain()
+}
+
+func (s *Store) maintain() error {
+	segments, err := s.segments()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	rolledUp := make(map[int64]bool)
+	for _, segment := range segments[rollupKind] {
+		rolledUp[segment.Unix()] = true
+	}
+
+	var errs []error
+	for _, start := range segments[rawKind] {
+		end := start.Add(s.cfg.SegmentDuration)
+		if !rolledUp[start.Unix()] && !end.After(s.segmentStart(now)) {
+			if err = s.rollup(start); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if end.Before(now.Add(-s.cfg.RawRetention)) {
+			errs = append(errs, os.Remove(s.segmentPath(rawKind, start)))
+		}
+	}
+	for _, start := range segments[rollupKind] {
+		if start.Add(s.cfg.SegmentDuration).Before(now.Add(-s.cfg.Retention)) {
+			errs = append(errs, os.Remove(s.segmentPath(rollupKind, start)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollup downsamples the raw segment. The rollup segment is written to a temporary file first, so a crash never leaves
+// an incomplete rollup behind.
+func (s *Store) rollup(start time.Time) error {
+	type key struct {
+		account string
+		sn      string
+		time    int64
+	}
+	buckets := make(map[key]map[string]Aggregate)
+	var order []key
+	err := readSegment(s.segmentPath(rawKind, start), func(r record) {
+		k := key{account: r.Account, sn: r.SN, time: time.UnixMilli(r.Time).Truncate(s.cfg.RollupInterval).UnixMilli()}
+		aggregates, ok := buckets[k]
+		if !ok {
+			aggregates = make(map[string]Aggregate)
+			buckets[k] = aggregates
+			order = append(order, k)
+		}
+		for param, value := range r.Values {
+			aggregate := aggregates[param]
+			aggregate.add(single(value))
+			aggregates[param] = aggregate
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	path := s.segmentPath(rollupKind, start)
+	file, err := os.CreateTemp(s.cfg.Dir, rollupKind+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, k := range order {
+		if err = encoder.Encode(record{Time: k.time, Account: k.account, SN: k.sn, Aggregates: buckets[k]}); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Oldest returns the start of the oldest segment that can still be retained, older samples are deleted.
+func (s *Store) Oldest() time.Time {
+	return s.segmentStart(s.now().Add(-s.cfg.Retention))
+}
+
+// Query returns the samples of the parameters of the device between from (inclusive) and to (exclusive), aggregated
+// per step. Steps without samples are omitted. Raw samples are used while they are retained, unless the step is at
+// least the rollup interval. Only the segments that can still be retained are read, whatever the range.
+func (s *Store) Query(account, sn string, params []string, from, to time.Time, step time.Duration) (map[string][]Point, error) {
+	wanted := make(map[string]bool, len(params))
+	buckets := make(map[string]map[int64]Aggregate, len(params))
+	for _, param := range params {
+		wanted[param] = true
+		buckets[param] = make(map[int64]Aggregate)
+	}
+	collect := func(t int64, param string, aggregate Aggregate) {
+		sample := time.UnixMilli(t)
+		if !wanted[param] || sample.Before(from) || !sample.Before(to) {
+			return
+		}
+		bucket := sample.Truncate(step).UnixMilli()
+		current := buckets[param][bucket]
+		current.add(aggregate)
+		buckets[param][bucket] = current
+	}
+
+	preferRollup := step >= s.cfg.RollupInterval
+	first := s.segmentStart(from)
+	if oldest := s.Oldest(); first.Before(oldest) {
+		first = oldest
+	}
+	// samples are recorded when they are polled, there are no segments after the current one
+	end := to
+	if last := s.segmentStart(s.now()).Add(s.cfg.SegmentDuration); end.After(last) {
+		end = last
+	}
+	for start := first; start.Before(end); start = start.Add(s.cfg.SegmentDuration) {
+		kinds := []string{rawKind, rollupKind}
+		if preferRollup {
+			kinds = []string{rollupKind, rawKind}
+		}
+		for _, kind := range kinds {
+			err := readSegment(s.segmentPath(kind, start), func(r record) {
+				if r.Account != account || r.SN != sn {
+					return
+				}
+				for param, value := range r.Values {
+					collect(r.Time, param, single(value))
+				}
+				for param, aggregate := range r.Aggregates {
+					collect(r.Time, param, aggregate)
+				}
+			})
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	result := make(map[string][]Point, len(params))
+	for param, series := range buckets {
+		points := make([]Point, 0, len(series))
+		for t, aggregate := range series {
+			points = append(points, Point{
+				Time:  time.UnixMilli(t).UTC(),
+				Min:   aggregate.Min,
+				Max:   aggregate.Max,
+				Avg:   aggregate.Sum / float64(aggregate.Count),
+				Count: aggregate.Count,
+			})
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+		result[param] = points
+	}
+	return result, nil
+}
+
+// readSegment calls fn for every record of the segment. Invalid lines, e.g. a line that is still being written, are
+// skipped.
+func readSegment(path string, fn func(record)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var r record
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			fn(r)
+		}
+	}
+	return scanner.Err()
+}
+
+// segments returns the start times of the segment files by kind.
+func (s *Store) segments() (map[string][]time.Time, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("can't list history segments: %w", err)
+	}
+	segments := make(map[string][]time.Time)
+	for _, entry := range entries {
+		kind, start, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".jsonl"), "-")
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") || !ok {
+			continue
+		}
+		unix, err := strconv.ParseInt(start, 10, 64)
+		if err != nil {
+			continue
+		}
+		segments[kind] = append(segments[kind], time.Unix(unix, 0))
+	}
+	return segments, nil
+}
+
+func (s *Store) segmentStart(t time.Time) time.Time {
+	return t.Truncate(s.cfg.SegmentDuration)
+}
+
+func (s *Store) segmentPath(kind string, start time.Time) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("%s-%d.jsonl", kind, start.Unix()))
+}
@@ -0,0 +1,155 @@
+package history
+
+import (
+	"go-ecoflow-api-server/ingest"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 1, 12, 0, 0, 0, 0, time.UTC)
+
+func openStore(t *testing.T, dir string, now *time.Time) *Store {
+	t.Helper()
+	store, err := Open(Config{
+		Dir:             dir,
+		SegmentDuration: 24 * time.Hour,
+		RollupInterval:  time.Hour,
+		RawRetention:    48 * time.Hour,
+		Retention:       96 * time.Hour,
+	})
+	require.NoError(t, err)
+	store.now = func() time.Time { return *now }
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestStore_RecordAndQuery(t *testing.T) {
+	now := start.Add(time.Hour)
+	store := openStore(t, t.TempDir(), &now)
+
+	for i, soc := range []float64{50, 52, 54, 60} {
+		require.NoError(t, store.Record(start.Add(time.Duration(i)*time.Minute), "vault:home", "SN1", map[string]float64{"pd.soc": soc, "pd.wattsInSum": 100}))
+	}
+	require.NoError(t, store.Record(start, "vault:other", "SN1", map[string]float64{"pd.soc": 10}))
+	require.NoError(t, store.Record(start, "vault:home", "SN2", map[string]float64{"pd.soc": 10}))
+
+	series, err := store.Query("vault:home", "SN1", []string{"pd.soc", "pd.watts"}, start, start.Add(time.Hour), 2*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []Point{
+		{Time: start, Min: 50, Max: 52, Avg: 51, Count: 2},
+		{Time: start.Add(2 * time.Minute), Min: 54, Max: 60, Avg: 57, Count: 2},
+	}, series["pd.soc"])
+	assert.Empty(t, series["pd.watts"], "unknown parameters have no samples")
+	assert.NotContains(t, series, "pd.wattsInSum", "only the requested parameters are returned")
+
+	// to is exclusive
+	series, err = store.Query("vault:home", "SN1", []string{"pd.soc"}, start, start.Add(time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []Point{{Time: start, Min: 50, Max: 50, Avg: 50, Count: 1}}, series["pd.soc"])
+
+	// ranges far beyond the retention only read the retained segments
+	series, err = store.Query("vault:home", "SN1", []string{"pd.soc"}, time.Unix(0, 0), time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC), 1000*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 4, series["pd.soc"][0].Count)
+	assert.Equal(t, start.Add(-96*time.Hour), store.Oldest())
+}
+
+func TestStore_RollupAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := start
+	store := openStore(t, dir, &now)
+
+	require.NoError(t, store.Record(start.Add(10*time.Minute), "vault:home", "SN1", map[string]float64{"pd.soc": 40}))
+	require.NoError(t, store.Record(start.Add(20*time.Minute), "vault:home", "SN1", map[string]float64{"pd.soc": 60}))
+	require.NoError(t, store.Record(start.Add(90*time.Minute), "vault:home", "SN1", map[string]float64{"pd.soc": 80}))
+
+	// the first sample of the next day completes the segment
+	now = start.Add(24 * time.Hour)
+	require.NoError(t, store.Record(now, "vault:home", "SN1", map[string]float64{"pd.soc": 90}))
+	assert.FileExists(t, filepath.Join(dir, "rollup-1736640000.jsonl"))
+
+	hourly := []Point{
+		{Time: start, Min: 40, Max: 60, Avg: 50, Count: 2},
+		{Time: start.Add(time.Hour), Min: 80, Max: 80, Avg: 80, Count: 1},
+	}
+	series, err := store.Query("vault:home", "SN1", []string{"pd.soc"}, start, start.Add(24*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, hourly, series["pd.soc"], "steps of at least the rollup interval use the rollup")
+
+	series, err = store.Query("vault:home", "SN1", []string{"pd.soc"}, start, start.Add(24*time.Hour), 10*time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, series["pd.soc"], 3, "smaller steps use the raw samples")
+
+	// raw samples expire first, the rollup is used afterward
+	now = start.Add(73 * time.Hour)
+	require.NoError(t, store.Maintain())
+	assert.NoFileExists(t, filepath.Join(dir, "raw-1736640000.jsonl"))
+	series, err = store.Query("vault:home", "SN1", []string{"pd.soc"}, start, start.Add(24*time.Hour), 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, hourly, series["pd.soc"])
+
+	now = start.Add(121 * time.Hour)
+	require.NoError(t, store.Maintain())
+	series, err = store.Query("vault:home", "SN1", []string{"pd.soc"}, start, start.Add(24*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, series["pd.soc"])
+}
+
+func TestOpen_RollsUpCompleteSegments(t *testing.T) {
+	dir := t.TempDir()
+	now := start.Add(time.Hour)
+	store := openStore(t, dir, &now)
+	require.NoError(t, store.Record(start, "vault:home", "SN1", map[string]float64{"pd.soc": 40}))
+	require.NoError(t, store.Close())
+
+	// a partially written line is skipped
+	file, err := os.OpenFile(filepath.Join(dir, "raw-1736640000.jsonl"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"t":17366`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// the server was down until the segment was complete
+	_, err = Open(Config{Dir: dir, SegmentDuration: 24 * time.Hour, RollupInterval: time.Hour, RawRetention: 48 * time.Hour, Retention: 96 * time.Hour})
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "rollup-1736640000.jsonl"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"t":1736640000000,"account":"vault:home","sn":"SN1","aggregates":{"pd.soc":{"min":40,"max":40,"sum":40,"count":1}}}`, string(data))
+}
+
+func TestOpen_RejectsRollupInterval(t *testing.T) {
+	_, err := Open(Config{Dir: t.TempDir(), SegmentDuration: 24 * time.Hour, RollupInterval: 7 * time.Hour})
+	assert.Error(t, err)
+}
+
+type devices []ingest.Device
+
+func (d devices) Devices() []ingest.Device {
+	return d
+}
+
+func TestRecorder_Sample(t *testing.T) {
+	now := start
+	store := openStore(t, t.TempDir(), &now)
+	recorder := NewRecorder(store, devices{
+		{Account: "vault:home", SN: "SN1", Params: map[string]interface{}{"pd.soc": 50.0, "pd.model": "DELTA", "pd.watts": 5.0}},
+		{Account: "vault:home", SN: "SN2", Params: map[string]interface{}{"pd.model": "DELTA"}},
+	}, []string{"pd.soc", "pd.model"}, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	recorder.sample(start)
+	series, err := store.Query("vault:home", "SN1", []string{"pd.soc", "pd.watts"}, start, start.Add(time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []Point{{Time: start, Min: 50, Max: 50, Avg: 50, Count: 1}}, series["pd.soc"])
+	assert.Empty(t, series["pd.watts"], "only the selected parameters are recorded")
+
+	series, err = store.Query("vault:home", "SN2", []string{"pd.model"}, start, start.Add(time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, series["pd.model"], "parameters that are not numeric are not recorded")
+}
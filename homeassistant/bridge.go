@@ -0,0 +1,394 @@
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go-ecoflow-api-server/catalog"
+	"go-ecoflow-api-server/devicestatus"
+	"go-ecoflow-api-server/ingest"
+	"go-ecoflow-api-server/mqttutil"
+	"go-ecoflow-api-server/service"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// offlineTimeout limits the publishing of the offline status when the bridge stops
+const offlineTimeout = time.Second
+
+// DeviceSource provides the parameters of the devices of the vault accounts, see ingest.Store.
+type DeviceSource interface {
+	Devices() []ingest.Device
+}
+
+type Config struct {
+	// Broker is the URL of the MQTT broker of Home Assistant, e.g. tcp://homeassistant.local:1883.
+	Broker   string
+	Username string
+	Password string
+	ClientID string
+	// DiscoveryPrefix is the discovery prefix of the MQTT integration of Home Assistant, "homeassistant" by default.
+	DiscoveryPrefix string
+	// TopicPrefix is the prefix of the state, availability and command topics of the devices.
+	TopicPrefix string
+	// Interval is the interval at which the states of the devices are published.
+	Interval time.Duration
+	// RetryDelay is the backoff after a failed connection, it is doubled on every further failure.
+	RetryDelay time.Duration
+}
+
+// knownDevice is a power station whose entities are published, commands are only accepted for known devices.
+type knownDevice struct {
+	account string
+	status  *devicestatus.PowerStation
+}
+
+// Bridge publishes the power stations of the vault accounts to Home Assistant with MQTT discovery. Every power station
+// becomes a device with sensor entities for the battery, the power and the temperatures, and switch entities for its
+// outputs. The states are published every interval to {prefix}/{sn}/state, switching an output in Home Assistant
+// publishes ON or OFF to {prefix}/{sn}/{output}/set, which sends the power station command of the output.
+type Bridge struct {
+	devices DeviceSource
+	execute service.CommandExecutor
+	cfg     Config
+	logger  *slog.Logger
+
+	// rediscover is signalled when Home Assistant restarts and expects the discovery configs again
+	rediscover chan struct{}
+	running    sync.WaitGroup
+
+	mu    sync.Mutex
+	known map[string]knownDevice // keyed by serial number
+	// stopped is set when Run returns, no commands are accepted afterward
+	stopped bool
+
+	// only accessed by the session
+	announced map[string]map[string]bool // announced entity keys, keyed by serial number
+	online    map[string]bool
+}
+
+func NewBridge(devices DeviceSource, execute service.CommandExecutor, cfg Config, logger *slog.Logger) *Bridge {
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = 5 * time.Second
+	}
+	return &Bridge{
+		devices:    devices,
+		execute:    execute,
+		cfg:        cfg,
+		logger:     logger,
+		rediscover: make(chan struct{}, 1),
+		known:      make(map[string]knownDevice),
+	}
+}
+
+// Run publishes the devices until ctx is cancelled. Commands that were received are completed before it returns.
+func (b *Bridge) Run(ctx context.Context) {
+	defer b.stop()
+	delay := b.cfg.RetryDelay
+	for {
+		err := b.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		b.logger.Warn("Home Assistant bridge failed, retrying", "broker", b.cfg.Broker, "error", err, "retry_in", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		delay = min(2*delay, mqttutil.MaxRetryDelay)
+	}
+}
+
+// stop stops accepting commands and waits for the commands that were accepted.
+func (b *Bridge) stop() {
+	b.mu.Lock()
+	b.stopped = true
+	b.mu.Unlock()
+	b.running.Wait()
+}
+
+// session connects to the broker and publishes the devices until ctx is cancelled. Lost connections are
+// re-established by the MQTT client, the subscriptions and the discovery configs are renewed afterward.
+func (b *Bridge) session(ctx context.Context) error {
+	connected := make(chan struct{}, 1)
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.cfg.Broker).
+		SetClientID(b.cfg.ClientID).
+		SetUsername(b.cfg.Username).
+		SetPassword(b.cfg.Password).
+		SetWill(b.statusTopic(), "offline", 1, true).
+		SetAutoReconnect(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(func(mqtt.Client) {
+			select {
+			case connected <- struct{}{}:
+			default:
+			}
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			b.logger.Warn("Home Assistant MQTT connection lost", "broker", b.cfg.Broker, "error", err)
+		})
+	client := mqtt.NewClient(opts)
+	if err := mqttutil.Wait(ctx, client.Connect()); err != nil {
+		return fmt.Errorf("can't connect to the mqtt broker: %w", err)
+	}
+	defer func() {
+		// the will is only sent when the connection is lost
+		client.Publish(b.statusTopic(), 1, true, "offline").WaitTimeout(offlineTimeout)
+		client.Disconnect(mqttutil.DisconnectQuiesce)
+	}()
+	b.logger.Info("Connected to the Home Assistant MQTT broker", "broker", b.cfg.Broker)
+
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-connected:
+			if err := b.subscribe(ctx, client); err != nil {
+				return err
+			}
+		case <-b.rediscover:
+			b.announced = make(map[string]map[string]bool)
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := b.publish(ctx, client); err != nil && ctx.Err() == nil {
+			b.logger.Warn("Failed to publish the devices to Home Assistant", "error", err)
+		}
+	}
+}
+
+// subscribe subscribes to the command topics and the status of Home Assistant, and marks the bridge online. The
+// broker drops the subscriptions of a clean session when the connection is lost, all devices are announced again.
+func (b *Bridge) subscribe(ctx context.Context, client mqtt.Client) error {
+	topics := map[string]byte{
+		b.cfg.TopicPrefix + "/+/+/set":    1,
+		b.cfg.DiscoveryPrefix + "/status": 1,
+	}
+	if err := mqttutil.Wait(ctx, client.SubscribeMultiple(topics, b.handleMessage(ctx))); err != nil {
+		return fmt.Errorf("can't subscribe to the command topics: %w", err)
+	}
+	if err := mqttutil.Wait(ctx, client.Publish(b.statusTopic(), 1, true, "online")); err != nil {
+		return fmt.Errorf("can't publish the status: %w", err)
+	}
+	b.announced = make(map[string]map[string]bool)
+	if b.online == nil {
+		b.online = make(map[string]bool)
+	}
+	// the availability is published again, devices that went stale meanwhile are marked offline
+	for sn := range b.online {
+		b.online[sn] = false
+	}
+	return nil
+}
+
+// publish announces the new entities of the power stations and publishes their states and availability.
+func (b *Bridge) publish(ctx context.Context, client mqtt.Client) error {
+	if b.announced == nil {
+		// not subscribed yet
+		return nil
+	}
+	known := make(map[string]knownDevice)
+	var errs []error
+	for _, d := range b.devices.Devices() {
+		account, ok := service.VaultAccount(d.Account)
+		if !ok {
+			continue
+		}
+		status, err := devicestatus.Decode(d.SN, d.Params)
+		if err != nil || status.PowerStation == nil {
+			continue
+		}
+		known[d.SN] = knownDevice{account: account, status: status.PowerStation}
+
+		values := state(status.PowerStation)
+		if err = b.announce(ctx, client, status, values); err != nil {
+			errs = append(errs, err)
+		}
+		payload, err := json.Marshal(values)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err = mqttutil.Wait(ctx, client.Publish(b.deviceTopic(d.SN, "state"), 1, true, payload)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !b.online[d.SN] {
+			if err = mqttutil.Wait(ctx, client.Publish(b.deviceTopic(d.SN, "availability"), 1, true, "online")); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			b.online[d.SN] = true
+		}
+	}
+
+	// devices without fresh parameters are unavailable
+	for sn := range b.online {
+		if _, ok := known[sn]; ok {
+			continue
+		}
+		if err := mqttutil.Wait(ctx, client.Publish(b.deviceTopic(sn, "availability"), 1, true, "offline")); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(b.online, sn)
+	}
+
+	b.mu.Lock()
+	b.known = known
+	b.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// announce publishes the discovery configs of the entities the device reports and that are not announced yet.
+func (b *Bridge) announce(ctx context.Context, client mqtt.Client, status *devicestatus.Status, values map[string]interface{}) error {
+	announced := b.announced[status.SerialNumber]
+	if announced == nil {
+		announced = make(map[string]bool)
+		b.announced[status.SerialNumber] = announced
+	}
+	for _, s := range sensors {
+		if _, ok := values[s.key]; !ok || announced[s.key] {
+			continue
+		}
+		config := b.entityConfig(status, s.key, s.name)
+		config.DeviceClass = s.deviceClass
+		config.UnitOfMeasurement = s.unit
+		config.StateClass = "measurement"
+		if err := b.publishConfig(ctx, client, "sensor", status.SerialNumber, s.key, config); err != nil {
+			return err
+		}
+		announced[s.key] = true
+	}
+	for _, s := range switches {
+		if _, ok := values[s.key]; !ok || announced[s.key] {
+			continue
+		}
+		config := b.entityConfig(status, s.key, s.name)
+		config.DeviceClass = "outlet"
+		config.CommandTopic = b.deviceTopic(status.SerialNumber, s.key+"/set")
+		config.PayloadOn = payloadOn
+		config.PayloadOff = payloadOff
+		if err := b.publishConfig(ctx, client, "switch", status.SerialNumber, s.key, config); err != nil {
+			return err
+		}
+		announced[s.key] = true
+	}
+	return nil
+}
+
+func (b *Bridge) entityConfig(status *devicestatus.Status, key, name string) entityConfig {
+	id := nodeID(status.SerialNumber)
+	model := status.Model
+	if status.Family == catalog.FamilyPowerStation && model == catalog.Unknown.Name {
+		model = "Power station"
+	}
+	return entityConfig{
+		Name:          name,
+		UniqueID:      id + "_" + key,
+		ObjectID:      id + "_" + key,
+		StateTopic:    b.deviceTopic(status.SerialNumber, "state"),
+		ValueTemplate: "{{ value_json." + key + " }}",
+		Availability: []availability{
+			{Topic: b.statusTopic()},
+			{Topic: b.deviceTopic(status.SerialNumber, "availability")},
+		},
+		AvailabilityMode: "all",
+		Device: device{
+			Identifiers:  []string{id},
+			Name:         "EcoFlow " + model + " " + status.SerialNumber,
+			Manufacturer: "EcoFlow",
+			Model:        model,
+			SerialNumber: status.SerialNumber,
+		},
+	}
+}
+
+func (b *Bridge) publishConfig(ctx context.Context, client mqtt.Client, component, sn, key string, config entityConfig) error {
+	payload, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	topic := b.cfg.DiscoveryPrefix + "/" + component + "/" + nodeID(sn) + "/" + key + "/config"
+	return mqttutil.Wait(ctx, client.Publish(topic, 1, true, payload))
+}
+
+// handleMessage handles the commands of the switches and the status of Home Assistant.
+func (b *Bridge) handleMessage(ctx context.Context) mqtt.MessageHandler {
+	return func(_ mqtt.Client, message mqtt.Message) {
+		payload := strings.ToUpper(strings.TrimSpace(string(message.Payload())))
+		if message.Topic() == b.cfg.DiscoveryPrefix+"/status" {
+			if payload == "ONLINE" {
+				select {
+				case b.rediscover <- struct{}{}:
+				default:
+				}
+			}
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(message.Topic(), b.cfg.TopicPrefix+"/"), "/")
+		if len(parts) != 3 || parts[2] != "set" {
+			return
+		}
+		sn := parts[0]
+		output, ok := findSwitch(parts[1])
+		if !ok || (payload != payloadOn && payload != payloadOff) {
+			b.logger.Debug("Ignoring invalid Home Assistant command", "topic", message.Topic(), "payload", payload)
+			return
+		}
+		b.mu.Lock()
+		d, ok := b.known[sn]
+		b.mu.Unlock()
+		if !ok {
+			b.logger.Warn("Ignoring Home Assistant command for a device without fresh parameters", "serial_number", sn, "command", output.command)
+			return
+		}
+		body, err := output.payload(d.status, payload == payloadOn)
+		if err != nil {
+			b.logger.Warn("Failed to send the Home Assistant command", "serial_number", sn, "command", output.command, "error", err)
+			return
+		}
+
+		// the command is only accepted while the bridge is running, stop waits for it
+		b.mu.Lock()
+		if b.stopped || ctx.Err() != nil {
+			b.mu.Unlock()
+			b.logger.Warn("Ignoring Home Assistant command, the bridge is stopping", "serial_number", sn, "command", output.command)
+			return
+		}
+		b.running.Add(1)
+		b.mu.Unlock()
+		defer b.running.Done()
+		status, response := b.execute(context.WithoutCancel(ctx), d.account, sn, output.command, body)
+		if status != http.StatusOK {
+			b.logger.Warn("Home Assistant command failed", "serial_number", sn, "command", output.command, "status", status, "response", string(response))
+			return
+		}
+		b.logger.Info("Sent Home Assistant command", "serial_number", sn, "command", output.command, "payload", string(body))
+	}
+}
+
+func (b *Bridge) statusTopic() string {
+	return b.cfg.TopicPrefix + "/status"
+}
+
+func (b *Bridge) deviceTopic(sn, suffix string) string {
+	return b.cfg.TopicPrefix + "/" + sn + "/" + suffix
+}
+
+// nodeID identifies the device in Home Assistant.
+func nodeID(sn string) string {
+	return "ecoflow_" + sn
+}
@@ -0,0 +1,216 @@
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"go-ecoflow-api-server/devicestatus"
+	"go-ecoflow-api-server/ingest"
+	"go-ecoflow-api-server/service"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	mqttserver "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// devices is a DeviceSource with the parameters of the devices of the home account.
+type devices struct {
+	mu     sync.Mutex
+	params map[string]map[string]interface{}
+}
+
+func (d *devices) Devices() []ingest.Device {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var result []ingest.Device
+	for sn, params := range d.params {
+		result = append(result, ingest.Device{Account: service.VaultIdentity("home"), SN: sn, Params: params})
+	}
+	return result
+}
+
+func (d *devices) remove(sn string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.params, sn)
+}
+
+// recorder is a service.CommandExecutor that records the commands.
+type recorder struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (r *recorder) execute(_ context.Context, account, sn, command string, payload json.RawMessage) (int, json.RawMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, account+" "+sn+" "+command+" "+string(payload))
+	return http.StatusOK, json.RawMessage(`{"success":true}`)
+}
+
+func (r *recorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.commands...)
+}
+
+// messages records the last message of every topic published to the broker.
+type messages struct {
+	mu     sync.Mutex
+	topics map[string]string
+}
+
+func (m *messages) handle(_ *mqttserver.Client, _ packets.Subscription, pk packets.Packet) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.topics[pk.TopicName] = string(pk.Payload)
+}
+
+func (m *messages) get(topic string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payload, ok := m.topics[topic]
+	return payload, ok
+}
+
+// startBroker starts a local MQTT broker that stands in for the broker of Home Assistant.
+func startBroker(t *testing.T) (*mqttserver.Server, string, *messages) {
+	t.Helper()
+	broker := mqttserver.New(&mqttserver.Options{InlineClient: true, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	require.NoError(t, broker.AddHook(new(auth.Hook), &auth.Options{
+		Ledger: &auth.Ledger{Auth: auth.AuthRules{
+			{Username: auth.RString("bridge"), Password: auth.RString("password"), Allow: true},
+		}},
+	}))
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	require.NoError(t, broker.AddListener(listener))
+	require.NoError(t, broker.Serve())
+	t.Cleanup(func() { _ = broker.Close() })
+
+	received := &messages{topics: make(map[string]string)}
+	require.NoError(t, broker.Subscribe("#", 1, received.handle))
+	return broker, "tcp://" + listener.Address(), received
+}
+
+func TestBridge_Run(t *testing.T) {
+	broker, address, received := startBroker(t)
+	source := &devices{params: map[string]map[string]interface{}{
+		"R601ZEB4ZEAL0528": {
+			"pd.soc": 51.0, "pd.wattsInSum": 120.0, "pd.wattsOutSum": 80.0, "mppt.inWatts": 1205.0, "bms_bmsStatus.temp": 25.0,
+			"inv.cfgAcEnabled": 1.0, "inv.cfgAcXboost": 1.0, "inv.cfgAcOutFreq": 50.0, "inv.cfgAcOutVol": 230000.0,
+			"pd.dcOutState": 0.0,
+		},
+		"HW52ZDH4SF123456": {"2_1.watts": 100.0},
+	}}
+	commands := &recorder{}
+	bridge := NewBridge(source, commands.execute, Config{
+		Broker:          address,
+		Username:        "bridge",
+		Password:        "password",
+		ClientID:        "go-ecoflow-api-server",
+		DiscoveryPrefix: "homeassistant",
+		TopicPrefix:     "ecoflow",
+		Interval:        50 * time.Millisecond,
+		RetryDelay:      10 * time.Millisecond,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bridge.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		_, ok := received.get("ecoflow/R601ZEB4ZEAL0528/state")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status, _ := received.get("ecoflow/status")
+	assert.Equal(t, "online", status)
+	availability, _ := received.get("ecoflow/R601ZEB4ZEAL0528/availability")
+	assert.Equal(t, "online", availability)
+	state, _ := received.get("ecoflow/R601ZEB4ZEAL0528/state")
+	assert.JSONEq(t, `{"battery":51,"input_watts":120,"output_watts":80,"solar_input_watts":120.5,"battery_temperature":25,"ac":"ON","dc":"OFF"}`, state)
+
+	battery, ok := received.get("homeassistant/sensor/ecoflow_R601ZEB4ZEAL0528/battery/config")
+	require.True(t, ok, "the battery sensor is announced")
+	assert.JSONEq(t, `{
+		"name": "Battery",
+		"unique_id": "ecoflow_R601ZEB4ZEAL0528_battery",
+		"object_id": "ecoflow_R601ZEB4ZEAL0528_battery",
+		"state_topic": "ecoflow/R601ZEB4ZEAL0528/state",
+		"value_template": "{{ value_json.battery }}",
+		"device_class": "battery",
+		"unit_of_measurement": "%",
+		"state_class": "measurement",
+		"availability": [{"topic": "ecoflow/status"}, {"topic": "ecoflow/R601ZEB4ZEAL0528/availability"}],
+		"availability_mode": "all",
+		"device": {"identifiers": ["ecoflow_R601ZEB4ZEAL0528"], "name": "EcoFlow RIVER 2 R601ZEB4ZEAL0528", "manufacturer": "EcoFlow", "model": "RIVER 2", "serial_number": "R601ZEB4ZEAL0528"}
+	}`, battery)
+	ac, ok := received.get("homeassistant/switch/ecoflow_R601ZEB4ZEAL0528/ac/config")
+	require.True(t, ok, "the AC switch is announced")
+	assert.Contains(t, ac, `"command_topic":"ecoflow/R601ZEB4ZEAL0528/ac/set"`)
+	_, ok = received.get("homeassistant/switch/ecoflow_R601ZEB4ZEAL0528/car/config")
+	assert.False(t, ok, "entities the device doesn't report are not announced")
+	_, ok = received.get("ecoflow/HW52ZDH4SF123456/state")
+	assert.False(t, ok, "only power stations are published")
+
+	require.NoError(t, broker.Publish("ecoflow/R601ZEB4ZEAL0528/ac/set", []byte("OFF"), false, 1))
+	require.NoError(t, broker.Publish("ecoflow/R601ZEB4ZEAL0528/dc/set", []byte("on"), false, 1))
+	require.NoError(t, broker.Publish("ecoflow/R601ZEB4ZEAL0528/usb/set", []byte("ON"), false, 1))
+	require.NoError(t, broker.Publish("ecoflow/R331ZEB4ZEAL0528/dc/set", []byte("ON"), false, 1))
+	assert.Eventually(t, func() bool { return len(commands.recorded()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{
+		`home R601ZEB4ZEAL0528 out/ac {"ac_state":"off","out_freq":50,"out_voltage":230,"xboost_state":"on"}`,
+		`home R601ZEB4ZEAL0528 out/dc {"state":"on"}`,
+	}, commands.recorded(), "only the outputs of known devices are switched")
+
+	source.remove("R601ZEB4ZEAL0528")
+	assert.Eventually(t, func() bool {
+		availability, _ := received.get("ecoflow/R601ZEB4ZEAL0528/availability")
+		return availability == "offline"
+	}, 5*time.Second, 10*time.Millisecond, "devices without fresh parameters are unavailable")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bridge did not stop")
+	}
+	status, _ = received.get("ecoflow/status")
+	assert.Equal(t, "offline", status)
+}
+
+// message is an MQTT message received from Home Assistant.
+type message struct {
+	mqtt.Message
+	topic   string
+	payload string
+}
+
+func (m message) Topic() string   { return m.topic }
+func (m message) Payload() []byte { return []byte(m.payload) }
+
+func TestBridge_IgnoresCommandsWhenStopped(t *testing.T) {
+	commands := &recorder{}
+	bridge := NewBridge(&devices{}, commands.execute, Config{TopicPrefix: "ecoflow"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	bridge.known["R601ZEB4ZEAL0528"] = knownDevice{account: "home", status: &devicestatus.PowerStation{}}
+	handle := bridge.handleMessage(context.Background())
+
+	handle(nil, message{topic: "ecoflow/R601ZEB4ZEAL0528/dc/set", payload: "ON"})
+	require.Len(t, commands.recorded(), 1)
+
+	bridge.stop()
+	handle(nil, message{topic: "ecoflow/R601ZEB4ZEAL0528/dc/set", payload: "OFF"})
+	assert.Len(t, commands.recorded(), 1, "commands received after the bridge stopped are not sent")
+}
@@ -0,0 +1,141 @@
+package homeassistant
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-ecoflow-api-server/devicestatus"
+	"math"
+)
+
+const (
+	payloadOn  = "ON"
+	payloadOff = "OFF"
+)
+
+// sensor is a read-only value of a power station, published as a Home Assistant sensor entity.
+type sensor struct {
+	key         string
+	name        string
+	deviceClass string
+	unit        string
+	value       func(status *devicestatus.PowerStation) *float64
+}
+
+// sensors are the sensors of a power station. Their values are taken from the typed status of the device, so they
+// are scaled to the units Home Assistant expects.
+var sensors = []sensor{
+	{key: "battery", name: "Battery", deviceClass: "battery", unit: "%", value: func(s *devicestatus.PowerStation) *float64 { return s.Battery.StateOfChargePercent }},
+	{key: "input_watts", name: "Input power", deviceClass: "power", unit: "W", value: func(s *devicestatus.PowerStation) *float64 { return s.Input.TotalWatts }},
+	{key: "output_watts", name: "Output power", deviceClass: "power", unit: "W", value: func(s *devicestatus.PowerStation) *float64 { return s.Output.TotalWatts }},
+	{key: "ac_input_watts", name: "AC input power", deviceClass: "power", unit: "W", value: func(s *devicestatus.PowerStation) *float64 { return s.Input.ACWatts }},
+	{key: "ac_output_watts", name: "AC output power", deviceClass: "power", unit: "W", value: func(s *devicestatus.PowerStation) *float64 { return s.Output.ACWatts }},
+	{key: "solar_input_watts", name: "Solar input power", deviceClass: "power", unit: "W", value: func(s *devicestatus.PowerStation) *float64 { return s.Input.SolarWatts }},
+	{key: "car_output_watts", name: "Car output power", deviceClass: "power", unit: "W", value: func(s *devicestatus.PowerStation) *float64 { return s.Output.CarWatts }},
+	{key: "battery_temperature", name: "Battery temperature", deviceClass: "temperature", unit: "°C", value: func(s *devicestatus.PowerStation) *float64 { return s.Temperatures.BatteryCelsius }},
+	{key: "inverter_temperature", name: "Inverter temperature", deviceClass: "temperature", unit: "°C", value: func(s *devicestatus.PowerStation) *float64 { return s.Temperatures.InverterCelsius }},
+	{key: "mppt_temperature", name: "Solar charger temperature", deviceClass: "temperature", unit: "°C", value: func(s *devicestatus.PowerStation) *float64 { return s.Temperatures.MPPTCelsius }},
+}
+
+// outputSwitch is an output of a power station, published as a Home Assistant switch entity. Switching it sends the
+// power station command of the output.
+type outputSwitch struct {
+	key     string
+	name    string
+	command string
+	state   func(status *devicestatus.PowerStation) *bool
+	// payload returns the request body of the command, see the power station handlers
+	payload func(status *devicestatus.PowerStation, on bool) (json.RawMessage, error)
+}
+
+var switches = []outputSwitch{
+	{key: "ac", name: "AC output", command: "out/ac", state: func(s *devicestatus.PowerStation) *bool { return s.Switches.ACEnabled }, payload: acPayload},
+	{key: "dc", name: "DC output", command: "out/dc", state: func(s *devicestatus.PowerStation) *bool { return s.Switches.DCEnabled }, payload: statePayload},
+	{key: "car", name: "Car output", command: "out/car", state: func(s *devicestatus.PowerStation) *bool { return s.Switches.CarEnabled }, payload: statePayload},
+}
+
+func findSwitch(key string) (outputSwitch, bool) {
+	for _, s := range switches {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return outputSwitch{}, false
+}
+
+// statePayload returns the body of ChangeStateRequest.
+func statePayload(_ *devicestatus.PowerStation, on bool) (json.RawMessage, error) {
+	return json.Marshal(map[string]string{"state": onOff(on)})
+}
+
+// acPayload returns the body of EnableAcRequest. The AC command always sets the X-Boost, the frequency and the
+// voltage of the output as well, they keep the current settings of the device.
+func acPayload(status *devicestatus.PowerStation, on bool) (json.RawMessage, error) {
+	xboost, frequency, voltage := status.Switches.XBoostEnabled, status.Output.ACFrequencyHertz, status.Output.ACVoltageVolts
+	if xboost == nil || frequency == nil || voltage == nil {
+		return nil, fmt.Errorf("the device doesn't report its AC output settings")
+	}
+	return json.Marshal(map[string]interface{}{
+		"ac_state":     onOff(on),
+		"xboost_state": onOff(*xboost),
+		"out_freq":     int(math.Round(*frequency)),
+		"out_voltage":  int(math.Round(*voltage)),
+	})
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// state returns the values of the entities of the power station that it reports, keyed by the entity key.
+func state(status *devicestatus.PowerStation) map[string]interface{} {
+	values := make(map[string]interface{}, len(sensors)+len(switches))
+	for _, s := range sensors {
+		if value := s.value(status); value != nil {
+			values[s.key] = *value
+		}
+	}
+	for _, s := range switches {
+		if on := s.state(status); on != nil {
+			values[s.key] = payloadOff
+			if *on {
+				values[s.key] = payloadOn
+			}
+		}
+	}
+	return values
+}
+
+// availability is the availability of a Home Assistant entity, see the MQTT integration.
+type availability struct {
+	Topic string `json:"topic"`
+}
+
+// device groups the entities of a power station in Home Assistant.
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SerialNumber string   `json:"serial_number"`
+}
+
+// entityConfig is the discovery config of a sensor or switch entity.
+type entityConfig struct {
+	Name              string         `json:"name"`
+	UniqueID          string         `json:"unique_id"`
+	ObjectID          string         `json:"object_id"`
+	StateTopic        string         `json:"state_topic"`
+	ValueTemplate     string         `json:"value_template"`
+	DeviceClass       string         `json:"device_class,omitempty"`
+	UnitOfMeasurement string         `json:"unit_of_measurement,omitempty"`
+	StateClass        string         `json:"state_class,omitempty"`
+	CommandTopic      string         `json:"command_topic,omitempty"`
+	PayloadOn         string         `json:"payload_on,omitempty"`
+	PayloadOff        string         `json:"payload_off,omitempty"`
+	Availability      []availability `json:"availability"`
+	AvailabilityMode  string         `json:"availability_mode"`
+	Device            device         `json:"device"`
+}
@@ -0,0 +1,38 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/vault"
+	"net/http"
+)
+
+const certificationPath = "/iot-open/sign/certification"
+
+// ErrNotTracked is returned when a device that is not ingested is watched.
+var ErrNotTracked = errors.New("device is not ingested")
+
+// FetchCertification obtains the MQTT connection settings of the open platform account with the given credentials.
+// The errors have the same format as the errors of the go-ecoflow client, so they can be classified the same way.
+func FetchCertification(ctx context.Context, httpClient *http.Client, apiURL string, credentials vault.Credentials) (*ecoflow.MqttConnectionConfig, error) {
+	request := ecoflow.NewHttpRequest(httpClient, http.MethodGet, apiURL+certificationPath, nil, credentials.AccessKey, credentials.SecretKey)
+	response, err := request.Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var certification ecoflow.MqttCredentialsResponse
+	if err = json.Unmarshal(response, &certification); err != nil {
+		return nil, err
+	}
+	if certification.Code != "0" {
+		return nil, fmt.Errorf("can't get mqtt certification, error code: %s, error message: %s", certification.Code, certification.Message)
+	}
+	if certification.Data.CertificateAccount == "" || certification.Data.Url == "" {
+		return nil, errors.New("response is not valid, the mqtt certification is incomplete")
+	}
+	return &certification.Data, nil
+}
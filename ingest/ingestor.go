@@ -0,0 +1,244 @@
+package ingest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/mqttutil"
+	"go-ecoflow-api-server/service"
+	"go-ecoflow-api-server/vault"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// typeCodePrefixes maps the module of a pushed quota message to the prefix of its parameters in the REST API, e.g. the
+// "soc" parameter of a "bmsStatus" message is "bms_bmsStatus.soc".
+var typeCodePrefixes = map[string]string{
+	"pdStatus":   "pd.",
+	"bmsStatus":  "bms_bmsStatus.",
+	"bmsInfo":    "bms_bmsInfo.",
+	"emsStatus":  "bms_emsStatus.",
+	"invStatus":  "inv.",
+	"mpptStatus": "mppt.",
+}
+
+// Account is a vault account whose devices are ingested.
+type Account struct {
+	Name        string
+	Credentials vault.Credentials
+}
+
+type Config struct {
+	// APIURL is the Ecoflow API used to obtain the MQTT certification and to seed the device parameters.
+	APIURL string
+	// RefreshInterval is the interval at which the device lists are refreshed, so new devices are ingested. The Poller
+	// reads the parameters of the devices at this interval.
+	RefreshInterval time.Duration
+	// RetryDelay is the backoff after a failed connection, it is doubled on every further failure.
+	RetryDelay time.Duration
+	HTTPClient *http.Client
+}
+
+// Ingestor receives the parameters of the devices of the accounts from the Ecoflow MQTT broker and merges them into
+// the store. Every account gets its own certification and connection, devices are subscribed at
+// /open/{certificateAccount}/{sn}/quota.
+type Ingestor struct {
+	store    *Store
+	accounts []Account
+	cfg      Config
+	logger   *slog.Logger
+}
+
+func NewIngestor(store *Store, accounts []Account, cfg Config, logger *slog.Logger) *Ingestor {
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = 5 * time.Second
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = defaultHTTPClient()
+	}
+	return &Ingestor{store: store, accounts: accounts, cfg: cfg, logger: logger}
+}
+
+// Run ingests the devices of all accounts until ctx is cancelled.
+func (i *Ingestor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, account := range i.accounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i.runAccount(ctx, account)
+		}()
+	}
+	wg.Wait()
+}
+
+func (i *Ingestor) runAccount(ctx context.Context, account Account) {
+	delay := i.cfg.RetryDelay
+	for {
+		err := i.session(ctx, account)
+		if ctx.Err() != nil {
+			return
+		}
+		i.logger.Warn("MQTT ingestion failed, retrying", "account", account.Name, "error", err, "retry_in", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		delay = min(2*delay, mqttutil.MaxRetryDelay)
+	}
+}
+
+// session connects to the broker with a new certification and keeps the subscriptions up to date until ctx is
+// cancelled. Lost connections are re-established by the MQTT client.
+func (i *Ingestor) session(ctx context.Context, account Account) error {
+	certification, err := FetchCertification(ctx, i.cfg.HTTPClient, i.cfg.APIURL, account.Credentials)
+	if err != nil {
+		return err
+	}
+	identity := service.VaultIdentity(account.Name)
+	client := ecoflow.NewEcoflowClient(account.Credentials.AccessKey, account.Credentials.SecretKey,
+		ecoflow.WithBaseUrl(i.cfg.APIURL), ecoflow.WithHttpClient(i.cfg.HTTPClient))
+
+	connected := make(chan struct{}, 1)
+	opts := mqtt.NewClientOptions().
+		AddBroker(fmt.Sprintf("%s://%s:%s", certification.Protocol, certification.Url, certification.Port)).
+		SetClientID(clientID(certification.CertificateAccount)).
+		SetUsername(certification.CertificateAccount).
+		SetPassword(certification.CertificatePassword).
+		SetAutoReconnect(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(func(mqtt.Client) {
+			select {
+			case connected <- struct{}{}:
+			default:
+			}
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			i.logger.Warn("MQTT connection lost", "account", account.Name, "error", err)
+		})
+	mqttClient := mqtt.NewClient(opts)
+	if err = mqttutil.Wait(ctx, mqttClient.Connect()); err != nil {
+		return fmt.Errorf("can't connect to the mqtt broker: %w", err)
+	}
+	defer mqttClient.Disconnect(mqttutil.DisconnectQuiesce)
+	i.logger.Info("Connected to the MQTT broker", "account", account.Name, "broker", certification.Url)
+
+	handler := i.handleMessage(identity, certification.CertificateAccount)
+	subscribed := make(map[string]bool)
+	refresh := time.NewTicker(i.cfg.RefreshInterval)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-connected:
+			// the broker drops the subscriptions of a clean session when the connection is lost
+			subscribed = make(map[string]bool)
+		case <-refresh.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err = i.sync(ctx, client, mqttClient, identity, certification.CertificateAccount, subscribed, handler); err != nil && ctx.Err() == nil {
+			i.logger.Warn("Failed to synchronize the ingested devices", "account", account.Name, "error", err)
+		}
+	}
+}
+
+// sync subscribes to the devices of the account that are not subscribed yet and seeds the devices without fresh
+// parameters from the REST API.
+func (i *Ingestor) sync(ctx context.Context, client *ecoflow.Client, mqttClient mqtt.Client, identity, certificateAccount string, subscribed map[string]bool, handler mqtt.MessageHandler) error {
+	devices, err := client.GetDeviceList(ctx)
+	if err != nil {
+		return err
+	}
+
+	topics := make(map[string]byte)
+	for _, device := range devices.Devices {
+		if !subscribed[device.SN] {
+			i.store.Track(identity, device.SN)
+			topics[quotaTopic(certificateAccount, device.SN)] = 1
+		}
+	}
+	if len(topics) > 0 {
+		if err = mqttutil.Wait(ctx, mqttClient.SubscribeMultiple(topics, handler)); err != nil {
+			return fmt.Errorf("can't subscribe to the devices: %w", err)
+		}
+		for _, device := range devices.Devices {
+			subscribed[device.SN] = true
+		}
+	}
+
+	// seeded after subscribing, so no pushed update is missed
+	return seed(ctx, i.store, client, identity, devices.Devices, i.store.Stale)
+}
+
+func (i *Ingestor) handleMessage(identity, certificateAccount string) mqtt.MessageHandler {
+	prefix := "/open/" + certificateAccount + "/"
+	return func(_ mqtt.Client, message mqtt.Message) {
+		sn := strings.TrimSuffix(strings.TrimPrefix(message.Topic(), prefix), "/quota")
+		params, err := DecodeQuota(message.Payload())
+		if err != nil {
+			i.logger.Debug("Ignoring invalid quota message", "topic", message.Topic(), "error", err)
+			return
+		}
+		i.store.Merge(identity, sn, params)
+	}
+}
+
+// DecodeQuota returns the parameters of a pushed quota message, named like the parameters of the REST API.
+func DecodeQuota(payload []byte) (map[string]interface{}, error) {
+	var message struct {
+		TypeCode string                 `json:"typeCode"`
+		Params   map[string]interface{} `json:"params"`
+		// some devices, e.g. smart plugs, send the parameters in "param"
+		Param map[string]interface{} `json:"param"`
+	}
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return nil, err
+	}
+	params := message.Params
+	if params == nil {
+		params = message.Param
+	}
+	if len(params) == 0 {
+		return nil, errors.New("quota message has no parameters")
+	}
+
+	prefix, ok := typeCodePrefixes[message.TypeCode]
+	if !ok {
+		return params, nil
+	}
+	result := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if !strings.Contains(k, ".") {
+			k = prefix + k
+		}
+		result[k] = v
+	}
+	return result, nil
+}
+
+func defaultHTTPClient() *http.Client {
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+func quotaTopic(certificateAccount, sn string) string {
+	return "/open/" + certificateAccount + "/" + sn + "/quota"
+}
+
+// clientID returns a unique client ID, the broker disconnects clients whose ID is reused.
+func clientID(certificateAccount string) string {
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	return certificateAccount + "-" + hex.EncodeToString(suffix)
+}
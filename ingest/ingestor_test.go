@@ -0,0 +1,169 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"go-ecoflow-api-server/vault"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mqttserver "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startBroker starts a local MQTT broker that stands in for the Ecoflow broker. Only the given certificate account
+// may connect.
+func startBroker(t *testing.T, username, password string) (*mqttserver.Server, string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	broker := mqttserver.New(&mqttserver.Options{InlineClient: true, Logger: logger})
+	require.NoError(t, broker.AddHook(new(auth.Hook), &auth.Options{
+		Ledger: &auth.Ledger{Auth: auth.AuthRules{
+			{Username: auth.RString(username), Password: auth.RString(password), Allow: true},
+		}},
+	}))
+
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	require.NoError(t, broker.AddListener(listener))
+	require.NoError(t, broker.Serve())
+	t.Cleanup(func() { _ = broker.Close() })
+	return broker, listener.Address()
+}
+
+func TestIngestor_Run(t *testing.T) {
+	broker, address := startBroker(t, "open-home", "certificate-password")
+	host, port, err := net.SplitHostPort(address)
+	require.NoError(t, err)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "access", r.Header.Get("accessKey"))
+		switch r.URL.Path {
+		case certificationPath:
+			_, _ = fmt.Fprintf(w, `{"code":"0","message":"Success","data":{"certificateAccount":"open-home","certificatePassword":"certificate-password","url":"%s","port":"%s","protocol":"tcp"}}`, host, port)
+		case "/iot-open/sign/device/list":
+			_, _ = w.Write([]byte(`{"code":"0","message":"Success","data":[{"sn":"SN1","online":1},{"sn":"SN2","online":0}]}`))
+		case "/iot-open/sign/device/quota/all":
+			_, _ = w.Write([]byte(`{"code":"0","message":"Success","data":{"pd.soc":50,"pd.watts":10}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	store := NewStore(time.Minute)
+	ingestor := NewIngestor(store, []Account{{Name: "home", Credentials: vault.Credentials{AccessKey: "access", SecretKey: "secret"}}}, Config{
+		APIURL:          api.URL,
+		RefreshInterval: time.Hour,
+		RetryDelay:      10 * time.Millisecond,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ingestor.Run(ctx)
+	}()
+
+	// online devices are seeded from the REST API
+	assert.Eventually(t, func() bool {
+		_, _, ok := store.Fresh("vault:home", "SN1")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, store.Stale("vault:home", "SN2"), "offline devices are tracked but not seeded")
+
+	// pushed parameters are merged once the subscription is established
+	assert.Eventually(t, func() bool {
+		_ = broker.Publish("/open/open-home/SN1/quota", []byte(`{"typeCode":"pdStatus","params":{"soc":51}}`), false, 0)
+		_ = broker.Publish("/open/open-home/SN2/quota", []byte(`{"params":{"pd.soc":20}}`), false, 0)
+		params, _, _ := store.Fresh("vault:home", "SN1")
+		_, _, pushed := store.Fresh("vault:home", "SN2")
+		return params["pd.soc"] == 51.0 && pushed
+	}, 5*time.Second, 50*time.Millisecond)
+
+	params, _, _ := store.Fresh("vault:home", "SN1")
+	assert.Equal(t, map[string]interface{}{"pd.soc": 51.0, "pd.watts": 10.0}, params)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ingestor did not stop")
+	}
+}
+
+func TestIngestor_RetriesFailedCertification(t *testing.T) {
+	calls := make(chan struct{}, 10)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case calls <- struct{}{}:
+		default:
+		}
+		_, _ = w.Write([]byte(`{"code":"8521","message":"signature is wrong"}`))
+	}))
+	defer api.Close()
+
+	ingestor := NewIngestor(NewStore(time.Minute), []Account{{Name: "home"}}, Config{
+		APIURL:          api.URL,
+		RefreshInterval: time.Hour,
+		RetryDelay:      time.Millisecond,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ingestor.Run(ctx)
+	}()
+	for i := 0; i < 3; i++ {
+		<-calls
+	}
+	cancel()
+	<-done
+}
+
+func TestDecodeQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "module parameters are prefixed",
+			payload:  `{"typeCode":"bmsStatus","params":{"soc":80,"bms_bmsStatus.temp":25}}`,
+			expected: map[string]interface{}{"bms_bmsStatus.soc": 80.0, "bms_bmsStatus.temp": 25.0},
+		},
+		{
+			name:     "unknown modules are kept as is",
+			payload:  `{"typeCode":"somethingElse","params":{"20_1.watts":5}}`,
+			expected: map[string]interface{}{"20_1.watts": 5.0},
+		},
+		{
+			name:     "param field",
+			payload:  `{"cmdId":1,"param":{"2_1.switchSta":1}}`,
+			expected: map[string]interface{}{"2_1.switchSta": 1.0},
+		},
+		{name: "no parameters", payload: `{"typeCode":"pdStatus"}`, wantErr: true},
+		{name: "invalid json", payload: `{`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := DecodeQuota([]byte(tt.payload))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, params)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/service"
+	"log/slog"
+	"time"
+)
+
+// Poller reads the parameters of the devices of the accounts from the REST API at a fixed interval and replaces them
+// in the store. It keeps the store up to date when the devices are not ingested from the MQTT broker.
+type Poller struct {
+	store    *Store
+	accounts []Account
+	cfg      Config
+	logger   *slog.Logger
+}
+
+// NewPoller returns a poller that reads the devices every cfg.RefreshInterval, the store's max age should be longer.
+func NewPoller(store *Store, accounts []Account, cfg Config, logger *slog.Logger) *Poller {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = defaultHTTPClient()
+	}
+	return &Poller{store: store, accounts: accounts, cfg: cfg, logger: logger}
+}
+
+// Run polls the devices of all accounts until ctx is cancelled.
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		for _, account := range p.accounts {
+			if err := p.poll(ctx, account); err != nil && ctx.Err() == nil {
+				p.logger.Warn("Failed to poll the devices", "account", account.Name, "error", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Poller) poll(ctx context.Context, account Account) error {
+	identity := service.VaultIdentity(account.Name)
+	client := ecoflow.NewEcoflowClient(account.Credentials.AccessKey, account.Credentials.SecretKey,
+		ecoflow.WithBaseUrl(p.cfg.APIURL), ecoflow.WithHttpClient(p.cfg.HTTPClient))
+
+	devices, err := client.GetDeviceList(ctx)
+	if err != nil {
+		return err
+	}
+	for _, device := range devices.Devices {
+		p.store.Track(identity, device.SN)
+	}
+	return seed(ctx, p.store, client, identity, devices.Devices, func(string, string) bool { return true })
+}
+
+// seed replaces the parameters of the online devices selected by refresh with all parameters from the REST API.
+func seed(ctx context.Context, store *Store, client *ecoflow.Client, identity string, devices []ecoflow.DeviceInfo, refresh func(account, sn string) bool) error {
+	var errs []error
+	for _, device := range devices {
+		if device.Online != 1 || !refresh(identity, device.SN) {
+			continue
+		}
+		params, err := client.GetDeviceAllParameters(ctx, device.SN)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't read %s: %w", device.SN, err))
+			continue
+		}
+		store.Replace(identity, device.SN, params)
+	}
+	return errors.Join(errs...)
+}
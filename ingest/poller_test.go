@@ -0,0 +1,56 @@
+package ingest
+
+import (
+	"context"
+	"go-ecoflow-api-server/vault"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoller_Run(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/iot-open/sign/device/list":
+			_, _ = w.Write([]byte(`{"code":"0","message":"Success","data":[{"sn":"SN1","online":1},{"sn":"SN2","online":0}]}`))
+		case "/iot-open/sign/device/quota/all":
+			assert.Equal(t, "SN1", r.URL.Query().Get("sn"))
+			_, _ = w.Write([]byte(`{"code":"0","message":"Success","data":{"pd.soc":50}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	store := NewStore(time.Minute)
+	poller := NewPoller(store, []Account{{Name: "home", Credentials: vault.Credentials{AccessKey: "access", SecretKey: "secret"}}}, Config{
+		APIURL:          api.URL,
+		RefreshInterval: time.Hour,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		poller.Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return len(store.Devices()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	devices := store.Devices()
+	require.Len(t, devices, 1)
+	assert.Equal(t, "vault:home", devices[0].Account)
+	assert.Equal(t, "SN1", devices[0].SN)
+	assert.Equal(t, map[string]interface{}{"pd.soc": 50.0}, devices[0].Params)
+	assert.True(t, store.Stale("vault:home", "SN2"), "offline devices are tracked but not read")
+
+	cancel()
+	<-done
+}
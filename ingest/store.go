@@ -0,0 +1,274 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"go-ecoflow-api-server/telemetry"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store keeps the parameters of the ingested devices. The devices push partial updates, so the state of a device is
+// seeded with all parameters from the REST API and the pushed parameters are merged into it. All methods are safe for
+// concurrent use.
+type Store struct {
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	devices map[deviceKey]*deviceState
+}
+
+// deviceKey identifies a device of an account, the same device may be linked to several accounts.
+type deviceKey struct {
+	account string
+	sn      string
+}
+
+type deviceState struct {
+	params   map[string]interface{}
+	updated  time.Time
+	watchers map[*watcher]struct{}
+}
+
+// NewStore returns a store whose parameters are fresh for maxAge after the last update of the device.
+func NewStore(maxAge time.Duration) *Store {
+	return &Store{
+		maxAge:  maxAge,
+		now:     time.Now,
+		devices: make(map[deviceKey]*deviceState),
+	}
+}
+
+// Track registers an ingested device. Only tracked devices are updated.
+func (s *Store) Track(account, sn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := deviceKey{account: account, sn: sn}
+	if _, ok := s.devices[key]; !ok {
+		s.devices[key] = &deviceState{watchers: make(map[*watcher]struct{})}
+	}
+}
+
+// Replace sets all parameters of a tracked device.
+func (s *Store) Replace(account, sn string, params map[string]interface{}) {
+	s.update(account, sn, params, true)
+}
+
+// Merge merges pushed parameters into the state of a tracked device.
+func (s *Store) Merge(account, sn string, params map[string]interface{}) {
+	s.update(account, sn, params, false)
+}
+
+func (s *Store) update(account, sn string, params map[string]interface{}, complete bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.devices[deviceKey{account: account, sn: sn}]
+	if !ok {
+		return
+	}
+	if complete || state.params == nil {
+		state.params = make(map[string]interface{}, len(params))
+	}
+	for k, v := range params {
+		state.params[k] = v
+	}
+	state.updated = s.now()
+
+	for w := range state.watchers {
+		if complete {
+			w.replace(state.params)
+		} else {
+			w.merge(params)
+		}
+	}
+}
+
+// Fresh returns the parameters of the device and the time of its last update if the device is tracked and was updated
+// within the max age.
+func (s *Store) Fresh(account, sn string) (map[string]interface{}, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.devices[deviceKey{account: account, sn: sn}]
+	if !ok || !s.fresh(state) {
+		return nil, time.Time{}, false
+	}
+	return copyParams(state.params), state.updated, true
+}
+
+// Stale reports whether the device is tracked but has no fresh parameters.
+func (s *Store) Stale(account, sn string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.devices[deviceKey{account: account, sn: sn}]
+	return ok && !s.fresh(state)
+}
+
+// Device is a snapshot of the parameters of an ingested device.
+type Device struct {
+	Account string
+	SN      string
+	Params  map[string]interface{}
+	Updated time.Time
+}
+
+// Devices returns the devices with fresh parameters, sorted by account and serial number.
+func (s *Store) Devices() []Device {
+	s.mu.Lock()
+	devices := make([]Device, 0, len(s.devices))
+	for key, state := range s.devices {
+		if s.fresh(state) {
+			devices = append(devices, Device{Account: key.account, SN: key.sn, Params: copyParams(state.params), Updated: state.updated})
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Account != devices[j].Account {
+			return devices[i].Account < devices[j].Account
+		}
+		return devices[i].SN < devices[j].SN
+	})
+	return devices
+}
+
+func (s *Store) fresh(state *deviceState) bool {
+	return state.params != nil && s.now().Sub(state.updated) <= s.maxAge
+}
+
+// Source returns a telemetry source that watches the pushed parameters of the device. It returns nil if the device has
+// no fresh parameters, the device must then be polled.
+func (s *Store) Source(account, sn string) telemetry.Source {
+	if _, _, ok := s.Fresh(account, sn); !ok {
+		return nil
+	}
+	return &storeSource{store: s, account: account}
+}
+
+type storeSource struct {
+	store   *Store
+	account string
+}
+
+// Watch sends the current parameters of the device as a complete update, followed by the pushed parameters. Updates
+// that arrive while the receiver is busy are coalesced.
+func (s *storeSource) Watch(ctx context.Context, sn string) (<-chan telemetry.Update, error) {
+	w := &watcher{notify: make(chan struct{}, 1)}
+	key := deviceKey{account: s.account, sn: sn}
+
+	s.store.mu.Lock()
+	state, ok := s.store.devices[key]
+	if !ok {
+		s.store.mu.Unlock()
+		return nil, ErrNotTracked
+	}
+	if state.params != nil {
+		w.replace(state.params)
+	}
+	state.watchers[w] = struct{}{}
+	s.store.mu.Unlock()
+
+	updates := make(chan telemetry.Update)
+	go func() {
+		defer close(updates)
+		defer func() {
+			s.store.mu.Lock()
+			delete(state.watchers, w)
+			s.store.mu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-w.notify:
+				update := w.take()
+				select {
+				case updates <- update:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates, nil
+}
+
+// watcher collects the updates of a device until they are taken.
+type watcher struct {
+	mu       sync.Mutex
+	pending  map[string]interface{}
+	complete bool
+	notify   chan struct{}
+}
+
+func (w *watcher) replace(params map[string]interface{}) {
+	w.mu.Lock()
+	w.pending = copyParams(params)
+	w.complete = true
+	w.mu.Unlock()
+	w.signal()
+}
+
+func (w *watcher) merge(params map[string]interface{}) {
+	w.mu.Lock()
+	if w.pending == nil {
+		w.pending = make(map[string]interface{}, len(params))
+	}
+	for k, v := range params {
+		w.pending[k] = v
+	}
+	w.mu.Unlock()
+	w.signal()
+}
+
+func (w *watcher) signal() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) take() telemetry.Update {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	update := telemetry.Update{Params: w.pending, Complete: w.complete}
+	w.pending = nil
+	w.complete = false
+	return update
+}
+
+// Number returns the value of a numeric device parameter. Parameters decoded from JSON are float64, but numbers may also
+// be sent as strings.
+func Number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func copyParams(params map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+package ingest
+
+import (
+	"context"
+	"go-ecoflow-api-server/telemetry"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_MergeAndFreshness(t *testing.T) {
+	now := time.Date(2025, 1, 12, 10, 0, 0, 0, time.UTC)
+	store := NewStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	store.Merge("vault:home", "SN1", map[string]interface{}{"pd.soc": 50.0})
+	_, _, ok := store.Fresh("vault:home", "SN1")
+	assert.False(t, ok, "untracked devices are ignored")
+
+	store.Track("vault:home", "SN1")
+	assert.True(t, store.Stale("vault:home", "SN1"))
+	assert.False(t, store.Stale("vault:home", "SN2"), "untracked devices are not stale")
+
+	store.Replace("vault:home", "SN1", map[string]interface{}{"pd.soc": 50.0, "pd.watts": 10.0})
+	store.Merge("vault:home", "SN1", map[string]interface{}{"pd.soc": 51.0})
+	params, updated, ok := store.Fresh("vault:home", "SN1")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"pd.soc": 51.0, "pd.watts": 10.0}, params)
+	assert.Equal(t, now, updated)
+
+	_, _, ok = store.Fresh("vault:other", "SN1")
+	assert.False(t, ok, "devices are separated by account")
+
+	now = now.Add(2 * time.Minute)
+	_, _, ok = store.Fresh("vault:home", "SN1")
+	assert.False(t, ok)
+	assert.True(t, store.Stale("vault:home", "SN1"))
+	assert.Nil(t, store.Source("vault:home", "SN1"), "stale devices are polled")
+}
+
+func TestStore_Source(t *testing.T) {
+	store := NewStore(time.Minute)
+	store.Track("vault:home", "SN1")
+	assert.Nil(t, store.Source("vault:home", "SN1"), "devices without parameters are polled")
+	store.Replace("vault:home", "SN1", map[string]interface{}{"pd.soc": 50.0, "pd.watts": 10.0})
+
+	source := store.Source("vault:home", "SN1")
+	require.NotNil(t, source)
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := source.Watch(ctx, "SN1")
+	require.NoError(t, err)
+
+	first := <-updates
+	assert.True(t, first.Complete)
+	assert.Equal(t, map[string]interface{}{"pd.soc": 50.0, "pd.watts": 10.0}, first.Params)
+
+	// updates pushed while the receiver is busy are coalesced
+	store.Merge("vault:home", "SN1", map[string]interface{}{"pd.soc": 51.0})
+	store.Merge("vault:home", "SN1", map[string]interface{}{"pd.watts": 11.0})
+	var merged telemetry.Update
+	for len(merged.Params) < 2 {
+		update := <-updates
+		assert.False(t, update.Complete)
+		if merged.Params == nil {
+			merged.Params = map[string]interface{}{}
+		}
+		for k, v := range update.Params {
+			merged.Params[k] = v
+		}
+	}
+	assert.Equal(t, map[string]interface{}{"pd.soc": 51.0, "pd.watts": 11.0}, merged.Params)
+
+	cancel()
+	for range updates {
+	}
+	store.mu.Lock()
+	assert.Empty(t, store.devices[deviceKey{account: "vault:home", sn: "SN1"}].watchers)
+	store.mu.Unlock()
+
+	_, err = (&storeSource{store: store, account: "vault:home"}).Watch(context.Background(), "SN2")
+	assert.ErrorIs(t, err, ErrNotTracked)
+}
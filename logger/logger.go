@@ -15,6 +15,7 @@ func GetLogger(logLevel slog.Level) *httplog.Logger {
 		HideRequestHeaders: []string{
 			constants.HeaderAuthorization,
 			constants.HeaderXSecretToken,
+			constants.HeaderXAPIKey,
 		},
 		MessageFieldName: "message",
 	})
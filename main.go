@@ -1,18 +1,42 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/httplog/v2"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/audit"
+	"go-ecoflow-api-server/config"
 	"go-ecoflow-api-server/constants"
 	_ "go-ecoflow-api-server/docs" // Import generated docs package
 	"go-ecoflow-api-server/handlers"
+	"go-ecoflow-api-server/history"
+	"go-ecoflow-api-server/homeassistant"
+	"go-ecoflow-api-server/ingest"
 	"go-ecoflow-api-server/logger"
+	"go-ecoflow-api-server/metrics"
 	"go-ecoflow-api-server/middleware"
+	"go-ecoflow-api-server/resilience"
+	"go-ecoflow-api-server/rules"
+	"go-ecoflow-api-server/schedule"
+	"go-ecoflow-api-server/server"
 	"go-ecoflow-api-server/service"
+	"go-ecoflow-api-server/telemetry"
+	"go-ecoflow-api-server/vault"
+	"go-ecoflow-api-server/webhook"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 // @title Ecoflow API Server
@@ -30,42 +54,493 @@ import (
 // @name X-Secret-Token
 // @in header
 // @description Ecoflow Secret Token
+//
+// @securityDefinitions.apikey X-API-Key
+// @type apiKey
+// @name X-API-Key
+// @in header
+// @description Server-issued API key, replaces Authorization and X-Secret-Token when the credential vault is enabled
 
 // @security Authorization
 // @security X-Secret-Token
 func main() {
-	log := logger.GetLogger(slog.LevelDebug)
+	if len(os.Args) > 1 && os.Args[1] == "vault" {
+		if err := runVaultCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		config.Usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	log := logger.GetLogger(cfg.Log.SlogLevel())
+
+	// cancelled on SIGTERM/SIGINT, starts the graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	srv := server.New(cfg.Server, log)
+
+	v, err := openVault(cfg.Vault)
+	if err != nil {
+		log.Error("Failed to open the credential vault", "error", err)
+		os.Exit(1)
+	}
+	provider, identity, clientCache := newClientProvider(cfg, srv, v)
 
 	router := chi.NewRouter()
-	baseHandler := handlers.NewBaseHandler(log, service.GetEcoflowClient)
+	baseHandler := handlers.NewBaseHandler(log, provider)
+	baseHandler.UpstreamTimeout = cfg.Upstream.Timeout
+	baseHandler.Identity = identity
+	baseHandler.Guard = resilience.NewGuard(resilience.Config{
+		MaxAttempts:      cfg.Upstream.RetryAttempts,
+		BaseDelay:        cfg.Upstream.RetryBaseDelay,
+		MaxDelay:         cfg.Upstream.RetryMaxDelay,
+		FailureThreshold: cfg.Upstream.BreakerThreshold,
+		OpenDuration:     cfg.Upstream.BreakerOpenDuration,
+	})
+	var pushed *ingest.Store
+	if cfg.MQTT.Enabled {
+		pushed, err = startIngestion(cfg.MQTT, v, srv, log.Logger)
+		if err != nil {
+			log.Error("Failed to start the MQTT ingestion", "error", err)
+			os.Exit(1)
+		}
+		baseHandler.Pushed = pushed
+	}
+	var devices *ingest.Store
+	if cfg.Metrics.Enabled || cfg.History.Enabled || cfg.Rules.Enabled || cfg.Webhooks.Enabled || cfg.HomeAssistant.Enabled {
+		devices, err = deviceStore(cfg, v, pushed, srv, log.Logger)
+		if err != nil {
+			log.Error("Failed to start polling the devices", "error", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.Metrics.Enabled {
+		baseHandler.Metrics, err = newMetrics(devices, clientCache)
+		if err != nil {
+			log.Error("Failed to set up the metrics", "error", err)
+			os.Exit(1)
+		}
+	}
+	var historyHandler *handlers.HistoryHandler
+	if cfg.History.Enabled {
+		historyStore, err := startHistory(cfg.History, devices, srv, log.Logger)
+		if err != nil {
+			log.Error("Failed to open the device history", "error", err)
+			os.Exit(1)
+		}
+		historyHandler = handlers.NewHistoryHandler(baseHandler, historyStore, cfg.History.Params)
+	}
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		auditLog, err = audit.Open(cfg.Audit.File)
+		if err != nil {
+			log.Error("Failed to open the audit log", "error", err)
+			os.Exit(1)
+		}
+	}
 	deviceHandler := handlers.NewDeviceHandler(baseHandler)
 	powerStationHandler := handlers.NewPowerStationHandler(baseHandler)
+	smartPlugHandler := handlers.NewSmartPlugHandler(baseHandler)
+	powerStreamHandler := handlers.NewPowerStreamHandler(baseHandler)
+	smartHomePanelHandler := handlers.NewSmartHomePanelHandler(baseHandler)
+	statusHandler := handlers.NewStatusHandler(baseHandler)
+
+	broker := telemetry.NewBroker(cfg.Stream.HistorySize, cfg.Stream.Linger)
+	srv.OnDrain(broker.Close) // end the streams, otherwise they hold the shutdown until the grace period is over
+	feeds := handlers.NewDeviceFeeds(baseHandler, broker, cfg.Stream.PollInterval)
+	streamHandler := handlers.NewStreamHandler(baseHandler, feeds, cfg.Stream.HeartbeatInterval)
+
+	// commands sent over WebSocket count against the same rate limit as the HTTP requests and are audited like them
+	rateLimit := middleware.NewRateLimitMiddleware(baseHandler, cfg.RateLimit.Limit, cfg.RateLimit.Window).RateLimit()
+	var audited []func(http.Handler) http.Handler
+	if auditLog != nil {
+		audited = append(audited, middleware.NewAuditMiddleware(baseHandler, auditLog).Audit)
+	}
+	dispatcher := handlers.NewCommandDispatcher(append([]func(http.Handler) http.Handler{rateLimit}, audited...), powerStationHandler,
+		smartPlugHandler, powerStreamHandler, smartHomePanelHandler)
+	// the commands of the schedules, the rules and the Home Assistant bridge
+	executeCommand := newAccountExecutor(baseHandler, v, auditLog)
+	bulkHandler := handlers.NewBulkHandler(baseHandler, dispatcher, cfg.Bulk.Concurrency, cfg.Bulk.MaxDevices)
+	var scheduleHandler *handlers.ScheduleHandler
+	if cfg.Schedules.Enabled {
+		scheduleStore, err := startScheduler(cfg.Schedules, executeCommand, srv, log.Logger)
+		if err != nil {
+			log.Error("Failed to open the schedules", "error", err)
+			os.Exit(1)
+		}
+		scheduleHandler = handlers.NewScheduleHandler(baseHandler, scheduleStore)
+	}
+	var ruleHandler *handlers.RuleHandler
+	if cfg.Rules.Enabled {
+		ruleStore, err := startRules(cfg.Rules, executeCommand, devices, srv, log.Logger)
+		if err != nil {
+			log.Error("Failed to open the rules", "error", err)
+			os.Exit(1)
+		}
+		ruleHandler = handlers.NewRuleHandler(baseHandler, ruleStore)
+	}
+	var webhookHandler *handlers.WebhookHandler
+	if cfg.Webhooks.Enabled {
+		webhookStore, err := startWebhooks(cfg.Webhooks, cfg.MQTT.APIURL, v, baseHandler.Guard, devices, srv, log.Logger)
+		if err != nil {
+			log.Error("Failed to open the webhooks", "error", err)
+			os.Exit(1)
+		}
+		webhookHandler = handlers.NewWebhookHandler(baseHandler, webhookStore)
+	}
+	if cfg.HomeAssistant.Enabled {
+		startHomeAssistant(cfg.HomeAssistant, executeCommand, devices, srv, log.Logger)
+	}
+	webSocketHandler := handlers.NewWebSocketHandler(baseHandler, feeds, dispatcher, cfg.WebSocket.SendQueue, cfg.WebSocket.PingInterval)
+	srv.OnDrain(webSocketHandler.Close)
 
 	// create api routes
 	router.Group(func(apiRouter chi.Router) {
-		setMiddleware(apiRouter, log, baseHandler)
-		deviceHandler.RegisterRoutes(apiRouter)
-		powerStationHandler.RegisterRoutes(apiRouter)
+		setMiddleware(apiRouter, log, baseHandler.Metrics, rateLimit)
+		// WebSocket clients may authenticate with their first message
+		webSocketHandler.RegisterRoutes(apiRouter)
+
+		apiRouter.Group(func(apiRouter chi.Router) {
+			setAuthMiddleware(apiRouter, baseHandler, cfg)
+			streamHandler.RegisterRoutes(apiRouter)
+
+			apiRouter.Group(func(apiRouter chi.Router) {
+				apiRouter.Use(chimiddleware.Timeout(cfg.Server.RequestTimeout)) //max request duration
+				apiRouter.Use(audited...)                                       // record the mutating calls in the audit log
+				deviceHandler.RegisterRoutes(apiRouter)
+				powerStationHandler.RegisterRoutes(apiRouter)
+				bulkHandler.RegisterRoutes(apiRouter)
+				smartPlugHandler.RegisterRoutes(apiRouter)
+				powerStreamHandler.RegisterRoutes(apiRouter)
+				smartHomePanelHandler.RegisterRoutes(apiRouter)
+				statusHandler.RegisterRoutes(apiRouter)
+				if historyHandler != nil {
+					historyHandler.RegisterRoutes(apiRouter)
+				}
+				if scheduleHandler != nil {
+					scheduleHandler.RegisterRoutes(apiRouter)
+				}
+				if ruleHandler != nil {
+					ruleHandler.RegisterRoutes(apiRouter)
+				}
+				if webhookHandler != nil {
+					webhookHandler.RegisterRoutes(apiRouter)
+				}
+			})
+		})
+
+		// the audit log is not queried with Ecoflow keys
+		if auditLog != nil {
+			apiRouter.Group(func(auditRouter chi.Router) {
+				auditRouter.Use(middleware.NewBearerTokenMiddleware(baseHandler, cfg.Audit.Token).CheckToken)
+				handlers.NewAuditHandler(baseHandler, auditLog).RegisterRoutes(auditRouter)
+			})
+		}
 	})
 
 	router.Get("/swagger/*", httpSwagger.WrapHandler)
+	if baseHandler.Metrics != nil {
+		router.Group(func(metricsRouter chi.Router) {
+			if cfg.Metrics.Token != "" {
+				metricsRouter.Use(middleware.NewBearerTokenMiddleware(baseHandler, cfg.Metrics.Token).CheckToken)
+			}
+			metricsRouter.Handle("/metrics", baseHandler.Metrics.Handler())
+		})
+	}
+
+	slog.Info("Starting Ecoflow API Server... Swagger is available at /swagger/index.html", "address", cfg.Server.Address)
+
+	err = srv.Run(ctx, router)
+	if auditLog != nil {
+		if closeErr := auditLog.Close(); closeErr != nil {
+			log.Warn("Failed to close the audit log", "error", closeErr)
+		}
+	}
+	if err != nil {
+		log.Error("Server stopped with error", "error", err)
+		os.Exit(1)
+	}
+	log.Info("Server stopped")
+}
+
+func setMiddleware(router chi.Router, log *httplog.Logger, registry *metrics.Registry, rateLimit func(http.Handler) http.Handler) {
+	router.Use(chimiddleware.RequestID)    //add request id to each request
+	router.Use(chimiddleware.RealIP)       //get real ip address for headers
+	router.Use(httplog.RequestLogger(log)) //log all requests without sensitive headers
+	router.Use(chimiddleware.Recoverer)    //recover in case of panic
+	router.Use(registry.Middleware)        // request duration per route
+	router.Use(rateLimit)                  // rate limit (60 requests per minute by default)
+}
+
+func setAuthMiddleware(router chi.Router, baseHandler *handlers.BaseHandler, cfg *config.Config) {
+
+	authHeadersMiddleware := middleware.NewAuthHeadersMiddleware(baseHandler, []string{constants.HeaderAuthorization, constants.HeaderXSecretToken})
+	if cfg.Vault.Enabled() {
+		if cfg.Vault.AllowHeaders {
+			authHeadersMiddleware.AllowAlternative(constants.HeaderXAPIKey)
+		} else {
+			authHeadersMiddleware = middleware.NewAuthHeadersMiddleware(baseHandler, []string{constants.HeaderXAPIKey})
+		}
+	}
+	router.Use(authHeadersMiddleware.CheckAuthHeaders) // check mandatory auth headers
+}
+
+// openVault opens the credential vault, it returns nil when the vault is not configured.
+func openVault(cfg config.VaultConfig) (*vault.Vault, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	key, err := vault.ReadKeyFile(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return vault.Open(cfg.File, key)
+}
+
+// newClientProvider returns the provider that creates Ecoflow clients for API requests, the function that identifies
+// the account of a request and the client cache, if it is enabled. By default, the Ecoflow keys are taken from the
+// request headers. When the credential vault is configured, clients can authenticate with server-issued API keys
+// instead.
+func newClientProvider(cfg *config.Config, srv *server.Server, v *vault.Vault) (handlers.ClientProvider, func(r *http.Request) string, *service.ClientCache) {
+	newClient := service.NewClient
+	var cache *service.ClientCache
+	if cfg.ClientCache.Size > 0 {
+		cache = service.NewClientCache(cfg.ClientCache.Size, cfg.ClientCache.TTL, service.NewClient)
+		cacheCtx, stopCache := context.WithCancel(context.Background())
+		go cache.Run(cacheCtx)
+		srv.OnShutdown("client cache", func(ctx context.Context) error {
+			stopCache()
+			return nil
+		})
+		newClient = cache.Client
+	}
+
+	if v == nil {
+		return service.NewHeaderClientProvider(newClient), service.HeaderIdentity, cache
+	}
+	return service.NewVaultClientProvider(v, cfg.Vault.AllowHeaders, newClient), service.NewVaultIdentity(v), cache
+}
+
+// vaultAccounts returns the credentials of the named vault accounts, or of all accounts if names is empty.
+func vaultAccounts(v *vault.Vault, names []string) ([]ingest.Account, error) {
+	if len(names) == 0 {
+		names = v.Accounts()
+	}
+	accounts := make([]ingest.Account, 0, len(names))
+	for _, name := range names {
+		credentials, err := v.Credentials(name)
+		if err != nil {
+			return nil, fmt.Errorf("account %q: %w", name, err)
+		}
+		accounts = append(accounts, ingest.Account{Name: name, Credentials: credentials})
+	}
+	return accounts, nil
+}
+
+// runInBackground runs fn until the server shuts down.
+func runInBackground(srv *server.Server, name string, fn func(ctx context.Context)) {
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+	srv.OnShutdown(name, func(ctx context.Context) error {
+		stop()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// startIngestion starts the ingestion of the devices of the vault accounts from the Ecoflow MQTT broker and returns the
+// store with their parameters.
+func startIngestion(cfg config.MQTTConfig, v *vault.Vault, srv *server.Server, log *slog.Logger) (*ingest.Store, error) {
+	accounts, err := vaultAccounts(v, cfg.Accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	store := ingest.NewStore(cfg.MaxAge)
+	ingestor := ingest.NewIngestor(store, accounts, ingest.Config{
+		APIURL:          cfg.APIURL,
+		RefreshInterval: cfg.RefreshInterval,
+	}, log)
+	runInBackground(srv, "mqtt ingestion", ingestor.Run)
+	return store, nil
+}
+
+// deviceStore returns the store with the parameters of the devices of the vault accounts for the metrics, the history,
+// the rules, the webhooks and the Home Assistant bridge. These are the ingested devices, or, without MQTT ingestion,
+// the devices of all vault accounts which are polled from the Ecoflow API. It returns nil without the vault.
+func deviceStore(cfg *config.Config, v *vault.Vault, pushed *ingest.Store, srv *server.Server, log *slog.Logger) (*ingest.Store, error) {
+	if pushed != nil || v == nil {
+		return pushed, nil
+	}
+	accounts, err := vaultAccounts(v, nil)
+	if err != nil {
+		return nil, err
+	}
+	// a device that missed one poll is still fresh
+	store := ingest.NewStore(2 * cfg.Metrics.DevicePollInterval)
+	poller := ingest.NewPoller(store, accounts, ingest.Config{
+		APIURL:          cfg.MQTT.APIURL,
+		RefreshInterval: cfg.Metrics.DevicePollInterval,
+	}, log)
+	runInBackground(srv, "device polling", poller.Run)
+	return store, nil
+}
 
-	slog.Info("Starting Ecoflow API Server on :8080... Swagger is available at http://localhost:8080/swagger/index.html")
+// newMetrics returns the metrics registry. The device gauges are exported for the devices of the store and the client
+// cache counters for the cache, without them only the server metrics are exported.
+func newMetrics(devices *ingest.Store, clientCache *service.ClientCache) (*metrics.Registry, error) {
+	registry := metrics.New()
+	if devices != nil {
+		if err := registry.Register(metrics.NewDeviceCollector(devices)); err != nil {
+			return nil, err
+		}
+	}
+	if clientCache != nil {
+		if err := registry.Register(metrics.NewClientCacheCollector(clientCache)); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
 
-	err := http.ListenAndServe(":8080", router)
+// startHistory opens the history store and records the parameters of the devices until the server shuts down.
+func startHistory(cfg config.HistoryConfig, devices *ingest.Store, srv *server.Server, log *slog.Logger) (*history.Store, error) {
+	store, err := history.Open(history.Config{
+		Dir:             cfg.Dir,
+		SegmentDuration: 24 * time.Hour,
+		RollupInterval:  cfg.RollupInterval,
+		RawRetention:    cfg.RawRetention,
+		Retention:       cfg.Retention,
+	})
 	if err != nil {
-		log.Error("Failed to start server", "error", err)
+		return nil, err
 	}
+	recorder := history.NewRecorder(store, devices, cfg.Params, cfg.Interval, log)
+	runInBackground(srv, "device history", func(ctx context.Context) {
+		recorder.Run(ctx)
+		if err := store.Close(); err != nil {
+			log.Warn("Failed to close the device history", "error", err)
+		}
+	})
+	return store, nil
 }
 
-func setMiddleware(router chi.Router, log *httplog.Logger, baseHandler *handlers.BaseHandler) {
-	router.Use(chimiddleware.RequestID)                         //add request id to each request
-	router.Use(chimiddleware.RealIP)                            //get real ip address for headers
-	router.Use(httplog.RequestLogger(log))                      //log all requests without sensitive headers
-	router.Use(chimiddleware.Recoverer)                         //recover in case of panic
-	router.Use(chimiddleware.Timeout(constants.RequestTimeout)) //max request duration
+// startScheduler opens the schedules and runs them until the server shuts down.
+func startScheduler(cfg config.SchedulesConfig, execute service.CommandExecutor, srv *server.Server, log *slog.Logger) (*schedule.Store, error) {
+	store, err := schedule.Open(cfg.File, cfg.RunHistory)
+	if err != nil {
+		return nil, err
+	}
+	runInBackground(srv, "scheduler", schedule.NewScheduler(store, execute, log).Run)
+	return store, nil
+}
 
-	authheaders := []string{constants.HeaderAuthorization, constants.HeaderXSecretToken}
-	router.Use(middleware.NewAuthHeadersMiddleware(baseHandler, authheaders).CheckAuthHeaders)                                   // check mandatory auth headers
-	router.Use(middleware.NewRateLimitMiddleware(baseHandler, constants.RateLimit, constants.RateLimitWindowLength).RateLimit()) // rate limit (60 requests per minute)
+// startRules opens the rules and evaluates them over the parameters of the devices until the server shuts down.
+func startRules(cfg config.RulesConfig, execute service.CommandExecutor, devices *ingest.Store, srv *server.Server, log *slog.Logger) (*rules.Store, error) {
+	store, err := rules.Open(cfg.File, cfg.History)
+	if err != nil {
+		return nil, err
+	}
+	engine := rules.NewEngine(store, devices, execute, cfg.Interval, log)
+	runInBackground(srv, "rules engine", engine.Run)
+	return store, nil
+}
+
+// startWebhooks opens the webhook subscriptions, watches the devices of their accounts and sends the deliveries until
+// the server shuts down. The device lists are read from apiURL through the guard, like the API requests of the accounts.
+func startWebhooks(cfg config.WebhooksConfig, apiURL string, v *vault.Vault, guard *resilience.Guard, devices *ingest.Store, srv *server.Server, log *slog.Logger) (*webhook.Store, error) {
+	store, err := webhook.Open(cfg.File, cfg.History)
+	if err != nil {
+		return nil, err
+	}
+	deliverer := webhook.NewDeliverer(store, webhook.Config{
+		Timeout:     cfg.Timeout,
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+	}, log)
+	// the clients are created like the clients of the device polling and reused while the devices are watched
+	clients := service.NewClientCache(max(len(v.Accounts()), 1), 2*cfg.Interval, func(accessKey, secretKey string) *ecoflow.Client {
+		return ecoflow.NewEcoflowClient(accessKey, secretKey, ecoflow.WithBaseUrl(apiURL))
+	})
+	// the online state of the devices is only returned by the device list
+	list := func(ctx context.Context, account string) ([]ecoflow.DeviceInfo, error) {
+		credentials, err := v.Credentials(account)
+		if err != nil {
+			return nil, err
+		}
+		client := clients.Client(credentials.AccessKey, credentials.SecretKey)
+		var devices []ecoflow.DeviceInfo
+		err = guard.Read(ctx, service.VaultIdentity(account), func(ctx context.Context) error {
+			response, err := client.GetDeviceList(ctx)
+			if err != nil {
+				return err
+			}
+			devices = response.Devices
+			return nil
+		})
+		return devices, err
+	}
+	monitor := webhook.NewMonitor(store, list, devices, cfg.Interval, log)
+	runInBackground(srv, "webhook deliveries", deliverer.Run)
+	runInBackground(srv, "webhook events", monitor.Run)
+	return store, nil
+}
+
+// startHomeAssistant publishes the power stations to Home Assistant and switches their outputs until the server shuts
+// down.
+func startHomeAssistant(cfg config.HomeAssistantConfig, execute service.CommandExecutor, devices *ingest.Store, srv *server.Server, log *slog.Logger) {
+	bridge := homeassistant.NewBridge(devices, execute, homeassistant.Config{
+		Broker:          cfg.Broker,
+		Username:        cfg.Username,
+		Password:        cfg.Password,
+		ClientID:        cfg.ClientID,
+		DiscoveryPrefix: cfg.DiscoveryPrefix,
+		TopicPrefix:     cfg.TopicPrefix,
+		Interval:        cfg.Interval,
+	}, log)
+	runInBackground(srv, "home assistant bridge", bridge.Run)
+}
+
+// newAccountExecutor returns a function that sends the commands of the server itself, e.g. scheduled commands. They are
+// dispatched to the power station handlers with the credentials of the vault account, and recorded in the audit log
+// unless it's nil.
+func newAccountExecutor(baseHandler *handlers.BaseHandler, v *vault.Vault, auditLog *audit.Log) service.CommandExecutor {
+	accountHandler := *baseHandler
+	accountHandler.Provider = service.NewVaultAccountClientProvider(v, service.NewClient)
+	accountHandler.Identity = service.VaultAccountIdentity
+	var middlewares []func(http.Handler) http.Handler
+	if auditLog != nil {
+		middlewares = append(middlewares, middleware.NewAuditMiddleware(&accountHandler, auditLog).Audit)
+	}
+	dispatcher := handlers.NewCommandDispatcher(middlewares, handlers.NewPowerStationHandler(&accountHandler))
+	return func(ctx context.Context, account, sn, command string, payload json.RawMessage) (int, json.RawMessage) {
+		result := dispatcher.Dispatch(service.WithVaultAccount(ctx, account), handlers.DispatchRequest{
+			Method: http.MethodPut,
+			Path:   "/api/power_station/" + url.PathEscape(sn) + "/" + command,
+			Header: http.Header{},
+			Body:   payload,
+		})
+		return result.Status, result.Body
+	}
 }
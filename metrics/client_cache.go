@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"go-ecoflow-api-server/service"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ClientCacheSource provides the counters of the exported client cache, see service.ClientCache.
+type ClientCacheSource interface {
+	Stats() service.ClientCacheStats
+}
+
+var (
+	clientCacheSizeDesc = prometheus.NewDesc("ecoflow_client_cache_size",
+		"Ecoflow clients in the client cache.", nil, nil)
+	clientCacheHitsDesc = prometheus.NewDesc("ecoflow_client_cache_hits_total",
+		"Requests that reused a cached Ecoflow client.", nil, nil)
+	clientCacheMissesDesc = prometheus.NewDesc("ecoflow_client_cache_misses_total",
+		"Requests that created a new Ecoflow client.", nil, nil)
+	clientCacheEvictionsDesc = prometheus.NewDesc("ecoflow_client_cache_evictions_total",
+		"Ecoflow clients removed from the client cache because it was full or they were idle.", nil, nil)
+)
+
+// ClientCacheCollector exports the counters of a ClientCacheSource.
+type ClientCacheCollector struct {
+	source ClientCacheSource
+}
+
+func NewClientCacheCollector(source ClientCacheSource) *ClientCacheCollector {
+	return &ClientCacheCollector{source: source}
+}
+
+func (c *ClientCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clientCacheSizeDesc
+	ch <- clientCacheHitsDesc
+	ch <- clientCacheMissesDesc
+	ch <- clientCacheEvictionsDesc
+}
+
+func (c *ClientCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.source.Stats()
+	ch <- prometheus.MustNewConstMetric(clientCacheSizeDesc, prometheus.GaugeValue, float64(stats.Size))
+	ch <- prometheus.MustNewConstMetric(clientCacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(clientCacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(clientCacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+}
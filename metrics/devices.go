@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"go-ecoflow-api-server/catalog"
+	"go-ecoflow-api-server/ingest"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DeviceSource provides the parameters of the exported devices, see ingest.Store.
+type DeviceSource interface {
+	Devices() []ingest.Device
+}
+
+// deviceGauge exports a device parameter. The first of the keys present in the parameters is used, devices of other
+// families report the same value under different keys.
+type deviceGauge struct {
+	desc  *prometheus.Desc
+	keys  []string
+	scale float64
+}
+
+var deviceLabels = []string{"serial_number", "model"}
+
+func newDeviceGauge(name, help string, scale float64, keys ...string) deviceGauge {
+	return deviceGauge{desc: prometheus.NewDesc(name, help, deviceLabels, nil), keys: keys, scale: scale}
+}
+
+// deviceGauges are the exported parameters. Ecoflow reports some of them in tenths or thousandths, they are scaled
+// to base units.
+var deviceGauges = []deviceGauge{
+	newDeviceGauge("ecoflow_device_battery_level_percent", "State of charge of the battery.", 1, "pd.soc", "bms_bmsStatus.soc"),
+	newDeviceGauge("ecoflow_device_input_watts", "Total input power.", 1, "pd.wattsInSum"),
+	newDeviceGauge("ecoflow_device_output_watts", "Total output power.", 1, "pd.wattsOutSum"),
+	newDeviceGauge("ecoflow_device_ac_input_watts", "AC input power.", 1, "inv.inputWatts"),
+	newDeviceGauge("ecoflow_device_ac_output_watts", "AC output power.", 1, "inv.outputWatts"),
+	newDeviceGauge("ecoflow_device_solar_input_watts", "Solar input power.", 0.1, "mppt.inWatts"),
+	newDeviceGauge("ecoflow_device_car_output_watts", "Car charger output power.", 1, "pd.carWatts"),
+	newDeviceGauge("ecoflow_device_battery_temperature_celsius", "Temperature of the battery.", 1, "bms_bmsStatus.temp"),
+	newDeviceGauge("ecoflow_device_inverter_temperature_celsius", "Temperature of the inverter.", 1, "inv.outTemp"),
+	newDeviceGauge("ecoflow_device_mppt_temperature_celsius", "Temperature of the solar charge controller.", 1, "mppt.mpptTemp"),
+	newDeviceGauge("ecoflow_device_battery_cycles", "Charge cycles of the battery.", 1, "bms_bmsStatus.cycles"),
+	newDeviceGauge("ecoflow_device_battery_voltage_volts", "Voltage of the battery.", 0.001, "bms_bmsStatus.vol"),
+	newDeviceGauge("ecoflow_device_remaining_seconds", "Estimated time until the battery is full or empty.", 60, "pd.remainTime"),
+}
+
+var lastUpdateDesc = prometheus.NewDesc("ecoflow_device_last_update_timestamp_seconds",
+	"Time of the last update of the device parameters.", deviceLabels, nil)
+
+// DeviceCollector exports the curated device gauges of the devices of a DeviceSource.
+type DeviceCollector struct {
+	source DeviceSource
+}
+
+func NewDeviceCollector(source DeviceSource) *DeviceCollector {
+	return &DeviceCollector{source: source}
+}
+
+func (c *DeviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, gauge := range deviceGauges {
+		ch <- gauge.desc
+	}
+	ch <- lastUpdateDesc
+}
+
+func (c *DeviceCollector) Collect(ch chan<- prometheus.Metric) {
+	// a device linked to several accounts is exported once, with its latest parameters
+	latest := make(map[string]ingest.Device)
+	var order []string
+	for _, device := range c.source.Devices() {
+		current, ok := latest[device.SN]
+		if !ok {
+			order = append(order, device.SN)
+		}
+		if !ok || device.Updated.After(current.Updated) {
+			latest[device.SN] = device
+		}
+	}
+
+	for _, sn := range order {
+		device := latest[sn]
+		labels := []string{sn, catalog.Identify(sn).Name}
+		for _, gauge := range deviceGauges {
+			if value, ok := gauge.value(device.Params); ok {
+				ch <- prometheus.MustNewConstMetric(gauge.desc, prometheus.GaugeValue, value, labels...)
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(lastUpdateDesc, prometheus.GaugeValue, float64(device.Updated.UnixMilli())/1000, labels...)
+	}
+}
+
+func (g deviceGauge) value(params map[string]interface{}) (float64, bool) {
+	for _, key := range g.keys {
+		if value, ok := ingest.Number(params[key]); ok {
+			return value * g.scale, true
+		}
+	}
+	return 0, false
+}
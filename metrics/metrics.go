@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Registry collects the metrics of the server and exposes them in the Prometheus text format. All methods are safe
+// for concurrent use and do nothing on a nil *Registry, so metrics can be disabled by not creating one.
+type Registry struct {
+	registry            *prometheus.Registry
+	requestDuration     *prometheus.HistogramVec
+	upstreamDuration    *prometheus.HistogramVec
+	upstreamErrors      *prometheus.CounterVec
+	rateLimitRejections prometheus.Counter
+}
+
+func New() *Registry {
+	r := &Registry{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ecoflow_http_request_duration_seconds",
+			Help:    "Duration of the API requests by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ecoflow_upstream_request_duration_seconds",
+			Help:    "Duration of the calls to the Ecoflow API, every retry is a separate call.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ecoflow_upstream_errors_total",
+			Help: "Failed calls to the Ecoflow API by error class.",
+		}, []string{"operation", "class"}),
+		rateLimitRejections: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ecoflow_rate_limit_rejections_total",
+			Help: "Requests and WebSocket commands rejected by the rate limiter.",
+		}),
+	}
+	r.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		r.requestDuration,
+		r.upstreamDuration,
+		r.upstreamErrors,
+		r.rateLimitRejections,
+	)
+	return r
+}
+
+// Handler serves the metrics.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{})
+}
+
+// Register adds a collector, e.g. the device gauges of a DeviceCollector.
+func (r *Registry) Register(collector prometheus.Collector) error {
+	if r == nil {
+		return nil
+	}
+	return r.registry.Register(collector)
+}
+
+// Middleware observes the duration of the requests. Requests are labelled with the chi route pattern, not the path, so
+// serial numbers don't create new series. Streams and WebSocket connections are not observed, their duration is the
+// lifetime of the connection.
+func (r *Registry) Middleware(next http.Handler) http.Handler {
+	if r == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, req.ProtoMajor)
+		next.ServeHTTP(ww, req)
+
+		// hijacked connections, i.e. WebSocket upgrades, have no status
+		if ww.Status() == 0 || strings.HasPrefix(ww.Header().Get("Content-Type"), "text/event-stream") {
+			return
+		}
+		route := "unmatched"
+		if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		r.requestDuration.WithLabelValues(req.Method, route, strconv.Itoa(ww.Status())).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveUpstream records a call to the Ecoflow API. class is the error class of a failed call and empty on success.
+func (r *Registry) ObserveUpstream(operation string, duration time.Duration, class string) {
+	if r == nil {
+		return
+	}
+	r.upstreamDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if class != "" {
+		r.upstreamErrors.WithLabelValues(operation, class).Inc()
+	}
+}
+
+// RateLimited records a request rejected by the rate limiter.
+func (r *Registry) RateLimited() {
+	if r == nil {
+		return
+	}
+	r.rateLimitRejections.Inc()
+}
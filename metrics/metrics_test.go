@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"go-ecoflow-api-server/ingest"
+	"go-ecoflow-api-server/service"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, registry *Registry) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestRegistry_Middleware(t *testing.T) {
+	registry := New()
+	router := chi.NewRouter()
+	router.Use(registry.Middleware)
+	router.Get("/api/devices/{sn}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	})
+	router.Get("/api/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, path := range []string{"/api/devices/SN1", "/api/devices/SN2", "/api/stream", "/unknown"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, registry)
+	assert.Contains(t, body, `ecoflow_http_request_duration_seconds_count{method="GET",route="/api/devices/{sn}",status="409"} 2`)
+	assert.Contains(t, body, `ecoflow_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`)
+	assert.NotContains(t, body, `route="/api/stream"`, "streams are not observed")
+}
+
+func TestRegistry_UpstreamAndRateLimit(t *testing.T) {
+	registry := New()
+	registry.ObserveUpstream("get_all_parameters", 100*time.Millisecond, "")
+	registry.ObserveUpstream("get_all_parameters", time.Second, "timeout")
+	registry.RateLimited()
+
+	assert.Contains(t, scrape(t, registry), `ecoflow_upstream_request_duration_seconds_count{operation="get_all_parameters"} 2`)
+	assert.Equal(t, 1.0, testutil.ToFloat64(registry.upstreamErrors.WithLabelValues("get_all_parameters", "timeout")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(registry.rateLimitRejections))
+
+	// a nil registry disables the metrics
+	var disabled *Registry
+	disabled.ObserveUpstream("device_list", time.Second, "")
+	disabled.RateLimited()
+	assert.NoError(t, disabled.Register(NewDeviceCollector(ingest.NewStore(time.Minute))))
+}
+
+type devices []ingest.Device
+
+func (d devices) Devices() []ingest.Device {
+	return d
+}
+
+func TestDeviceCollector(t *testing.T) {
+	updated := time.Unix(1736676000, 0)
+	source := devices{
+		{Account: "vault:home", SN: "R331ZEB4ZEAL0528", Updated: updated, Params: map[string]interface{}{
+			"pd.soc":            80.0,
+			"pd.wattsInSum":     120.0,
+			"mppt.inWatts":      1205.0,
+			"bms_bmsStatus.vol": 52100.0,
+			"pd.remainTime":     90.0,
+			"pd.model":          "not a number",
+		}},
+		// the same device linked to another account with older parameters
+		{Account: "vault:work", SN: "R331ZEB4ZEAL0528", Updated: updated.Add(-time.Minute), Params: map[string]interface{}{"pd.soc": 10.0}},
+		{Account: "vault:home", SN: "XX01", Updated: updated, Params: map[string]interface{}{"bms_bmsStatus.soc": 55.0}},
+	}
+
+	registry := New()
+	require.NoError(t, registry.Register(NewDeviceCollector(source)))
+	expected := `
+# HELP ecoflow_device_battery_level_percent State of charge of the battery.
+# TYPE ecoflow_device_battery_level_percent gauge
+ecoflow_device_battery_level_percent{model="DELTA 2",serial_number="R331ZEB4ZEAL0528"} 80
+ecoflow_device_battery_level_percent{model="Unknown",serial_number="XX01"} 55
+# HELP ecoflow_device_battery_voltage_volts Voltage of the battery.
+# TYPE ecoflow_device_battery_voltage_volts gauge
+ecoflow_device_battery_voltage_volts{model="DELTA 2",serial_number="R331ZEB4ZEAL0528"} 52.1
+# HELP ecoflow_device_input_watts Total input power.
+# TYPE ecoflow_device_input_watts gauge
+ecoflow_device_input_watts{model="DELTA 2",serial_number="R331ZEB4ZEAL0528"} 120
+# HELP ecoflow_device_last_update_timestamp_seconds Time of the last update of the device parameters.
+# TYPE ecoflow_device_last_update_timestamp_seconds gauge
+ecoflow_device_last_update_timestamp_seconds{model="DELTA 2",serial_number="R331ZEB4ZEAL0528"} 1.736676e+09
+ecoflow_device_last_update_timestamp_seconds{model="Unknown",serial_number="XX01"} 1.736676e+09
+# HELP ecoflow_device_remaining_seconds Estimated time until the battery is full or empty.
+# TYPE ecoflow_device_remaining_seconds gauge
+ecoflow_device_remaining_seconds{model="DELTA 2",serial_number="R331ZEB4ZEAL0528"} 5400
+# HELP ecoflow_device_solar_input_watts Solar input power.
+# TYPE ecoflow_device_solar_input_watts gauge
+ecoflow_device_solar_input_watts{model="DELTA 2",serial_number="R331ZEB4ZEAL0528"} 120.5
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry.registry, strings.NewReader(expected),
+		"ecoflow_device_battery_level_percent",
+		"ecoflow_device_battery_voltage_volts",
+		"ecoflow_device_input_watts",
+		"ecoflow_device_last_update_timestamp_seconds",
+		"ecoflow_device_remaining_seconds",
+		"ecoflow_device_solar_input_watts",
+		"ecoflow_device_output_watts",
+	))
+}
+
+type clientCache service.ClientCacheStats
+
+func (c clientCache) Stats() service.ClientCacheStats {
+	return service.ClientCacheStats(c)
+}
+
+func TestClientCacheCollector(t *testing.T) {
+	registry := New()
+	require.NoError(t, registry.Register(NewClientCacheCollector(clientCache{Size: 2, Hits: 10, Misses: 3, Evictions: 1})))
+	expected := `
+# HELP ecoflow_client_cache_evictions_total Ecoflow clients removed from the client cache because it was full or they were idle.
+# TYPE ecoflow_client_cache_evictions_total counter
+ecoflow_client_cache_evictions_total 1
+# HELP ecoflow_client_cache_hits_total Requests that reused a cached Ecoflow client.
+# TYPE ecoflow_client_cache_hits_total counter
+ecoflow_client_cache_hits_total 10
+# HELP ecoflow_client_cache_misses_total Requests that created a new Ecoflow client.
+# TYPE ecoflow_client_cache_misses_total counter
+ecoflow_client_cache_misses_total 3
+# HELP ecoflow_client_cache_size Ecoflow clients in the client cache.
+# TYPE ecoflow_client_cache_size gauge
+ecoflow_client_cache_size 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry.registry, strings.NewReader(expected),
+		"ecoflow_client_cache_evictions_total",
+		"ecoflow_client_cache_hits_total",
+		"ecoflow_client_cache_misses_total",
+		"ecoflow_client_cache_size",
+	))
+}
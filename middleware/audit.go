@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"go-ecoflow-api-server/audit"
+	"go-ecoflow-api-server/handlers"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// maxAuditedBody is the size of the largest request body kept in an entry, the request bodies of the API are far
+	// smaller
+	maxAuditedBody = 64 << 10
+	// maxAuditedResponse is the size of the response read for the error code
+	maxAuditedResponse = 64 << 10
+	// redacted replaces the values of the secret fields of the request bodies
+	redacted = "[REDACTED]"
+)
+
+// secretFields are the fields of request bodies whose values are never written to the audit log, e.g. the secret of a
+// webhook subscription. They are matched case-insensitively at any depth.
+var secretFields = []string{"secret", "api_key", "authorization", "password", "token", "access_key", "secret_key"}
+
+// AuditMiddleware records the mutating calls in the audit log: the hashed identity of the caller, the device, the
+// request body, the commands sent to the Ecoflow cloud and the outcome.
+type AuditMiddleware struct {
+	*handlers.BaseHandler
+	log *audit.Log
+}
+
+func NewAuditMiddleware(baseHandler *handlers.BaseHandler, log *audit.Log) *AuditMiddleware {
+	return &AuditMiddleware{BaseHandler: baseHandler, log: log}
+}
+
+func (a *AuditMiddleware) Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(r.Body)
+			if err != nil {
+				a.Logger.Warn("Failed to read the audited request body", "error", err)
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		ctx, recorder := audit.WithRecorder(r.Context())
+		response := &limitedBuffer{limit: maxAuditedResponse}
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(response)
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		entry := audit.Entry{
+			Time:      start,
+			RequestID: chimiddleware.GetReqID(r.Context()),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Request:   auditedBody(body),
+			Status:    ww.Status(),
+			ErrorCode: errorCode(response.Bytes()),
+			LatencyMs: audit.Milliseconds(time.Since(start)),
+			Upstream:  recorder.Calls(),
+		}
+		if a.Identity != nil {
+			entry.Actor = audit.Actor(a.Identity(r))
+		}
+		// the routing state is filled in while the request is routed
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			entry.Route = rctx.RoutePattern()
+			entry.SerialNumber = rctx.URLParam("serial_number")
+		}
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		entry.Outcome = outcome(entry.Status, len(entry.Upstream))
+		if _, err := a.log.Append(entry); err != nil {
+			a.Logger.Error("Failed to write the audit log", "error", err, "path", entry.Path)
+		}
+	})
+}
+
+// outcome classifies a call by its status. Client errors are only rejections if no command was sent, otherwise the
+// Ecoflow cloud refused the command.
+func outcome(status, upstreamCalls int) string {
+	switch {
+	case status >= 200 && status < 300:
+		return audit.OutcomeSucceeded
+	case status >= 400 && status < 500 && upstreamCalls == 0:
+		return audit.OutcomeRejected
+	}
+	return audit.OutcomeFailed
+}
+
+// auditedBody returns the request body for the entry with the values of the secret fields redacted. Bodies that are
+// not JSON are kept as a string.
+func auditedBody(body []byte) json.RawMessage {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	if len(body) > maxAuditedBody {
+		data, _ := json.Marshal(fmt.Sprintf("request body of %d bytes is not logged", len(body)))
+		return data
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // keep the numbers as they were sent
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		data, _ := json.Marshal(string(body))
+		return data
+	}
+	data, err := json.Marshal(redact(value))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// redact replaces the values of the secret fields in the decoded JSON value.
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if slices.ContainsFunc(secretFields, func(secret string) bool { return strings.EqualFold(key, secret) }) {
+				v[key] = redacted
+			} else {
+				v[key] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return value
+}
+
+// errorCode returns the error code of an error response or a problem document, see handlers.ErrorResponse and
+// handlers.ProblemDetails.
+func errorCode(response []byte) string {
+	var body struct {
+		Code  string          `json:"code"`
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(response, &body) != nil {
+		return ""
+	}
+	if body.Code != "" {
+		return body.Code
+	}
+	var field handlers.ErrorField
+	if json.Unmarshal(body.Error, &field) != nil {
+		return ""
+	}
+	return field.Code
+}
+
+// limitedBuffer keeps the first limit bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (l *limitedBuffer) Write(data []byte) (int, error) {
+	if remaining := l.limit - l.Len(); remaining > 0 {
+		l.Buffer.Write(data[:min(len(data), remaining)])
+	}
+	return len(data), nil
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"go-ecoflow-api-server/audit"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/handlers"
+	"go-ecoflow-api-server/service"
+	"go-ecoflow-api-server/webhook"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httplog/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tess1o/go-ecoflow"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	var offline atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if offline.Load() {
+			_, _ = w.Write([]byte(`{"code":"9999","message":"current device is offline"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":"0","message":"Success"}`))
+	}))
+	defer upstream.Close()
+
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	defer log.Close()
+	baseHandler := handlers.NewBaseHandler(httplog.NewLogger("test-logger", httplog.Options{}), func(r *http.Request) (*ecoflow.Client, error) {
+		return ecoflow.NewEcoflowClient("access", "secret", ecoflow.WithBaseUrl(upstream.URL)), nil
+	})
+	baseHandler.Identity = func(r *http.Request) string {
+		return "key:" + r.Header.Get(constants.HeaderAuthorization)
+	}
+	auditMiddleware := NewAuditMiddleware(baseHandler, log)
+	router := chi.NewRouter()
+	router.Group(func(router chi.Router) {
+		router.Use(auditMiddleware.Audit)
+		handlers.NewPowerStationHandler(baseHandler).RegisterRoutes(router)
+	})
+	send := func(method, path, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(constants.HeaderAuthorization, "Bearer access")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send(http.MethodPut, "/api/power_station/R331ZEB4ZEAL0528/out/dc", `{"state":"on"}`)
+	send(http.MethodPut, "/api/power_station/R331ZEB4ZEAL0528/out/dc", `{"state":`)
+	offline.Store(true)
+	send(http.MethodPut, "/api/power_station/R601ZEB4ZEAL0528/out/car", `{"state":"off"}`)
+	send(http.MethodGet, "/api/power_station/R601ZEB4ZEAL0528", "")
+	dispatcher := handlers.NewCommandDispatcher([]func(http.Handler) http.Handler{auditMiddleware.Audit}, handlers.NewPowerStationHandler(baseHandler))
+	dispatcher.Dispatch(context.Background(), handlers.DispatchRequest{
+		Method: http.MethodPut,
+		Path:   "/api/power_station/R601ZEB4ZEAL0528/out/dc",
+		Header: http.Header{constants.HeaderAuthorization: {"Bearer dispatched"}},
+		Body:   []byte(`{"state":"off"}`),
+	})
+
+	entries, err := log.Query(audit.Filter{}, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 4, "only mutating calls are audited")
+
+	succeeded := entries[3]
+	assert.Equal(t, audit.Actor("key:Bearer access"), succeeded.Actor)
+	assert.Equal(t, http.MethodPut, succeeded.Method)
+	assert.Equal(t, "/api/power_station/R331ZEB4ZEAL0528/out/dc", succeeded.Path)
+	assert.Equal(t, "/api/power_station/{serial_number}/out/dc", succeeded.Route)
+	assert.Equal(t, "R331ZEB4ZEAL0528", succeeded.SerialNumber)
+	assert.JSONEq(t, `{"state":"on"}`, string(succeeded.Request))
+	assert.Equal(t, http.StatusOK, succeeded.Status)
+	assert.Equal(t, audit.OutcomeSucceeded, succeeded.Outcome)
+	assert.Empty(t, succeeded.ErrorCode)
+	require.Len(t, succeeded.Upstream, 1)
+	assert.Contains(t, string(succeeded.Upstream[0].Response), `"code":"0"`)
+
+	rejected := entries[2]
+	assert.Equal(t, audit.OutcomeRejected, rejected.Outcome)
+	assert.Equal(t, http.StatusBadRequest, rejected.Status)
+	assert.Equal(t, constants.ErrInvalidJsonBody, rejected.ErrorCode)
+	assert.JSONEq(t, `"{\"state\":"`, string(rejected.Request), "invalid bodies are kept as a string")
+	assert.Empty(t, rejected.Upstream)
+
+	failed := entries[1]
+	assert.Equal(t, audit.OutcomeFailed, failed.Outcome)
+	assert.Equal(t, http.StatusConflict, failed.Status)
+	assert.Equal(t, constants.ErrDeviceOffline, failed.ErrorCode)
+	require.Len(t, failed.Upstream, 1)
+	assert.NotEmpty(t, failed.Upstream[0].Error)
+
+	dispatched := entries[0]
+	assert.Equal(t, audit.Actor("key:Bearer dispatched"), dispatched.Actor)
+	assert.Equal(t, "R601ZEB4ZEAL0528", dispatched.SerialNumber)
+	assert.Equal(t, "/api/power_station/{serial_number}/out/dc", dispatched.Route)
+	assert.Equal(t, audit.OutcomeFailed, dispatched.Outcome)
+}
+
+func TestAuditMiddleware_RedactsSecrets(t *testing.T) {
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	defer log.Close()
+	store, err := webhook.Open(filepath.Join(t.TempDir(), "webhooks.json"), 10)
+	require.NoError(t, err)
+	baseHandler := handlers.NewBaseHandler(httplog.NewLogger("test-logger", httplog.Options{}), func(r *http.Request) (*ecoflow.Client, error) {
+		return ecoflow.NewEcoflowClient("access", "secret"), nil
+	})
+	baseHandler.Identity = func(r *http.Request) string {
+		return service.VaultIdentity(r.Header.Get(constants.HeaderXAPIKey))
+	}
+	router := chi.NewRouter()
+	router.Group(func(router chi.Router) {
+		router.Use(NewAuditMiddleware(baseHandler, log).Audit)
+		handlers.NewWebhookHandler(baseHandler, store).RegisterRoutes(router)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks", strings.NewReader(`{"name":"On-call","url":"https://example.com/ecoflow","secret":"hmac-secret-1234","events":["device.offline"]}`))
+	req.Header.Set(constants.HeaderXAPIKey, "home")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var exported bytes.Buffer
+	require.NoError(t, log.Export(&exported, audit.Filter{}))
+	assert.NotContains(t, exported.String(), "hmac-secret-1234", "the webhook secret never reaches the audit log")
+	entries, err := log.Query(audit.Filter{}, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.JSONEq(t, `{"name":"On-call","url":"https://example.com/ecoflow","secret":"[REDACTED]","events":["device.offline"]}`, string(entries[0].Request))
+}
+
+func TestAuditedBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{name: "empty", body: "", expected: ""},
+		{name: "numbers are kept", body: `{"watts":1200,"factor":0.25}`, expected: `{"factor":0.25,"watts":1200}`},
+		{name: "nested secrets", body: `{"items":[{"API_KEY":"k","value":1}],"auth":{"Password":"p"}}`, expected: `{"auth":{"Password":"[REDACTED]"},"items":[{"API_KEY":"[REDACTED]","value":1}]}`},
+		{name: "invalid json", body: `{"secret":`, expected: `"{\"secret\":"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, string(auditedBody([]byte(tt.body))))
+		})
+	}
+}
@@ -8,7 +8,8 @@ import (
 
 type AuthHeadersMiddleware struct {
 	*handlers.BaseHandler
-	headers []string
+	headers     []string
+	alternative string
 }
 
 func NewAuthHeadersMiddleware(baseHandler *handlers.BaseHandler, headers []string) *AuthHeadersMiddleware {
@@ -18,11 +19,22 @@ func NewAuthHeadersMiddleware(baseHandler *handlers.BaseHandler, headers []strin
 	}
 }
 
+// AllowAlternative accepts requests that carry the given header instead of the mandatory ones,
+// e.g. requests authenticated with a server-issued API key.
+func (a *AuthHeadersMiddleware) AllowAlternative(header string) *AuthHeadersMiddleware {
+	a.alternative = header
+	return a
+}
+
 func (a *AuthHeadersMiddleware) CheckAuthHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.alternative != "" && r.Header.Get(a.alternative) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
 		for _, h := range a.headers {
 			if v, exists := r.Header[h]; !exists || (len(v) == 1 && v[0] == "") {
-				a.RespondWithError(w, http.StatusUnauthorized, constants.ErrMandatoryHeaderMissing, "Mandatory header is missing or empty", map[string]string{
+				a.RespondWithError(w, r, http.StatusUnauthorized, constants.ErrMandatoryHeaderMissing, "Mandatory header is missing or empty", map[string]string{
 					"header": h,
 				})
 				return
@@ -81,3 +81,25 @@ func TestCheckAuthHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckAuthHeaders_AllowAlternative(t *testing.T) {
+	middleware := NewAuthHeadersMiddleware(&handlers.BaseHandler{}, []string{"Header1", "Header2"}).AllowAlternative("X-Api-Key")
+	testHandler := middleware.CheckAuthHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Api-Key", "key")
+	recorder := httptest.NewRecorder()
+	testHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status %d with alternative header, got %d", http.StatusOK, recorder.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder = httptest.NewRecorder()
+	testHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d without any header, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
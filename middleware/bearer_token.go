@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/handlers"
+	"net/http"
+	"strings"
+)
+
+// BearerTokenMiddleware protects endpoints that are not called with Ecoflow keys, e.g. /metrics, with a static token.
+type BearerTokenMiddleware struct {
+	*handlers.BaseHandler
+	token string
+}
+
+func NewBearerTokenMiddleware(baseHandler *handlers.BaseHandler, token string) *BearerTokenMiddleware {
+	return &BearerTokenMiddleware{BaseHandler: baseHandler, token: token}
+}
+
+func (b *BearerTokenMiddleware) CheckToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get(constants.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(b.token)) != 1 {
+			b.RespondWithError(w, r, http.StatusUnauthorized, constants.ErrInvalidAuthHeader, "Bearer token is missing or invalid", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
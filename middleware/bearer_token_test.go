@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"go-ecoflow-api-server/handlers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckToken(t *testing.T) {
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "valid token", authorization: "Bearer secret", expectedStatus: http.StatusOK},
+		{name: "invalid token", authorization: "Bearer other", expectedStatus: http.StatusUnauthorized},
+		{name: "missing scheme", authorization: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "no header", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testHandler := NewBearerTokenMiddleware(&handlers.BaseHandler{}, "secret").CheckToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			testHandler.ServeHTTP(recorder, req)
+			if recorder.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, recorder.Code)
+			}
+		})
+	}
+}
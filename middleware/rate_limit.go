@@ -21,7 +21,8 @@ func NewRateLimitMiddleware(b *handlers.BaseHandler, limit int, windowLength tim
 func (rl *RateLimitMiddleware) RateLimit() func(next http.Handler) http.Handler {
 	rateLimiter := httprate.Limit(rl.limit, rl.windowLength,
 		httprate.WithLimitHandler(func(w http.ResponseWriter, r *http.Request) {
-			rl.RespondWithError(w, http.StatusTooManyRequests, constants.ErrRateLimitExceeded, "Rate limit exceeded", map[string]string{
+			rl.Metrics.RateLimited()
+			rl.RespondWithError(w, r, http.StatusTooManyRequests, constants.ErrRateLimitExceeded, "Rate limit exceeded", map[string]string{
 				"url":         r.URL.String(),
 				"method":      r.Method,
 				"retry_after": w.Header().Get("Retry-After"),
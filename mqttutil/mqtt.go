@@ -0,0 +1,24 @@
+package mqttutil
+
+import (
+	"context"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"time"
+)
+
+const (
+	// MaxRetryDelay limits the delay between the connection attempts to a broker
+	MaxRetryDelay = 5 * time.Minute
+	// DisconnectQuiesce is the time in milliseconds given to pending work when disconnecting from a broker
+	DisconnectQuiesce = 250
+)
+
+// Wait waits until the MQTT operation completed or ctx is cancelled.
+func Wait(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
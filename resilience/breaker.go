@@ -0,0 +1,143 @@
+package resilience
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState string
+
+const (
+	StateClosed   BreakerState = "closed"    // calls pass through
+	StateOpen     BreakerState = "open"      // calls are rejected until the open duration is over
+	StateHalfOpen BreakerState = "half_open" // a single probe call is allowed to test the upstream
+)
+
+// OpenError is returned when a call is rejected because the circuit breaker of the account is open.
+type OpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("ecoflow api is unavailable, circuit breaker is open, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// BreakerStatus is a snapshot of a circuit breaker.
+type BreakerStatus struct {
+	Account             string       `json:"account"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	RetryAfter          string       `json:"retry_after,omitempty"`
+}
+
+// breaker opens after threshold consecutive failures. After openDuration it lets one probe call through,
+// a successful probe closes the breaker, a failed one opens it again.
+type breaker struct {
+	mu            sync.Mutex
+	threshold     int
+	openDuration  time.Duration
+	state         BreakerState
+	failures      int
+	openedAt      time.Time
+	probeInFlight bool
+	// calls is the number of calls in progress, lastCall is the time the last one was over
+	calls    int
+	lastCall time.Time
+}
+
+func newBreaker(threshold int, openDuration time.Duration) *breaker {
+	return &breaker{threshold: threshold, openDuration: openDuration, state: StateClosed}
+}
+
+// allow reports whether a call may be executed. It returns an *OpenError when the call is rejected.
+func (b *breaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if wait := b.openedAt.Add(b.openDuration).Sub(now); wait > 0 {
+			return &OpenError{RetryAfter: wait}
+		}
+		b.state = StateHalfOpen
+		b.probeInFlight = true
+		return nil
+	case StateHalfOpen:
+		if b.probeInFlight {
+			return &OpenError{RetryAfter: time.Second}
+		}
+		b.probeInFlight = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of a call. Only upstream failures count, see Guard.
+func (b *breaker) record(now time.Time, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probeInFlight = false
+	if !failed {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = now
+	}
+}
+
+// begin counts a call in progress, the breaker is not idle until done is called.
+func (b *breaker) begin() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls++
+}
+
+// done counts the end of a call that was started with begin.
+func (b *breaker) done(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls--
+	b.lastCall = now
+}
+
+// idle reports whether the breaker is closed and had no calls for longer than the open duration. Such a breaker can be
+// dropped, a new one is in the same state.
+func (b *breaker) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == StateClosed && b.calls == 0 && now.Sub(b.lastCall) > b.openDuration
+}
+
+// release lets another probe through without changing the state, used when a call was abandoned by the caller.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probeInFlight = false
+}
+
+func (b *breaker) status(account string, now time.Time) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{Account: account, State: b.state, ConsecutiveFailures: b.failures}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if b.state == StateOpen {
+		if wait := b.openedAt.Add(b.openDuration).Sub(now); wait > 0 {
+			status.RetryAfter = wait.Round(time.Second).String()
+		}
+	}
+	return status
+}
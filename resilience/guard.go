@@ -0,0 +1,217 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config contains the retry and circuit breaker settings.
+type Config struct {
+	// MaxAttempts is the maximum number of attempts of an idempotent read, including the first one.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, it doubles with every retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// FailureThreshold is the number of consecutive upstream failures that open the circuit breaker of an account.
+	FailureThreshold int
+	// OpenDuration is how long an open circuit breaker rejects calls before it lets a probe call through.
+	OpenDuration time.Duration
+}
+
+// Guard protects calls to the Ecoflow cloud. Idempotent reads are retried with jittered exponential backoff, writes are
+// retried only if the connection could not be established, so the command never reached Ecoflow. Each account has its
+// own circuit breaker which opens after repeated upstream failures and rejects calls with an *OpenError.
+type Guard struct {
+	cfg Config
+	// Transient decides whether an error is a temporary upstream failure: only such errors are retried and counted
+	// by the circuit breaker. Errors caused by the request itself (bad keys, invalid parameters) are not.
+	Transient func(error) bool
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+	prunedAt time.Time
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+func NewGuard(cfg Config) *Guard {
+	return &Guard{
+		cfg:       cfg,
+		Transient: IsTransient,
+		breakers:  make(map[string]*breaker),
+		now:       time.Now,
+		sleep:     sleepContext,
+	}
+}
+
+// Read executes an idempotent call for the account.
+func (g *Guard) Read(ctx context.Context, account string, call func(ctx context.Context) error) error {
+	return g.execute(ctx, account, call, g.Transient)
+}
+
+// Write executes a call that changes the device state. It is retried only when the connection to Ecoflow failed.
+func (g *Guard) Write(ctx context.Context, account string, call func(ctx context.Context) error) error {
+	return g.execute(ctx, account, call, isConnectError)
+}
+
+// Status returns the state of the circuit breakers of the accounts that called Ecoflow recently, sorted by account.
+func (g *Guard) Status() []BreakerStatus {
+	now := g.now()
+	g.mu.Lock()
+	g.prune(now)
+	breakers := make(map[string]*breaker, len(g.breakers))
+	accounts := make([]string, 0, len(g.breakers))
+	for account, b := range g.breakers {
+		breakers[account] = b
+		accounts = append(accounts, account)
+	}
+	g.mu.Unlock()
+	sort.Strings(accounts)
+
+	statuses := make([]BreakerStatus, 0, len(accounts))
+	for _, account := range accounts {
+		statuses = append(statuses, breakers[account].status(account, now))
+	}
+	return statuses
+}
+
+// AccountStatus returns the state of the circuit breaker of the account, false if the account didn't call Ecoflow
+// recently.
+func (g *Guard) AccountStatus(account string) (BreakerStatus, bool) {
+	now := g.now()
+	g.mu.Lock()
+	b, ok := g.breakers[account]
+	g.mu.Unlock()
+	if !ok || b.idle(now) {
+		return BreakerStatus{}, false
+	}
+	return b.status(account, now), true
+}
+
+func (g *Guard) execute(ctx context.Context, account string, call func(ctx context.Context) error, retryable func(error) bool) error {
+	b := g.breaker(account)
+	defer func() { b.done(g.now()) }()
+	attempts := max(g.cfg.MaxAttempts, 1)
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if sleepErr := g.sleep(ctx, g.backoff(attempt)); sleepErr != nil {
+				return err // the caller gave up, report the last upstream error
+			}
+		}
+		if allowErr := b.allow(g.now()); allowErr != nil {
+			if err != nil {
+				return err
+			}
+			return allowErr
+		}
+
+		err = call(ctx)
+		if ctx.Err() != nil {
+			// the caller gave up, this says nothing about the upstream health
+			b.release()
+			return err
+		}
+		// errors caused by the request itself (invalid keys or parameters) mean that Ecoflow is reachable
+		b.record(g.now(), err != nil && g.Transient(err))
+		if err == nil || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// breaker returns the circuit breaker of the account for a call, the caller must call done when the call is over.
+func (g *Guard) breaker(account string) *breaker {
+	now := g.now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.prunedAt) >= g.cfg.OpenDuration {
+		g.prune(now)
+	}
+	b, ok := g.breakers[account]
+	if !ok {
+		b = newBreaker(g.cfg.FailureThreshold, g.cfg.OpenDuration)
+		g.breakers[account] = b
+	}
+	b.begin()
+	return b
+}
+
+// prune removes the breakers that are closed and idle for longer than the open duration, so the breakers of accounts
+// that stopped calling Ecoflow, e.g. the Ecoflow keys of former clients, are not kept forever. The caller must hold
+// the lock.
+func (g *Guard) prune(now time.Time) {
+	for account, b := range g.breakers {
+		if b.idle(now) {
+			delete(g.breakers, account)
+		}
+	}
+	g.prunedAt = now
+}
+
+// backoff returns a random delay between 0 and BaseDelay * 2^(attempt-1), capped at MaxDelay ("full jitter").
+func (g *Guard) backoff(attempt int) time.Duration {
+	delay := g.cfg.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > g.cfg.MaxDelay {
+		delay = g.cfg.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// go-ecoflow reports non-200 responses as "response status is failed|url=..., statusCode=503 Service Unavailable"
+var statusCodePattern = regexp.MustCompile(`statusCode=(\d{3})`)
+
+// UpstreamStatusCode extracts the HTTP status code of a failed Ecoflow response from a go-ecoflow error.
+// It returns 0 if the error does not contain a status code.
+func UpstreamStatusCode(err error) int {
+	match := statusCodePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	code, _ := strconv.Atoi(match[1])
+	return code
+}
+
+// IsTransient reports whether err is a temporary failure of the Ecoflow cloud: network errors,
+// HTTP 429 and 5xx responses.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	code := UpstreamStatusCode(err)
+	return code == 429 || code >= 500
+}
+
+// isConnectError reports whether the connection to Ecoflow could not be established, i.e. the request was not sent.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
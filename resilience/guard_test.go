@@ -0,0 +1,201 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	errUnavailable = errors.New("response status is failed|url=https://api.ecoflow.com, statusCode=503 Service Unavailable")
+	errBadRequest  = errors.New("response status is failed|url=https://api.ecoflow.com, statusCode=400 Bad Request")
+	errDial        = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	errReset       = &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+)
+
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) sleep(_ context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func newTestGuard(cfg Config) (*Guard, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	g := NewGuard(cfg)
+	g.now = func() time.Time { return clock.now }
+	g.sleep = clock.sleep
+	return g, clock
+}
+
+// failing returns a call that fails with the given errors in order and then succeeds.
+func failing(calls *int, errs ...error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestGuard_Read(t *testing.T) {
+	tests := []struct {
+		name          string
+		errs          []error
+		expectedErr   error
+		expectedCalls int
+	}{
+		{name: "success", expectedCalls: 1},
+		{name: "transient failure is retried", errs: []error{errUnavailable, errReset}, expectedCalls: 3},
+		{name: "gives up after max attempts", errs: []error{errUnavailable, errUnavailable, errUnavailable}, expectedErr: errUnavailable, expectedCalls: 3},
+		{name: "client error is not retried", errs: []error{errBadRequest}, expectedErr: errBadRequest, expectedCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, clock := newTestGuard(Config{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, FailureThreshold: 10, OpenDuration: time.Minute})
+
+			calls := 0
+			err := g.Read(context.Background(), "account", failing(&calls, tt.errs...))
+
+			assert.Equal(t, tt.expectedErr, err)
+			assert.Equal(t, tt.expectedCalls, calls)
+			require.Len(t, clock.sleeps, tt.expectedCalls-1)
+			for i, d := range clock.sleeps {
+				assert.Less(t, d, 100*time.Millisecond<<i, "backoff must be jittered below the exponential cap")
+			}
+		})
+	}
+}
+
+func TestGuard_Write(t *testing.T) {
+	tests := []struct {
+		name          string
+		errs          []error
+		expectedErr   error
+		expectedCalls int
+	}{
+		{name: "upstream failure is not retried", errs: []error{errUnavailable}, expectedErr: errUnavailable, expectedCalls: 1},
+		{name: "broken connection is not retried", errs: []error{errReset}, expectedErr: errReset, expectedCalls: 1},
+		{name: "dial failure is retried", errs: []error{errDial}, expectedCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, _ := newTestGuard(Config{MaxAttempts: 3, FailureThreshold: 10, OpenDuration: time.Minute})
+
+			calls := 0
+			err := g.Write(context.Background(), "account", failing(&calls, tt.errs...))
+
+			assert.Equal(t, tt.expectedErr, err)
+			assert.Equal(t, tt.expectedCalls, calls)
+		})
+	}
+}
+
+func TestGuard_CircuitBreaker(t *testing.T) {
+	g, clock := newTestGuard(Config{MaxAttempts: 1, FailureThreshold: 2, OpenDuration: 30 * time.Second})
+	ctx := context.Background()
+	fail := func(ctx context.Context) error { return errUnavailable }
+	succeed := func(ctx context.Context) error { return nil }
+
+	// client errors do not count
+	assert.Equal(t, errBadRequest, g.Read(ctx, "a", func(ctx context.Context) error { return errBadRequest }))
+	assert.Equal(t, StateClosed, g.Status()[0].State)
+
+	assert.Equal(t, errUnavailable, g.Read(ctx, "a", fail))
+	assert.Equal(t, errUnavailable, g.Write(ctx, "a", fail))
+
+	var openErr *OpenError
+	err := g.Read(ctx, "a", succeed)
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, 30*time.Second, openErr.RetryAfter)
+	assert.NoError(t, g.Read(ctx, "b", succeed), "breakers are per account")
+
+	status := g.Status()
+	require.Len(t, status, 2)
+	assert.Equal(t, "a", status[0].Account)
+	assert.Equal(t, StateOpen, status[0].State)
+	assert.Equal(t, 2, status[0].ConsecutiveFailures)
+	assert.Equal(t, "30s", status[0].RetryAfter)
+	assert.Equal(t, StateClosed, status[1].State)
+
+	// a failed probe opens the breaker again
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.Equal(t, errUnavailable, g.Read(ctx, "a", fail))
+	require.ErrorAs(t, g.Read(ctx, "a", succeed), &openErr)
+
+	// a successful probe closes it
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.NoError(t, g.Read(ctx, "a", succeed))
+	assert.Equal(t, StateClosed, g.Status()[0].State)
+	assert.Zero(t, g.Status()[0].ConsecutiveFailures)
+}
+
+func TestGuard_PrunesIdleBreakers(t *testing.T) {
+	g, clock := newTestGuard(Config{MaxAttempts: 1, FailureThreshold: 1, OpenDuration: 30 * time.Second})
+	ctx := context.Background()
+	succeed := func(ctx context.Context) error { return nil }
+
+	assert.NoError(t, g.Read(ctx, "a", succeed))
+	assert.Equal(t, errUnavailable, g.Read(ctx, "b", func(ctx context.Context) error { return errUnavailable }))
+	assert.Len(t, g.Status(), 2)
+
+	clock.now = clock.now.Add(time.Minute)
+	assert.NoError(t, g.Read(ctx, "c", func(ctx context.Context) error {
+		clock.now = clock.now.Add(time.Minute)
+		assert.Len(t, g.Status(), 2, "breakers of calls in progress are kept")
+		return nil
+	}))
+	status := g.Status()
+	require.Len(t, status, 2, "idle closed breakers are removed")
+	assert.Equal(t, "b", status[0].Account)
+	assert.Equal(t, StateOpen, status[0].State, "open breakers are kept")
+	assert.Equal(t, "c", status[1].Account)
+
+	clock.now = clock.now.Add(time.Minute)
+	assert.NoError(t, g.Read(ctx, "b", succeed))
+	assert.Equal(t, []BreakerStatus{{Account: "b", State: StateClosed}}, g.Status())
+	current, ok := g.AccountStatus("b")
+	assert.True(t, ok)
+	assert.Equal(t, BreakerStatus{Account: "b", State: StateClosed}, current)
+	clock.now = clock.now.Add(time.Minute)
+	_, ok = g.AccountStatus("b")
+	assert.False(t, ok, "idle closed breakers are not reported")
+}
+
+func TestGuard_CancelledCallDoesNotCount(t *testing.T) {
+	g, _ := newTestGuard(Config{MaxAttempts: 3, FailureThreshold: 1, OpenDuration: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := g.Read(ctx, "a", func(ctx context.Context) error {
+		calls++
+		cancel()
+		return context.Canceled
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls, "cancelled calls must not be retried")
+	assert.Equal(t, StateClosed, g.Status()[0].State)
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, IsTransient(errUnavailable))
+	assert.True(t, IsTransient(errors.New("response status is failed|url=x, statusCode=429 Too Many Requests")))
+	assert.True(t, IsTransient(errReset))
+	assert.True(t, IsTransient(context.DeadlineExceeded))
+	assert.False(t, IsTransient(errBadRequest))
+	assert.False(t, IsTransient(context.Canceled))
+	assert.False(t, IsTransient(errors.New("can't get device list, error code: 8521, error message: signature is wrong")))
+}
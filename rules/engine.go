@@ -0,0 +1,173 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"go-ecoflow-api-server/ingest"
+	"go-ecoflow-api-server/service"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeviceSource provides the parameters of the devices of the vault accounts, see ingest.Store. Devices are identified
+// by the identity of their account, see service.VaultIdentity.
+type DeviceSource interface {
+	Fresh(account, sn string) (map[string]interface{}, time.Time, bool)
+}
+
+// Engine evaluates the enabled rules of the store over the parameters of their devices and sends the commands of the
+// rules that fire.
+type Engine struct {
+	store    *Store
+	devices  DeviceSource
+	execute  service.CommandExecutor
+	interval time.Duration
+	logger   *slog.Logger
+	now      func() time.Time
+
+	running sync.WaitGroup
+}
+
+func NewEngine(store *Store, devices DeviceSource, execute service.CommandExecutor, interval time.Duration, logger *slog.Logger) *Engine {
+	return &Engine{store: store, devices: devices, execute: execute, interval: interval, logger: logger, now: time.Now}
+}
+
+// Run evaluates the rules every interval until ctx is cancelled. Commands that were started are completed before it
+// returns.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.evaluate(ctx)
+		case <-ctx.Done():
+			e.running.Wait()
+			return
+		}
+	}
+}
+
+// evaluate evaluates the rules once. An armed rule fires when its condition is true and the cooldown is over, a
+// triggered rule is armed again when its clear condition is true.
+func (e *Engine) evaluate(ctx context.Context) {
+	now := e.now()
+	for _, rule := range e.store.enabled() {
+		condition, clear, err := rule.parse()
+		if err != nil {
+			// rules are validated before they are stored
+			continue
+		}
+		evaluation := Evaluation{RuleID: rule.ID, Time: now.UTC()}
+		var missing error
+		evaluation.Values, missing = e.values(rule, condition, clear)
+		state := rule.State
+
+		switch {
+		case missing != nil:
+			evaluation.Result = ResultUnavailable
+			evaluation.Message = missing.Error()
+		case state.Triggered:
+			if cleared, _ := clear.Evaluate(evaluation.Values); cleared {
+				state.Triggered = false
+				evaluation.Result = ResultCleared
+			}
+		default:
+			fired, _ := condition.Evaluate(evaluation.Values)
+			if !fired {
+				break
+			}
+			cooldown := time.Duration(rule.CooldownSeconds) * time.Second
+			if state.LastFiredAt != nil && now.Sub(*state.LastFiredAt) < cooldown {
+				evaluation.Result = ResultCooldown
+				evaluation.Message = fmt.Sprintf("the rule fired at %s", state.LastFiredAt.Format(time.RFC3339))
+				break
+			}
+			firedAt := now.UTC()
+			state.Triggered = true
+			state.LastFiredAt = &firedAt
+			evaluation.Result = ResultFired
+			if rule.DryRun {
+				evaluation.Result = ResultDryRun
+				evaluation.Message = fmt.Sprintf("%s would be sent to %s", rule.Action.Command, strings.Join(rule.Action.SerialNumbers, ", "))
+			}
+		}
+
+		if evaluation.Result == state.LastResult && evaluation.Result != ResultFired && evaluation.Result != ResultDryRun {
+			// unavailable parameters and the cooldown are logged once until the result changes
+			continue
+		}
+		if evaluation.Result == "" {
+			// nothing happened, the next unavailable parameters or cooldown are logged again
+			if state.LastResult == ResultUnavailable || state.LastResult == ResultCooldown {
+				state.LastResult = ""
+				e.setState(rule, state, nil)
+			}
+			continue
+		}
+		state.LastResult = evaluation.Result
+		if evaluation.Result != ResultFired {
+			e.setState(rule, state, &evaluation)
+			continue
+		}
+
+		// the rule is triggered before its command is sent, so it doesn't fire again meanwhile
+		e.setState(rule, state, nil)
+		e.logger.Info("Rule fired", "rule", rule.ID, "command", rule.Action.Command)
+		e.running.Add(1)
+		go func(rule Rule, evaluation Evaluation) {
+			defer e.running.Done()
+			e.addEvaluation(e.runCommand(context.WithoutCancel(ctx), rule, evaluation))
+		}(rule, evaluation)
+	}
+}
+
+// values returns the values of the parameters the conditions of the rule reference. It fails if the device or a
+// parameter is not available.
+func (e *Engine) values(rule Rule, condition, clear *Expression) (map[string]float64, error) {
+	params, _, ok := e.devices.Fresh(service.VaultIdentity(rule.Account), rule.SerialNumber)
+	if !ok {
+		return nil, fmt.Errorf("no recent parameters of device %s", rule.SerialNumber)
+	}
+	values := make(map[string]float64)
+	for _, expression := range []*Expression{condition, clear} {
+		for _, param := range expression.Params() {
+			value, ok := ingest.Number(params[param])
+			if !ok {
+				return nil, fmt.Errorf("parameter %s of device %s is not available", param, rule.SerialNumber)
+			}
+			values[param] = value
+		}
+	}
+	return values, nil
+}
+
+// runCommand sends the command of the rule to its devices one after another.
+func (e *Engine) runCommand(ctx context.Context, rule Rule, evaluation Evaluation) Evaluation {
+	for _, sn := range rule.Action.SerialNumbers {
+		status, body := e.execute(ctx, rule.Account, sn, rule.Action.Command, rule.Action.Payload)
+		evaluation.Devices = append(evaluation.Devices, DeviceRun{SerialNumber: sn, Status: status, Body: body})
+		if status >= http.StatusBadRequest {
+			evaluation.Result = ResultFailed
+		}
+	}
+	if evaluation.Result == ResultFailed {
+		e.logger.Warn("Rule command failed", "rule", rule.ID, "command", rule.Action.Command)
+	}
+	return evaluation
+}
+
+func (e *Engine) setState(rule Rule, state State, evaluation *Evaluation) {
+	if err := e.store.setState(rule, state, evaluation); err != nil {
+		e.logger.Warn("Failed to save the rule", "rule", rule.ID, "error", err)
+	}
+}
+
+func (e *Engine) addEvaluation(evaluation Evaluation) {
+	if err := e.store.addEvaluation(evaluation); err != nil {
+		e.logger.Warn("Failed to save the rule evaluation", "rule", evaluation.RuleID, "error", err)
+	}
+}
@@ -0,0 +1,230 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"go-ecoflow-api-server/service"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// devices is a DeviceSource with the parameters of the devices of the home account.
+type devices map[string]map[string]interface{}
+
+func (d devices) Fresh(account, sn string) (map[string]interface{}, time.Time, bool) {
+	params, ok := d[sn]
+	if account != service.VaultIdentity("home") || !ok {
+		return nil, time.Time{}, false
+	}
+	return params, start, true
+}
+
+// recorder is a service.CommandExecutor that records the commands, the commands sent to failing devices fail.
+type recorder struct {
+	mu       sync.Mutex
+	commands []string
+	failing  map[string]bool
+}
+
+func (r *recorder) execute(_ context.Context, account, sn, command string, payload json.RawMessage) (int, json.RawMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, account+" "+sn+" "+command+" "+string(payload))
+	if r.failing[sn] {
+		return http.StatusConflict, json.RawMessage(`{"success":false}`)
+	}
+	return http.StatusOK, json.RawMessage(`{"success":true}`)
+}
+
+func newTestEngine(t *testing.T, path string, now *time.Time, params devices) (*Engine, *Store, *recorder) {
+	t.Helper()
+	store, err := Open(path, 10)
+	require.NoError(t, err)
+	store.now = func() time.Time { return *now }
+	commands := &recorder{failing: map[string]bool{"R601ZEB4ZEAL0999": true}}
+	engine := NewEngine(store, params, commands.execute, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	engine.now = func() time.Time { return *now }
+	return engine, store, commands
+}
+
+func lowBattery(serialNumbers ...string) Rule {
+	return Rule{
+		Account:         "home",
+		Name:            "AC off on low battery",
+		SerialNumber:    "R601ZEB4ZEAL0528",
+		Condition:       "bms_bmsStatus.soc < 20",
+		Clear:           "bms_bmsStatus.soc > 30",
+		CooldownSeconds: 600,
+		Action: Action{
+			SerialNumbers: serialNumbers,
+			Command:       "out/ac",
+			Payload:       json.RawMessage(`{"state":"off"}`),
+		},
+		Enabled: true,
+	}
+}
+
+// evaluateAt evaluates the rules at the time with the state of charge and waits for the commands to complete.
+func evaluateAt(engine *Engine, now *time.Time, t time.Time, params devices, soc interface{}) {
+	*now = t
+	params["R601ZEB4ZEAL0528"]["bms_bmsStatus.soc"] = soc
+	engine.evaluate(context.Background())
+	engine.running.Wait()
+}
+
+func results(t *testing.T, store *Store, id string) []string {
+	t.Helper()
+	evaluations, err := store.Evaluations("home", id)
+	require.NoError(t, err)
+	results := make([]string, 0, len(evaluations))
+	for _, evaluation := range evaluations {
+		results = append(results, evaluation.Result)
+	}
+	return results
+}
+
+func TestStore_Rules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	now := start
+	_, store, _ := newTestEngine(t, path, &now, devices{})
+
+	created, err := store.Create(lowBattery("R601ZEB4ZEAL0528"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	_, err = store.Create(Rule{Account: "office", Condition: "pd.soc > 90"})
+	require.NoError(t, err)
+
+	_, err = store.Create(Rule{Account: "home", Condition: "pd.soc"})
+	assert.Error(t, err)
+	_, err = store.Create(Rule{Account: "home", Condition: "pd.soc < 20", Clear: "pd.soc >"})
+	assert.Error(t, err)
+	_, err = store.Create(Rule{Account: "home", Condition: "pd.soc < 20", CooldownSeconds: -1})
+	assert.Error(t, err)
+
+	_, err = store.Get("office", created.ID)
+	assert.ErrorIs(t, err, ErrRuleNotFound, "rules of other accounts are not found")
+
+	now = start.Add(time.Hour)
+	update := lowBattery("R601ZEB4ZEAL0528")
+	update.Account = "office"
+	update.DryRun = true
+	updated, err := store.Update("home", created.ID, update)
+	require.NoError(t, err)
+	assert.Equal(t, "home", updated.Account, "the account is kept")
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+	assert.Equal(t, now, updated.UpdatedAt)
+
+	reopened, err := Open(path, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{updated}, reopened.List("home"), "the rules are persisted")
+
+	require.NoError(t, store.Delete("home", created.ID))
+	assert.ErrorIs(t, store.Delete("home", created.ID), ErrRuleNotFound)
+	assert.Empty(t, store.List("home"))
+	assert.Len(t, store.List("office"), 1)
+}
+
+func TestEngine_HysteresisAndCooldown(t *testing.T) {
+	now := start
+	params := devices{"R601ZEB4ZEAL0528": {}}
+	engine, store, commands := newTestEngine(t, filepath.Join(t.TempDir(), "rules.json"), &now, params)
+	rule, err := store.Create(lowBattery("R601ZEB4ZEAL0528", "R601ZEB4ZEAL0999"))
+	require.NoError(t, err)
+	disabled := lowBattery("R601ZEB4ZEAL0528")
+	disabled.Enabled = false
+	_, err = store.Create(disabled)
+	require.NoError(t, err)
+
+	evaluateAt(engine, &now, start, params, 50)
+	assert.Empty(t, commands.commands)
+
+	evaluateAt(engine, &now, start.Add(time.Minute), params, 15)
+	assert.Equal(t, []string{
+		`home R601ZEB4ZEAL0528 out/ac {"state":"off"}`,
+		`home R601ZEB4ZEAL0999 out/ac {"state":"off"}`,
+	}, commands.commands)
+
+	evaluateAt(engine, &now, start.Add(2*time.Minute), params, 18)
+	evaluateAt(engine, &now, start.Add(3*time.Minute), params, 25)
+	assert.Len(t, commands.commands, 2, "the rule fires once until it is cleared")
+
+	evaluateAt(engine, &now, start.Add(4*time.Minute), params, 35.5)
+	evaluateAt(engine, &now, start.Add(5*time.Minute), params, 10)
+	evaluateAt(engine, &now, start.Add(6*time.Minute), params, 10)
+	assert.Len(t, commands.commands, 2, "the rule doesn't fire within the cooldown")
+
+	evaluateAt(engine, &now, start.Add(11*time.Minute), params, 10)
+	assert.Len(t, commands.commands, 4, "the rule fires after the cooldown")
+
+	assert.Equal(t, []string{ResultFailed, ResultCooldown, ResultCleared, ResultFailed}, results(t, store, rule.ID))
+	evaluations, err := store.Evaluations("home", rule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"bms_bmsStatus.soc": 10}, evaluations[0].Values)
+	assert.Equal(t, []DeviceRun{
+		{SerialNumber: "R601ZEB4ZEAL0528", Status: http.StatusOK, Body: json.RawMessage(`{"success":true}`)},
+		{SerialNumber: "R601ZEB4ZEAL0999", Status: http.StatusConflict, Body: json.RawMessage(`{"success":false}`)},
+	}, evaluations[0].Devices)
+
+	rule, err = store.Get("home", rule.ID)
+	require.NoError(t, err)
+	assert.True(t, rule.Triggered)
+	assert.Equal(t, start.Add(11*time.Minute), *rule.LastFiredAt)
+}
+
+func TestEngine_DryRun(t *testing.T) {
+	now := start
+	params := devices{"R601ZEB4ZEAL0528": {}}
+	engine, store, commands := newTestEngine(t, filepath.Join(t.TempDir(), "rules.json"), &now, params)
+	dryRun := lowBattery("R601ZEB4ZEAL0528")
+	dryRun.Clear = ""
+	dryRun.CooldownSeconds = 0
+	dryRun.DryRun = true
+	rule, err := store.Create(dryRun)
+	require.NoError(t, err)
+
+	evaluateAt(engine, &now, start, params, 15)
+	evaluateAt(engine, &now, start.Add(time.Minute), params, 20)
+	evaluateAt(engine, &now, start.Add(2*time.Minute), params, 19)
+
+	assert.Empty(t, commands.commands, "dry-run rules don't send commands")
+	assert.Equal(t, []string{ResultDryRun, ResultCleared, ResultDryRun}, results(t, store, rule.ID), "cleared by the negated condition")
+}
+
+func TestEngine_UnavailableParameters(t *testing.T) {
+	now := start
+	params := devices{"R601ZEB4ZEAL0528": {}}
+	engine, store, commands := newTestEngine(t, filepath.Join(t.TempDir(), "rules.json"), &now, params)
+	rule, err := store.Create(lowBattery("R601ZEB4ZEAL0528"))
+	require.NoError(t, err)
+
+	evaluateAt(engine, &now, start, params, "unknown")
+	evaluateAt(engine, &now, start.Add(time.Minute), params, nil)
+	evaluateAt(engine, &now, start.Add(2*time.Minute), params, 50)
+	evaluateAt(engine, &now, start.Add(3*time.Minute), params, nil)
+
+	assert.Empty(t, commands.commands)
+	assert.Equal(t, []string{ResultUnavailable, ResultUnavailable}, results(t, store, rule.ID), "logged once until the parameters are available again")
+	evaluations, err := store.Evaluations("home", rule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "parameter bms_bmsStatus.soc of device R601ZEB4ZEAL0528 is not available", evaluations[0].Message)
+
+	// the rule is armed again when it is updated
+	evaluateAt(engine, &now, start.Add(4*time.Minute), params, 10)
+	require.Len(t, commands.commands, 1)
+	_, err = store.Update("home", rule.ID, lowBattery("R601ZEB4ZEAL0528"))
+	require.NoError(t, err)
+	evaluateAt(engine, &now, start.Add(5*time.Minute), params, 10)
+	assert.Len(t, commands.commands, 1, "the cooldown still applies")
+	evaluateAt(engine, &now, start.Add(15*time.Minute), params, 10)
+	assert.Len(t, commands.commands, 2)
+}
@@ -0,0 +1,386 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a parsed condition over the parameters of a device, e.g. "bms_bmsStatus.soc < 20 && inv.outputWatts > 0".
+//
+// Parameters are referenced by their names, names that are not identifiers are quoted, e.g. "20_1.pv1InputWatts".
+// Numbers and parameters are combined with + - * / and compared with < <= > >= == !=, comparisons are combined with
+// && || ! and parentheses.
+type Expression struct {
+	source string
+	root   node
+	params []string
+}
+
+// ParseExpression parses a condition, it must evaluate to true or false.
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, params: make(map[string]bool)}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+	if !root.boolean() {
+		return nil, fmt.Errorf("expression %q must be a comparison, e.g. pd.soc < 20", source)
+	}
+
+	params := make([]string, 0, len(p.params))
+	for param := range p.params {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	return &Expression{source: source, root: root, params: params}, nil
+}
+
+// Params returns the parameters the expression references, sorted by name.
+func (e *Expression) Params() []string {
+	return e.params
+}
+
+// Evaluate evaluates the expression with the values of the parameters. It fails if a parameter is missing.
+func (e *Expression) Evaluate(values map[string]float64) (bool, error) {
+	for _, param := range e.params {
+		if _, ok := values[param]; !ok {
+			return false, fmt.Errorf("parameter %s is not available", param)
+		}
+	}
+	return e.root.eval(values) != 0, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// node is a node of the syntax tree. Boolean nodes evaluate to 1 or 0.
+type node interface {
+	eval(values map[string]float64) float64
+	boolean() bool
+}
+
+type number float64
+
+func (n number) eval(map[string]float64) float64 { return float64(n) }
+func (n number) boolean() bool                   { return false }
+
+type param string
+
+func (p param) eval(values map[string]float64) float64 { return values[string(p)] }
+func (p param) boolean() bool                          { return false }
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (u unary) eval(values map[string]float64) float64 {
+	v := u.operand.eval(values)
+	if u.op == "!" {
+		return toFloat(v == 0)
+	}
+	return -v
+}
+
+func (u unary) boolean() bool { return u.op == "!" }
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (b binary) eval(values map[string]float64) float64 {
+	// && and || short-circuit
+	switch b.op {
+	case "&&":
+		return toFloat(b.left.eval(values) != 0 && b.right.eval(values) != 0)
+	case "||":
+		return toFloat(b.left.eval(values) != 0 || b.right.eval(values) != 0)
+	}
+
+	l, r := b.left.eval(values), b.right.eval(values)
+	switch b.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		if r == 0 {
+			return math.NaN()
+		}
+		return l / r
+	case "<":
+		return toFloat(l < r)
+	case "<=":
+		return toFloat(l <= r)
+	case ">":
+		return toFloat(l > r)
+	case ">=":
+		return toFloat(l >= r)
+	case "==":
+		return toFloat(l == r)
+	case "!=":
+		return toFloat(l != r)
+	}
+	return math.NaN()
+}
+
+func (b binary) boolean() bool {
+	switch b.op {
+	case "+", "-", "*", "/":
+		return false
+	}
+	return true
+}
+
+func toFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenParam
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "!", "+", "-", "*", "/", "(", ")"}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			start := i
+			for i < len(source) && (unicode.IsDigit(rune(source[i])) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[start:i], pos: start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(source) && isParamChar(rune(source[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenParam, text: source[start:i], pos: start})
+		case c == '"':
+			end := strings.IndexByte(source[i+1:], '"')
+			if end <= 0 {
+				return nil, fmt.Errorf("unterminated parameter name at position %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenParam, text: source[i+1 : i+1+end], pos: i})
+			i += end + 2
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at position %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEnd, pos: len(source)}), nil
+}
+
+func isParamChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.'
+}
+
+// parser is a recursive descent parser, the precedence from low to high is || && ! comparisons + - * / and unary -.
+type parser struct {
+	tokens []token
+	pos    int
+	params map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseLogical(p.parseAnd, "||")
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseLogical(p.parseNot, "&&")
+}
+
+func (p *parser) parseLogical(operand func() (node, error), op string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept(op); !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if !left.boolean() || !right.boolean() {
+			return nil, fmt.Errorf("operands of %s must be comparisons", op)
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.accept("!"); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if !operand.boolean() {
+			return nil, fmt.Errorf("operand of ! must be a comparison")
+		}
+		return unary{op: "!", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("<", "<=", ">", ">=", "==", "!=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if left.boolean() || right.boolean() {
+		return nil, fmt.Errorf("operands of %s must be numbers or parameters", op)
+	}
+	return binary{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseSum() (node, error) {
+	return p.parseArithmetic(p.parseProduct, "+", "-")
+}
+
+func (p *parser) parseProduct() (node, error) {
+	return p.parseArithmetic(p.parseUnary, "*", "/")
+}
+
+func (p *parser) parseArithmetic(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if left.boolean() || right.boolean() {
+			return nil, fmt.Errorf("operands of %s must be numbers or parameters", op)
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.boolean() {
+			return nil, fmt.Errorf("operand of - must be a number or a parameter")
+		}
+		return unary{op: "-", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return number(value), nil
+	case tokenParam:
+		p.params[t.text] = true
+		return param(t.text), nil
+	case tokenOperator:
+		if t.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("missing ) at position %d", p.peek().pos)
+			}
+			return inner, nil
+		}
+	case tokenEnd:
+		return nil, fmt.Errorf("unexpected end of the expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
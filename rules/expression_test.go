@@ -0,0 +1,78 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpression_Errors(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"pd.soc",
+		"pd.soc +",
+		"pd.soc < 20 &&",
+		"(pd.soc < 20",
+		"pd.soc < 20)",
+		"pd.soc < 20 < 30",
+		"pd.soc && pd.watts",
+		"(pd.soc < 20) + 1 > 0",
+		"!pd.soc",
+		"-(pd.soc < 20)",
+		"pd.soc = 20",
+		`"pd.soc < 20`,
+		"1.2.3 < pd.soc",
+	} {
+		_, err := ParseExpression(source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestExpression_Evaluate(t *testing.T) {
+	values := map[string]float64{
+		"bms_bmsStatus.soc":  15,
+		"inv.outputWatts":    120,
+		"mppt.inWatts":       350,
+		"20_1.pv1InputWatts": 0,
+	}
+
+	tests := []struct {
+		source   string
+		expected bool
+	}{
+		{source: "bms_bmsStatus.soc < 20", expected: true},
+		{source: "bms_bmsStatus.soc >= 20", expected: false},
+		{source: "bms_bmsStatus.soc < 20 && inv.outputWatts > 0", expected: true},
+		{source: "bms_bmsStatus.soc > 20 || mppt.inWatts > 300", expected: true},
+		{source: "!(mppt.inWatts > 300)", expected: false},
+		{source: "mppt.inWatts - inv.outputWatts > 200", expected: true},
+		{source: "mppt.inWatts - inv.outputWatts * 2 > 200", expected: false},
+		{source: "(mppt.inWatts - inv.outputWatts) * 2 > 200", expected: true},
+		{source: "-inv.outputWatts < -100", expected: true},
+		{source: "inv.outputWatts / 0 > 0", expected: false},
+		{source: "inv.outputWatts == 120 && bms_bmsStatus.soc != 120", expected: true},
+		{source: `"20_1.pv1InputWatts" <= 0.5`, expected: true},
+		// && binds stronger than ||
+		{source: "mppt.inWatts > 300 || bms_bmsStatus.soc > 20 && inv.outputWatts > 500", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			expression, err := ParseExpression(tt.source)
+			require.NoError(t, err)
+			result, err := expression.Evaluate(values)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestExpression_Params(t *testing.T) {
+	expression, err := ParseExpression(`pd.soc < 20 && ("20_1.pv1InputWatts" > 0 || pd.soc > 90)`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20_1.pv1InputWatts", "pd.soc"}, expression.Params())
+
+	_, err = expression.Evaluate(map[string]float64{"pd.soc": 10})
+	assert.ErrorContains(t, err, "20_1.pv1InputWatts", "missing parameters are reported")
+}
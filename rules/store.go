@@ -0,0 +1,362 @@
+package rules
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ecoflow-api-server/jsonfile"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ResultFired is logged when the condition became true and the command was sent.
+	ResultFired = "fired"
+	// ResultFailed is logged when the condition became true and the command failed on a device.
+	ResultFailed = "failed"
+	// ResultDryRun is logged when the condition of a dry-run rule became true, the command is not sent.
+	ResultDryRun = "dry_run"
+	// ResultCooldown is logged when the condition became true within the cooldown after the rule fired.
+	ResultCooldown = "cooldown"
+	// ResultCleared is logged when the rule is armed again.
+	ResultCleared = "cleared"
+	// ResultUnavailable is logged when the parameters of the device are not available.
+	ResultUnavailable = "unavailable"
+)
+
+var ErrRuleNotFound = errors.New("rule not found")
+
+// Rule sends a power station command to devices when a condition over the parameters of a device becomes true.
+//
+// A rule fires once when the condition becomes true and is armed again when the clear condition is true, by default
+// when the condition is false. A clear condition with a margin, e.g. bms_bmsStatus.soc > 30 for the condition
+// bms_bmsStatus.soc < 20, keeps a rule from firing repeatedly while the parameter fluctuates around the threshold.
+type Rule struct {
+	ID string `json:"id"`
+	// Account is the vault account the parameters are read and the command is sent for
+	Account string `json:"account"`
+	Name    string `json:"name"`
+	// SerialNumber is the device whose parameters the conditions are evaluated over
+	SerialNumber string `json:"serial_number"`
+	// Condition fires the rule, see Expression
+	Condition string `json:"condition"`
+	// Clear arms the rule again after it fired, !(Condition) if it's empty
+	Clear string `json:"clear,omitempty"`
+	// CooldownSeconds is the minimum time between two firings of the rule
+	CooldownSeconds int    `json:"cooldown_seconds"`
+	Action          Action `json:"action"`
+	// DryRun rules are evaluated and logged but don't send their command
+	DryRun    bool      `json:"dry_run"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	State
+}
+
+// Action is the command a rule sends when it fires.
+type Action struct {
+	SerialNumbers []string `json:"serial_numbers"`
+	// Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac
+	Command string `json:"command"`
+	// Payload is the request body of the command
+	Payload json.RawMessage `json:"payload" swaggertype:"object"`
+}
+
+// State is the evaluation state of a rule, it is kept by the engine and reset when the rule is updated.
+type State struct {
+	// Triggered is true after the rule fired until the clear condition is true
+	Triggered   bool       `json:"triggered"`
+	LastFiredAt *time.Time `json:"last_fired_at,omitempty"`
+	// LastResult is the result of the latest logged evaluation
+	LastResult string `json:"last_result,omitempty"`
+}
+
+// Validate checks the conditions and the cooldown of the rule.
+func (r Rule) Validate() error {
+	if _, _, err := r.parse(); err != nil {
+		return err
+	}
+	if r.CooldownSeconds < 0 {
+		return fmt.Errorf("cooldown_seconds must not be negative")
+	}
+	return nil
+}
+
+// parse returns the condition and the clear condition of the rule.
+func (r Rule) parse() (*Expression, *Expression, error) {
+	condition, err := ParseExpression(r.Condition)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid condition: %w", err)
+	}
+	clear := r.Clear
+	if clear == "" {
+		clear = "!(" + r.Condition + ")"
+	}
+	clearExpr, err := ParseExpression(clear)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid clear condition: %w", err)
+	}
+	return condition, clearExpr, nil
+}
+
+// Evaluation is a logged evaluation of a rule. Evaluations are logged when the rule fires or is armed again, or when
+// the result changes, e.g. when the parameters of the device are not available anymore.
+type Evaluation struct {
+	RuleID string    `json:"rule_id"`
+	Time   time.Time `json:"time"`
+	// Result is fired, failed, dry_run, cooldown, cleared or unavailable
+	Result string `json:"result"`
+	// Values are the values of the parameters the conditions reference
+	Values  map[string]float64 `json:"values,omitempty"`
+	Message string             `json:"message,omitempty"`
+	Devices []DeviceRun        `json:"devices,omitempty"`
+}
+
+// DeviceRun is the response of the command sent to a device.
+type DeviceRun struct {
+	SerialNumber string          `json:"serial_number"`
+	Status       int             `json:"status"`
+	Body         json.RawMessage `json:"body" swaggertype:"object"`
+}
+
+// contents is the structure of the store file.
+type contents struct {
+	Rules       map[string]Rule         `json:"rules"`       // keyed by ID
+	Evaluations map[string][]Evaluation `json:"evaluations"` // keyed by rule ID, oldest first
+}
+
+// Store keeps the rules and their latest evaluations in a JSON file. All methods are safe for concurrent use, mutating
+// methods persist the store before returning.
+type Store struct {
+	path        string
+	historySize int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	contents contents
+}
+
+// Open loads the store from path, a missing file results in an empty store. historySize is the number of evaluations
+// kept per rule.
+func Open(path string, historySize int) (*Store, error) {
+	s := &Store{
+		path:        path,
+		historySize: historySize,
+		now:         time.Now,
+		contents: contents{
+			Rules:       make(map[string]Rule),
+			Evaluations: make(map[string][]Evaluation),
+		},
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read rules file: %w", err)
+	}
+	if err = json.Unmarshal(data, &s.contents); err != nil {
+		return nil, fmt.Errorf("rules file is corrupted: %w", err)
+	}
+	if s.contents.Rules == nil {
+		s.contents.Rules = make(map[string]Rule)
+	}
+	if s.contents.Evaluations == nil {
+		s.contents.Evaluations = make(map[string][]Evaluation)
+	}
+	return s, nil
+}
+
+// List returns the rules of the account, sorted by creation time.
+func (s *Store) List(account string) []Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := make([]Rule, 0)
+	for _, rule := range s.contents.Rules {
+		if rule.Account == account {
+			rules = append(rules, rule)
+		}
+	}
+	sortRules(rules)
+	return rules
+}
+
+// Get returns the rule of the account.
+func (s *Store) Get(account, id string) (Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, exists := s.contents.Rules[id]
+	if !exists || rule.Account != account {
+		return Rule{}, ErrRuleNotFound
+	}
+	return rule, nil
+}
+
+// Create stores a new rule, its ID, timestamps and state are set by the store. The rule is armed.
+func (s *Store) Create(rule Rule) (Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Rule{}, err
+	}
+	now := s.now().UTC()
+	rule.ID = hex.EncodeToString(id)
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	rule.State = State{}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contents.Rules[rule.ID] = rule
+	if err := s.save(); err != nil {
+		delete(s.contents.Rules, rule.ID)
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Update replaces the rule of the account, the ID, account and creation time are kept. The rule is armed again, the
+// cooldown of an earlier firing still applies.
+func (s *Store) Update(account, id string, rule Rule) (Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.contents.Rules[id]
+	if !exists || current.Account != account {
+		return Rule{}, ErrRuleNotFound
+	}
+	rule.ID = current.ID
+	rule.Account = current.Account
+	rule.CreatedAt = current.CreatedAt
+	rule.UpdatedAt = s.now().UTC()
+	rule.State = State{LastFiredAt: current.LastFiredAt}
+
+	s.contents.Rules[id] = rule
+	if err := s.save(); err != nil {
+		s.contents.Rules[id] = current
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Delete deletes the rule of the account and its evaluations.
+func (s *Store) Delete(account, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, exists := s.contents.Rules[id]
+	if !exists || rule.Account != account {
+		return ErrRuleNotFound
+	}
+	evaluations := s.contents.Evaluations[id]
+	delete(s.contents.Rules, id)
+	delete(s.contents.Evaluations, id)
+	if err := s.save(); err != nil {
+		s.contents.Rules[id] = rule
+		s.contents.Evaluations[id] = evaluations
+		return err
+	}
+	return nil
+}
+
+// Evaluations returns the latest logged evaluations of the rule of the account, newest first.
+func (s *Store) Evaluations(account, id string) ([]Evaluation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, exists := s.contents.Rules[id]
+	if !exists || rule.Account != account {
+		return nil, ErrRuleNotFound
+	}
+	stored := s.contents.Evaluations[id]
+	evaluations := make([]Evaluation, len(stored))
+	for i, evaluation := range stored {
+		evaluations[len(stored)-1-i] = evaluation
+	}
+	return evaluations, nil
+}
+
+// enabled returns the enabled rules of all accounts, sorted by creation time.
+func (s *Store) enabled() []Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := make([]Rule, 0, len(s.contents.Rules))
+	for _, rule := range s.contents.Rules {
+		if rule.Enabled {
+			rules = append(rules, rule)
+		}
+	}
+	sortRules(rules)
+	return rules
+}
+
+// setState sets the state of the rule, unless the rule was updated or deleted in the meantime. The evaluation is
+// logged unless it is nil.
+func (s *Store) setState(rule Rule, state State, evaluation *Evaluation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.contents.Rules[rule.ID]
+	if !exists || !current.UpdatedAt.Equal(rule.UpdatedAt) {
+		return nil
+	}
+	current.State = state
+	s.contents.Rules[rule.ID] = current
+	if evaluation != nil {
+		s.appendEvaluation(*evaluation)
+	}
+	return s.save()
+}
+
+// addEvaluation logs the evaluation of a rule, e.g. after its command was sent.
+func (s *Store) addEvaluation(evaluation Evaluation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.contents.Rules[evaluation.RuleID]; !exists {
+		return nil
+	}
+	s.appendEvaluation(evaluation)
+	return s.save()
+}
+
+// appendEvaluation appends the evaluation to the evaluations of its rule, the oldest ones are dropped beyond the
+// history size. The caller must hold the lock.
+func (s *Store) appendEvaluation(evaluation Evaluation) {
+	evaluations := append(s.contents.Evaluations[evaluation.RuleID], evaluation)
+	if len(evaluations) > s.historySize {
+		evaluations = append([]Evaluation(nil), evaluations[len(evaluations)-s.historySize:]...)
+	}
+	s.contents.Evaluations[evaluation.RuleID] = evaluations
+}
+
+// save writes the contents to the store file. The caller must hold the lock.
+func (s *Store) save() error {
+	if err := jsonfile.Write(s.path, s.contents); err != nil {
+		return fmt.Errorf("can't write rules file: %w", err)
+	}
+	return nil
+}
+
+func sortRules(rules []Rule) {
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreatedAt.Equal(rules[j].CreatedAt) {
+			return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+		}
+		return rules[i].ID < rules[j].ID
+	})
+}
@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"0 23 * *",
+		"0 23 * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"30-10 * * * *",
+		"a * * * *",
+		"* * * foo *",
+	} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// Saturday
+	after := time.Date(2025, 3, 1, 22, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr     string
+		loc      *time.Location
+		after    time.Time
+		expected time.Time
+	}{
+		{expr: "* * * * *", loc: time.UTC, after: after, expected: time.Date(2025, 3, 1, 22, 31, 0, 0, time.UTC)},
+		{expr: "0 23 * * *", loc: time.UTC, after: after, expected: time.Date(2025, 3, 1, 23, 0, 0, 0, time.UTC)},
+		{expr: "0 23 * * *", loc: berlin, after: after, expected: time.Date(2025, 3, 2, 22, 0, 0, 0, time.UTC)},
+		{expr: "*/20 6-8 * * *", loc: time.UTC, after: after, expected: time.Date(2025, 3, 2, 6, 0, 0, 0, time.UTC)},
+		{expr: "15,45 * * * *", loc: time.UTC, after: after, expected: time.Date(2025, 3, 1, 22, 45, 0, 0, time.UTC)},
+		{expr: "0 6 * * mon-fri", loc: time.UTC, after: after, expected: time.Date(2025, 3, 3, 6, 0, 0, 0, time.UTC)},
+		{expr: "0 6 * * 7", loc: time.UTC, after: after, expected: time.Date(2025, 3, 2, 6, 0, 0, 0, time.UTC)},
+		{expr: "0 0 1 jan *", loc: time.UTC, after: after, expected: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", loc: time.UTC, after: after, expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either the day of month or the day of week
+		{expr: "0 0 15 * fri", loc: time.UTC, after: after, expected: time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 30 2 *", loc: time.UTC, after: after, expected: time.Time{}},
+		// 02:30 doesn't exist on the day the daylight saving time starts
+		{expr: "30 2 * * *", loc: berlin, after: time.Date(2025, 3, 30, 0, 0, 0, 0, time.UTC), expected: time.Date(2025, 3, 31, 0, 30, 0, 0, time.UTC)},
+		// 02:30 exists twice on the day the daylight saving time ends
+		{expr: "30 2 * * *", loc: berlin, after: time.Date(2025, 10, 26, 0, 30, 0, 0, time.UTC), expected: time.Date(2025, 10, 26, 1, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cron, err := ParseCron(tt.expr)
+			require.NoError(t, err)
+			assert.True(t, tt.expected.Equal(cron.Next(tt.after, tt.loc)), "expected %s, got %s", tt.expected, cron.Next(tt.after, tt.loc))
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package schedule
+
+import (
+	"context"
+	"go-ecoflow-api-server/service"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// checkInterval is the interval at which the scheduler looks for due occurrences.
+	checkInterval = time.Second
+	// missedAfter is the delay after which an occurrence that was not run yet counts as missed, e.g. because the server
+	// was down at its time.
+	missedAfter = time.Minute
+)
+
+// Scheduler runs the due occurrences of the enabled schedules of the store.
+type Scheduler struct {
+	store   *Store
+	execute service.CommandExecutor
+	logger  *slog.Logger
+	now     func() time.Time
+
+	running sync.WaitGroup
+}
+
+func NewScheduler(store *Store, execute service.CommandExecutor, logger *slog.Logger) *Scheduler {
+	return &Scheduler{store: store, execute: execute, logger: logger, now: time.Now}
+}
+
+// Run runs the schedules until ctx is cancelled. Runs that were started are completed before it returns.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		s.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			s.running.Wait()
+			return
+		}
+	}
+}
+
+// check starts the due occurrences of the schedules. Only the latest due occurrence of a schedule is run, the earlier
+// ones are missed. The latest one is missed as well if it is overdue, unless the schedule runs missed occurrences once.
+func (s *Scheduler) check(ctx context.Context) {
+	now := s.now()
+	for _, schedule := range s.store.enabled() {
+		cron, loc, err := schedule.parse()
+		if err != nil {
+			// schedules are validated before they are stored
+			continue
+		}
+		due := 0
+		var latest time.Time
+		for next := cron.Next(schedule.LastScheduledAt, loc); !next.IsZero() && !next.After(now); next = cron.Next(next, loc) {
+			latest = next
+			due++
+		}
+		if due == 0 {
+			continue
+		}
+		if err = s.store.advance(schedule, latest); err != nil {
+			s.logger.Warn("Failed to save the schedule", "schedule", schedule.ID, "error", err)
+		}
+
+		run := Run{ScheduleID: schedule.ID, ScheduledAt: latest.UTC(), StartedAt: now.UTC(), Missed: due - 1}
+		if now.Sub(latest) > missedAfter && schedule.MissedRuns == MissedRunsSkip {
+			run.Result = RunMissed
+			run.Missed = due
+			run.FinishedAt = run.StartedAt
+			s.logger.Warn("Missed scheduled command", "schedule", schedule.ID, "scheduled_at", latest, "missed", due)
+			s.addRun(run)
+			continue
+		}
+
+		s.running.Add(1)
+		go func(schedule Schedule, run Run) {
+			defer s.running.Done()
+			// a run is completed even if the server shuts down meanwhile, the calls are bounded by their timeouts
+			s.addRun(s.runCommand(context.WithoutCancel(ctx), schedule, run))
+		}(schedule, run)
+	}
+}
+
+// runCommand sends the command of the schedule to its devices one after another.
+func (s *Scheduler) runCommand(ctx context.Context, schedule Schedule, run Run) Run {
+	run.Result = RunSucceeded
+	for _, sn := range schedule.SerialNumbers {
+		status, body := s.execute(ctx, schedule.Account, sn, schedule.Command, schedule.Payload)
+		run.Devices = append(run.Devices, DeviceRun{SerialNumber: sn, Status: status, Body: body})
+		if status >= http.StatusBadRequest {
+			run.Result = RunFailed
+		}
+	}
+	run.FinishedAt = s.now().UTC()
+	if run.Result == RunFailed {
+		s.logger.Warn("Scheduled command failed", "schedule", schedule.ID, "command", schedule.Command)
+	} else {
+		s.logger.Info("Scheduled command sent", "schedule", schedule.ID, "command", schedule.Command)
+	}
+	return run
+}
+
+func (s *Scheduler) addRun(run Run) {
+	if err := s.store.addRun(run); err != nil {
+		s.logger.Warn("Failed to save the schedule run", "schedule", run.ScheduleID, "error", err)
+	}
+}
@@ -0,0 +1,198 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 3, 1, 22, 30, 0, 0, time.UTC)
+
+// recorder is a service.CommandExecutor that records the commands, the commands sent to failing devices fail.
+type recorder struct {
+	mu       sync.Mutex
+	commands []string
+	failing  map[string]bool
+}
+
+func (r *recorder) execute(_ context.Context, account, sn, command string, payload json.RawMessage) (int, json.RawMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, account+" "+sn+" "+command+" "+string(payload))
+	if r.failing[sn] {
+		return http.StatusConflict, json.RawMessage(`{"success":false}`)
+	}
+	return http.StatusOK, json.RawMessage(`{"success":true}`)
+}
+
+func newTestScheduler(t *testing.T, path string, now *time.Time) (*Scheduler, *Store, *recorder) {
+	t.Helper()
+	store, err := Open(path, 3)
+	require.NoError(t, err)
+	store.now = func() time.Time { return *now }
+	commands := &recorder{failing: map[string]bool{"R331ZEB4ZEAL0999": true}}
+	scheduler := NewScheduler(store, commands.execute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	scheduler.now = func() time.Time { return *now }
+	return scheduler, store, commands
+}
+
+func acOff(missedRuns string, serialNumbers ...string) Schedule {
+	return Schedule{
+		Account:       "home",
+		Name:          "AC off",
+		Cron:          "0 23 * * *",
+		TimeZone:      "UTC",
+		SerialNumbers: serialNumbers,
+		Command:       "out/ac",
+		Payload:       json.RawMessage(`{"state":"off"}`),
+		MissedRuns:    missedRuns,
+		Enabled:       true,
+	}
+}
+
+// checkAt runs the due occurrences at the time and waits for the runs to complete.
+func checkAt(scheduler *Scheduler, now *time.Time, t time.Time) {
+	*now = t
+	scheduler.check(context.Background())
+	scheduler.running.Wait()
+}
+
+func TestStore_Schedules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	now := start
+	_, store, _ := newTestScheduler(t, path, &now)
+
+	created, err := store.Create(acOff(MissedRunsSkip, "R331ZEB4ZEAL0528"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, start, created.LastScheduledAt)
+	_, err = store.Create(Schedule{Account: "office", Cron: "0 6 * * *", TimeZone: "UTC", MissedRuns: MissedRunsSkip})
+	require.NoError(t, err)
+
+	_, err = store.Create(Schedule{Account: "home", Cron: "0 25 * * *", TimeZone: "UTC", MissedRuns: MissedRunsSkip})
+	assert.Error(t, err)
+	_, err = store.Create(Schedule{Account: "home", Cron: "0 6 * * *", TimeZone: "Mars/Olympus", MissedRuns: MissedRunsSkip})
+	assert.Error(t, err)
+	_, err = store.Create(Schedule{Account: "home", Cron: "0 6 * * *", TimeZone: "UTC", MissedRuns: "always"})
+	assert.Error(t, err)
+
+	_, err = store.Get("office", created.ID)
+	assert.ErrorIs(t, err, ErrScheduleNotFound, "schedules of other accounts are not found")
+
+	now = start.Add(time.Hour)
+	update := acOff(MissedRunsRunOnce, "R331ZEB4ZEAL0528")
+	update.Account = "office"
+	updated, err := store.Update("home", created.ID, update)
+	require.NoError(t, err)
+	assert.Equal(t, "home", updated.Account, "the account is kept")
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+	assert.Equal(t, now, updated.LastScheduledAt)
+
+	reopened, err := Open(path, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []Schedule{updated}, reopened.List("home"), "the schedules are persisted")
+
+	require.NoError(t, store.Delete("home", created.ID))
+	assert.ErrorIs(t, store.Delete("home", created.ID), ErrScheduleNotFound)
+	assert.Empty(t, store.List("home"))
+	assert.Len(t, store.List("office"), 1)
+}
+
+func TestScheduler_RunsDueOccurrences(t *testing.T) {
+	now := start
+	scheduler, store, commands := newTestScheduler(t, filepath.Join(t.TempDir(), "schedules.json"), &now)
+	s, err := store.Create(acOff(MissedRunsSkip, "R331ZEB4ZEAL0528", "R331ZEB4ZEAL0999"))
+	require.NoError(t, err)
+	disabled := acOff(MissedRunsSkip, "R331ZEB4ZEAL0528")
+	disabled.Enabled = false
+	_, err = store.Create(disabled)
+	require.NoError(t, err)
+
+	checkAt(scheduler, &now, start.Add(29*time.Minute))
+	assert.Empty(t, commands.commands, "not due yet")
+
+	checkAt(scheduler, &now, start.Add(30*time.Minute+time.Second))
+	assert.Equal(t, []string{
+		`home R331ZEB4ZEAL0528 out/ac {"state":"off"}`,
+		`home R331ZEB4ZEAL0999 out/ac {"state":"off"}`,
+	}, commands.commands)
+
+	checkAt(scheduler, &now, start.Add(31*time.Minute))
+	assert.Len(t, commands.commands, 2, "an occurrence is run once")
+
+	runs, err := store.Runs("home", s.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, RunFailed, runs[0].Result, "the command failed on a device")
+	assert.Equal(t, start.Add(30*time.Minute), runs[0].ScheduledAt)
+	assert.Zero(t, runs[0].Missed)
+	assert.Equal(t, []DeviceRun{
+		{SerialNumber: "R331ZEB4ZEAL0528", Status: http.StatusOK, Body: json.RawMessage(`{"success":true}`)},
+		{SerialNumber: "R331ZEB4ZEAL0999", Status: http.StatusConflict, Body: json.RawMessage(`{"success":false}`)},
+	}, runs[0].Devices)
+}
+
+func TestScheduler_MissedRuns(t *testing.T) {
+	tests := []struct {
+		name       string
+		missedRuns string
+		result     string
+		missed     int
+		commands   int
+	}{
+		{name: "skip", missedRuns: MissedRunsSkip, result: RunMissed, missed: 4, commands: 0},
+		{name: "run once", missedRuns: MissedRunsRunOnce, result: RunSucceeded, missed: 3, commands: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schedules.json")
+			now := start
+			_, store, _ := newTestScheduler(t, path, &now)
+			s, err := store.Create(acOff(tt.missedRuns, "R331ZEB4ZEAL0528"))
+			require.NoError(t, err)
+
+			// the server was down for four occurrences and restarts at 08:00
+			scheduler, store, commands := newTestScheduler(t, path, &now)
+			checkAt(scheduler, &now, start.Add(3*24*time.Hour).Add(9*time.Hour+30*time.Minute))
+			assert.Len(t, commands.commands, tt.commands)
+
+			runs, err := store.Runs("home", s.ID)
+			require.NoError(t, err)
+			require.Len(t, runs, 1)
+			assert.Equal(t, tt.result, runs[0].Result)
+			assert.Equal(t, tt.missed, runs[0].Missed)
+			assert.Equal(t, time.Date(2025, 3, 4, 23, 0, 0, 0, time.UTC), runs[0].ScheduledAt, "the latest occurrence")
+
+			// the next occurrence is run on time
+			checkAt(scheduler, &now, time.Date(2025, 3, 5, 23, 0, 1, 0, time.UTC))
+			assert.Len(t, commands.commands, tt.commands+1)
+		})
+	}
+}
+
+func TestScheduler_KeepsRunHistory(t *testing.T) {
+	now := start
+	scheduler, store, _ := newTestScheduler(t, filepath.Join(t.TempDir(), "schedules.json"), &now)
+	s, err := store.Create(acOff(MissedRunsSkip, "R331ZEB4ZEAL0528"))
+	require.NoError(t, err)
+
+	for day := 0; day < 5; day++ {
+		checkAt(scheduler, &now, time.Date(2025, 3, 1+day, 23, 0, 0, 0, time.UTC))
+	}
+
+	runs, err := store.Runs("home", s.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 3, "only the latest runs are kept")
+	assert.Equal(t, time.Date(2025, 3, 5, 23, 0, 0, 0, time.UTC), runs[0].ScheduledAt, "newest first")
+	assert.Equal(t, time.Date(2025, 3, 3, 23, 0, 0, 0, time.UTC), runs[2].ScheduledAt)
+}
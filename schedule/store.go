@@ -0,0 +1,336 @@
+package schedule
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ecoflow-api-server/jsonfile"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// MissedRunsSkip doesn't run missed occurrences, the schedule continues with its next occurrence.
+	MissedRunsSkip = "skip"
+	// MissedRunsRunOnce runs the latest missed occurrence once, e.g. after the server was down.
+	MissedRunsRunOnce = "run_once"
+)
+
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunMissed    = "missed"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// Schedule sends a power station command to devices at the times of a cron expression.
+type Schedule struct {
+	ID string `json:"id"`
+	// Account is the vault account the command is sent for
+	Account string `json:"account"`
+	Name    string `json:"name"`
+	// Cron is the cron expression of the times the command is sent, see Cron
+	Cron string `json:"cron"`
+	// TimeZone is the IANA time zone of the cron expression, e.g. Europe/Berlin
+	TimeZone      string   `json:"time_zone"`
+	SerialNumbers []string `json:"serial_numbers"`
+	// Command is the route of the command below /api/power_station/{serial_number}/, e.g. out/ac
+	Command string `json:"command"`
+	// Payload is the request body of the command
+	Payload json.RawMessage `json:"payload" swaggertype:"object"`
+	// MissedRuns is the policy for occurrences that were missed, skip or run_once
+	MissedRuns string    `json:"missed_runs"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// LastScheduledAt is the time of the last occurrence that was run or missed, occurrences after it are due
+	LastScheduledAt time.Time `json:"last_scheduled_at"`
+}
+
+// Validate checks the cron expression, the time zone and the missed runs policy of the schedule.
+func (s Schedule) Validate() error {
+	if _, _, err := s.parse(); err != nil {
+		return err
+	}
+	if s.MissedRuns != MissedRunsSkip && s.MissedRuns != MissedRunsRunOnce {
+		return fmt.Errorf("invalid missed runs policy %q, expected %s or %s", s.MissedRuns, MissedRunsSkip, MissedRunsRunOnce)
+	}
+	return nil
+}
+
+// Next returns the first occurrence of the schedule after t, or the zero time if there is none.
+func (s Schedule) Next(t time.Time) time.Time {
+	cron, loc, err := s.parse()
+	if err != nil {
+		return time.Time{}
+	}
+	return cron.Next(t, loc)
+}
+
+func (s Schedule) parse() (*Cron, *time.Location, error) {
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid time zone %q", s.TimeZone)
+	}
+	return cron, loc, nil
+}
+
+// Run is the run of an occurrence of a schedule.
+type Run struct {
+	ScheduleID string `json:"schedule_id"`
+	// ScheduledAt is the time of the occurrence
+	ScheduledAt time.Time `json:"scheduled_at"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	// Result is succeeded, failed if the command failed on a device, or missed if the occurrence was not run
+	Result string `json:"result"`
+	// Missed is the number of occurrences before ScheduledAt that were not run
+	Missed  int         `json:"missed,omitempty"`
+	Devices []DeviceRun `json:"devices,omitempty"`
+}
+
+// DeviceRun is the response of the command sent to a device.
+type DeviceRun struct {
+	SerialNumber string          `json:"serial_number"`
+	Status       int             `json:"status"`
+	Body         json.RawMessage `json:"body" swaggertype:"object"`
+}
+
+// contents is the structure of the store file.
+type contents struct {
+	Schedules map[string]Schedule `json:"schedules"` // keyed by ID
+	Runs      map[string][]Run    `json:"runs"`      // keyed by schedule ID, oldest first
+}
+
+// Store keeps the schedules and their latest runs in a JSON file. All methods are safe for concurrent use, mutating
+// methods persist the store before returning.
+type Store struct {
+	path        string
+	historySize int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	contents contents
+}
+
+// Open loads the store from path, a missing file results in an empty store. historySize is the number of runs kept per
+// schedule.
+func Open(path string, historySize int) (*Store, error) {
+	s := &Store{
+		path:        path,
+		historySize: historySize,
+		now:         time.Now,
+		contents: contents{
+			Schedules: make(map[string]Schedule),
+			Runs:      make(map[string][]Run),
+		},
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read schedules file: %w", err)
+	}
+	if err = json.Unmarshal(data, &s.contents); err != nil {
+		return nil, fmt.Errorf("schedules file is corrupted: %w", err)
+	}
+	if s.contents.Schedules == nil {
+		s.contents.Schedules = make(map[string]Schedule)
+	}
+	if s.contents.Runs == nil {
+		s.contents.Runs = make(map[string][]Run)
+	}
+	return s, nil
+}
+
+// List returns the schedules of the account, sorted by creation time.
+func (s *Store) List(account string) []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := make([]Schedule, 0)
+	for _, schedule := range s.contents.Schedules {
+		if schedule.Account == account {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sortSchedules(schedules)
+	return schedules
+}
+
+// Get returns the schedule of the account.
+func (s *Store) Get(account, id string) (Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, exists := s.contents.Schedules[id]
+	if !exists || schedule.Account != account {
+		return Schedule{}, ErrScheduleNotFound
+	}
+	return schedule, nil
+}
+
+// Create stores a new schedule, its ID and timestamps are set by the store. Only occurrences after its creation are
+// run.
+func (s *Store) Create(schedule Schedule) (Schedule, error) {
+	if err := schedule.Validate(); err != nil {
+		return Schedule{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Schedule{}, err
+	}
+	now := s.now().UTC()
+	schedule.ID = hex.EncodeToString(id)
+	schedule.CreatedAt = now
+	schedule.UpdatedAt = now
+	schedule.LastScheduledAt = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contents.Schedules[schedule.ID] = schedule
+	if err := s.save(); err != nil {
+		delete(s.contents.Schedules, schedule.ID)
+		return Schedule{}, err
+	}
+	return schedule, nil
+}
+
+// Update replaces the schedule of the account, the ID, account and creation time are kept. Occurrences before the
+// update are not run anymore.
+func (s *Store) Update(account, id string, schedule Schedule) (Schedule, error) {
+	if err := schedule.Validate(); err != nil {
+		return Schedule{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.contents.Schedules[id]
+	if !exists || current.Account != account {
+		return Schedule{}, ErrScheduleNotFound
+	}
+	now := s.now().UTC()
+	schedule.ID = current.ID
+	schedule.Account = current.Account
+	schedule.CreatedAt = current.CreatedAt
+	schedule.UpdatedAt = now
+	schedule.LastScheduledAt = now
+
+	s.contents.Schedules[id] = schedule
+	if err := s.save(); err != nil {
+		s.contents.Schedules[id] = current
+		return Schedule{}, err
+	}
+	return schedule, nil
+}
+
+// Delete deletes the schedule of the account and its runs.
+func (s *Store) Delete(account, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, exists := s.contents.Schedules[id]
+	if !exists || schedule.Account != account {
+		return ErrScheduleNotFound
+	}
+	runs := s.contents.Runs[id]
+	delete(s.contents.Schedules, id)
+	delete(s.contents.Runs, id)
+	if err := s.save(); err != nil {
+		s.contents.Schedules[id] = schedule
+		s.contents.Runs[id] = runs
+		return err
+	}
+	return nil
+}
+
+// Runs returns the latest runs of the schedule of the account, newest first.
+func (s *Store) Runs(account, id string) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, exists := s.contents.Schedules[id]
+	if !exists || schedule.Account != account {
+		return nil, ErrScheduleNotFound
+	}
+	stored := s.contents.Runs[id]
+	runs := make([]Run, len(stored))
+	for i, run := range stored {
+		runs[len(stored)-1-i] = run
+	}
+	return runs, nil
+}
+
+// enabled returns the enabled schedules of all accounts, sorted by creation time.
+func (s *Store) enabled() []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := make([]Schedule, 0, len(s.contents.Schedules))
+	for _, schedule := range s.contents.Schedules {
+		if schedule.Enabled {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sortSchedules(schedules)
+	return schedules
+}
+
+// advance sets the last occurrence of the schedule, unless the schedule was updated or deleted in the meantime.
+func (s *Store) advance(schedule Schedule, scheduledAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.contents.Schedules[schedule.ID]
+	if !exists || !current.UpdatedAt.Equal(schedule.UpdatedAt) {
+		return nil
+	}
+	current.LastScheduledAt = scheduledAt
+	s.contents.Schedules[schedule.ID] = current
+	return s.save()
+}
+
+// addRun appends the run to the runs of its schedule, the oldest runs are dropped beyond the history size.
+func (s *Store) addRun(run Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.contents.Schedules[run.ScheduleID]; !exists {
+		return nil
+	}
+	runs := append(s.contents.Runs[run.ScheduleID], run)
+	if len(runs) > s.historySize {
+		runs = append([]Run(nil), runs[len(runs)-s.historySize:]...)
+	}
+	s.contents.Runs[run.ScheduleID] = runs
+	return s.save()
+}
+
+// save writes the contents to the store file. The caller must hold the lock.
+func (s *Store) save() error {
+	if err := jsonfile.Write(s.path, s.contents); err != nil {
+		return fmt.Errorf("can't write schedules file: %w", err)
+	}
+	return nil
+}
+
+func sortSchedules(schedules []Schedule) {
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].CreatedAt.Equal(schedules[j].CreatedAt) {
+			return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+}
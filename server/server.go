@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/httplog/v2"
+	"go-ecoflow-api-server/config"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Hook is executed when the server shuts down. The context expires when the shutdown grace period is over.
+type Hook func(ctx context.Context) error
+
+type namedHook struct {
+	name string
+	hook Hook
+}
+
+// Server manages the lifecycle of the HTTP server: it applies the configured timeouts and limits, drains in-flight
+// requests on shutdown and then runs the shutdown hooks registered by background subsystems.
+type Server struct {
+	cfg    config.ServerConfig
+	logger *httplog.Logger
+
+	mu     sync.Mutex
+	hooks  []namedHook
+	drains []func()
+}
+
+func New(cfg config.ServerConfig, logger *httplog.Logger) *Server {
+	return &Server{cfg: cfg, logger: logger}
+}
+
+// OnShutdown registers a hook that is executed after the HTTP server stopped accepting requests and in-flight requests
+// were drained. Hooks run in reverse registration order, so subsystems registered later are stopped first.
+func (s *Server) OnShutdown(name string, hook Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, namedHook{name: name, hook: hook})
+}
+
+// OnDrain registers a function that is called as soon as the shutdown starts, before in-flight requests are drained.
+// It is used to end long-lived requests, e.g. event streams, which would otherwise hold the shutdown until the grace
+// period is over.
+func (s *Server) OnDrain(drain func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drains = append(s.drains, drain)
+}
+
+// Run listens on the configured address and serves handler until ctx is cancelled (e.g. on SIGTERM), then shuts down
+// gracefully.
+func (s *Server) Run(ctx context.Context, handler http.Handler) error {
+	listener, err := net.Listen("tcp", s.cfg.Address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener, handler)
+}
+
+// Serve serves handler on listener until ctx is cancelled. In-flight requests get the configured shutdown timeout to
+// finish, afterward the remaining connections are closed. The shutdown hooks share the same deadline.
+func (s *Server) Serve(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	httpServer := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       s.cfg.ReadTimeout,
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout,
+		WriteTimeout:      s.cfg.WriteTimeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
+		MaxHeaderBytes:    s.cfg.MaxHeaderBytes,
+	}
+	s.mu.Lock()
+	for _, drain := range s.drains {
+		httpServer.RegisterOnShutdown(drain)
+	}
+	s.mu.Unlock()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		// the server failed before a shutdown was requested, still give the subsystems a chance to stop
+		s.runHooks(context.Background())
+		return err
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("Shutting down, draining in-flight requests", "grace_period", s.cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		s.logger.Warn("Grace period is over, closing remaining connections", "error", err)
+		errs = append(errs, err, httpServer.Close())
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	errs = append(errs, s.runHooks(shutdownCtx))
+	return errors.Join(errs...)
+}
+
+func (s *Server) runHooks(ctx context.Context) error {
+	s.mu.Lock()
+	hooks := make([]namedHook, len(s.hooks))
+	copy(hooks, s.hooks)
+	s.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		started := time.Now()
+		if err := hooks[i].hook(ctx); err != nil {
+			s.logger.Error("Shutdown hook failed", "hook", hooks[i].name, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		s.logger.Debug("Shutdown hook finished", "hook", hooks[i].name, "duration", time.Since(started).String())
+	}
+	return errors.Join(errs...)
+}
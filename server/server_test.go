@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/httplog/v2"
+	"go-ecoflow-api-server/config"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig(shutdownTimeout time.Duration) config.ServerConfig {
+	cfg := config.Default().Server
+	cfg.ShutdownTimeout = shutdownTimeout
+	return cfg
+}
+
+func TestServer_DrainsInFlightRequestsAndRunsHooks(t *testing.T) {
+	logger := httplog.NewLogger("test-logger", httplog.Options{})
+	srv := New(testConfig(5*time.Second), logger)
+
+	var order []string
+	srv.OnShutdown("first", func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	srv.OnShutdown("second", func(ctx context.Context) error {
+		order = append(order, "second")
+		return nil
+	})
+
+	drained := make(chan struct{})
+	srv.OnDrain(func() {
+		close(drained)
+	})
+
+	requestStarted := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ctx, listener, handler)
+	}()
+
+	responseBody := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responseBody <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responseBody <- string(body)
+	}()
+
+	<-requestStarted
+	cancel()
+
+	assert.Equal(t, "done", <-responseBody, "in-flight request must complete")
+	select {
+	case <-drained:
+	default:
+		t.Error("drain functions must be called on shutdown")
+	}
+	assert.NoError(t, <-served)
+	assert.Equal(t, []string{"second", "first"}, order, "hooks run in reverse registration order")
+
+	_, err = http.Get("http://" + listener.Addr().String())
+	assert.Error(t, err, "server must not accept new connections after shutdown")
+}
+
+func TestServer_GracePeriodExpires(t *testing.T) {
+	logger := httplog.NewLogger("test-logger", httplog.Options{})
+	srv := New(testConfig(100*time.Millisecond), logger)
+
+	hookErr := errors.New("hook failed")
+	var hookDeadline time.Time
+	srv.OnShutdown("failing", func(ctx context.Context) error {
+		hookDeadline, _ = ctx.Deadline()
+		return hookErr
+	})
+
+	requestStarted := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		<-release
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ctx, listener, handler)
+	}()
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+
+	<-requestStarted
+	cancel()
+
+	select {
+	case err = <-served:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, hookErr)
+		assert.False(t, hookDeadline.IsZero(), "hooks must get the shutdown deadline")
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop after the grace period")
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/tess1o/go-ecoflow"
+	"sync"
+	"time"
+)
+
+// ClientFactory creates an Ecoflow client for the given access and secret keys.
+type ClientFactory func(accessKey, secretKey string) *ecoflow.Client
+
+// NewClient is the default ClientFactory, it creates a new client on every call.
+func NewClient(accessKey, secretKey string) *ecoflow.Client {
+	return ecoflow.NewEcoflowClient(accessKey, secretKey)
+}
+
+// ClientCacheStats contains the counters of a ClientCache.
+type ClientCacheStats struct {
+	Size      int    `json:"size"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+type cacheEntry struct {
+	key      string
+	client   *ecoflow.Client
+	lastUsed time.Time
+}
+
+// ClientCache reuses Ecoflow clients per credential pair. The cache holds at most maxSize clients, the least recently
+// used one is evicted when the cache is full. Clients that were not used for longer than ttl are evicted as well.
+// Entries are keyed by the SHA-256 hash of the credentials, the keys themselves are only kept inside the clients.
+type ClientCache struct {
+	mu        sync.Mutex
+	maxSize   int
+	ttl       time.Duration
+	newClient ClientFactory
+	now       func() time.Time
+	entries   map[string]*list.Element
+	lru       *list.List // front is the most recently used entry
+	stats     ClientCacheStats
+}
+
+func NewClientCache(maxSize int, ttl time.Duration, newClient ClientFactory) *ClientCache {
+	return &ClientCache{
+		maxSize:   maxSize,
+		ttl:       ttl,
+		newClient: newClient,
+		now:       time.Now,
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// Client returns the cached client for the credentials or creates a new one. It implements ClientFactory.
+func (c *ClientCache) Client(accessKey, secretKey string) *ecoflow.Client {
+	key := credentialsHash(accessKey, secretKey)
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if now.Sub(entry.lastUsed) <= c.ttl {
+			entry.lastUsed = now
+			c.lru.MoveToFront(element)
+			c.stats.Hits++
+			return entry.client
+		}
+		c.remove(element)
+	}
+
+	c.stats.Misses++
+	entry := &cacheEntry{key: key, client: c.newClient(accessKey, secretKey), lastUsed: now}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+	return entry.client
+}
+
+// EvictIdle removes all clients that were not used within the ttl and returns the number of removed clients.
+func (c *ClientCache) EvictIdle() int {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	evicted := 0
+	for element := c.lru.Back(); element != nil; {
+		entry := element.Value.(*cacheEntry)
+		if now.Sub(entry.lastUsed) <= c.ttl {
+			break // the remaining entries were used more recently
+		}
+		previous := element.Prev()
+		c.remove(element)
+		evicted++
+		element = previous
+	}
+	return evicted
+}
+
+// Run evicts idle clients periodically until ctx is cancelled.
+func (c *ClientCache) Run(ctx context.Context) {
+	interval := c.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.EvictIdle()
+		}
+	}
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *ClientCache) Stats() ClientCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+func (c *ClientCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.stats.Evictions++
+}
+
+// credentialsHash identifies a credential pair without keeping the keys in memory in plain text.
+func credentialsHash(accessKey, secretKey string) string {
+	sum := sha256.Sum256([]byte(accessKey + "\x00" + secretKey))
+	return hex.EncodeToString(sum[:])
+}
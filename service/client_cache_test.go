@@ -0,0 +1,91 @@
+package service
+
+import (
+	"github.com/tess1o/go-ecoflow"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func newTestCache(maxSize int, ttl time.Duration) (*ClientCache, *fakeClock, *int) {
+	created := 0
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cache := NewClientCache(maxSize, ttl, func(accessKey, secretKey string) *ecoflow.Client {
+		created++
+		return ecoflow.NewEcoflowClient(accessKey, secretKey)
+	})
+	cache.now = clock.Now
+	return cache, clock, &created
+}
+
+func TestClientCache_ReusesClients(t *testing.T) {
+	cache, _, created := newTestCache(10, time.Minute)
+
+	first := cache.Client("access", "secret")
+	second := cache.Client("access", "secret")
+	other := cache.Client("access", "other-secret")
+
+	if first != second {
+		t.Errorf("expected the same client for the same credentials")
+	}
+	if first == other {
+		t.Errorf("expected different clients for different credentials")
+	}
+	if *created != 2 {
+		t.Errorf("expected 2 clients to be created, got %d", *created)
+	}
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Size != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestClientCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache, _, created := newTestCache(2, time.Minute)
+
+	cache.Client("a", "1")
+	cache.Client("b", "2")
+	cache.Client("a", "1") // "b" is now the least recently used entry
+	cache.Client("c", "3")
+
+	if size := cache.Stats().Size; size != 2 {
+		t.Errorf("expected cache size 2, got %d", size)
+	}
+	cache.Client("a", "1")
+	if *created != 3 {
+		t.Errorf("expected the recently used client to stay cached, %d clients created", *created)
+	}
+	cache.Client("b", "2")
+	if *created != 4 {
+		t.Errorf("expected the least recently used client to be evicted, %d clients created", *created)
+	}
+}
+
+func TestClientCache_EvictsIdleClients(t *testing.T) {
+	cache, clock, created := newTestCache(10, time.Minute)
+
+	cache.Client("a", "1")
+	clock.now = clock.now.Add(30 * time.Second)
+	cache.Client("b", "2")
+	clock.now = clock.now.Add(45 * time.Second)
+
+	if evicted := cache.EvictIdle(); evicted != 1 {
+		t.Errorf("expected 1 idle client to be evicted, got %d", evicted)
+	}
+	if size := cache.Stats().Size; size != 1 {
+		t.Errorf("expected cache size 1, got %d", size)
+	}
+
+	clock.now = clock.now.Add(2 * time.Minute)
+	cache.Client("b", "2")
+	if *created != 3 {
+		t.Errorf("expected an expired client to be recreated, %d clients created", *created)
+	}
+}
@@ -7,12 +7,33 @@ import (
 	"net/http"
 )
 
+// GetEcoflowClient creates a new client from the Ecoflow keys sent in the Authorization and X-Secret-Token headers.
 func GetEcoflowClient(r *http.Request) (*ecoflow.Client, error) {
+	return NewHeaderClientProvider(NewClient)(r)
+}
+
+// NewHeaderClientProvider returns a client provider that takes the Ecoflow keys from the Authorization and
+// X-Secret-Token headers and obtains the client from newClient, e.g. ClientCache.Client.
+func NewHeaderClientProvider(newClient ClientFactory) func(r *http.Request) (*ecoflow.Client, error) {
+	return func(r *http.Request) (*ecoflow.Client, error) {
+		accessToken, secretToken, err := getTokens(r)
+		if err != nil {
+			return nil, err
+		}
+		return newClient(accessToken, secretToken), nil
+	}
+}
+
+// HeaderIdentity identifies the Ecoflow account of a request by a hash of both keys sent in the Authorization and
+// X-Secret-Token headers, so the keys never show up in logs or status pages. Requests with the same access key but
+// another secret key have another identity: they never share a stream, a circuit breaker or an audit actor with the
+// requests that hold the valid keys.
+func HeaderIdentity(r *http.Request) string {
 	accessToken, secretToken, err := getTokens(r)
 	if err != nil {
-		return nil, err
+		return ""
 	}
-	return ecoflow.NewEcoflowClient(accessToken, secretToken), nil
+	return "key:" + credentialsHash(accessToken, secretToken)[:32]
 }
 
 func getTokens(r *http.Request) (string, string, error) {
@@ -4,6 +4,7 @@ import (
 	"go-ecoflow-api-server/constants"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -71,3 +72,26 @@ func TestGetEcoflowClient(t *testing.T) {
 		})
 	}
 }
+
+func TestHeaderIdentity(t *testing.T) {
+	request := func(accessKey, secretKey string) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(constants.HeaderAuthorization, "Bearer "+accessKey)
+		req.Header.Set(constants.HeaderXSecretToken, secretKey)
+		return req
+	}
+
+	identity := HeaderIdentity(request("access", "secret"))
+	if identity == "" || strings.Contains(identity, "access") || strings.Contains(identity, "secret") {
+		t.Errorf("expected hashed identity, got %q", identity)
+	}
+	if got := HeaderIdentity(request("access", "secret")); got != identity {
+		t.Errorf("expected the same identity for the same keys, got %q and %q", identity, got)
+	}
+	if got := HeaderIdentity(request("access", "guessed")); got == identity {
+		t.Errorf("expected another identity for another secret key, got %q", got)
+	}
+	if got := HeaderIdentity(request("access", "")); got != "" {
+		t.Errorf("expected no identity without a secret key, got %q", got)
+	}
+}
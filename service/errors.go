@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ecoflow-api-server/resilience"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ErrorClass describes why a call to the Ecoflow cloud failed.
+type ErrorClass string
+
+const (
+	ErrorClassUnknown        ErrorClass = "unknown"
+	ErrorClassInvalidRequest ErrorClass = "invalid_request"  // rejected by go-ecoflow before it was sent
+	ErrorClassUnauthorized   ErrorClass = "unauthorized"     // invalid access key, secret key or signature
+	ErrorClassForbidden      ErrorClass = "forbidden"        // the keys are valid but not allowed to perform the call
+	ErrorClassDeviceNotFound ErrorClass = "device_not_found" // the device is not linked to the account
+	ErrorClassDeviceOffline  ErrorClass = "device_offline"   // the device is not connected to the Ecoflow cloud
+	ErrorClassBadResponse    ErrorClass = "bad_response"     // Ecoflow returned an unexpected or invalid response
+	ErrorClassUnavailable    ErrorClass = "unavailable"      // Ecoflow is unreachable, overloaded or failing
+	ErrorClassTimeout        ErrorClass = "timeout"          // Ecoflow did not respond in time
+	ErrorClassCanceled       ErrorClass = "canceled"         // the caller gave up
+)
+
+// ecoflowCodeClasses maps the error codes of the Ecoflow open platform to error classes.
+var ecoflowCodeClasses = map[string]ErrorClass{
+	"8513": ErrorClassUnauthorized,   // accessKey is invalid
+	"8521": ErrorClassUnauthorized,   // signature is wrong
+	"8524": ErrorClassUnauthorized,   // timestamp is expired, the server clock is off
+	"1006": ErrorClassDeviceNotFound, // current device is not allowed to get device info
+}
+
+// ecoflowMessageClasses is used for codes missing in ecoflowCodeClasses, Ecoflow messages are more stable than codes.
+var ecoflowMessageClasses = []struct {
+	keyword string
+	class   ErrorClass
+}{
+	{"offline", ErrorClassDeviceOffline},
+	{"not online", ErrorClassDeviceOffline},
+	{"accesskey", ErrorClassUnauthorized},
+	{"signature", ErrorClassUnauthorized},
+	{"not allowed", ErrorClassDeviceNotFound},
+	{"not bound", ErrorClassDeviceNotFound},
+	{"does not exist", ErrorClassDeviceNotFound},
+	{"permission", ErrorClassForbidden},
+	{"forbidden", ErrorClassForbidden},
+}
+
+// go-ecoflow validates some arguments itself, e.g. "brightness out of range" or "device SN is mandatory"
+var invalidRequestKeywords = []string{"out of range", "must be positive", "is mandatory", "are mandatory"}
+
+// go-ecoflow reports response codes as "error code: 8521, error message: signature is wrong" or "error code 1006"
+var (
+	ecoflowCodePattern    = regexp.MustCompile(`error code:? (\w+)`)
+	ecoflowMessagePattern = regexp.MustCompile(`error message: (.*)$`)
+)
+
+// UpstreamError is a classified failure of a call to the Ecoflow cloud.
+type UpstreamError struct {
+	Class ErrorClass
+	// StatusCode is the HTTP status of the Ecoflow response, 0 if no response was received or it was 200.
+	StatusCode int
+	// Code and Message are the error code and message from the Ecoflow response body, if any.
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *UpstreamError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("ecoflow api error, code: %s, message: %s", e.Code, e.Message)
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// NewResponseError returns the error for an Ecoflow response with a non-zero code. go-ecoflow doesn't check the code
+// of command responses, so it must be checked by the caller.
+func NewResponseError(code, message string) *UpstreamError {
+	return &UpstreamError{Class: classifyResponse(code, message), Code: code, Message: message}
+}
+
+// ClassifyError determines the class of an error returned by go-ecoflow.
+func ClassifyError(err error) *UpstreamError {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr
+	}
+
+	result := &UpstreamError{Class: ErrorClassUnknown, Err: err}
+	var (
+		openErr   *resilience.OpenError
+		netErr    net.Error
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		result.Class = ErrorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Class = ErrorClassTimeout
+	case errors.As(err, &openErr):
+		result.Class = ErrorClassUnavailable
+	case errors.As(err, &netErr):
+		result.Class = ErrorClassUnavailable
+		if netErr.Timeout() {
+			result.Class = ErrorClassTimeout
+		}
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		result.Class = ErrorClassBadResponse
+	case resilience.UpstreamStatusCode(err) != 0:
+		result.StatusCode = resilience.UpstreamStatusCode(err)
+		result.Class = classifyStatusCode(result.StatusCode)
+	default:
+		message := err.Error()
+		if match := ecoflowCodePattern.FindStringSubmatch(message); match != nil {
+			result.Code = match[1]
+			if match = ecoflowMessagePattern.FindStringSubmatch(message); match != nil {
+				result.Message = match[1]
+			}
+			result.Class = classifyResponse(result.Code, result.Message)
+		} else if strings.Contains(message, "response is not valid") {
+			result.Class = ErrorClassBadResponse
+		} else if containsAny(message, invalidRequestKeywords) {
+			result.Class = ErrorClassInvalidRequest
+		}
+	}
+	return result
+}
+
+func classifyStatusCode(statusCode int) ErrorClass {
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return ErrorClassUnauthorized
+	case statusCode == http.StatusForbidden:
+		return ErrorClassForbidden
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		return ErrorClassUnavailable
+	default:
+		return ErrorClassBadResponse
+	}
+}
+
+// classifyResponse classifies an Ecoflow response code, unknown codes are reported as bad responses.
+func classifyResponse(code, message string) ErrorClass {
+	if class, ok := ecoflowCodeClasses[code]; ok {
+		return class
+	}
+	lower := strings.ToLower(message)
+	for _, c := range ecoflowMessageClasses {
+		if strings.Contains(lower, c.keyword) {
+			return c.class
+		}
+	}
+	return ErrorClassBadResponse
+}
+
+func containsAny(s string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(s, keyword) {
+			return true
+		}
+	}
+	return false
+}
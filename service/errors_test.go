@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ecoflow-api-server/resilience"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		expectedClass ErrorClass
+		expectedCode  string
+	}{
+		{
+			name:          "invalid signature",
+			err:           errors.New("can't get device list, error code: 8521, error message: signature is wrong"),
+			expectedClass: ErrorClassUnauthorized,
+			expectedCode:  "8521",
+		},
+		{
+			name:          "device not linked to the account",
+			err:           errors.New("can't get parameters, error code 1006"),
+			expectedClass: ErrorClassDeviceNotFound,
+			expectedCode:  "1006",
+		},
+		{
+			name:          "device offline by message",
+			err:           NewResponseError("9999", "Current device is offline"),
+			expectedClass: ErrorClassDeviceOffline,
+			expectedCode:  "9999",
+		},
+		{
+			name:          "unknown ecoflow code",
+			err:           errors.New("can't get parameters, error code 4242"),
+			expectedClass: ErrorClassBadResponse,
+			expectedCode:  "4242",
+		},
+		{
+			name:          "http 401",
+			err:           errors.New("response status is failed|url=https://api.ecoflow.com, statusCode=401 Unauthorized"),
+			expectedClass: ErrorClassUnauthorized,
+		},
+		{
+			name:          "http 403",
+			err:           errors.New("response status is failed|url=https://api.ecoflow.com, statusCode=403 Forbidden"),
+			expectedClass: ErrorClassForbidden,
+		},
+		{
+			name:          "http 502",
+			err:           errors.New("response status is failed|url=https://api.ecoflow.com, statusCode=502 Bad Gateway"),
+			expectedClass: ErrorClassUnavailable,
+		},
+		{
+			name:          "http 404",
+			err:           errors.New("response status is failed|url=https://api.ecoflow.com, statusCode=404 Not Found"),
+			expectedClass: ErrorClassBadResponse,
+		},
+		{
+			name:          "connection refused",
+			err:           &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expectedClass: ErrorClassUnavailable,
+		},
+		{
+			name:          "circuit breaker open",
+			err:           &resilience.OpenError{RetryAfter: time.Second},
+			expectedClass: ErrorClassUnavailable,
+		},
+		{
+			name:          "deadline exceeded",
+			err:           fmt.Errorf("Get \"https://api.ecoflow.com\": %w", context.DeadlineExceeded),
+			expectedClass: ErrorClassTimeout,
+		},
+		{
+			name:          "cancelled",
+			err:           context.Canceled,
+			expectedClass: ErrorClassCanceled,
+		},
+		{
+			name:          "invalid json",
+			err:           json.Unmarshal([]byte("<html>"), &struct{}{}),
+			expectedClass: ErrorClassBadResponse,
+		},
+		{
+			name:          "rejected by go-ecoflow",
+			err:           errors.New("brightness out of range. Expected value from 0 to 1023"),
+			expectedClass: ErrorClassInvalidRequest,
+		},
+		{
+			name:          "unknown",
+			err:           errors.New("something else"),
+			expectedClass: ErrorClassUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ClassifyError(tt.err)
+			if result.Class != tt.expectedClass {
+				t.Errorf("expected class %s, got %s", tt.expectedClass, result.Class)
+			}
+			if result.Code != tt.expectedCode {
+				t.Errorf("expected code %q, got %q", tt.expectedCode, result.Code)
+			}
+		})
+	}
+}
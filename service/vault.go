@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/vault"
+	"net/http"
+	"strings"
+)
+
+// NewVaultClientProvider returns a client provider that authenticates requests with a server-issued API key
+// (X-API-Key header) and creates the Ecoflow client from the credentials of the account stored in the vault.
+// When allowHeaders is true, requests without an API key fall back to the Ecoflow keys sent in the Authorization and
+// X-Secret-Token headers. Clients are obtained from newClient, e.g. ClientCache.Client.
+func NewVaultClientProvider(v *vault.Vault, allowHeaders bool, newClient ClientFactory) func(r *http.Request) (*ecoflow.Client, error) {
+	headerProvider := NewHeaderClientProvider(newClient)
+	return func(r *http.Request) (*ecoflow.Client, error) {
+		apiKey := r.Header.Get(constants.HeaderXAPIKey)
+		if apiKey == "" {
+			if allowHeaders {
+				return headerProvider(r)
+			}
+			return nil, errors.New("unauthorized: missing " + constants.HeaderXAPIKey + " header")
+		}
+
+		_, credentials, err := v.Resolve(apiKey)
+		if err != nil {
+			return nil, errors.New("unauthorized: invalid " + constants.HeaderXAPIKey + " header")
+		}
+		return newClient(credentials.AccessKey, credentials.SecretKey), nil
+	}
+}
+
+// NewVaultIdentity returns a function that identifies requests authenticated with an API key by the name of the vault
+// account, all other requests are identified by HeaderIdentity.
+func NewVaultIdentity(v *vault.Vault) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		apiKey := r.Header.Get(constants.HeaderXAPIKey)
+		if apiKey == "" {
+			return HeaderIdentity(r)
+		}
+		account, _, err := v.Resolve(apiKey)
+		if err != nil {
+			return ""
+		}
+		return VaultIdentity(account)
+	}
+}
+
+const vaultIdentityPrefix = "vault:"
+
+// VaultIdentity returns the identity of the requests authenticated with an API key of the vault account.
+func VaultIdentity(account string) string {
+	return vaultIdentityPrefix + account
+}
+
+// VaultAccount returns the vault account of the identity, false if it is not the identity of a vault account.
+func VaultAccount(identity string) (string, bool) {
+	return strings.CutPrefix(identity, vaultIdentityPrefix)
+}
+
+type vaultAccountKey struct{}
+
+// WithVaultAccount returns a context for requests sent by the server itself for the vault account, e.g. scheduled
+// commands, see NewVaultAccountClientProvider.
+func WithVaultAccount(ctx context.Context, account string) context.Context {
+	return context.WithValue(ctx, vaultAccountKey{}, account)
+}
+
+// NewVaultAccountClientProvider returns a client provider for requests sent by the server itself, it creates the Ecoflow
+// client from the credentials of the vault account of the request context, see WithVaultAccount.
+func NewVaultAccountClientProvider(v *vault.Vault, newClient ClientFactory) func(r *http.Request) (*ecoflow.Client, error) {
+	return func(r *http.Request) (*ecoflow.Client, error) {
+		account, _ := r.Context().Value(vaultAccountKey{}).(string)
+		credentials, err := v.Credentials(account)
+		if err != nil {
+			return nil, errors.New("unauthorized: vault account " + account + " not found")
+		}
+		return newClient(credentials.AccessKey, credentials.SecretKey), nil
+	}
+}
+
+// VaultAccountIdentity identifies requests sent for a vault account like the requests authenticated with its API keys.
+func VaultAccountIdentity(r *http.Request) string {
+	account, ok := r.Context().Value(vaultAccountKey{}).(string)
+	if !ok {
+		return ""
+	}
+	return VaultIdentity(account)
+}
+
+// CommandExecutor sends a power station command for the vault account and returns the HTTP status and the body of the
+// response, see handlers.CommandDispatcher. It sends the commands of the server itself, e.g. scheduled commands.
+type CommandExecutor func(ctx context.Context, account, sn, command string, payload json.RawMessage) (int, json.RawMessage)
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"encoding/base64"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/constants"
+	"go-ecoflow-api-server/vault"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestVault returns a vault with the account "home" and an API key issued for it.
+func newTestVault(t *testing.T) (*vault.Vault, string) {
+	encodedKey, err := vault.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := base64.StdEncoding.DecodeString(encodedKey)
+	v, err := vault.Open(filepath.Join(t.TempDir(), "vault.json"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.AddAccount("home", vault.Credentials{AccessKey: "access", SecretKey: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	apiKey, _, err := v.IssueAPIKey("home", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, apiKey
+}
+
+func TestNewVaultClientProvider(t *testing.T) {
+	v, apiKey := newTestVault(t)
+
+	tests := []struct {
+		name          string
+		allowHeaders  bool
+		headers       map[string]string
+		expectedError bool
+	}{
+		{
+			name:    "valid api key",
+			headers: map[string]string{constants.HeaderXAPIKey: apiKey},
+		},
+		{
+			name:          "invalid api key",
+			allowHeaders:  true,
+			headers:       map[string]string{constants.HeaderXAPIKey: "efk_00000000_invalid"},
+			expectedError: true,
+		},
+		{
+			name:         "header mode allowed",
+			allowHeaders: true,
+			headers: map[string]string{
+				constants.HeaderAuthorization: "Bearer access",
+				constants.HeaderXSecretToken:  "secret",
+			},
+		},
+		{
+			name: "header mode disabled",
+			headers: map[string]string{
+				constants.HeaderAuthorization: "Bearer access",
+				constants.HeaderXSecretToken:  "secret",
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			client, err := NewVaultClientProvider(v, tt.allowHeaders, NewClient)(req)
+			if tt.expectedError {
+				if err == nil || client != nil {
+					t.Errorf("expected error and nil client, got client %v, error %v", client, err)
+				}
+			} else if err != nil || client == nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewVaultIdentity(t *testing.T) {
+	v, apiKey := newTestVault(t)
+	identity := NewVaultIdentity(v)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(constants.HeaderXAPIKey, apiKey)
+	if got := identity(req); got != "vault:home" {
+		t.Errorf("expected vault:home, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(constants.HeaderAuthorization, "Bearer access")
+	req.Header.Set(constants.HeaderXSecretToken, "secret")
+	got := identity(req)
+	if got == "" || got != HeaderIdentity(req) || strings.Contains(got, "access") {
+		t.Errorf("expected hashed header identity, got %q", got)
+	}
+}
+
+func TestNewVaultAccountClientProvider(t *testing.T) {
+	v, _ := newTestVault(t)
+	var keys []string
+	provider := NewVaultAccountClientProvider(v, func(accessKey, secretKey string) *ecoflow.Client {
+		keys = append(keys, accessKey, secretKey)
+		return NewClient(accessKey, secretKey)
+	})
+
+	req := httptest.NewRequest("PUT", "/", nil)
+	req = req.WithContext(WithVaultAccount(req.Context(), "home"))
+	if _, err := provider(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(keys, ",") != "access,secret" {
+		t.Errorf("expected the credentials of the account, got %v", keys)
+	}
+	if got := VaultAccountIdentity(req); got != "vault:home" {
+		t.Errorf("expected vault:home, got %q", got)
+	}
+
+	req = httptest.NewRequest("PUT", "/", nil)
+	if _, err := provider(req.WithContext(WithVaultAccount(req.Context(), "office"))); err == nil {
+		t.Error("expected an error for an unknown account")
+	}
+	if got := VaultAccountIdentity(req); got != "" {
+		t.Errorf("expected no identity without an account, got %q", got)
+	}
+}
@@ -0,0 +1,350 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBrokerClosed is returned by Subscribe after the broker was closed.
+var ErrBrokerClosed = errors.New("telemetry broker is closed")
+
+// subscriberBuffer is the number of events buffered per subscriber. Subscribers that fall further behind are dropped,
+// they can reconnect and resume from the last event they received.
+const subscriberBuffer = 64
+
+// EventType is the type of telemetry event.
+type EventType string
+
+const (
+	EventSnapshot EventType = "snapshot" // all parameters of the device
+	EventDelta    EventType = "delta"    // parameters that changed since the previous event
+	EventError    EventType = "error"    // the source failed to read the parameters
+)
+
+// Event is a change of the device parameters. Snapshot and delta events have an ID that can be used to resume a
+// subscription, error events have none.
+type Event struct {
+	ID      string                 `json:"-"`
+	Type    EventType              `json:"-"`
+	Time    time.Time              `json:"time"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	Removed []string               `json:"removed,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// Broker shares one Source feed per device between all subscribers. Each feed keeps the current state of the device
+// and the last events, so subscribers that reconnect can resume where they stopped. Feeds are stopped when they had no
+// subscribers for the linger duration.
+type Broker struct {
+	historySize int
+	linger      time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	feeds  map[string]*feed
+	closed bool
+}
+
+func NewBroker(historySize int, linger time.Duration) *Broker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Broker{
+		historySize: max(historySize, 1),
+		linger:      linger,
+		ctx:         ctx,
+		cancel:      cancel,
+		feeds:       make(map[string]*feed),
+	}
+}
+
+// Subscription receives the events of a device. Replay must be sent before the events from Events. Events is closed
+// when the subscriber falls too far behind or the broker is closed.
+type Subscription struct {
+	Replay []Event
+	Events <-chan Event
+
+	feed *feed
+	sub  *subscriber
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.feed.unsubscribe(s.sub)
+}
+
+// Subscribe subscribes to the feed identified by key, starting it with source if it is not running. Feeds of
+// different accounts must use different keys. When lastEventID is a known event of the feed, the events after it are
+// replayed, otherwise the subscription starts with a snapshot.
+func (b *Broker) Subscribe(key, sn string, source Source, lastEventID string) (*Subscription, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrBrokerClosed
+	}
+	f, ok := b.feeds[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(b.ctx)
+		updates, err := source.Watch(ctx, sn)
+		if err != nil {
+			cancel()
+			b.mu.Unlock()
+			return nil, err
+		}
+		f = newFeed(b, key, b.historySize, cancel)
+		b.feeds[key] = f
+		go f.run(updates)
+	}
+	b.mu.Unlock()
+
+	return f.subscribe(lastEventID), nil
+}
+
+// Close stops all feeds and ends all subscriptions.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	b.closed = true
+	feeds := b.feeds
+	b.feeds = make(map[string]*feed)
+	b.mu.Unlock()
+
+	b.cancel()
+	for _, f := range feeds {
+		f.stop()
+	}
+}
+
+func (b *Broker) remove(f *feed) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.feeds[f.key] == f {
+		delete(b.feeds, f.key)
+	}
+}
+
+type subscriber struct {
+	events chan Event
+	closed bool
+}
+
+type feed struct {
+	broker      *Broker
+	key         string
+	epoch       string
+	historySize int
+	cancel      context.CancelFunc
+
+	mu          sync.Mutex
+	state       map[string]interface{}
+	hasState    bool
+	seq         uint64
+	history     []Event
+	subscribers map[*subscriber]struct{}
+	lingerTimer *time.Timer
+	stopped     bool
+}
+
+func newFeed(b *Broker, key string, historySize int, cancel context.CancelFunc) *feed {
+	return &feed{
+		broker:      b,
+		key:         key,
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		cancel:      cancel,
+		state:       make(map[string]interface{}),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (f *feed) run(updates <-chan Update) {
+	for update := range updates {
+		f.apply(update)
+	}
+	f.broker.remove(f)
+	f.stop()
+}
+
+func (f *feed) subscribe(lastEventID string) *Subscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.lingerTimer != nil {
+		f.lingerTimer.Stop()
+		f.lingerTimer = nil
+	}
+
+	sub := &subscriber{events: make(chan Event, subscriberBuffer)}
+	if f.stopped {
+		close(sub.events)
+		sub.closed = true
+	} else {
+		f.subscribers[sub] = struct{}{}
+	}
+	return &Subscription{Replay: f.replay(lastEventID), Events: sub.events, feed: f, sub: sub}
+}
+
+// replay returns the events a new subscriber must receive first.
+func (f *feed) replay(lastEventID string) []Event {
+	if seq, ok := f.parseID(lastEventID); ok && len(f.history) > 0 && seq+1 >= f.sequence(f.history[0]) {
+		var events []Event
+		for _, e := range f.history {
+			if f.sequence(e) > seq {
+				events = append(events, e)
+			}
+		}
+		return events
+	}
+	if !f.hasState {
+		// the snapshot is sent to all subscribers with the first update
+		return nil
+	}
+	return []Event{{ID: f.eventID(f.seq), Type: EventSnapshot, Time: time.Now(), Params: copyParams(f.state)}}
+}
+
+func (f *feed) unsubscribe(sub *subscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dropLocked(sub)
+	if len(f.subscribers) == 0 && !f.stopped && f.lingerTimer == nil {
+		f.lingerTimer = time.AfterFunc(f.broker.linger, f.expire)
+	}
+}
+
+func (f *feed) expire() {
+	f.mu.Lock()
+	idle := len(f.subscribers) == 0
+	f.mu.Unlock()
+	if idle {
+		f.broker.remove(f)
+		f.stop()
+	}
+}
+
+func (f *feed) stop() {
+	f.cancel()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	for sub := range f.subscribers {
+		f.dropLocked(sub)
+	}
+}
+
+func (f *feed) dropLocked(sub *subscriber) {
+	delete(f.subscribers, sub)
+	if !sub.closed {
+		sub.closed = true
+		close(sub.events)
+	}
+}
+
+func (f *feed) apply(update Update) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if update.Err != nil {
+		f.broadcastLocked(Event{Type: EventError, Time: time.Now(), Error: update.Err.Error()})
+		return
+	}
+
+	changed, removed := diff(f.state, update.Params, update.Complete)
+	for k, v := range changed {
+		f.state[k] = v
+	}
+	for _, k := range removed {
+		delete(f.state, k)
+	}
+
+	var event Event
+	switch {
+	case !f.hasState:
+		f.hasState = true
+		event = Event{Type: EventSnapshot, Params: copyParams(f.state)}
+	case len(changed) > 0 || len(removed) > 0:
+		event = Event{Type: EventDelta, Params: changed, Removed: removed}
+	default:
+		return
+	}
+	f.seq++
+	event.ID = f.eventID(f.seq)
+	event.Time = time.Now()
+
+	f.history = append(f.history, event)
+	if len(f.history) > f.historySize {
+		f.history = f.history[len(f.history)-f.historySize:]
+	}
+	f.broadcastLocked(event)
+}
+
+// broadcastLocked sends the event to all subscribers. Subscribers whose buffer is full are dropped instead of blocking
+// the feed.
+func (f *feed) broadcastLocked(event Event) {
+	for sub := range f.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			f.dropLocked(sub)
+		}
+	}
+}
+
+func (f *feed) eventID(seq uint64) string {
+	return fmt.Sprintf("%s-%d", f.epoch, seq)
+}
+
+// parseID returns the sequence number of an event ID of this feed. IDs of other feeds, e.g. from before a restart,
+// are rejected.
+func (f *feed) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != f.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || n > f.seq {
+		return 0, false
+	}
+	return n, true
+}
+
+func (f *feed) sequence(e Event) uint64 {
+	n, _ := f.parseID(e.ID)
+	return n
+}
+
+// diff returns the parameters of next that differ from state and, for complete updates, the parameters that are
+// missing in next. Removed keys are sorted.
+func diff(state, next map[string]interface{}, complete bool) (map[string]interface{}, []string) {
+	changed := make(map[string]interface{})
+	for k, v := range next {
+		if old, ok := state[k]; !ok || !reflect.DeepEqual(old, v) {
+			changed[k] = v
+		}
+	}
+
+	var removed []string
+	if complete {
+		for k := range state {
+			if _, ok := next[k]; !ok {
+				removed = append(removed, k)
+			}
+		}
+		sort.Strings(removed)
+	}
+	return changed, removed
+}
+
+func copyParams(params map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,221 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// manualSource is a Source whose updates are sent by the test.
+type manualSource struct {
+	updates chan Update
+	watches atomic.Int32
+}
+
+func newManualSource() *manualSource {
+	return &manualSource{updates: make(chan Update)}
+}
+
+func (m *manualSource) Watch(ctx context.Context, sn string) (<-chan Update, error) {
+	m.watches.Add(1)
+	out := make(chan Update)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case u := <-m.updates:
+				select {
+				case out <- u:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (m *manualSource) send(params map[string]interface{}, complete bool) {
+	m.updates <- Update{Params: params, Complete: complete}
+}
+
+func receive(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		require.True(t, ok, "subscription was closed")
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return Event{}
+	}
+}
+
+func TestBroker_SnapshotThenDeltas(t *testing.T) {
+	broker := NewBroker(10, time.Minute)
+	defer broker.Close()
+	source := newManualSource()
+
+	sub, err := broker.Subscribe("account/SN1", "SN1", source, "")
+	require.NoError(t, err)
+	defer sub.Close()
+	assert.Empty(t, sub.Replay)
+
+	source.send(map[string]interface{}{"soc": 50.0, "watts": 100.0}, true)
+	snapshot := receive(t, sub.Events)
+	assert.Equal(t, EventSnapshot, snapshot.Type)
+	assert.Equal(t, map[string]interface{}{"soc": 50.0, "watts": 100.0}, snapshot.Params)
+
+	// unchanged updates produce no event
+	source.send(map[string]interface{}{"soc": 50.0, "watts": 100.0}, true)
+	source.send(map[string]interface{}{"soc": 51.0}, true)
+	delta := receive(t, sub.Events)
+	assert.Equal(t, EventDelta, delta.Type)
+	assert.Equal(t, map[string]interface{}{"soc": 51.0}, delta.Params)
+	assert.Equal(t, []string{"watts"}, delta.Removed)
+
+	// partial updates from push feeds don't remove parameters
+	source.send(map[string]interface{}{"temp": 25.0}, false)
+	delta = receive(t, sub.Events)
+	assert.Equal(t, map[string]interface{}{"temp": 25.0}, delta.Params)
+	assert.Empty(t, delta.Removed)
+
+	// a second subscriber shares the feed and starts with the current state
+	second, err := broker.Subscribe("account/SN1", "SN1", source, "")
+	require.NoError(t, err)
+	defer second.Close()
+	assert.Equal(t, int32(1), source.watches.Load())
+	require.Len(t, second.Replay, 1)
+	assert.Equal(t, EventSnapshot, second.Replay[0].Type)
+	assert.Equal(t, map[string]interface{}{"soc": 51.0, "temp": 25.0}, second.Replay[0].Params)
+	assert.Equal(t, delta.ID, second.Replay[0].ID)
+}
+
+func TestBroker_ResumeFromLastEventID(t *testing.T) {
+	broker := NewBroker(1, time.Minute)
+	defer broker.Close()
+	source := newManualSource()
+
+	sub, err := broker.Subscribe("k", "SN1", source, "")
+	require.NoError(t, err)
+	source.send(map[string]interface{}{"soc": 1.0}, false)
+	first := receive(t, sub.Events)
+	source.send(map[string]interface{}{"soc": 2.0}, false)
+	second := receive(t, sub.Events)
+	sub.Close()
+
+	source.send(map[string]interface{}{"soc": 3.0}, false)
+	time.Sleep(50 * time.Millisecond) // let the feed apply the update
+
+	resumed, err := broker.Subscribe("k", "SN1", source, second.ID)
+	require.NoError(t, err)
+	defer resumed.Close()
+	require.Len(t, resumed.Replay, 1)
+	assert.Equal(t, EventDelta, resumed.Replay[0].Type)
+	assert.Equal(t, map[string]interface{}{"soc": 3.0}, resumed.Replay[0].Params)
+
+	// the first event is no longer in the history, the client gets a new snapshot
+	fromFirst, err := broker.Subscribe("k", "SN1", source, first.ID)
+	require.NoError(t, err)
+	defer fromFirst.Close()
+	require.Len(t, fromFirst.Replay, 1)
+	assert.Equal(t, EventSnapshot, fromFirst.Replay[0].Type)
+
+	unknown, err := broker.Subscribe("k", "SN1", source, "otherepoch-1")
+	require.NoError(t, err)
+	defer unknown.Close()
+	require.Len(t, unknown.Replay, 1)
+	assert.Equal(t, EventSnapshot, unknown.Replay[0].Type)
+}
+
+func TestBroker_DropsSlowSubscribers(t *testing.T) {
+	broker := NewBroker(10, time.Minute)
+	defer broker.Close()
+	source := newManualSource()
+
+	slow, err := broker.Subscribe("k", "SN1", source, "")
+	require.NoError(t, err)
+	defer slow.Close()
+
+	// the feed applies an update before it receives the next one, so the overflowing update was applied once the
+	// update after it was sent
+	for i := 0; i < subscriberBuffer+3; i++ {
+		source.send(map[string]interface{}{"counter": float64(i)}, false)
+	}
+
+	received := 0
+	for range slow.Events {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received, "the subscription must be closed once its buffer is full")
+}
+
+func TestBroker_StopsIdleFeeds(t *testing.T) {
+	broker := NewBroker(10, 10*time.Millisecond)
+	defer broker.Close()
+	source := newManualSource()
+
+	sub, err := broker.Subscribe("k", "SN1", source, "")
+	require.NoError(t, err)
+	sub.Close()
+
+	assert.Eventually(t, func() bool {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+		return len(broker.feeds) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	sub, err = broker.Subscribe("k", "SN1", source, "")
+	require.NoError(t, err)
+	sub.Close()
+	assert.Equal(t, int32(2), source.watches.Load())
+}
+
+func TestBroker_ErrorEvents(t *testing.T) {
+	broker := NewBroker(10, time.Minute)
+	source := newManualSource()
+
+	sub, err := broker.Subscribe("k", "SN1", source, "")
+	require.NoError(t, err)
+	source.updates <- Update{Err: errors.New("ecoflow is down")}
+
+	event := receive(t, sub.Events)
+	assert.Equal(t, EventError, event.Type)
+	assert.Empty(t, event.ID)
+	assert.Equal(t, "ecoflow is down", event.Error)
+
+	broker.Close()
+	_, ok := <-sub.Events
+	assert.False(t, ok, "closing the broker ends the subscriptions")
+	_, err = broker.Subscribe("k", "SN1", source, "")
+	assert.ErrorIs(t, err, ErrBrokerClosed)
+}
+
+func TestPollingSource(t *testing.T) {
+	var calls atomic.Int32
+	source := NewPollingSource(func(ctx context.Context, sn string) (map[string]interface{}, error) {
+		calls.Add(1)
+		return map[string]interface{}{"sn": sn}, nil
+	}, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := source.Watch(ctx, "SN1")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		update := <-updates
+		assert.True(t, update.Complete)
+		assert.Equal(t, map[string]interface{}{"sn": "SN1"}, update.Params)
+	}
+	cancel()
+	for range updates {
+	}
+	assert.GreaterOrEqual(t, calls.Load(), int32(3))
+}
@@ -0,0 +1,63 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+)
+
+// Update carries the parameters of a device or the error that prevented reading them.
+type Update struct {
+	Params map[string]interface{}
+	// Complete is set when Params contains all parameters of the device, parameters missing in a complete update
+	// are removed from the state. Push feeds usually send only the changed parameters.
+	Complete bool
+	Err      error
+}
+
+// Source provides the parameters of a device. Watch sends an update whenever the parameters may have changed, until ctx
+// is cancelled; then the channel is closed. Sources may send the complete parameter set or only the changed
+// parameters, see Update.Complete.
+type Source interface {
+	Watch(ctx context.Context, sn string) (<-chan Update, error)
+}
+
+// FetchFunc reads all parameters of a device, e.g. with ecoflow.Client.GetDeviceAllParameters.
+type FetchFunc func(ctx context.Context, sn string) (map[string]interface{}, error)
+
+// PollingSource is a Source that fetches the parameters of a device at a fixed interval.
+type PollingSource struct {
+	fetch    FetchFunc
+	interval time.Duration
+}
+
+func NewPollingSource(fetch FetchFunc, interval time.Duration) *PollingSource {
+	return &PollingSource{fetch: fetch, interval: interval}
+}
+
+func (p *PollingSource) Watch(ctx context.Context, sn string) (<-chan Update, error) {
+	updates := make(chan Update, 1)
+	go func() {
+		defer close(updates)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			params, err := p.fetch(ctx, sn)
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case updates <- Update{Params: params, Complete: err == nil, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates, nil
+}
@@ -0,0 +1,450 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileVersion  = 1
+	keySize      = 32 // AES-256
+	apiKeyPrefix = "efk"
+	// apiKeyIDSize is the size of the random API key IDs in bytes
+	apiKeyIDSize = 8
+)
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	ErrAccountExists   = errors.New("account already exists")
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	ErrInvalidAPIKey   = errors.New("invalid api key")
+)
+
+// Credentials are the Ecoflow access and secret keys of an account.
+type Credentials struct {
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// Account is a named Ecoflow account stored in the vault.
+type Account struct {
+	Name        string      `json:"name"`
+	Credentials Credentials `json:"credentials"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// APIKey describes a server-issued API key. Only the SHA-256 hash of the key is stored, the key itself
+// is shown once when it is issued.
+type APIKey struct {
+	ID          string    `json:"id"`
+	Hash        string    `json:"hash"`
+	Account     string    `json:"account"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// contents is the plaintext structure that is encrypted on disk.
+type contents struct {
+	Accounts map[string]Account `json:"accounts"`
+	APIKeys  map[string]APIKey  `json:"api_keys"` // keyed by ID
+}
+
+// envelope is the on-disk format: AES-256-GCM encrypted contents with the nonce needed to decrypt them.
+type envelope struct {
+	Version int    `json:"version"`
+	Nonce   string `json:"nonce"`
+	Data    string `json:"data"`
+}
+
+// Vault stores Ecoflow accounts and the API keys mapped to them in a file that is encrypted at rest.
+// All methods are safe for concurrent use. Mutating methods persist the vault before returning. Lookups reload the
+// vault when the file was replaced, e.g. by the vault sub command, so revoked API keys stop working immediately.
+type Vault struct {
+	mu       sync.RWMutex
+	path     string
+	aead     cipher.AEAD
+	contents contents
+	byHash   map[string]string // api key hash -> api key ID
+	// loaded is the file the contents were loaded from or saved to, nil if there was no file
+	loaded os.FileInfo
+}
+
+// GenerateKey returns a new random master key, base64 encoded.
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ReadKeyFile reads a base64 encoded master key from the given file.
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read vault key file: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("vault key is not valid base64: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("vault key must be %d bytes long, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+// Open loads the vault stored at path and decrypts it with key. A missing file results in an empty vault
+// which is created on the first write.
+func Open(path string, key []byte) (*Vault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Vault{path: path, aead: aead}
+	if err = v.load(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// load reads the vault file, a missing file results in an empty vault. The contents are only replaced if the file can
+// be decrypted. The caller must hold the write lock.
+func (v *Vault) load() error {
+	loaded, err := os.Stat(v.path)
+	if errors.Is(err, os.ErrNotExist) {
+		v.contents = contents{Accounts: make(map[string]Account), APIKeys: make(map[string]APIKey)}
+		v.loaded = nil
+		v.reindex()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can't read vault file: %w", err)
+	}
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return fmt.Errorf("can't read vault file: %w", err)
+	}
+	c, err := v.decrypt(data)
+	if err != nil {
+		return err
+	}
+	v.contents = c
+	v.loaded = loaded
+	v.reindex()
+	return nil
+}
+
+// refresh reloads the vault if the file changed since it was loaded or saved. Every save replaces the file, so the
+// file info tells whether it changed.
+func (v *Vault) refresh() error {
+	current, err := os.Stat(v.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("can't read vault file: %w", err)
+	}
+
+	v.mu.RLock()
+	unchanged := sameFile(v.loaded, current)
+	v.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.load()
+}
+
+// sameFile reports whether the file infos describe the same unchanged file, both are nil if there is no file.
+func sameFile(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// AddAccount stores a new account.
+func (v *Vault) AddAccount(name string, credentials Credentials) error {
+	if name == "" || credentials.AccessKey == "" || credentials.SecretKey == "" {
+		return errors.New("account name, access key and secret key are mandatory")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, exists := v.contents.Accounts[name]; exists {
+		return ErrAccountExists
+	}
+	v.contents.Accounts[name] = Account{Name: name, Credentials: credentials, CreatedAt: time.Now().UTC()}
+	return v.save()
+}
+
+// RemoveAccount deletes the account and revokes all API keys issued for it.
+func (v *Vault) RemoveAccount(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, exists := v.contents.Accounts[name]; !exists {
+		return ErrAccountNotFound
+	}
+	delete(v.contents.Accounts, name)
+	for id, key := range v.contents.APIKeys {
+		if key.Account == name {
+			delete(v.contents.APIKeys, id)
+		}
+	}
+	v.reindex()
+	return v.save()
+}
+
+// Accounts returns the names of all stored accounts, sorted alphabetically. If the changed vault file can't be
+// reloaded the accounts that were loaded before are returned.
+func (v *Vault) Accounts() []string {
+	_ = v.refresh()
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	names := make([]string, 0, len(v.contents.Accounts))
+	for name := range v.contents.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Credentials returns the credentials of the named account.
+func (v *Vault) Credentials(account string) (Credentials, error) {
+	if err := v.refresh(); err != nil {
+		return Credentials{}, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	a, exists := v.contents.Accounts[account]
+	if !exists {
+		return Credentials{}, ErrAccountNotFound
+	}
+	return a.Credentials, nil
+}
+
+// IssueAPIKey creates a new API key for the account. The returned plaintext key is not stored and can't be
+// recovered later.
+func (v *Vault) IssueAPIKey(account, description string) (string, APIKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, exists := v.contents.Accounts[account]; !exists {
+		return "", APIKey{}, ErrAccountNotFound
+	}
+	id, err := v.newAPIKeyID()
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	key := APIKey{
+		ID:          id,
+		Account:     account,
+		Description: description,
+		CreatedAt:   time.Now().UTC(),
+	}
+	plaintext := apiKeyPrefix + "_" + key.ID + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	key.Hash = hashAPIKey(plaintext)
+	v.contents.APIKeys[key.ID] = key
+	v.byHash[key.Hash] = key.ID
+	if err := v.save(); err != nil {
+		return "", APIKey{}, err
+	}
+	return plaintext, key, nil
+}
+
+// newAPIKeyID returns a random ID that no issued API key has, an existing key must never be replaced. The caller holds
+// the lock.
+func (v *Vault) newAPIKeyID() (string, error) {
+	id := make([]byte, apiKeyIDSize)
+	for {
+		if _, err := rand.Read(id); err != nil {
+			return "", err
+		}
+		if _, exists := v.contents.APIKeys[hex.EncodeToString(id)]; !exists {
+			return hex.EncodeToString(id), nil
+		}
+	}
+}
+
+// RevokeAPIKey deletes the API key with the given ID.
+func (v *Vault) RevokeAPIKey(id string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, exists := v.contents.APIKeys[id]; !exists {
+		return ErrAPIKeyNotFound
+	}
+	delete(v.contents.APIKeys, id)
+	v.reindex()
+	return v.save()
+}
+
+// APIKeys returns all issued API keys, sorted by creation time.
+func (v *Vault) APIKeys() []APIKey {
+	_ = v.refresh()
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(v.contents.APIKeys))
+	for _, key := range v.contents.APIKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Resolve maps a plaintext API key to the account it was issued for. If the changed vault file can't be reloaded, no
+// key is resolved: a key revoked in the file must not keep working.
+func (v *Vault) Resolve(apiKey string) (string, Credentials, error) {
+	hash := hashAPIKey(apiKey)
+	if err := v.refresh(); err != nil {
+		return "", Credentials{}, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	id, exists := v.byHash[hash]
+	if !exists {
+		return "", Credentials{}, ErrInvalidAPIKey
+	}
+	account, exists := v.contents.Accounts[v.contents.APIKeys[id].Account]
+	if !exists {
+		return "", Credentials{}, ErrInvalidAPIKey
+	}
+	return account.Name, account.Credentials, nil
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func (v *Vault) reindex() {
+	v.byHash = make(map[string]string, len(v.contents.APIKeys))
+	for id, key := range v.contents.APIKeys {
+		v.byHash[key.Hash] = id
+	}
+}
+
+func (v *Vault) decrypt(data []byte) (contents, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return contents{}, fmt.Errorf("vault file is corrupted: %w", err)
+	}
+	if env.Version != fileVersion {
+		return contents{}, fmt.Errorf("unsupported vault file version %d", env.Version)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return contents{}, fmt.Errorf("vault file is corrupted: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.Data)
+	if err != nil {
+		return contents{}, fmt.Errorf("vault file is corrupted: %w", err)
+	}
+	if len(nonce) != v.aead.NonceSize() {
+		return contents{}, errors.New("vault file is corrupted: invalid nonce")
+	}
+	plaintext, err := v.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return contents{}, errors.New("can't decrypt vault file, wrong key or corrupted file")
+	}
+	var c contents
+	if err = json.Unmarshal(plaintext, &c); err != nil {
+		return contents{}, fmt.Errorf("vault file is corrupted: %w", err)
+	}
+	if c.Accounts == nil {
+		c.Accounts = make(map[string]Account)
+	}
+	if c.APIKeys == nil {
+		c.APIKeys = make(map[string]APIKey)
+	}
+	return c, nil
+}
+
+// Save writes the vault to disk. Mutating methods save automatically, so this is only needed to create an empty vault.
+func (v *Vault) Save() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.save()
+}
+
+// save encrypts the contents and atomically replaces the vault file. The caller must hold the write lock.
+func (v *Vault) save() error {
+	plaintext, err := json.Marshal(v.contents)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(envelope{
+		Version: fileVersion,
+		Nonce:   base64.StdEncoding.EncodeToString(nonce),
+		Data:    base64.StdEncoding.EncodeToString(v.aead.Seal(nil, nonce, plaintext, nil)),
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), filepath.Base(v.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("can't write vault file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("can't write vault file: %w", err)
+	}
+	if err = tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("can't write vault file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("can't write vault file: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("can't write vault file: %w", err)
+	}
+	// the saved file is not reloaded
+	if saved, err := os.Stat(v.path); err == nil {
+		v.loaded = saved
+	}
+	return nil
+}
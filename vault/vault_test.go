@@ -0,0 +1,145 @@
+package vault
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	encoded, err := GenerateKey()
+	require.NoError(t, err)
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	return key
+}
+
+func TestVault_PersistsEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	key := newKey(t)
+
+	v, err := Open(path, key)
+	require.NoError(t, err)
+	require.NoError(t, v.AddAccount("home", Credentials{AccessKey: "access-123", SecretKey: "secret-456"}))
+	apiKey, issued, err := v.IssueAPIKey("home", "dashboard")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(apiKey, "efk_"+issued.ID+"_"))
+	assert.Len(t, issued.ID, 16)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "access-123")
+	assert.NotContains(t, string(data), "secret-456")
+	assert.NotContains(t, string(data), apiKey)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	reopened, err := Open(path, key)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"home"}, reopened.Accounts())
+
+	account, credentials, err := reopened.Resolve(apiKey)
+	require.NoError(t, err)
+	assert.Equal(t, "home", account)
+	assert.Equal(t, Credentials{AccessKey: "access-123", SecretKey: "secret-456"}, credentials)
+
+	_, err = Open(path, newKey(t))
+	assert.Error(t, err, "wrong key must not decrypt the vault")
+}
+
+func TestVault_APIKeys(t *testing.T) {
+	v, err := Open(filepath.Join(t.TempDir(), "vault.json"), newKey(t))
+	require.NoError(t, err)
+
+	_, _, err = v.IssueAPIKey("unknown", "")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	require.NoError(t, v.AddAccount("home", Credentials{AccessKey: "a", SecretKey: "s"}))
+	assert.ErrorIs(t, v.AddAccount("home", Credentials{AccessKey: "a", SecretKey: "s"}), ErrAccountExists)
+
+	first, firstKey, err := v.IssueAPIKey("home", "first")
+	require.NoError(t, err)
+	second, _, err := v.IssueAPIKey("home", "second")
+	require.NoError(t, err)
+	assert.Len(t, v.APIKeys(), 2)
+
+	_, _, err = v.Resolve("efk_invalid")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	require.NoError(t, v.RevokeAPIKey(firstKey.ID))
+	_, _, err = v.Resolve(first)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	assert.ErrorIs(t, v.RevokeAPIKey(firstKey.ID), ErrAPIKeyNotFound)
+
+	require.NoError(t, v.RemoveAccount("home"))
+	_, _, err = v.Resolve(second)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey, "removing an account revokes its api keys")
+	assert.Empty(t, v.APIKeys())
+}
+
+func TestVault_ReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	key := newKey(t)
+	server, err := Open(path, key)
+	require.NoError(t, err)
+
+	// the vault sub command changes the file while the server is running
+	command, err := Open(path, key)
+	require.NoError(t, err)
+	require.NoError(t, command.AddAccount("home", Credentials{AccessKey: "a", SecretKey: "s"}))
+	apiKey, issued, err := command.IssueAPIKey("home", "dashboard")
+	require.NoError(t, err)
+
+	account, _, err := server.Resolve(apiKey)
+	require.NoError(t, err)
+	assert.Equal(t, "home", account)
+	assert.Equal(t, []string{"home"}, server.Accounts())
+
+	command, err = Open(path, key)
+	require.NoError(t, err)
+	require.NoError(t, command.RevokeAPIKey(issued.ID))
+	_, _, err = server.Resolve(apiKey)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey, "a revoked key stops working without a restart")
+
+	require.NoError(t, os.WriteFile(path, []byte("corrupted"), 0o600))
+	_, err = server.Credentials("home")
+	assert.Error(t, err, "nothing is resolved from a file that can't be loaded")
+}
+
+func TestReadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	valid, err := GenerateKey()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "valid key", content: valid + "\n"},
+		{name: "not base64", content: "not a key", wantErr: true},
+		{name: "too short", content: base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_"))
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			key, err := ReadKeyFile(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, key, keySize)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go-ecoflow-api-server/config"
+	"go-ecoflow-api-server/vault"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+const vaultUsage = `Usage: go-ecoflow-api-server vault <command> [flags]
+
+Commands:
+  init            create the master key file (if it does not exist) and an empty vault
+  add-account     store an Ecoflow account: -name NAME [-access-key KEY -secret-key KEY]
+                  the keys can also be passed in ECOFLOW_ACCESS_KEY and ECOFLOW_SECRET_KEY
+  remove-account  delete an account and all its API keys: -name NAME
+  list            list accounts and issued API keys
+  issue-key       issue a new API key: -account NAME [-description TEXT]
+  revoke-key      revoke an API key: -id ID
+
+Common flags:
+  -file           vault file (default: vault.file from the server configuration)
+  -key-file       master key file (default: vault.key_file from the server configuration)
+
+The server reads the vault at startup, restart it to apply changes.
+`
+
+// runVaultCommand implements the "vault" sub command which manages accounts and API keys of the credential vault.
+func runVaultCommand(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(vaultUsage)
+	}
+	cfg, err := config.Load(nil, os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	command := args[0]
+	fs := flag.NewFlagSet("vault "+command, flag.ContinueOnError)
+	file := fs.String("file", cfg.Vault.File, "vault file")
+	keyFile := fs.String("key-file", cfg.Vault.KeyFile, "master key file")
+	name := fs.String("name", "", "account name")
+	accessKey := fs.String("access-key", os.Getenv("ECOFLOW_ACCESS_KEY"), "Ecoflow access key")
+	secretKey := fs.String("secret-key", os.Getenv("ECOFLOW_SECRET_KEY"), "Ecoflow secret key")
+	account := fs.String("account", "", "account the API key is issued for")
+	description := fs.String("description", "", "API key description")
+	id := fs.String("id", "", "API key id")
+	if err = fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *file == "" || *keyFile == "" {
+		return errors.New("vault file and key file are mandatory")
+	}
+
+	if command == "init" {
+		if _, err = os.Stat(*keyFile); errors.Is(err, os.ErrNotExist) {
+			key, err := vault.GenerateKey()
+			if err != nil {
+				return err
+			}
+			if err = os.WriteFile(*keyFile, []byte(key+"\n"), 0o600); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(stdout, "created master key", *keyFile)
+		}
+	}
+
+	key, err := vault.ReadKeyFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	v, err := vault.Open(*file, key)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "init":
+		if _, err = os.Stat(*file); err == nil {
+			_, _ = fmt.Fprintln(stdout, "vault already exists", *file)
+			return nil
+		}
+		if err = v.Save(); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(stdout, "created vault", *file)
+	case "add-account":
+		if err = v.AddAccount(*name, vault.Credentials{AccessKey: *accessKey, SecretKey: *secretKey}); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(stdout, "added account", *name)
+	case "remove-account":
+		if err = v.RemoveAccount(*name); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(stdout, "removed account", *name)
+	case "list":
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ACCOUNT\tAPI KEY ID\tDESCRIPTION\tCREATED")
+		for _, a := range v.Accounts() {
+			_, _ = fmt.Fprintf(w, "%s\t\t\t\n", a)
+		}
+		for _, k := range v.APIKeys() {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", k.Account, k.ID, k.Description, k.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+	case "issue-key":
+		plaintext, apiKey, err := v.IssueAPIKey(*account, *description)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(stdout, "issued API key %s for account %s, it is shown only once:\n%s\n", apiKey.ID, apiKey.Account, plaintext)
+	case "revoke-key":
+		if err = v.RevokeAPIKey(*id); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(stdout, "revoked API key", *id)
+	default:
+		return errors.New(vaultUsage)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// checkInterval is the interval at which the deliverer looks for due deliveries.
+const checkInterval = time.Second
+
+// Sign returns the signature of a payload sent at the Unix timestamp: "sha256=" followed by the hex encoded
+// HMAC-SHA256 of "{timestamp}.{payload}" with the secret of the subscription.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Config contains the settings of the deliveries.
+type Config struct {
+	// Timeout limits every attempt
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts before a delivery is dead
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles with every retry up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// HTTPClient sends the requests, http.DefaultClient is used when it's nil
+	HTTPClient *http.Client
+}
+
+// Deliverer sends the pending deliveries of the store and retries the failed ones with an exponential backoff.
+type Deliverer struct {
+	store  *Store
+	cfg    Config
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+	running  sync.WaitGroup
+}
+
+func NewDeliverer(store *Store, cfg Config, logger *slog.Logger) *Deliverer {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &Deliverer{store: store, cfg: cfg, logger: logger, now: time.Now, inFlight: make(map[string]bool)}
+}
+
+// Run sends the due deliveries until ctx is cancelled. Attempts that were started are completed before it returns.
+func (d *Deliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		d.deliverDue(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			d.running.Wait()
+			return
+		}
+	}
+}
+
+// deliverDue starts an attempt for every due delivery that is not being attempted already.
+func (d *Deliverer) deliverDue(ctx context.Context) {
+	for _, delivery := range d.store.due(d.now()) {
+		d.mu.Lock()
+		if d.inFlight[delivery.ID] {
+			d.mu.Unlock()
+			continue
+		}
+		d.inFlight[delivery.ID] = true
+		d.mu.Unlock()
+
+		d.running.Add(1)
+		go func(delivery Delivery) {
+			defer d.running.Done()
+			delivery = d.attempt(context.WithoutCancel(ctx), delivery)
+			if err := d.store.update(delivery); err != nil {
+				d.logger.Warn("Failed to save the webhook delivery", "delivery", delivery.ID, "error", err)
+			}
+			d.mu.Lock()
+			delete(d.inFlight, delivery.ID)
+			d.mu.Unlock()
+		}(delivery)
+	}
+}
+
+// attempt sends the delivery to the URL of its subscription and returns it with the result of the attempt.
+func (d *Deliverer) attempt(ctx context.Context, delivery Delivery) Delivery {
+	subscription, exists := d.store.subscription(delivery.SubscriptionID)
+	if !exists {
+		return delivery
+	}
+
+	delivery.Attempts++
+	delivery.LastStatusCode, delivery.LastError = 0, ""
+	status, err := d.send(ctx, subscription, delivery)
+	now := d.now().UTC()
+	delivery.UpdatedAt = now
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		delivery.Status = DeliveryDelivered
+		delivery.LastStatusCode = status
+		delivery.NextAttemptAt = nil
+		return delivery
+	case err != nil:
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastStatusCode = status
+		delivery.LastError = fmt.Sprintf("unexpected status %d", status)
+	}
+
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		delivery.Status = DeliveryDead
+		delivery.NextAttemptAt = nil
+		d.logger.Warn("Webhook delivery failed, moved to the dead letters", "subscription", subscription.ID, "delivery", delivery.ID, "attempts", delivery.Attempts, "error", delivery.LastError)
+		return delivery
+	}
+	next := now.Add(d.backoff(delivery.Attempts))
+	delivery.NextAttemptAt = &next
+	return delivery
+}
+
+func (d *Deliverer) send(ctx context.Context, subscription Subscription, delivery Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain the body, so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay after the failed attempt: BaseDelay * 2^(attempts-1), capped at MaxDelay.
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseDelay << (attempts - 1)
+	if delay <= 0 || delay > d.cfg.MaxDelay {
+		delay = d.cfg.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,224 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// receiver is a webhook endpoint that verifies the signatures and answers with the next status.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	events   []Event
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(rc.t, err)
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	require.NoError(rc.t, err)
+	assert.Equal(rc.t, Sign(rc.secret, timestamp, body), r.Header.Get(HeaderSignature))
+	assert.NotEmpty(rc.t, r.Header.Get(HeaderDelivery))
+
+	var event Event
+	require.NoError(rc.t, json.Unmarshal(body, &event))
+	assert.Equal(rc.t, event.Type, r.Header.Get(HeaderEvent))
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.events = append(rc.events, event)
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestStore(t *testing.T, now *time.Time) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "webhooks.json"), 10)
+	require.NoError(t, err)
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func newTestDeliverer(store *Store, now *time.Time) *Deliverer {
+	deliverer := NewDeliverer(store, Config{
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		BaseDelay:   10 * time.Second,
+		MaxDelay:    15 * time.Second,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	deliverer.now = func() time.Time { return *now }
+	return deliverer
+}
+
+// deliver runs the attempts of the due deliveries and waits for them.
+func deliver(d *Deliverer) {
+	d.deliverDue(context.Background())
+	d.running.Wait()
+}
+
+func offline(id string) Event {
+	return Event{ID: id, Type: EventDeviceOffline, Time: start, Account: "home", SerialNumber: "R601ZEB4ZEAL0528"}
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "sha256=ea3adbc2e448b83a4b607454a331a3e169d8cd02571ffc47d91d8ba92ab8c34e", Sign("secret", 1740830400, []byte(`{}`)))
+	assert.NotEqual(t, Sign("secret", 1740830400, []byte(`{}`)), Sign("secret", 1740830401, []byte(`{}`)), "the timestamp is signed")
+	assert.NotEqual(t, Sign("secret", 1740830400, []byte(`{}`)), Sign("other", 1740830400, []byte(`{}`)))
+}
+
+func TestDeliverer_DeliversSignedEvents(t *testing.T) {
+	now := start
+	store := newTestStore(t, &now)
+	endpoint := &receiver{t: t}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	subscription, err := store.Create(Subscription{Account: "home", URL: server.URL, Enabled: true})
+	require.NoError(t, err)
+	require.Len(t, subscription.Secret, 64, "a secret is generated")
+	endpoint.secret = subscription.Secret
+	_, err = store.Create(Subscription{Account: "home", URL: server.URL, Events: []string{EventFaultRaised}, Enabled: true})
+	require.NoError(t, err)
+	_, err = store.Create(Subscription{Account: "office", URL: server.URL, Enabled: true})
+	require.NoError(t, err)
+
+	require.NoError(t, store.enqueue(offline("e1")))
+	deliver(newTestDeliverer(store, &now))
+
+	require.Len(t, endpoint.events, 1, "only the matching subscription of the account receives the event")
+	assert.Equal(t, offline("e1"), endpoint.events[0])
+	deliveries, err := store.Deliveries("home", subscription.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, DeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].LastStatusCode)
+	assert.Nil(t, deliveries[0].NextAttemptAt)
+}
+
+func TestDeliverer_RetriesWithBackoff(t *testing.T) {
+	now := start
+	store := newTestStore(t, &now)
+	endpoint := &receiver{t: t, statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusInternalServerError}}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+	subscription, err := store.Create(Subscription{Account: "home", URL: server.URL, Enabled: true})
+	require.NoError(t, err)
+	endpoint.secret = subscription.Secret
+	deliverer := newTestDeliverer(store, &now)
+
+	require.NoError(t, store.enqueue(offline("e1")))
+	deliver(deliverer)
+	deliveries, err := store.Deliveries("home", subscription.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, DeliveryPending, deliveries[0].Status)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].LastStatusCode)
+	assert.Equal(t, start.Add(10*time.Second), *deliveries[0].NextAttemptAt)
+
+	now = start.Add(9 * time.Second)
+	deliver(deliverer)
+	assert.Len(t, endpoint.events, 1, "not due before the backoff")
+
+	now = start.Add(10 * time.Second)
+	deliver(deliverer)
+	deliveries, _ = store.Deliveries("home", subscription.ID)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, now.Add(15*time.Second), *deliveries[0].NextAttemptAt, "the doubled delay is capped")
+
+	now = now.Add(15 * time.Second)
+	deliver(deliverer)
+	require.Len(t, endpoint.events, 3)
+	deliveries, _ = store.Deliveries("home", subscription.ID)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, DeliveryDead, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, "unexpected status 503", deliveries[0].LastError)
+	deadLetters, err := store.DeadLetters("home", subscription.ID)
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+
+	now = now.Add(time.Hour)
+	deliver(deliverer)
+	assert.Len(t, endpoint.events, 3, "dead letters are not retried")
+
+	retried, err := store.Retry("home", subscription.ID, deadLetters[0].ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, deadLetters[0].ID, retried.ID)
+	assert.Equal(t, DeliveryPending, retried.Status)
+	assert.Zero(t, retried.Attempts)
+	deadLetters, _ = store.DeadLetters("home", subscription.ID)
+	assert.Empty(t, deadLetters)
+	_, err = store.Retry("home", subscription.ID, retried.ID)
+	assert.ErrorIs(t, err, ErrDeliveryNotFound)
+
+	deliver(deliverer)
+	require.Len(t, endpoint.events, 4)
+	deliveries, _ = store.Deliveries("home", subscription.ID)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, retried.ID, deliveries[0].ID, "newest first")
+	assert.Equal(t, DeliveryPending, deliveries[0].Status, "the retry failed once")
+}
+
+func TestDeliverer_ReportsRequestErrors(t *testing.T) {
+	now := start
+	store := newTestStore(t, &now)
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	subscription, err := store.Create(Subscription{Account: "home", URL: server.URL, Enabled: true})
+	require.NoError(t, err)
+
+	require.NoError(t, store.enqueue(offline("e1")))
+	deliver(newTestDeliverer(store, &now))
+
+	deliveries, _ := store.Deliveries("home", subscription.ID)
+	require.Len(t, deliveries, 1)
+	assert.Zero(t, deliveries[0].LastStatusCode)
+	assert.NotEmpty(t, deliveries[0].LastError)
+}
+
+func TestStore_PersistsPendingDeliveries(t *testing.T) {
+	now := start
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	store, err := Open(path, 10)
+	require.NoError(t, err)
+	store.now = func() time.Time { return now }
+	subscription, err := store.Create(Subscription{Account: "home", URL: "https://example.com/hook", Enabled: true})
+	require.NoError(t, err)
+	require.NoError(t, store.enqueue(offline("e1")))
+
+	reopened, err := Open(path, 10)
+	require.NoError(t, err)
+	assert.Len(t, reopened.due(now), 1, "pending deliveries survive restarts")
+	loaded, err := reopened.Get("home", subscription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, subscription.Secret, loaded.Secret)
+
+	updated, err := reopened.Update("home", subscription.ID, Subscription{URL: "https://example.com/other", Enabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, subscription.Secret, updated.Secret, "the secret is kept without a new one")
+	_, err = reopened.Get("office", subscription.ID)
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+
+	require.NoError(t, reopened.Delete("home", subscription.ID))
+	assert.Empty(t, reopened.due(now), "the deliveries of deleted subscriptions are dropped")
+}
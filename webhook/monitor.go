@@ -0,0 +1,201 @@
+package webhook
+
+import (
+	"context"
+	"github.com/tess1o/go-ecoflow"
+	"go-ecoflow-api-server/ingest"
+	"go-ecoflow-api-server/service"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// BatteryParams are the parameters with the state of charge of a device, the first one the device has is used.
+var BatteryParams = []string{"bms_bmsStatus.soc", "pd.soc"}
+
+// FaultParams are the fault codes of a device that are watched, a non-zero code is a fault.
+var FaultParams = []string{"inv.errCode", "mppt.faultCode", "bms_bmsStatus.bmsFault"}
+
+// DeviceLister returns the devices of the vault account and whether they are online, see ecoflow.Client.GetDeviceList.
+type DeviceLister func(ctx context.Context, account string) ([]ecoflow.DeviceInfo, error)
+
+// DeviceSource provides the parameters of the devices of the vault accounts, see ingest.Store. Devices are identified
+// by the identity of their account, see service.VaultIdentity.
+type DeviceSource interface {
+	Fresh(account, sn string) (map[string]interface{}, time.Time, bool)
+}
+
+type deviceKey struct {
+	account string
+	sn      string
+}
+
+// deviceState is the observed state of a device. The battery and the fault codes keep their last known values while
+// the parameters of the device are not available.
+type deviceState struct {
+	online   bool
+	hasSOC   bool
+	soc      float64
+	socParam string
+	faults   map[string]float64
+}
+
+// Monitor watches the devices of the accounts with enabled subscriptions and queues the deliveries of their events.
+// Events are changes between two checks, the first check of a device only records its state.
+type Monitor struct {
+	store    *Store
+	list     DeviceLister
+	devices  DeviceSource
+	interval time.Duration
+	logger   *slog.Logger
+	now      func() time.Time
+
+	states map[deviceKey]deviceState
+}
+
+func NewMonitor(store *Store, list DeviceLister, devices DeviceSource, interval time.Duration, logger *slog.Logger) *Monitor {
+	return &Monitor{
+		store:    store,
+		list:     list,
+		devices:  devices,
+		interval: interval,
+		logger:   logger,
+		now:      time.Now,
+		states:   make(map[deviceKey]deviceState),
+	}
+}
+
+// Run checks the devices every interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check observes the devices of the accounts with enabled subscriptions and queues the deliveries of their events.
+func (m *Monitor) check(ctx context.Context) {
+	for _, account := range m.store.accounts() {
+		devices, err := m.list(ctx, account)
+		if err != nil {
+			if ctx.Err() == nil {
+				m.logger.Warn("Failed to list the devices for the webhooks", "account", account, "error", err)
+			}
+			continue
+		}
+		thresholds := m.thresholds(account)
+		for _, device := range devices {
+			for _, event := range m.observe(account, device, thresholds) {
+				if err = m.store.enqueue(event); err != nil {
+					m.logger.Warn("Failed to queue the webhook deliveries", "event", event.Type, "serial_number", event.SerialNumber, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// thresholds returns the distinct battery thresholds of the enabled subscriptions of the account.
+func (m *Monitor) thresholds(account string) []float64 {
+	var thresholds []float64
+	for _, subscription := range m.store.enabled(account) {
+		if subscription.BatteryThreshold > 0 && !slices.Contains(thresholds, subscription.BatteryThreshold) {
+			thresholds = append(thresholds, subscription.BatteryThreshold)
+		}
+	}
+	return thresholds
+}
+
+// observe records the state of the device and returns the events of its changes since the last check.
+func (m *Monitor) observe(account string, device ecoflow.DeviceInfo, thresholds []float64) []Event {
+	key := deviceKey{account: account, sn: device.SN}
+	previous, known := m.states[key]
+	current := deviceState{
+		online:   device.Online == 1,
+		hasSOC:   previous.hasSOC,
+		soc:      previous.soc,
+		socParam: previous.socParam,
+		faults:   make(map[string]float64, len(FaultParams)),
+	}
+	for param, value := range previous.faults {
+		current.faults[param] = value
+	}
+	if params, _, ok := m.devices.Fresh(service.VaultIdentity(account), device.SN); ok && current.online {
+		for _, param := range BatteryParams {
+			if value, ok := ingest.Number(params[param]); ok {
+				current.hasSOC, current.soc, current.socParam = true, value, param
+				break
+			}
+		}
+		for _, param := range FaultParams {
+			if value, ok := ingest.Number(params[param]); ok {
+				current.faults[param] = value
+			}
+		}
+	}
+	m.states[key] = current
+	if !known {
+		return nil
+	}
+
+	var events []Event
+	newEvent := func(eventType string, data map[string]interface{}) {
+		id, err := randomHex(8)
+		if err != nil {
+			m.logger.Warn("Failed to create the webhook event", "error", err)
+			return
+		}
+		events = append(events, Event{
+			ID:           id,
+			Type:         eventType,
+			Time:         m.now().UTC(),
+			Account:      account,
+			SerialNumber: device.SN,
+			Data:         data,
+		})
+	}
+
+	if previous.online && !current.online {
+		newEvent(EventDeviceOffline, nil)
+	} else if !previous.online && current.online {
+		newEvent(EventDeviceOnline, nil)
+	}
+	if previous.hasSOC && current.hasSOC {
+		for _, threshold := range thresholds {
+			data := map[string]interface{}{
+				"param":     current.socParam,
+				"value":     current.soc,
+				"previous":  previous.soc,
+				"threshold": threshold,
+			}
+			if previous.soc >= threshold && current.soc < threshold {
+				newEvent(EventBatteryBelow, data)
+			} else if previous.soc < threshold && current.soc >= threshold {
+				newEvent(EventBatteryAbove, data)
+			}
+		}
+	}
+	for _, param := range FaultParams {
+		before, ok := previous.faults[param]
+		after := current.faults[param]
+		if !ok || (before == 0) == (after == 0) {
+			continue
+		}
+		data := map[string]interface{}{
+			"param":    param,
+			"value":    after,
+			"previous": before,
+		}
+		if after != 0 {
+			newEvent(EventFaultRaised, data)
+		} else {
+			newEvent(EventFaultCleared, data)
+		}
+	}
+	return events
+}
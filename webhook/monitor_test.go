@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"go-ecoflow-api-server/service"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tess1o/go-ecoflow"
+)
+
+// devices is a DeviceSource with the parameters of the devices of the home account.
+type devices map[string]map[string]interface{}
+
+func (d devices) Fresh(account, sn string) (map[string]interface{}, time.Time, bool) {
+	params, ok := d[sn]
+	if account != service.VaultIdentity("home") || !ok {
+		return nil, time.Time{}, false
+	}
+	return params, start, true
+}
+
+// deviceList is a DeviceLister with the devices of the home account.
+type deviceList map[string]int
+
+func (l deviceList) list(_ context.Context, account string) ([]ecoflow.DeviceInfo, error) {
+	if account != "home" {
+		return nil, errors.New("unknown account")
+	}
+	var result []ecoflow.DeviceInfo
+	for sn, online := range l {
+		result = append(result, ecoflow.DeviceInfo{SN: sn, Online: online})
+	}
+	return result, nil
+}
+
+// newTestMonitor returns a monitor of the subscriptions and their IDs.
+func newTestMonitor(t *testing.T, list deviceList, params devices, subscriptions ...Subscription) (*Monitor, *Store, []string) {
+	t.Helper()
+	now := start
+	store := newTestStore(t, &now)
+	var ids []string
+	for _, subscription := range subscriptions {
+		created, err := store.Create(subscription)
+		require.NoError(t, err)
+		ids = append(ids, created.ID)
+	}
+	monitor := NewMonitor(store, list.list, params, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	monitor.now = func() time.Time { return now }
+	return monitor, store, ids
+}
+
+// queued returns the types of the pending deliveries of the subscription and removes them.
+func queued(t *testing.T, store *Store, subscription string) []string {
+	t.Helper()
+	var events []string
+	for _, delivery := range store.due(start.Add(time.Hour)) {
+		if delivery.SubscriptionID != subscription {
+			continue
+		}
+		events = append(events, delivery.EventType)
+		delivery.Status = DeliveryDelivered
+		require.NoError(t, store.update(delivery))
+	}
+	return events
+}
+
+func TestMonitor_SendsEvents(t *testing.T) {
+	list := deviceList{"R601ZEB4ZEAL0528": 1}
+	params := devices{"R601ZEB4ZEAL0528": {"bms_bmsStatus.soc": 35.0, "inv.errCode": 0.0}}
+	monitor, store, ids := newTestMonitor(t, list, params,
+		Subscription{Account: "home", URL: "https://example.com/all", BatteryThreshold: 20, Enabled: true},
+		Subscription{Account: "home", URL: "https://example.com/faults", Events: []string{EventFaultRaised, EventFaultCleared}, Enabled: true},
+		Subscription{Account: "home", URL: "https://example.com/other", SerialNumbers: []string{"R331ZEB4ZEAL0528"}, Enabled: true},
+		Subscription{Account: "home", URL: "https://example.com/disabled"},
+	)
+	all, faults, other, disabled := ids[0], ids[1], ids[2], ids[3]
+	ctx := context.Background()
+
+	monitor.check(ctx)
+	assert.Empty(t, queued(t, store, all), "the first check records the state")
+
+	params["R601ZEB4ZEAL0528"]["bms_bmsStatus.soc"] = 15.0
+	params["R601ZEB4ZEAL0528"]["inv.errCode"] = 3.0
+	monitor.check(ctx)
+	assert.ElementsMatch(t, []string{EventBatteryBelow, EventFaultRaised}, queued(t, store, all))
+	assert.Equal(t, []string{EventFaultRaised}, queued(t, store, faults))
+
+	monitor.check(ctx)
+	assert.Empty(t, queued(t, store, all), "events are only sent on changes")
+
+	list["R601ZEB4ZEAL0528"] = 0
+	params["R601ZEB4ZEAL0528"]["bms_bmsStatus.soc"] = 25.0
+	params["R601ZEB4ZEAL0528"]["inv.errCode"] = 0.0
+	monitor.check(ctx)
+	assert.Equal(t, []string{EventDeviceOffline}, queued(t, store, all), "the parameters of offline devices are ignored")
+
+	list["R601ZEB4ZEAL0528"] = 1
+	monitor.check(ctx)
+	assert.ElementsMatch(t, []string{EventDeviceOnline, EventBatteryAbove, EventFaultCleared}, queued(t, store, all))
+	assert.Equal(t, []string{EventFaultCleared}, queued(t, store, faults))
+
+	assert.Empty(t, queued(t, store, other), "the events of other devices are filtered")
+	assert.Empty(t, queued(t, store, disabled))
+}
+
+func TestMonitor_KeepsTheLastKnownParameters(t *testing.T) {
+	list := deviceList{"R601ZEB4ZEAL0528": 1}
+	params := devices{"R601ZEB4ZEAL0528": {"pd.soc": 25.0}}
+	monitor, store, ids := newTestMonitor(t, list, params,
+		Subscription{Account: "home", URL: "https://example.com/low", BatteryThreshold: 20, Enabled: true},
+		Subscription{Account: "home", URL: "https://example.com/critical", BatteryThreshold: 10, Enabled: true},
+	)
+	low, critical := ids[0], ids[1]
+	ctx := context.Background()
+
+	monitor.check(ctx)
+	delete(params, "R601ZEB4ZEAL0528")
+	monitor.check(ctx)
+	assert.Empty(t, queued(t, store, low), "missing parameters are no change")
+
+	params["R601ZEB4ZEAL0528"] = map[string]interface{}{"pd.soc": 5.0}
+	monitor.check(ctx)
+	assert.Equal(t, []string{EventBatteryBelow}, queued(t, store, low))
+	assert.Equal(t, []string{EventBatteryBelow}, queued(t, store, critical), "every subscription gets the crossing of its threshold")
+
+	params["R601ZEB4ZEAL0528"]["pd.soc"] = 15.0
+	monitor.check(ctx)
+	assert.Empty(t, queued(t, store, low))
+	assert.Equal(t, []string{EventBatteryAbove}, queued(t, store, critical))
+}
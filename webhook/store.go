@@ -0,0 +1,560 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ecoflow-api-server/jsonfile"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// EventDeviceOffline is sent when a device of the account goes offline.
+	EventDeviceOffline = "device.offline"
+	// EventDeviceOnline is sent when a device of the account is online again.
+	EventDeviceOnline = "device.online"
+	// EventBatteryBelow is sent when the state of charge of a device drops below the battery threshold.
+	EventBatteryBelow = "battery.below"
+	// EventBatteryAbove is sent when the state of charge of a device rises to the battery threshold again.
+	EventBatteryAbove = "battery.above"
+	// EventFaultRaised is sent when a fault code of a device turns non-zero, see FaultParams.
+	EventFaultRaised = "fault.raised"
+	// EventFaultCleared is sent when a fault code of a device turns zero again.
+	EventFaultCleared = "fault.cleared"
+)
+
+// Events are the events a subscription can be filtered by.
+var Events = []string{EventDeviceOffline, EventDeviceOnline, EventBatteryBelow, EventBatteryAbove, EventFaultRaised, EventFaultCleared}
+
+const (
+	// DeliveryPending deliveries are sent or retried.
+	DeliveryPending = "pending"
+	// DeliveryDelivered deliveries were acknowledged with a 2xx response.
+	DeliveryDelivered = "delivered"
+	// DeliveryDead deliveries failed every attempt, they are kept in the dead letters until they are retried.
+	DeliveryDead = "dead"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrDeliveryNotFound     = errors.New("delivery not found")
+)
+
+// Subscription sends the events of the devices of an account to a URL.
+type Subscription struct {
+	ID string `json:"id"`
+	// Account is the vault account whose devices are watched
+	Account string `json:"account"`
+	Name    string `json:"name"`
+	// URL receives the events as POST requests
+	URL string `json:"url"`
+	// Secret signs the payloads, see Sign
+	Secret string `json:"secret,omitempty"`
+	// Events filters the events, all events are sent if it's empty
+	Events []string `json:"events"`
+	// SerialNumbers filters the devices, the events of all devices are sent if it's empty
+	SerialNumbers []string `json:"serial_numbers"`
+	// BatteryThreshold is the state of charge in percent the battery events are sent for, there are none if it's 0
+	BatteryThreshold float64   `json:"battery_threshold,omitempty"`
+	Enabled          bool      `json:"enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Validate checks the URL, the events and the battery threshold of the subscription.
+func (s Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	for _, event := range s.Events {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("unknown event %q, expected one of %v", event, Events)
+		}
+	}
+	if s.BatteryThreshold < 0 || s.BatteryThreshold >= 100 {
+		return fmt.Errorf("battery_threshold must be between 0 and 100")
+	}
+	if s.BatteryThreshold == 0 && (slices.Contains(s.Events, EventBatteryBelow) || slices.Contains(s.Events, EventBatteryAbove)) {
+		return fmt.Errorf("battery events require a battery_threshold")
+	}
+	return nil
+}
+
+// Matches reports whether the event passes the filters of the subscription.
+func (s Subscription) Matches(event Event) bool {
+	if len(s.Events) > 0 && !slices.Contains(s.Events, event.Type) {
+		return false
+	}
+	if len(s.SerialNumbers) > 0 && !slices.Contains(s.SerialNumbers, event.SerialNumber) {
+		return false
+	}
+	if event.Type == EventBatteryBelow || event.Type == EventBatteryAbove {
+		return event.Data["threshold"] == s.BatteryThreshold
+	}
+	return true
+}
+
+// Event is the payload of a webhook request.
+type Event struct {
+	ID           string                 `json:"id"`
+	Type         string                 `json:"type"`
+	Time         time.Time              `json:"time"`
+	Account      string                 `json:"account"`
+	SerialNumber string                 `json:"serial_number"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+}
+
+// Delivery is the delivery of an event to a subscription.
+type Delivery struct {
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscription_id"`
+	EventID        string `json:"event_id"`
+	EventType      string `json:"event_type"`
+	// Payload is the request body, the event
+	Payload json.RawMessage `json:"payload" swaggertype:"object"`
+	// Status is pending, delivered or dead
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	// LastStatusCode is the HTTP status of the last attempt, it is not set if the request failed
+	LastStatusCode int       `json:"last_status_code,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// NextAttemptAt is the time of the next attempt of a pending delivery
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// contents is the structure of the store file.
+type contents struct {
+	Subscriptions map[string]Subscription `json:"subscriptions"` // keyed by ID
+	Pending       map[string]Delivery     `json:"pending"`       // keyed by delivery ID
+	Deliveries    map[string][]Delivery   `json:"deliveries"`    // completed, keyed by subscription ID, oldest first
+	DeadLetters   map[string][]Delivery   `json:"dead_letters"`  // keyed by subscription ID, oldest first
+}
+
+// Store keeps the subscriptions, the pending deliveries, the latest completed deliveries and the dead letters in a JSON
+// file. All methods are safe for concurrent use, mutating methods persist the store before returning.
+type Store struct {
+	path        string
+	historySize int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	contents contents
+}
+
+// Open loads the store from path, a missing file results in an empty store. historySize is the number of completed
+// deliveries and of dead letters kept per subscription.
+func Open(path string, historySize int) (*Store, error) {
+	s := &Store{
+		path:        path,
+		historySize: historySize,
+		now:         time.Now,
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("can't read webhooks file: %w", err)
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, &s.contents); err != nil {
+			return nil, fmt.Errorf("webhooks file is corrupted: %w", err)
+		}
+	}
+	if s.contents.Subscriptions == nil {
+		s.contents.Subscriptions = make(map[string]Subscription)
+	}
+	if s.contents.Pending == nil {
+		s.contents.Pending = make(map[string]Delivery)
+	}
+	if s.contents.Deliveries == nil {
+		s.contents.Deliveries = make(map[string][]Delivery)
+	}
+	if s.contents.DeadLetters == nil {
+		s.contents.DeadLetters = make(map[string][]Delivery)
+	}
+	return s, nil
+}
+
+// List returns the subscriptions of the account, sorted by creation time.
+func (s *Store) List(account string) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscriptions := make([]Subscription, 0)
+	for _, subscription := range s.contents.Subscriptions {
+		if subscription.Account == account {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	sortSubscriptions(subscriptions)
+	return subscriptions
+}
+
+// Get returns the subscription of the account.
+func (s *Store) Get(account, id string) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, exists := s.contents.Subscriptions[id]
+	if !exists || subscription.Account != account {
+		return Subscription{}, ErrSubscriptionNotFound
+	}
+	return subscription, nil
+}
+
+// Create stores a new subscription, its ID and timestamps are set by the store. A random secret is generated if it
+// has none.
+func (s *Store) Create(subscription Subscription) (Subscription, error) {
+	if err := subscription.Validate(); err != nil {
+		return Subscription{}, err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if subscription.Secret == "" {
+		if subscription.Secret, err = randomHex(32); err != nil {
+			return Subscription{}, err
+		}
+	}
+	now := s.now().UTC()
+	subscription.ID = id
+	subscription.CreatedAt = now
+	subscription.UpdatedAt = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contents.Subscriptions[subscription.ID] = subscription
+	if err = s.save(); err != nil {
+		delete(s.contents.Subscriptions, subscription.ID)
+		return Subscription{}, err
+	}
+	return subscription, nil
+}
+
+// Update replaces the subscription of the account, the ID, account and creation time are kept, and the secret if the
+// update has none. Pending deliveries are sent to the new URL.
+func (s *Store) Update(account, id string, subscription Subscription) (Subscription, error) {
+	if err := subscription.Validate(); err != nil {
+		return Subscription{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.contents.Subscriptions[id]
+	if !exists || current.Account != account {
+		return Subscription{}, ErrSubscriptionNotFound
+	}
+	subscription.ID = current.ID
+	subscription.Account = current.Account
+	subscription.CreatedAt = current.CreatedAt
+	subscription.UpdatedAt = s.now().UTC()
+	if subscription.Secret == "" {
+		subscription.Secret = current.Secret
+	}
+
+	s.contents.Subscriptions[id] = subscription
+	if err := s.save(); err != nil {
+		s.contents.Subscriptions[id] = current
+		return Subscription{}, err
+	}
+	return subscription, nil
+}
+
+// Delete deletes the subscription of the account and its deliveries.
+func (s *Store) Delete(account, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, exists := s.contents.Subscriptions[id]
+	if !exists || subscription.Account != account {
+		return ErrSubscriptionNotFound
+	}
+	backup := s.contents
+	s.contents = contents{
+		Subscriptions: make(map[string]Subscription, len(backup.Subscriptions)),
+		Pending:       make(map[string]Delivery, len(backup.Pending)),
+		Deliveries:    make(map[string][]Delivery, len(backup.Deliveries)),
+		DeadLetters:   make(map[string][]Delivery, len(backup.DeadLetters)),
+	}
+	for key, value := range backup.Subscriptions {
+		if key != id {
+			s.contents.Subscriptions[key] = value
+		}
+	}
+	for key, value := range backup.Pending {
+		if value.SubscriptionID != id {
+			s.contents.Pending[key] = value
+		}
+	}
+	for key, value := range backup.Deliveries {
+		if key != id {
+			s.contents.Deliveries[key] = value
+		}
+	}
+	for key, value := range backup.DeadLetters {
+		if key != id {
+			s.contents.DeadLetters[key] = value
+		}
+	}
+	if err := s.save(); err != nil {
+		s.contents = backup
+		return err
+	}
+	return nil
+}
+
+// Deliveries returns the pending and the latest completed deliveries of the subscription of the account, newest first.
+func (s *Store) Deliveries(account, id string) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, exists := s.contents.Subscriptions[id]
+	if !exists || subscription.Account != account {
+		return nil, ErrSubscriptionNotFound
+	}
+	deliveries := append([]Delivery{}, s.contents.Deliveries[id]...)
+	for _, delivery := range s.contents.Pending {
+		if delivery.SubscriptionID == id {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+// DeadLetters returns the dead letters of the subscription of the account, newest first.
+func (s *Store) DeadLetters(account, id string) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, exists := s.contents.Subscriptions[id]
+	if !exists || subscription.Account != account {
+		return nil, ErrSubscriptionNotFound
+	}
+	deliveries := append([]Delivery{}, s.contents.DeadLetters[id]...)
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+// Retry removes the dead letter from the dead letters of the subscription of the account and returns a new pending
+// delivery of its event.
+func (s *Store) Retry(account, id, deliveryID string) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, exists := s.contents.Subscriptions[id]
+	if !exists || subscription.Account != account {
+		return Delivery{}, ErrSubscriptionNotFound
+	}
+	deadLetters := s.contents.DeadLetters[id]
+	i := slices.IndexFunc(deadLetters, func(d Delivery) bool { return d.ID == deliveryID })
+	if i < 0 {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	newID, err := randomHex(8)
+	if err != nil {
+		return Delivery{}, err
+	}
+	now := s.now().UTC()
+	delivery := Delivery{
+		ID:             newID,
+		SubscriptionID: id,
+		EventID:        deadLetters[i].EventID,
+		EventType:      deadLetters[i].EventType,
+		Payload:        deadLetters[i].Payload,
+		Status:         DeliveryPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		NextAttemptAt:  &now,
+	}
+
+	s.contents.DeadLetters[id] = slices.Delete(slices.Clone(deadLetters), i, i+1)
+	s.contents.Pending[delivery.ID] = delivery
+	if err = s.save(); err != nil {
+		s.contents.DeadLetters[id] = deadLetters
+		delete(s.contents.Pending, delivery.ID)
+		return Delivery{}, err
+	}
+	return delivery, nil
+}
+
+// accounts returns the accounts with enabled subscriptions, sorted by name.
+func (s *Store) accounts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var accounts []string
+	for _, subscription := range s.contents.Subscriptions {
+		if subscription.Enabled && !slices.Contains(accounts, subscription.Account) {
+			accounts = append(accounts, subscription.Account)
+		}
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// enabled returns the enabled subscriptions of the account, sorted by creation time.
+func (s *Store) enabled(account string) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subscriptions []Subscription
+	for _, subscription := range s.contents.Subscriptions {
+		if subscription.Enabled && subscription.Account == account {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	sortSubscriptions(subscriptions)
+	return subscriptions
+}
+
+// subscription returns the subscription with the ID of any account.
+func (s *Store) subscription(id string) (Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, exists := s.contents.Subscriptions[id]
+	return subscription, exists
+}
+
+// enqueue adds the deliveries of the event to the enabled subscriptions of its account that it matches.
+func (s *Store) enqueue(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	now := s.now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var added []string
+	for _, subscription := range s.contents.Subscriptions {
+		if !subscription.Enabled || subscription.Account != event.Account || !subscription.Matches(event) {
+			continue
+		}
+		id, err := randomHex(8)
+		if err != nil {
+			return err
+		}
+		s.contents.Pending[id] = Delivery{
+			ID:             id,
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        payload,
+			Status:         DeliveryPending,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			NextAttemptAt:  &now,
+		}
+		added = append(added, id)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	if err = s.save(); err != nil {
+		for _, id := range added {
+			delete(s.contents.Pending, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// due returns the pending deliveries whose next attempt is due, oldest first.
+func (s *Store) due(now time.Time) []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deliveries []Delivery
+	for _, delivery := range s.contents.Pending {
+		if !delivery.NextAttemptAt.After(now) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	return deliveries
+}
+
+// update stores the delivery after an attempt. Pending deliveries stay pending, delivered ones are moved to the
+// completed deliveries and dead ones to the dead letters as well. The oldest deliveries are dropped beyond the history
+// size.
+func (s *Store) update(delivery Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.contents.Pending[delivery.ID]; !exists {
+		// the subscription was deleted in the meantime
+		return nil
+	}
+	if delivery.Status == DeliveryPending {
+		s.contents.Pending[delivery.ID] = delivery
+		return s.save()
+	}
+	delete(s.contents.Pending, delivery.ID)
+	s.contents.Deliveries[delivery.SubscriptionID] = s.appendCapped(s.contents.Deliveries[delivery.SubscriptionID], delivery)
+	if delivery.Status == DeliveryDead {
+		s.contents.DeadLetters[delivery.SubscriptionID] = s.appendCapped(s.contents.DeadLetters[delivery.SubscriptionID], delivery)
+	}
+	return s.save()
+}
+
+func (s *Store) appendCapped(deliveries []Delivery, delivery Delivery) []Delivery {
+	deliveries = append(deliveries, delivery)
+	if len(deliveries) > s.historySize {
+		deliveries = append([]Delivery(nil), deliveries[len(deliveries)-s.historySize:]...)
+	}
+	return deliveries
+}
+
+// save writes the contents to the store file. The caller must hold the lock.
+func (s *Store) save() error {
+	if err := jsonfile.Write(s.path, s.contents); err != nil {
+		return fmt.Errorf("can't write webhooks file: %w", err)
+	}
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func sortSubscriptions(subscriptions []Subscription) {
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if !subscriptions[i].CreatedAt.Equal(subscriptions[j].CreatedAt) {
+			return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+		}
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+}
+
+// sortDeliveries sorts the deliveries newest first.
+func sortDeliveries(deliveries []Delivery) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+}